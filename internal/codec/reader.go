@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"context"
 	"encoding/csv"
@@ -16,6 +17,10 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
 	"github.com/benthosdev/benthos/v4/internal/docs"
 	"github.com/benthosdev/benthos/v4/internal/message"
 )
@@ -30,11 +35,16 @@ var ReaderDocs = docs.FieldString(
 	"csv", "Consume structured rows as comma separated values, the first row must be a header row.",
 	"csv:x", "Consume structured rows as values separated by a custom delimiter, the first row must be a header row. The custom delimiter must be a single character, e.g. the codec `\"csv:\\t\"` would consume a tab delimited file.",
 	"delim:x", "Consume the file in segments divided by a custom delimiter.",
+	"brotli", "Decompress a brotli file, this codec should precede another codec, e.g. `brotli/lines`, `brotli/csv`, etc.",
+	"bzip2", "Decompress a bzip2 file, this codec should precede another codec, e.g. `bzip2/lines`, `bzip2/csv`, etc.",
 	"gzip", "Decompress a gzip file, this codec should precede another codec, e.g. `gzip/all-bytes`, `gzip/tar`, `gzip/csv`, etc.",
 	"lines", "Consume the file in segments divided by linebreaks.",
 	"multipart", "Consumes the output of another codec and batches messages together. A batch ends when an empty message is consumed. For example, the codec `lines/multipart` could be used to consume multipart messages where an empty line indicates the end of each batch.",
 	"regex:(?m)^\\d\\d:\\d\\d:\\d\\d", "Consume the file in segments divided by regular expression.",
+	"sse", "Consume a stream of [server-sent events](https://html.spec.whatwg.org/multipage/server-sent-events.html), where the data of each event becomes a message and the event type and last event ID are added as the metadata fields `sse_event` and `sse_id`.",
 	"tar", "Parse the file as a tar archive, and consume each file of the archive as a message.",
+	"xz", "Decompress an xz file, this codec should precede another codec, e.g. `xz/lines`, `xz/csv`, etc.",
+	"zstd", "Decompress a zstd file, this codec should precede another codec, e.g. `zstd/lines`, `zstd/csv`, etc.",
 ).LinterFunc(nil) // Disable default option linter as it doesn't include foo:bar formats.
 
 //------------------------------------------------------------------------------
@@ -179,8 +189,24 @@ func chainedReader(codec string, conf ReaderConfig) (ReaderConstructor, error) {
 	return partCtor, nil
 }
 
+// decompressReadCloser reads from a decompressor and closes both the
+// decompressor and the underlying reader.
+type decompressReadCloser struct {
+	io.Reader
+	closeFn func()
+	rc      io.ReadCloser
+}
+
+func (d *decompressReadCloser) Close() error {
+	if d.closeFn != nil {
+		d.closeFn()
+	}
+	return d.rc.Close()
+}
+
 func ioReader(codec string, conf ReaderConfig) (ioReaderConstructor, bool) {
-	if codec == "gzip" {
+	switch codec {
+	case "gzip":
 		return func(_ string, r io.ReadCloser) (io.ReadCloser, error) {
 			g, err := gzip.NewReader(r)
 			if err != nil {
@@ -189,6 +215,32 @@ func ioReader(codec string, conf ReaderConfig) (ioReaderConstructor, bool) {
 			}
 			return g, nil
 		}, true
+	case "zstd":
+		return func(_ string, r io.ReadCloser) (io.ReadCloser, error) {
+			z, err := zstd.NewReader(r)
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			return &decompressReadCloser{Reader: z, closeFn: z.Close, rc: r}, nil
+		}, true
+	case "bzip2":
+		return func(_ string, r io.ReadCloser) (io.ReadCloser, error) {
+			return &decompressReadCloser{Reader: bzip2.NewReader(r), rc: r}, nil
+		}, true
+	case "brotli":
+		return func(_ string, r io.ReadCloser) (io.ReadCloser, error) {
+			return &decompressReadCloser{Reader: brotli.NewReader(r), rc: r}, nil
+		}, true
+	case "xz":
+		return func(_ string, r io.ReadCloser) (io.ReadCloser, error) {
+			x, err := xz.NewReader(r)
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			return &decompressReadCloser{Reader: x, rc: r}, nil
+		}, true
 	}
 	return nil, false
 }
@@ -218,6 +270,10 @@ func partReader(codec string, conf ReaderConfig) (ReaderConstructor, bool, error
 		}, true, nil
 	case "tar":
 		return newTarReader, true, nil
+	case "sse":
+		return func(path string, r io.ReadCloser, fn ReaderAckFn) (Reader, error) {
+			return newSSEReader(conf, r, fn)
+		}, true, nil
 	}
 	if strings.HasPrefix(codec, "delim:") {
 		by := strings.TrimPrefix(codec, "delim:")
@@ -758,6 +814,123 @@ func (a *tarReader) Close(ctx context.Context) error {
 
 //------------------------------------------------------------------------------
 
+type sseReader struct {
+	buf       *bufio.Scanner
+	r         io.ReadCloser
+	sourceAck ReaderAckFn
+	lastID    string
+
+	mut      sync.Mutex
+	finished bool
+	pending  int32
+}
+
+func newSSEReader(conf ReaderConfig, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+	scanner := bufio.NewScanner(r)
+	if conf.MaxScanTokenSize != bufio.MaxScanTokenSize {
+		scanner.Buffer([]byte{}, conf.MaxScanTokenSize)
+	}
+	return &sseReader{
+		buf:       scanner,
+		r:         r,
+		sourceAck: ackOnce(ackFn),
+	}, nil
+}
+
+func (a *sseReader) ack(ctx context.Context, err error) error {
+	a.mut.Lock()
+	a.pending--
+	doAck := a.pending == 0 && a.finished
+	a.mut.Unlock()
+
+	if err != nil {
+		return a.sourceAck(ctx, err)
+	}
+	if doAck {
+		return a.sourceAck(ctx, nil)
+	}
+	return nil
+}
+
+// nextEvent scans lines until an event with data is dispatched, following the
+// interpretation rules of https://html.spec.whatwg.org/multipage/server-sent-events.html
+func (a *sseReader) nextEvent() *message.Part {
+	var event string
+	var data []string
+	for a.buf.Scan() {
+		line := a.buf.Text()
+		if line == "" {
+			if data == nil {
+				event = ""
+				continue
+			}
+			p := message.NewPart([]byte(strings.Join(data, "\n")))
+			if event == "" {
+				event = "message"
+			}
+			p.MetaSet("sse_event", event)
+			if a.lastID != "" {
+				p.MetaSet("sse_id", a.lastID)
+			}
+			return p
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				a.lastID = value
+			}
+		}
+	}
+	return nil
+}
+
+func (a *sseReader) Next(ctx context.Context) ([]*message.Part, ReaderAckFn, error) {
+	p := a.nextEvent()
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if p != nil {
+		a.pending++
+		return []*message.Part{p}, a.ack, nil
+	}
+
+	err := a.buf.Err()
+	if err == nil {
+		err = io.EOF
+		a.finished = true
+	} else {
+		_ = a.sourceAck(ctx, err)
+	}
+	return nil, nil, err
+}
+
+func (a *sseReader) Close(ctx context.Context) error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if !a.finished {
+		_ = a.sourceAck(ctx, errors.New("service shutting down"))
+	}
+	if a.pending == 0 {
+		_ = a.sourceAck(ctx, nil)
+	}
+	return a.r.Close()
+}
+
+//------------------------------------------------------------------------------
+
 type multipartReader struct {
 	child Reader
 }
@@ -5,14 +5,18 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
 	"testing"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
 
 	"github.com/benthosdev/benthos/v4/internal/message"
 )
@@ -386,6 +390,44 @@ func TestCSVGzipReader(t *testing.T) {
 	)
 }
 
+func TestCompressedLinesReaders(t *testing.T) {
+	data := []byte("foo\nbar\nbaz")
+
+	var zstdBuf bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdBuf)
+	require.NoError(t, err)
+	_, _ = zw.Write(data)
+	require.NoError(t, zw.Close())
+
+	var brotliBuf bytes.Buffer
+	bw := brotli.NewWriter(&brotliBuf)
+	_, _ = bw.Write(data)
+	require.NoError(t, bw.Close())
+
+	var xzBuf bytes.Buffer
+	xw, err := xz.NewWriter(&xzBuf)
+	require.NoError(t, err)
+	_, _ = xw.Write(data)
+	require.NoError(t, xw.Close())
+
+	testReaderSuite(t, "zstd/lines", "", zstdBuf.Bytes(), "foo", "bar", "baz")
+	testReaderSuite(t, "brotli/lines", "", brotliBuf.Bytes(), "foo", "bar", "baz")
+	testReaderSuite(t, "xz/lines", "", xzBuf.Bytes(), "foo", "bar", "baz")
+}
+
+func TestCSVBzip2Reader(t *testing.T) {
+	// bzip2 compressed "col1,col2,col3\nfoo1,bar1,baz1\nfoo2,bar2,baz2\nfoo3,bar3,baz3"
+	data, err := base64.StdEncoding.DecodeString("QlpoOTFBWSZTWVCQ0mcAABHZgAAQAAQ4ADkEkBAgADEA0ADJlANqYeKpJJKXI22En4HoPKHToGzDAfi7kinChIKEhpM4")
+	require.NoError(t, err)
+
+	testReaderSuite(
+		t, "bzip2/csv", "", data,
+		`{"col1":"foo1","col2":"bar1","col3":"baz1"}`,
+		`{"col1":"foo2","col2":"bar2","col3":"baz2"}`,
+		`{"col1":"foo3","col2":"bar3","col3":"baz3"}`,
+	)
+}
+
 func TestCSVGzipReaderOld(t *testing.T) {
 	var gzipBuf bytes.Buffer
 	zw := gzip.NewWriter(&gzipBuf)
@@ -770,3 +812,49 @@ func TestRegexpSplitReader(t *testing.T) {
 	data = []byte("")
 	testReaderSuite(t, "regex:split", "", data)
 }
+
+func TestSSEReader(t *testing.T) {
+	data := []byte(": comment\n\ndata: foo\n\nevent: update\nid: 1\ndata: bar\ndata:baz\n\nretry: 10\n\ndata\n\n")
+	testReaderSuite(t, "sse", "", data, "foo", "bar\nbaz", "")
+
+	data = []byte("data: incomplete")
+	testReaderSuite(t, "sse", "", data)
+}
+
+func TestSSEReaderMetadata(t *testing.T) {
+	data := []byte("data: foo\r\n\r\nevent: update\r\nid: 1\r\ndata: bar\r\n\r\nid: 2\r\n\r\ndata: baz\r\n\r\n")
+
+	ctor, err := GetReader("sse", NewReaderConfig())
+	require.NoError(t, err)
+
+	r, err := ctor("", noopCloser{bytes.NewReader(data), false}, func(ctx context.Context, err error) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	type event struct {
+		data, event, id string
+	}
+	var events []event
+	for {
+		p, ackFn, err := r.Next(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, ackFn(context.Background(), nil))
+		require.Len(t, p, 1)
+		events = append(events, event{
+			data:  string(p[0].Get()),
+			event: p[0].MetaGet("sse_event"),
+			id:    p[0].MetaGet("sse_id"),
+		})
+	}
+	require.NoError(t, r.Close(context.Background()))
+
+	assert.Equal(t, []event{
+		{data: "foo", event: "message"},
+		{data: "bar", event: "update", id: "1"},
+		{data: "baz", event: "message", id: "2"},
+	}, events)
+}
@@ -0,0 +1,280 @@
+package codec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/internal/docs"
+	"github.com/benthosdev/benthos/v4/internal/message"
+)
+
+// StreamingDocs is a static field documentation for streaming messages into
+// objects of object storage outputs.
+var StreamingDocs = docs.FieldObject(
+	"streaming", "Write messages into long lived objects using a codec instead of uploading an object per message. Messages are written to an open object for each unique value of `path`, where the final object key is the path with a unix nanosecond timestamp inserted before the extension, and messages are only acknowledged once the object containing them is committed.",
+).WithChildren(
+	docs.FieldBool("enabled", "Whether to stream messages into objects."),
+	docs.FieldString("codec", "The way in which the bytes of messages should be written into objects. The `all-bytes` codec is not supported.", "lines", "delim:\t").LinterFunc(nil),
+	docs.FieldInt("max_bytes", "The number of bytes written to an object after which it is committed, set to `0` to disable."),
+	docs.FieldString("max_age", "The maximum period of time an object is written to after which it is committed, set to an empty string to disable. Since messages are not acknowledged until their object is committed this should be kept reasonably short.", "1m", "1h"),
+)
+
+// StreamingConfig contains configuration fields for streaming messages into
+// objects.
+type StreamingConfig struct {
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	Codec    string `json:"codec" yaml:"codec"`
+	MaxBytes int64  `json:"max_bytes" yaml:"max_bytes"`
+	MaxAge   string `json:"max_age" yaml:"max_age"`
+}
+
+// NewStreamingConfig creates a new StreamingConfig with default values.
+func NewStreamingConfig() StreamingConfig {
+	return StreamingConfig{
+		Enabled:  false,
+		Codec:    "lines",
+		MaxBytes: 0,
+		MaxAge:   "1m",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ObjectOpenFn opens a new object with a given key, the object must be
+// committed once the returned writer is closed. If the writer also implements
+// CloseWithError then it is called instead of Close when the object should be
+// abandoned.
+type ObjectOpenFn func(key string) (io.WriteCloser, error)
+
+// ObjectStreamer writes messages into objects through a codec, keeping an open
+// object for each path and committing it once it reaches a size or age.
+type ObjectStreamer struct {
+	codec    WriterConstructor
+	maxBytes int64
+	maxAge   time.Duration
+
+	mut     sync.Mutex
+	objects map[string]*streamedObject
+}
+
+// NewObjectStreamer creates a new ObjectStreamer from a config.
+func NewObjectStreamer(conf StreamingConfig) (*ObjectStreamer, error) {
+	ctor, codecConf, err := GetWriter(conf.Codec)
+	if err != nil {
+		return nil, err
+	}
+	if codecConf.CloseAfter {
+		return nil, fmt.Errorf("codec %v is not supported when streaming", conf.Codec)
+	}
+
+	s := &ObjectStreamer{
+		codec:    ctor,
+		maxBytes: conf.MaxBytes,
+		objects:  map[string]*streamedObject{},
+	}
+	if conf.MaxAge != "" {
+		if s.maxAge, err = time.ParseDuration(conf.MaxAge); err != nil {
+			return nil, fmt.Errorf("failed to parse max age: %w", err)
+		}
+	}
+	if s.maxAge <= 0 && s.maxBytes <= 0 {
+		return nil, errors.New("at least one of max_bytes or max_age must be set when streaming")
+	}
+	return s, nil
+}
+
+// StreamedObjectKey returns the key of an object streamed to a path, which is
+// the path with a timestamp inserted before the extension.
+func StreamedObjectKey(p string, t time.Time) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + "-" + strconv.FormatInt(t.UnixNano(), 10) + ext
+}
+
+// Write a message part into the open object of a path, opening a new object
+// with the provided func if necessary. The returned func blocks until the
+// object containing the part is committed, returning an error if the commit
+// failed.
+func (s *ObjectStreamer) Write(ctx context.Context, p string, part *message.Part, open ObjectOpenFn) (func(context.Context) error, error) {
+	for {
+		obj, err := s.getObject(p, open)
+		if err != nil {
+			return nil, err
+		}
+
+		// Writes are serialised per object so that a slow object does not
+		// block writes to other paths.
+		obj.mut.Lock()
+		if obj.closed {
+			// The object was committed between obtaining it and writing to
+			// it, therefore try again with a fresh object.
+			obj.mut.Unlock()
+			continue
+		}
+
+		if err := obj.writer.Write(ctx, part); err != nil {
+			obj.closed = true
+			obj.mut.Unlock()
+			s.removeObject(p, obj)
+			obj.abort(err)
+			return nil, err
+		}
+
+		if s.maxBytes > 0 && obj.counter.n >= s.maxBytes {
+			obj.closed = true
+			s.removeObject(p, obj)
+			go obj.commit(context.Background())
+		}
+		obj.mut.Unlock()
+		return obj.wait, nil
+	}
+}
+
+func (s *ObjectStreamer) getObject(p string, open ObjectOpenFn) (*streamedObject, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	obj, exists := s.objects[p]
+	if !exists {
+		var err error
+		if obj, err = s.openObject(p, open); err != nil {
+			return nil, err
+		}
+		s.objects[p] = obj
+	}
+	return obj, nil
+}
+
+func (s *ObjectStreamer) removeObject(p string, obj *streamedObject) {
+	s.mut.Lock()
+	if s.objects[p] == obj {
+		delete(s.objects, p)
+	}
+	s.mut.Unlock()
+}
+
+func (s *ObjectStreamer) openObject(p string, open ObjectOpenFn) (*streamedObject, error) {
+	raw, err := open(StreamedObjectKey(p, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &streamedObject{
+		raw:     raw,
+		counter: &countingWriteCloser{w: raw},
+		done:    make(chan struct{}),
+	}
+	if obj.writer, err = s.codec(obj.counter); err != nil {
+		obj.abort(err)
+		return nil, err
+	}
+
+	if s.maxAge > 0 {
+		obj.timer = time.AfterFunc(s.maxAge, func() {
+			s.removeObject(p, obj)
+			if obj.markClosed() {
+				obj.commit(context.Background())
+			}
+		})
+	}
+	return obj, nil
+}
+
+// Close commits all open objects.
+func (s *ObjectStreamer) Close(ctx context.Context) error {
+	s.mut.Lock()
+	objects := s.objects
+	s.objects = map[string]*streamedObject{}
+	s.mut.Unlock()
+
+	var err error
+	for _, obj := range objects {
+		if obj.markClosed() {
+			obj.commit(ctx)
+		}
+		if werr := obj.wait(ctx); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+type streamedObject struct {
+	raw     io.WriteCloser
+	counter *countingWriteCloser
+	writer  Writer
+	timer   *time.Timer
+
+	// Guards writes to the object and whether it has been closed, after which
+	// it must no longer be written to.
+	mut    sync.Mutex
+	closed bool
+
+	done chan struct{}
+	err  error
+}
+
+// markClosed prevents any further writes to the object, returning false if it
+// was already closed.
+func (o *streamedObject) markClosed() bool {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	if o.closed {
+		return false
+	}
+	o.closed = true
+	return true
+}
+
+func (o *streamedObject) commit(ctx context.Context) {
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+	o.err = o.writer.Close(ctx)
+	close(o.done)
+}
+
+func (o *streamedObject) abort(err error) {
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+	if ce, ok := o.raw.(interface{ CloseWithError(error) error }); ok {
+		_ = ce.CloseWithError(err)
+	} else {
+		_ = o.raw.Close()
+	}
+	o.err = err
+	close(o.done)
+}
+
+func (o *streamedObject) wait(ctx context.Context) error {
+	select {
+	case <-o.done:
+		return o.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type countingWriteCloser struct {
+	w io.WriteCloser
+	n int64
+}
+
+func (c *countingWriteCloser) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingWriteCloser) Close() error {
+	return c.w.Close()
+}
@@ -0,0 +1,200 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/message"
+)
+
+type testStreamedObject struct {
+	bytes.Buffer
+	closed  bool
+	aborted error
+}
+
+func (t *testStreamedObject) Close() error {
+	t.closed = true
+	return nil
+}
+
+func (t *testStreamedObject) CloseWithError(err error) error {
+	t.aborted = err
+	return nil
+}
+
+type testObjectStore struct {
+	mut     sync.Mutex
+	objects map[string]*testStreamedObject
+	keys    []string
+}
+
+func (t *testObjectStore) open(key string) (io.WriteCloser, error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.objects == nil {
+		t.objects = map[string]*testStreamedObject{}
+	}
+	o := &testStreamedObject{}
+	t.objects[key] = o
+	t.keys = append(t.keys, key)
+	return o, nil
+}
+
+func TestStreamedObjectKey(t *testing.T) {
+	ts := time.Unix(1, 5)
+	assert.Equal(t, "foo/bar-1000000005.jsonl", StreamedObjectKey("foo/bar.jsonl", ts))
+	assert.Equal(t, "dt=2022.01/part-1000000005", StreamedObjectKey("dt=2022.01/part", ts))
+}
+
+func TestObjectStreamerBadConfig(t *testing.T) {
+	conf := NewStreamingConfig()
+	conf.Codec = "all-bytes"
+	_, err := NewObjectStreamer(conf)
+	require.Error(t, err)
+
+	conf = NewStreamingConfig()
+	conf.MaxAge = ""
+	_, err = NewObjectStreamer(conf)
+	require.Error(t, err)
+}
+
+func TestObjectStreamerMaxBytes(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	conf := NewStreamingConfig()
+	conf.MaxAge = ""
+	conf.MaxBytes = 8
+
+	s, err := NewObjectStreamer(conf)
+	require.NoError(t, err)
+
+	store := &testObjectStore{}
+
+	waitFoo, err := s.Write(ctx, "a/b.txt", message.NewPart([]byte("foo")), store.open)
+	require.NoError(t, err)
+
+	waitBar, err := s.Write(ctx, "a/b.txt", message.NewPart([]byte("bar")), store.open)
+	require.NoError(t, err)
+
+	waitBaz, err := s.Write(ctx, "a/c.txt", message.NewPart([]byte("baz")), store.open)
+	require.NoError(t, err)
+
+	require.NoError(t, waitFoo(ctx))
+	require.NoError(t, waitBar(ctx))
+
+	shortCtx, shortDone := context.WithTimeout(ctx, time.Millisecond*50)
+	defer shortDone()
+	require.Error(t, waitBaz(shortCtx))
+
+	require.NoError(t, s.Close(ctx))
+	require.NoError(t, waitBaz(ctx))
+
+	require.Len(t, store.keys, 2)
+	assert.Regexp(t, `^a/b-\d+\.txt$`, store.keys[0])
+	assert.Regexp(t, `^a/c-\d+\.txt$`, store.keys[1])
+
+	assert.Equal(t, "foo\nbar\n", store.objects[store.keys[0]].String())
+	assert.True(t, store.objects[store.keys[0]].closed)
+	assert.Equal(t, "baz\n", store.objects[store.keys[1]].String())
+	assert.True(t, store.objects[store.keys[1]].closed)
+}
+
+func TestObjectStreamerMaxAge(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	conf := NewStreamingConfig()
+	conf.MaxAge = "50ms"
+
+	s, err := NewObjectStreamer(conf)
+	require.NoError(t, err)
+
+	store := &testObjectStore{}
+
+	wait, err := s.Write(ctx, "foo", message.NewPart([]byte("foo")), store.open)
+	require.NoError(t, err)
+	require.NoError(t, wait(ctx))
+
+	wait, err = s.Write(ctx, "foo", message.NewPart([]byte("bar")), store.open)
+	require.NoError(t, err)
+	require.NoError(t, wait(ctx))
+
+	require.Len(t, store.keys, 2)
+	assert.Equal(t, "foo\n", store.objects[store.keys[0]].String())
+	assert.Equal(t, "bar\n", store.objects[store.keys[1]].String())
+}
+
+func TestObjectStreamerOpenError(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	s, err := NewObjectStreamer(NewStreamingConfig())
+	require.NoError(t, err)
+
+	errOpen := errors.New("nope")
+	_, err = s.Write(ctx, "foo", message.NewPart([]byte("foo")), func(string) (io.WriteCloser, error) {
+		return nil, errOpen
+	})
+	require.Equal(t, errOpen, err)
+	require.NoError(t, s.Close(ctx))
+}
+
+type blockingStreamedObject struct {
+	testStreamedObject
+	unblock chan struct{}
+}
+
+func (b *blockingStreamedObject) Write(p []byte) (int, error) {
+	<-b.unblock
+	return b.testStreamedObject.Write(p)
+}
+
+func TestObjectStreamerSlowObject(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	conf := NewStreamingConfig()
+	conf.Codec = "lines"
+
+	s, err := NewObjectStreamer(conf)
+	require.NoError(t, err)
+
+	slow := &blockingStreamedObject{unblock: make(chan struct{})}
+	store := &testObjectStore{}
+
+	slowWritten := make(chan error)
+	go func() {
+		_, err := s.Write(ctx, "slow.txt", message.NewPart([]byte("foo")), func(string) (io.WriteCloser, error) {
+			return slow, nil
+		})
+		slowWritten <- err
+	}()
+
+	// Writes to other paths must not wait for the slow object.
+	_, err = s.Write(ctx, "fast.txt", message.NewPart([]byte("bar")), store.open)
+	require.NoError(t, err)
+
+	select {
+	case err := <-slowWritten:
+		t.Fatalf("slow write should still be blocked: %v", err)
+	default:
+	}
+
+	close(slow.unblock)
+	require.NoError(t, <-slowWritten)
+
+	require.NoError(t, s.Close(ctx))
+	assert.Equal(t, "foo\n", slow.String())
+	assert.True(t, slow.closed)
+	assert.Equal(t, "bar\n", store.objects[store.keys[0]].String())
+}
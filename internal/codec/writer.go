@@ -2,24 +2,39 @@ package codec
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strings"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/parquet"
+	pwriter "github.com/xitongsys/parquet-go/writer"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
 	"github.com/benthosdev/benthos/v4/internal/docs"
 	"github.com/benthosdev/benthos/v4/internal/message"
 )
 
 // WriterDocs is a static field documentation for output codecs.
 var WriterDocs = docs.FieldString(
-	"codec", "The way in which the bytes of messages should be written out into the output data stream. It's possible to write lines using a custom delimiter with the `delim:x` codec, where x is the character sequence custom delimiter.", "lines", "delim:\t", "delim:foobar",
+	"codec", "The way in which the bytes of messages should be written out into the output data stream. It's possible to write lines using a custom delimiter with the `delim:x` codec, where x is the character sequence custom delimiter. Codecs can be chained with `/`, for example gzip compressed lines can be written with the codec `gzip/lines`.", "lines", "delim:\t", "delim:foobar", "gzip/lines",
 ).HasAnnotatedOptions(
 	"all-bytes", "Only applicable to file based outputs. Writes each message to a file in full, if the file already exists the old content is deleted.",
 	"append", "Append each message to the output stream without any delimiter or special encoding.",
-	"lines", "Append each message to the output stream followed by a line break.",
+	"csv", "Write structured messages as rows of comma separated values. A header row is written at the start of each file, where the columns are the sorted keys of the first message written. Only applicable to file based outputs, if the file already exists the old content is deleted.",
+	"csv:x", "Write structured messages as rows of values separated by a custom single character delimiter, with a header row derived from the first message written. Only applicable to file based outputs, if the file already exists the old content is deleted.",
 	"delim:x", "Append each message to the output stream followed by a custom delimiter.",
+	"gzip", "Compress the output stream with gzip, this codec should precede another codec, e.g. `gzip/lines`, `gzip/csv`, etc.",
+	"lines", "Append each message to the output stream followed by a line break.",
+	"parquet:x", "Write structured messages as rows of a parquet file, where x is the path of a file containing the schema. The format of the schema is a JSON document detailing the tag and fields of documents, which can be found at: https://pkg.go.dev/github.com/xitongsys/parquet-go#readme-json. A parquet file is only complete once it is closed, and therefore existing files are always overwritten.",
+	"zstd", "Compress the output stream with zstd, this codec should precede another codec, e.g. `zstd/lines`, `zstd/csv`, etc.",
 ).LinterFunc(nil) // Disable default option linter as it doesn't include foo:bar formats.
 
 //------------------------------------------------------------------------------
@@ -45,30 +60,135 @@ type WriterConfig struct {
 // WriterConstructor creates a writer from an io.WriteCloser.
 type WriterConstructor func(io.WriteCloser) (Writer, error)
 
+type ioWriterConstructor func(io.WriteCloser) (io.WriteCloser, error)
+
+func chainIOIntoWriterCtor(first ioWriterConstructor, second WriterConstructor) WriterConstructor {
+	return func(w io.WriteCloser) (Writer, error) {
+		w1, err := first(w)
+		if err != nil {
+			return nil, err
+		}
+		w2, err := second(w1)
+		if err != nil {
+			w1.Close()
+			return nil, err
+		}
+		return w2, nil
+	}
+}
+
 // GetWriter returns a constructor that creates write codecs.
 func GetWriter(codec string) (WriterConstructor, WriterConfig, error) {
+	if ctor, conf, ok, err := partWriter(codec); ok || err != nil {
+		return ctor, conf, err
+	}
+
+	codecs := strings.Split(codec, "/")
+	ctor, conf, ok, err := partWriter(codecs[len(codecs)-1])
+	if err != nil {
+		return nil, WriterConfig{}, err
+	}
+	if !ok {
+		return nil, WriterConfig{}, fmt.Errorf("codec was not recognised: %v", codec)
+	}
+	for i := len(codecs) - 2; i >= 0; i-- {
+		ioCtor, ok := ioWriter(codecs[i])
+		if !ok {
+			return nil, WriterConfig{}, fmt.Errorf("unable to follow codec '%v' with '%v'", codecs[i], codecs[i+1])
+		}
+		ctor = chainIOIntoWriterCtor(ioCtor, ctor)
+	}
+	return ctor, conf, nil
+}
+
+type compressedWriteCloser struct {
+	io.Writer
+	c io.Closer
+	w io.Closer
+}
+
+func (c *compressedWriteCloser) Close() error {
+	err := c.c.Close()
+	if cerr := c.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func ioWriter(codec string) (ioWriterConstructor, bool) {
+	switch codec {
+	case "gzip":
+		return func(w io.WriteCloser) (io.WriteCloser, error) {
+			g := gzip.NewWriter(w)
+			return &compressedWriteCloser{Writer: g, c: g, w: w}, nil
+		}, true
+	case "zstd":
+		return func(w io.WriteCloser) (io.WriteCloser, error) {
+			z, err := zstd.NewWriter(w)
+			if err != nil {
+				return nil, err
+			}
+			return &compressedWriteCloser{Writer: z, c: z, w: w}, nil
+		}, true
+	}
+	return nil, false
+}
+
+func partWriter(codec string) (WriterConstructor, WriterConfig, bool, error) {
 	switch codec {
 	case "all-bytes":
 		return func(w io.WriteCloser) (Writer, error) {
 			return &allBytesWriter{w}, nil
-		}, allBytesConfig, nil
+		}, allBytesConfig, true, nil
 	case "append":
 		return func(w io.WriteCloser) (Writer, error) {
 			return newCustomDelimWriter(w, "")
-		}, customDelimConfig, nil
+		}, customDelimConfig, true, nil
 	case "lines":
-		return newLinesWriter, linesWriterConfig, nil
+		return newLinesWriter, linesWriterConfig, true, nil
+	case "csv":
+		return func(w io.WriteCloser) (Writer, error) {
+			return newCSVWriter(w, nil), nil
+		}, csvWriterConfig, true, nil
 	}
 	if strings.HasPrefix(codec, "delim:") {
 		by := strings.TrimPrefix(codec, "delim:")
 		if by == "" {
-			return nil, WriterConfig{}, errors.New("custom delimiter codec requires a non-empty delimiter")
+			return nil, WriterConfig{}, false, errors.New("custom delimiter codec requires a non-empty delimiter")
 		}
 		return func(w io.WriteCloser) (Writer, error) {
 			return newCustomDelimWriter(w, by)
-		}, customDelimConfig, nil
+		}, customDelimConfig, true, nil
+	}
+	if strings.HasPrefix(codec, "csv:") {
+		by := strings.TrimPrefix(codec, "csv:")
+		if by == "" {
+			return nil, WriterConfig{}, false, errors.New("csv codec requires a non-empty delimiter")
+		}
+		byRunes := []rune(by)
+		if len(byRunes) != 1 {
+			return nil, WriterConfig{}, false, errors.New("csv codec requires a single character delimiter")
+		}
+		byRune := byRunes[0]
+		return func(w io.WriteCloser) (Writer, error) {
+			return newCSVWriter(w, &byRune), nil
+		}, csvWriterConfig, true, nil
 	}
-	return nil, WriterConfig{}, fmt.Errorf("codec was not recognised: %v", codec)
+	if strings.HasPrefix(codec, "parquet:") {
+		schemaPath := strings.TrimPrefix(codec, "parquet:")
+		if schemaPath == "" {
+			return nil, WriterConfig{}, false, errors.New("parquet codec requires a schema file path")
+		}
+		schemaBytes, err := os.ReadFile(schemaPath)
+		if err != nil {
+			return nil, WriterConfig{}, false, fmt.Errorf("failed to read parquet schema file: %w", err)
+		}
+		schema := string(schemaBytes)
+		return func(w io.WriteCloser) (Writer, error) {
+			return newParquetWriter(w, schema)
+		}, parquetWriterConfig, true, nil
+	}
+	return nil, WriterConfig{}, false, nil
 }
 
 //------------------------------------------------------------------------------
@@ -166,3 +286,111 @@ func (d *customDelimWriter) EndBatch() error {
 func (d *customDelimWriter) Close(ctx context.Context) error {
 	return d.w.Close()
 }
+
+//------------------------------------------------------------------------------
+
+// The header of a csv file is written with its first row, and therefore csv
+// files are truncated rather than appended to.
+var csvWriterConfig = WriterConfig{
+	Truncate: true,
+}
+
+type csvWriter struct {
+	w      io.WriteCloser
+	cw     *csv.Writer
+	header []string
+}
+
+func newCSVWriter(w io.WriteCloser, customComma *rune) *csvWriter {
+	cw := csv.NewWriter(w)
+	if customComma != nil {
+		cw.Comma = *customComma
+	}
+	return &csvWriter{w: w, cw: cw}
+}
+
+func (c *csvWriter) Write(ctx context.Context, p *message.Part) error {
+	v, err := p.JSON()
+	if err != nil {
+		return fmt.Errorf("failed to parse message as structured: %w", err)
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected message to be an object, got %T", v)
+	}
+
+	if c.header == nil {
+		c.header = make([]string, 0, len(obj))
+		for k := range obj {
+			c.header = append(c.header, k)
+		}
+		sort.Strings(c.header)
+		if err := c.cw.Write(c.header); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(c.header))
+	for i, k := range c.header {
+		if v, exists := obj[k]; exists && v != nil {
+			row[i] = query.IToString(v)
+		}
+	}
+	if err := c.cw.Write(row); err != nil {
+		return err
+	}
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func (c *csvWriter) EndBatch() error {
+	return nil
+}
+
+func (c *csvWriter) Close(ctx context.Context) error {
+	c.cw.Flush()
+	err := c.cw.Error()
+	if cerr := c.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+var parquetWriterConfig = WriterConfig{
+	Truncate: true,
+}
+
+type parquetWriter struct {
+	w  io.WriteCloser
+	pw *pwriter.JSONWriter
+}
+
+func newParquetWriter(w io.WriteCloser, schema string) (*parquetWriter, error) {
+	pw, err := pwriter.NewJSONWriter(schema, writerfile.NewWriterFile(w), 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet writer: %w", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	return &parquetWriter{w: w, pw: pw}, nil
+}
+
+func (p *parquetWriter) Write(ctx context.Context, part *message.Part) error {
+	if err := p.pw.Write(part.Get()); err != nil {
+		return fmt.Errorf("failed to write document to parquet file: %w", err)
+	}
+	return nil
+}
+
+func (p *parquetWriter) EndBatch() error {
+	return nil
+}
+
+func (p *parquetWriter) Close(ctx context.Context) error {
+	err := p.pw.WriteStop()
+	if cerr := p.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
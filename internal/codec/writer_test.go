@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/message"
+)
+
+type closeRecordingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeRecordingBuffer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func testWriteParts(t *testing.T, codec string, parts ...string) *closeRecordingBuffer {
+	t.Helper()
+
+	ctor, _, err := GetWriter(codec)
+	require.NoError(t, err)
+
+	buf := &closeRecordingBuffer{}
+	w, err := ctor(buf)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, p := range parts {
+		require.NoError(t, w.Write(ctx, message.NewPart([]byte(p))))
+	}
+	require.NoError(t, w.Close(ctx))
+	assert.True(t, buf.closed)
+	return buf
+}
+
+func TestWriterCodecBadChains(t *testing.T) {
+	for _, codec := range []string{
+		"nope",
+		"lines/gzip",
+		"lines/lines",
+		"nope/lines",
+		"gzip",
+		"parquet:",
+	} {
+		_, _, err := GetWriter(codec)
+		assert.Error(t, err, codec)
+	}
+}
+
+func TestWriterCodecDelimWithSlash(t *testing.T) {
+	buf := testWriteParts(t, "delim:/", "foo", "bar")
+	assert.Equal(t, "foo/bar/", buf.String())
+}
+
+func TestWriterCodecGzipLines(t *testing.T) {
+	buf := testWriteParts(t, "gzip/lines", "foo", "bar")
+
+	gr, err := gzip.NewReader(&buf.Buffer)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", string(b))
+}
+
+func TestWriterCodecZstdCSV(t *testing.T) {
+	buf := testWriteParts(t, "zstd/csv",
+		`{"b":"bar1","a":1,"c":true}`,
+		`{"a":2,"b":"bar2"}`,
+		`{"a":3,"b":"bar3","c":false,"d":"ignored"}`,
+	)
+
+	zr, err := zstd.NewReader(&buf.Buffer)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b,c\n1,bar1,true\n2,bar2,\n3,bar3,false\n", string(b))
+}
+
+func TestWriterCodecCustomCSV(t *testing.T) {
+	buf := testWriteParts(t, "csv:\t", `{"a":"foo","b":"bar baz"}`)
+	assert.Equal(t, "a\tb\nfoo\tbar baz\n", buf.String())
+
+	ctor, conf, err := GetWriter("csv")
+	require.NoError(t, err)
+	assert.True(t, conf.Truncate)
+	assert.False(t, conf.Append)
+
+	w, err := ctor(&closeRecordingBuffer{})
+	require.NoError(t, err)
+	require.Error(t, w.Write(context.Background(), message.NewPart([]byte(`["not","an","object"]`))))
+}
+
+func TestWriterCodecParquet(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, os.WriteFile(schemaPath, []byte(`{
+  "Tag": "name=root, repetitiontype=REQUIRED",
+  "Fields": [
+    {"Tag":"name=name, inname=Name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED"},
+    {"Tag":"name=age, inname=Age, type=INT32, repetitiontype=REQUIRED"}
+  ]
+}`), 0o644))
+
+	_, conf, err := GetWriter("parquet:" + schemaPath)
+	require.NoError(t, err)
+	assert.True(t, conf.Truncate)
+
+	buf := testWriteParts(t, "parquet:"+schemaPath,
+		`{"name":"foo","age":10}`,
+		`{"name":"bar","age":20}`,
+	)
+
+	b := buf.Bytes()
+	require.True(t, len(b) > 8)
+	assert.Equal(t, "PAR1", string(b[:4]))
+	assert.Equal(t, "PAR1", string(b[len(b)-4:]))
+}
@@ -53,6 +53,7 @@ type Client struct {
 	host              *field.Expression
 	metaInsertFilter  *metadata.IncludeFilter
 	metaExtractFilter *metadata.IncludeFilter
+	requestHook       func(*http.Request)
 
 	conf          docs.Config
 	retryThrottle *throttle.Type
@@ -229,6 +230,14 @@ func OptSetManager(mgr interop.Manager) func(*Client) {
 	}
 }
 
+// OptSetRequestHook sets a function that is called with each request before
+// it is signed and sent, allowing components to add their own headers.
+func OptSetRequestHook(fn func(*http.Request)) func(*Client) {
+	return func(t *Client) {
+		t.requestHook = fn
+	}
+}
+
 // OptSetRoundTripper sets the *client.Transport to use for HTTP requests.
 // NOTE: This setting will override any configured TLS options.
 func OptSetRoundTripper(rt http.RoundTripper) func(*Client) {
@@ -372,6 +381,9 @@ func (h *Client) CreateRequest(sendMsg, refMsg *message.Batch) (req *http.Reques
 		req.Header.Del("Content-Type")
 		req.Header.Add("Content-Type", overrideContentType)
 	}
+	if h.requestHook != nil {
+		h.requestHook(req)
+	}
 
 	err = h.conf.Config.Sign(req)
 	return
@@ -0,0 +1,840 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/gofrs/uuid"
+
+	"github.com/benthosdev/benthos/v4/internal/checkpoint"
+	"github.com/benthosdev/benthos/v4/internal/component"
+	oinput "github.com/benthosdev/benthos/v4/internal/old/input"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func dynamoDBStreamsInputSpec() *service.ConfigSpec {
+	spec := service.NewConfigSpec().
+		Beta().
+		Categories("Services", "AWS").
+		Version("4.0.0").
+		Summary("Consumes item level changes of a DynamoDB table from its stream.").
+		Description(`
+The shards of the stream of a table are discovered automatically, and are consumed in an order that respects their lineage, where a child shard is only consumed once all of its parent shards have been consumed in full. This ensures that changes to a given item are consumed in the order in which they were made.
+
+The latest sequence consumed by this input for each shard is stored within a [DynamoDB table](#table-schema), which allows it to resume at the correct sequence of a shard during restarts. This table is also used for coordination across distributed instances of this input, where each shard is consumed by only one instance at a given time. Benthos will not store a consumed sequence unless it is acknowledged at the output level, which ensures at-least-once delivery guarantees.
+
+### Messages
+
+A message is created for each stream record, where the contents is an object containing the fields ` + "`keys`" + `, ` + "`new_image`" + ` and ` + "`old_image`" + `, each converted from DynamoDB attribute values into regular JSON values. The images that are present depend on the stream view type of the table, and images that aren't present are omitted. Numbers are converted into JSON numbers, binary values into base64 encoded strings, sets into arrays and null values into ` + "`null`" + `.
+
+The records of each call to a shard are consumed as a single batch.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```text" + `
+- dynamodb_table
+- dynamodb_stream_arn
+- dynamodb_shard
+- dynamodb_sequence_number
+- dynamodb_event_id
+- dynamodb_event_name
+- dynamodb_approximate_creation_time
+` + "```" + `
+
+You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).
+
+### Table Schema
+
+It's possible to configure Benthos to create the DynamoDB table required for checkpointing if it does not already exist. However, if you wish to create this yourself (recommended) then create a table with a string HASH key ` + "`StreamID`" + ` and a string RANGE key ` + "`ShardID`" + `. This schema matches that of the ` + "[`aws_kinesis`](/docs/components/inputs/aws_kinesis)" + ` input and therefore the same table can be shared by both inputs.`).
+		Field(service.NewStringField("table").
+			Description("The name of the DynamoDB table to consume changes from. The table must have a stream enabled.")).
+		Field(service.NewObjectField("dynamodb",
+			service.NewStringField("table").
+				Description("The name of the table to access."),
+			service.NewBoolField("create").
+				Description("Whether, if the table does not exist, it should be created.").
+				Default(false),
+			service.NewStringEnumField("billing_mode", "PROVISIONED", "PAY_PER_REQUEST").
+				Description("When creating the table determines the billing mode.").
+				Default("PAY_PER_REQUEST").
+				Advanced(),
+			service.NewIntField("read_capacity_units").
+				Description("Set the provisioned read capacity when creating the table with a `billing_mode` of `PROVISIONED`.").
+				Default(0).
+				Advanced(),
+			service.NewIntField("write_capacity_units").
+				Description("Set the provisioned write capacity when creating the table with a `billing_mode` of `PROVISIONED`.").
+				Default(0).
+				Advanced(),
+		).Description("Determines the table used for storing and accessing the latest consumed sequence for shards, and for coordinating consumers of the stream.")).
+		Field(service.NewIntField("checkpoint_limit").
+			Description("The maximum gap between the in flight sequence versus the latest acknowledged sequence of a shard at a given time. Increasing this limit enables parallel processing of the records of a shard. Any given sequence will not be committed unless all records under that sequence are delivered in order to preserve at least once delivery guarantees.").
+			Default(1024)).
+		Field(service.NewDurationField("commit_period").
+			Description("The period of time between each update to the checkpoint table.").
+			Default("5s")).
+		Field(service.NewDurationField("discovery_period").
+			Description("The period of time between each attempt to discover and claim shards of the stream.").
+			Default("30s").
+			Advanced()).
+		Field(service.NewDurationField("lease_period").
+			Description("The period of time after which a client that has failed to update a shard checkpoint is assumed to be inactive.").
+			Default("30s").
+			Advanced()).
+		Field(service.NewBoolField("start_from_oldest").
+			Description("Whether to consume from the oldest record of a shard when a sequence does not yet exist for it. When set to `false` only records added after the input starts are consumed, shards that are created after the input starts, or whose parent shard has a stored sequence, are always consumed from their oldest record so that no changes are missed when shards are rolled over.").
+			Default(true))
+
+	for _, f := range sessionFields() {
+		spec = spec.Field(f)
+	}
+
+	return spec.Example("Replicate Changes", "Consume the changes made to a table and write the new version of each item into another table, deleting items that were removed:", `
+input:
+  aws_dynamodb_streams:
+    table: foo
+    dynamodb:
+      table: benthos_checkpoints
+      create: true
+
+pipeline:
+  processors:
+    - bloblang: |
+        root = if meta("dynamodb_event_name") == "REMOVE" {
+          deleted()
+        } else {
+          this.new_image
+        }
+
+output:
+  aws_dynamodb:
+    table: bar
+    string_columns:
+      id: ${! json("id") }
+      content: ${! json("content") }
+`)
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"aws_dynamodb_streams", dynamoDBStreamsInputSpec(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			d, err := newDynamoDBStreamsInputFromConfig(conf, mgr)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(d), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// dynamoDBStreamsShardEnd is stored as the sequence number of a shard that has
+// been consumed in full, allowing the consumption of its children to begin.
+const dynamoDBStreamsShardEnd = "SHARD_END"
+
+// dynamoDBStreamsCheckpointer is the subset of checkpointer methods used for
+// claiming and checkpointing the shards of a stream.
+type dynamoDBStreamsCheckpointer interface {
+	AllClaims(ctx context.Context, streamID string) (map[string][]awsKinesisClientClaim, error)
+	Claim(ctx context.Context, streamID, shardID, fromClientID string) (string, error)
+	Checkpoint(ctx context.Context, streamID, shardID, sequenceNumber string, final bool) (bool, error)
+	Yield(ctx context.Context, streamID, shardID, sequenceNumber string) error
+}
+
+type dynamoDBStreamsBatch struct {
+	batch service.MessageBatch
+	ackFn service.AckFunc
+}
+
+type dynamoDBStreamsInput struct {
+	table           string
+	checkpointConf  oinput.DynamoDBCheckpointConfig
+	checkpointLimit int
+	commitPeriod    time.Duration
+	discoveryPeriod time.Duration
+	leasePeriod     time.Duration
+	startFromOldest bool
+
+	clientID string
+	sess     *session.Session
+	log      *service.Logger
+
+	svc          dynamodbstreamsiface.DynamoDBStreamsAPI
+	checkpointer dynamoDBStreamsCheckpointer
+	streamARN    string
+
+	cMut    sync.Mutex
+	msgChan chan dynamoDBStreamsBatch
+
+	ctx  context.Context
+	done func()
+
+	closedChan chan struct{}
+}
+
+func newDynamoDBStreamsInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*dynamoDBStreamsInput, error) {
+	d := &dynamoDBStreamsInput{
+		log:        mgr.Logger(),
+		closedChan: make(chan struct{}),
+	}
+	d.ctx, d.done = context.WithCancel(context.Background())
+
+	var err error
+	if d.table, err = conf.FieldString("table"); err != nil {
+		return nil, err
+	}
+	if d.checkpointConf.Table, err = conf.FieldString("dynamodb", "table"); err != nil {
+		return nil, err
+	}
+	if d.checkpointConf.Create, err = conf.FieldBool("dynamodb", "create"); err != nil {
+		return nil, err
+	}
+	if d.checkpointConf.BillingMode, err = conf.FieldString("dynamodb", "billing_mode"); err != nil {
+		return nil, err
+	}
+	var rcu, wcu int
+	if rcu, err = conf.FieldInt("dynamodb", "read_capacity_units"); err != nil {
+		return nil, err
+	}
+	if wcu, err = conf.FieldInt("dynamodb", "write_capacity_units"); err != nil {
+		return nil, err
+	}
+	d.checkpointConf.ReadCapacityUnits, d.checkpointConf.WriteCapacityUnits = int64(rcu), int64(wcu)
+
+	if d.checkpointLimit, err = conf.FieldInt("checkpoint_limit"); err != nil {
+		return nil, err
+	}
+	if d.checkpointLimit <= 0 {
+		return nil, errors.New("checkpoint_limit must be greater than zero")
+	}
+	if d.commitPeriod, err = conf.FieldDuration("commit_period"); err != nil {
+		return nil, err
+	}
+	if d.discoveryPeriod, err = conf.FieldDuration("discovery_period"); err != nil {
+		return nil, err
+	}
+	if d.leasePeriod, err = conf.FieldDuration("lease_period"); err != nil {
+		return nil, err
+	}
+	if d.startFromOldest, err = conf.FieldBool("start_from_oldest"); err != nil {
+		return nil, err
+	}
+	if d.sess, err = getSession(conf); err != nil {
+		return nil, err
+	}
+
+	u4, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	d.clientID = u4.String()
+	return d, nil
+}
+
+//------------------------------------------------------------------------------
+
+func dynamoDBStreamsAttributeToValue(av *dynamodb.AttributeValue) interface{} {
+	switch {
+	case av == nil:
+		return nil
+	case av.S != nil:
+		return *av.S
+	case av.N != nil:
+		return json.Number(*av.N)
+	case av.B != nil:
+		return base64.StdEncoding.EncodeToString(av.B)
+	case av.BOOL != nil:
+		return *av.BOOL
+	case av.NULL != nil:
+		return nil
+	case av.SS != nil:
+		values := make([]interface{}, len(av.SS))
+		for i, s := range av.SS {
+			values[i] = *s
+		}
+		return values
+	case av.NS != nil:
+		values := make([]interface{}, len(av.NS))
+		for i, n := range av.NS {
+			values[i] = json.Number(*n)
+		}
+		return values
+	case av.BS != nil:
+		values := make([]interface{}, len(av.BS))
+		for i, b := range av.BS {
+			values[i] = base64.StdEncoding.EncodeToString(b)
+		}
+		return values
+	case av.L != nil:
+		values := make([]interface{}, len(av.L))
+		for i, v := range av.L {
+			values[i] = dynamoDBStreamsAttributeToValue(v)
+		}
+		return values
+	case av.M != nil:
+		return dynamoDBStreamsAttributesToObject(av.M)
+	}
+	return nil
+}
+
+func dynamoDBStreamsAttributesToObject(m map[string]*dynamodb.AttributeValue) map[string]interface{} {
+	obj := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		obj[k] = dynamoDBStreamsAttributeToValue(v)
+	}
+	return obj
+}
+
+func (d *dynamoDBStreamsInput) recordToMessage(shardID string, r *dynamodbstreams.Record) *service.Message {
+	msg := service.NewMessage(nil)
+	msg.MetaSet("dynamodb_table", d.table)
+	msg.MetaSet("dynamodb_stream_arn", d.streamARN)
+	msg.MetaSet("dynamodb_shard", shardID)
+	if r.EventID != nil {
+		msg.MetaSet("dynamodb_event_id", *r.EventID)
+	}
+	if r.EventName != nil {
+		msg.MetaSet("dynamodb_event_name", *r.EventName)
+	}
+
+	obj := map[string]interface{}{}
+	if sr := r.Dynamodb; sr != nil {
+		if sr.SequenceNumber != nil {
+			msg.MetaSet("dynamodb_sequence_number", *sr.SequenceNumber)
+		}
+		if sr.ApproximateCreationDateTime != nil {
+			msg.MetaSet("dynamodb_approximate_creation_time", sr.ApproximateCreationDateTime.Format(time.RFC3339))
+		}
+		if sr.Keys != nil {
+			obj["keys"] = dynamoDBStreamsAttributesToObject(sr.Keys)
+		}
+		if sr.NewImage != nil {
+			obj["new_image"] = dynamoDBStreamsAttributesToObject(sr.NewImage)
+		}
+		if sr.OldImage != nil {
+			obj["old_image"] = dynamoDBStreamsAttributesToObject(sr.OldImage)
+		}
+	}
+	msg.SetStructured(obj)
+	return msg
+}
+
+//------------------------------------------------------------------------------
+
+// dynamoDBStreamsShardReady returns whether a shard can be consumed, which is
+// only the case once all of its parents that still exist have been consumed
+// in full.
+func dynamoDBStreamsShardReady(s *dynamodbstreams.Shard, present, finished map[string]struct{}) bool {
+	if s.ParentShardId == nil {
+		return true
+	}
+	parentID := *s.ParentShardId
+	if _, exists := present[parentID]; !exists {
+		// The parent has been trimmed from the stream and therefore there is
+		// nothing left to consume from it.
+		return true
+	}
+	_, parentFinished := finished[parentID]
+	return parentFinished
+}
+
+// dynamoDBStreamsStartLatest returns whether a shard without a stored sequence
+// should be consumed from its latest record, which is only the case when not
+// starting from the oldest record, the shard already existed when the input
+// started, and the parent of the shard is not part of a lineage that is being
+// consumed in full. Shards created after the input started are the result of a
+// rollover of shards that are being consumed and must therefore be consumed
+// from their oldest record.
+func dynamoDBStreamsStartLatest(s *dynamodbstreams.Shard, startFromOldest bool, initial, lineage map[string]struct{}) bool {
+	if startFromOldest {
+		return false
+	}
+	if _, exists := initial[*s.ShardId]; !exists {
+		return false
+	}
+	if s.ParentShardId != nil {
+		if _, exists := lineage[*s.ParentShardId]; exists {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *dynamoDBStreamsInput) listShards(ctx context.Context) ([]*dynamodbstreams.Shard, error) {
+	var shards []*dynamodbstreams.Shard
+	input := &dynamodbstreams.DescribeStreamInput{
+		StreamArn: aws.String(d.streamARN),
+	}
+	for {
+		res, err := d.svc.DescribeStreamWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if res.StreamDescription == nil {
+			return shards, nil
+		}
+		shards = append(shards, res.StreamDescription.Shards...)
+		if res.StreamDescription.LastEvaluatedShardId == nil {
+			return shards, nil
+		}
+		input.ExclusiveStartShardId = res.StreamDescription.LastEvaluatedShardId
+	}
+}
+
+func (d *dynamoDBStreamsInput) getIter(ctx context.Context, shardID, sequence string, fromLatest bool) (string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(d.streamARN),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon),
+	}
+	if fromLatest {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeLatest)
+	}
+	if sequence != "" {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = aws.String(sequence)
+	}
+
+	res, err := d.svc.GetShardIteratorWithContext(ctx, input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodbstreams.ErrCodeTrimmedDataAccessException || sequence == "" {
+			return "", err
+		}
+
+		// The sequence we have stored has been trimmed from the stream and
+		// therefore we start from the oldest record available.
+		d.log.Warnf("Sequence of shard '%v' has been trimmed, consuming from the oldest available record", shardID)
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon)
+		input.SequenceNumber = nil
+		if res, err = d.svc.GetShardIteratorWithContext(ctx, input); err != nil {
+			return "", err
+		}
+	}
+	if res.ShardIterator == nil || *res.ShardIterator == "" {
+		return "", errors.New("failed to obtain shard iterator")
+	}
+	return *res.ShardIterator, nil
+}
+
+// runConsumer consumes a claimed shard until it is either finished, stolen by
+// another client or the input is closed. Returns true if the shard was
+// consumed in full. When fromLatest is true and there is no starting sequence
+// the shard is consumed from its latest record.
+func (d *dynamoDBStreamsInput) runConsumer(shardID, startingSequence string, fromLatest bool) (finished bool) {
+	var ackedMut sync.Mutex
+	ackedSequence := startingSequence
+	getAcked := func() string {
+		ackedMut.Lock()
+		defer ackedMut.Unlock()
+		return ackedSequence
+	}
+
+	var pendingWG sync.WaitGroup
+	tracker := checkpoint.NewCapped(int64(d.checkpointLimit))
+
+	boff := backoff.NewExponentialBackOff()
+	boff.InitialInterval = time.Millisecond * 300
+	boff.MaxInterval = time.Second * 5
+	boff.MaxElapsedTime = 0
+
+	owned := true
+	defer func() {
+		if finished {
+			if _, err := d.checkpointer.Checkpoint(context.Background(), d.streamARN, shardID, dynamoDBStreamsShardEnd, true); err != nil {
+				d.log.Errorf("Failed to store final checkpoint for shard '%v': %v", shardID, err)
+			}
+			d.log.Debugf("Closing shard '%v' as client '%v' because the shard is finished", shardID, d.clientID)
+			return
+		}
+		if !owned {
+			if err := d.checkpointer.Yield(context.Background(), d.streamARN, shardID, getAcked()); err != nil {
+				d.log.Errorf("Failed to yield checkpoint for stolen shard '%v': %v", shardID, err)
+			}
+			d.log.Debugf("Closing shard '%v' as client '%v' because the shard has been claimed by another client", shardID, d.clientID)
+			return
+		}
+		if _, err := d.checkpointer.Checkpoint(context.Background(), d.streamARN, shardID, getAcked(), true); err != nil {
+			d.log.Errorf("Failed to store final checkpoint for shard '%v': %v", shardID, err)
+		}
+		d.log.Debugf("Closing shard '%v' as client '%v' because the input is shutting down", shardID, d.clientID)
+	}()
+
+	nextCommit := time.Now().Add(d.commitPeriod)
+	commitIfDue := func() bool {
+		if time.Now().Before(nextCommit) {
+			return true
+		}
+		nextCommit = time.Now().Add(d.commitPeriod)
+		stillOwned, err := d.checkpointer.Checkpoint(d.ctx, d.streamARN, shardID, getAcked(), false)
+		if err != nil {
+			d.log.Errorf("Failed to store checkpoint for shard '%v': %v", shardID, err)
+			return true
+		}
+		owned = stillOwned
+		return stillOwned
+	}
+
+	d.log.Debugf("Consuming shard '%v' as client '%v'", shardID, d.clientID)
+
+	iter, err := d.getIter(d.ctx, shardID, startingSequence, fromLatest)
+	for err != nil {
+		if d.ctx.Err() != nil {
+			return false
+		}
+		d.log.Errorf("Failed to obtain iterator for shard '%v': %v", shardID, err)
+		select {
+		case <-time.After(boff.NextBackOff()):
+		case <-d.ctx.Done():
+			return false
+		}
+		if !commitIfDue() {
+			return false
+		}
+		iter, err = d.getIter(d.ctx, shardID, getAcked(), fromLatest)
+	}
+	boff.Reset()
+
+	for {
+		if !commitIfDue() {
+			return false
+		}
+
+		res, err := d.svc.GetRecordsWithContext(d.ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: aws.String(iter),
+		})
+		if err != nil {
+			if d.ctx.Err() != nil {
+				return false
+			}
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodbstreams.ErrCodeExpiredIteratorException {
+				d.log.Warn("Shard iterator expired, attempting to refresh")
+				if newIter, err := d.getIter(d.ctx, shardID, getAcked(), fromLatest); err != nil {
+					d.log.Errorf("Failed to refresh shard iterator: %v", err)
+				} else {
+					iter = newIter
+				}
+			} else {
+				d.log.Errorf("Failed to pull DynamoDB stream records: %v", err)
+			}
+			select {
+			case <-time.After(boff.NextBackOff()):
+			case <-d.ctx.Done():
+				return false
+			}
+			continue
+		}
+
+		if len(res.Records) > 0 {
+			boff.Reset()
+
+			batch := make(service.MessageBatch, 0, len(res.Records))
+			var lastSequence string
+			for _, r := range res.Records {
+				batch = append(batch, d.recordToMessage(shardID, r))
+				if r.Dynamodb != nil && r.Dynamodb.SequenceNumber != nil {
+					lastSequence = *r.Dynamodb.SequenceNumber
+				}
+			}
+
+			var release func() interface{}
+			for release == nil {
+				trackCtx, done := context.WithTimeout(d.ctx, d.commitPeriod)
+				release, err = tracker.Track(trackCtx, lastSequence, int64(len(batch)))
+				done()
+				if err != nil {
+					if d.ctx.Err() != nil {
+						return false
+					}
+					if !commitIfDue() {
+						return false
+					}
+				}
+			}
+
+			pendingWG.Add(1)
+			b := dynamoDBStreamsBatch{
+				batch: batch,
+				ackFn: func(ctx context.Context, res error) error {
+					if topSequence, _ := release().(string); topSequence != "" {
+						ackedMut.Lock()
+						ackedSequence = topSequence
+						ackedMut.Unlock()
+					}
+					pendingWG.Done()
+					return nil
+				},
+			}
+
+		flushLoop:
+			for {
+				select {
+				case d.msgChan <- b:
+					break flushLoop
+				case <-time.After(time.Until(nextCommit)):
+					if !commitIfDue() {
+						return false
+					}
+				case <-d.ctx.Done():
+					return false
+				}
+			}
+		}
+
+		if res.NextShardIterator == nil {
+			// The shard is closed and all of its records have been consumed,
+			// we must wait for pending records to be acknowledged before it can
+			// be marked as finished.
+			waitChan := make(chan struct{})
+			go func() {
+				pendingWG.Wait()
+				close(waitChan)
+			}()
+			select {
+			case <-waitChan:
+				return true
+			case <-d.ctx.Done():
+				return false
+			}
+		}
+		iter = *res.NextShardIterator
+
+		if len(res.Records) == 0 {
+			select {
+			case <-time.After(boff.NextBackOff()):
+			case <-d.ctx.Done():
+				return false
+			}
+		}
+	}
+}
+
+func (d *dynamoDBStreamsInput) runShards() {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		close(d.msgChan)
+		close(d.closedChan)
+	}()
+
+	var stateMut sync.Mutex
+	running := map[string]struct{}{}
+	finished := map[string]struct{}{}
+
+	// The shards that existed when the input first listed the stream, and the
+	// shards that are being consumed in full (from a stored sequence or their
+	// oldest record), which determine whether a shard without a stored
+	// sequence may be consumed from its latest record.
+	var initial map[string]struct{}
+	lineage := map[string]struct{}{}
+
+	// Signals that a shard has finished and therefore its children might be
+	// ready for consumption.
+	shardFinishedChan := make(chan struct{}, 1)
+	signalFinished := func() {
+		select {
+		case shardFinishedChan <- struct{}{}:
+		default:
+		}
+	}
+
+	for {
+		shards, err := d.listShards(d.ctx)
+		var clientClaims map[string][]awsKinesisClientClaim
+		if err == nil {
+			clientClaims, err = d.checkpointer.AllClaims(d.ctx, d.streamARN)
+		}
+		if err != nil {
+			if d.ctx.Err() != nil {
+				return
+			}
+			d.log.Errorf("Failed to obtain stream shards or claims: %v", err)
+		} else {
+			// Maps shards claimed by other clients to the client, where the
+			// client ID is empty if the lease has not expired.
+			claimed := map[string]string{}
+			for clientID, claims := range clientClaims {
+				if clientID == d.clientID {
+					continue
+				}
+				for _, claim := range claims {
+					if time.Since(claim.LeaseTimeout) > d.leasePeriod*2 {
+						claimed[claim.ShardID] = clientID
+					} else {
+						claimed[claim.ShardID] = ""
+					}
+				}
+			}
+
+			present := make(map[string]struct{}, len(shards))
+			for _, s := range shards {
+				present[*s.ShardId] = struct{}{}
+			}
+			if initial == nil {
+				initial = present
+			}
+
+			stateMut.Lock()
+			for _, s := range shards {
+				shardID := *s.ShardId
+				if _, exists := running[shardID]; exists {
+					continue
+				}
+				if _, exists := finished[shardID]; exists {
+					continue
+				}
+				fromClientID, isClaimed := claimed[shardID]
+				if isClaimed && fromClientID == "" {
+					continue
+				}
+				if !dynamoDBStreamsShardReady(s, present, finished) {
+					continue
+				}
+
+				sequence, err := d.checkpointer.Claim(d.ctx, d.streamARN, shardID, fromClientID)
+				if err != nil {
+					if d.ctx.Err() != nil {
+						stateMut.Unlock()
+						return
+					}
+					if !errors.Is(err, ErrLeaseNotAcquired) {
+						d.log.Errorf("Failed to claim shard '%v': %v", shardID, err)
+					}
+					continue
+				}
+
+				fromLatest := sequence == "" && dynamoDBStreamsStartLatest(s, d.startFromOldest, initial, lineage)
+				if !fromLatest {
+					lineage[shardID] = struct{}{}
+				}
+
+				if sequence == dynamoDBStreamsShardEnd {
+					// The shard was consumed in full by a previous client, we
+					// therefore release our claim immediately.
+					if _, err := d.checkpointer.Checkpoint(d.ctx, d.streamARN, shardID, sequence, true); err != nil {
+						d.log.Errorf("Failed to release claim of finished shard '%v': %v", shardID, err)
+					}
+					finished[shardID] = struct{}{}
+					signalFinished()
+					continue
+				}
+
+				running[shardID] = struct{}{}
+				wg.Add(1)
+				go func(shardID, sequence string, fromLatest bool) {
+					defer wg.Done()
+					shardFinished := d.runConsumer(shardID, sequence, fromLatest)
+
+					stateMut.Lock()
+					delete(running, shardID)
+					if shardFinished {
+						finished[shardID] = struct{}{}
+					}
+					stateMut.Unlock()
+
+					if shardFinished {
+						signalFinished()
+					}
+				}(shardID, sequence, fromLatest)
+			}
+			stateMut.Unlock()
+		}
+
+		select {
+		case <-time.After(d.discoveryPeriod):
+		case <-shardFinishedChan:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (d *dynamoDBStreamsInput) Connect(ctx context.Context) error {
+	d.cMut.Lock()
+	defer d.cMut.Unlock()
+	if d.msgChan != nil {
+		return nil
+	}
+
+	tableRes, err := dynamodb.New(d.sess).DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(d.table),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe table '%v': %w", d.table, err)
+	}
+	if tableRes.Table == nil || tableRes.Table.LatestStreamArn == nil {
+		return fmt.Errorf("table '%v' does not have a stream enabled", d.table)
+	}
+
+	checkpointer, err := newAWSKinesisCheckpointer(d.sess, d.clientID, d.checkpointConf, d.leasePeriod, d.commitPeriod)
+	if err != nil {
+		return err
+	}
+
+	d.svc = dynamodbstreams.New(d.sess)
+	d.streamARN = *tableRes.Table.LatestStreamArn
+	d.checkpointer = checkpointer
+	d.msgChan = make(chan dynamoDBStreamsBatch)
+
+	go d.runShards()
+
+	d.log.Infof("Consuming DynamoDB stream: %v", d.streamARN)
+	return nil
+}
+
+func (d *dynamoDBStreamsInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	d.cMut.Lock()
+	msgChan := d.msgChan
+	d.cMut.Unlock()
+
+	if msgChan == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	select {
+	case b, open := <-msgChan:
+		if !open {
+			return nil, nil, service.ErrNotConnected
+		}
+		return b.batch, b.ackFn, nil
+	case <-ctx.Done():
+	}
+	return nil, nil, ctx.Err()
+}
+
+func (d *dynamoDBStreamsInput) Close(ctx context.Context) error {
+	d.done()
+
+	d.cMut.Lock()
+	started := d.msgChan != nil
+	d.cMut.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-d.closedChan:
+	case <-ctx.Done():
+		return component.ErrTimeout
+	}
+	return nil
+}
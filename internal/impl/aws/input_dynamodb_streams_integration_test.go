@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/integration"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestIntegrationDynamoDBStreams(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	pool.MaxWait = time.Second * 30
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository:   "localstack/localstack",
+		ExposedPorts: []string{"4566/tcp"},
+		Env:          []string{"SERVICES=dynamodb,dynamodbstreams"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Purge(resource))
+	})
+
+	resource.Expire(900)
+
+	servicePort := resource.GetPort("4566/tcp")
+	client := dynamodb.New(session.Must(session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("xxxxx", "xxxxx", "xxxxx"),
+		Endpoint:    aws.String(fmt.Sprintf("http://localhost:%v", servicePort)),
+		Region:      aws.String("us-east-1"),
+	})))
+
+	require.NoError(t, pool.Retry(func() error {
+		_, err := client.CreateTable(&dynamodb.CreateTableInput{
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{AttributeName: aws.String("id"), AttributeType: aws.String("S")},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			},
+			BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+			StreamSpecification: &dynamodb.StreamSpecification{
+				StreamEnabled:  aws.Bool(true),
+				StreamViewType: aws.String(dynamodb.StreamViewTypeNewAndOldImages),
+			},
+			TableName: aws.String("footable"),
+		})
+		return err
+	}))
+
+	for i := 0; i < 10; i++ {
+		_, err := client.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String("footable"),
+			Item: map[string]*dynamodb.AttributeValue{
+				"id":    {S: aws.String(fmt.Sprintf("id%v", i))},
+				"count": {N: aws.String(fmt.Sprintf("%v", i))},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	streamBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamBuilder.SetLoggerYAML(`level: OFF`))
+	require.NoError(t, streamBuilder.AddInputYAML(fmt.Sprintf(`
+aws_dynamodb_streams:
+  table: footable
+  endpoint: http://localhost:%v
+  region: us-east-1
+  dynamodb:
+    table: checkpoints
+    create: true
+  credentials:
+    id: xxxxx
+    secret: xxxxx
+    token: xxxxx
+`, servicePort)))
+
+	var resMut sync.Mutex
+	var results []string
+	require.NoError(t, streamBuilder.AddConsumerFunc(func(ctx context.Context, msg *service.Message) error {
+		mBytes, err := msg.AsBytes()
+		require.NoError(t, err)
+		eventName, _ := msg.MetaGet("dynamodb_event_name")
+		assert.Equal(t, "INSERT", eventName)
+
+		resMut.Lock()
+		results = append(results, string(mBytes))
+		resMut.Unlock()
+		return nil
+	}))
+
+	stream, err := streamBuilder.Build()
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, stream.Run(context.Background()))
+	}()
+	t.Cleanup(func() {
+		assert.NoError(t, stream.StopWithin(time.Second*10))
+	})
+
+	var expected []string
+	for i := 0; i < 10; i++ {
+		expected = append(expected, fmt.Sprintf(`{"keys":{"id":"id%v"},"new_image":{"count":%v,"id":"id%v"}}`, i, i, i))
+	}
+	assert.Eventually(t, func() bool {
+		resMut.Lock()
+		defer resMut.Unlock()
+		return len(results) == len(expected)
+	}, time.Second*30, time.Millisecond*100)
+
+	resMut.Lock()
+	assert.ElementsMatch(t, expected, results)
+	resMut.Unlock()
+}
@@ -0,0 +1,485 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestDynamoDBStreamsRecordToMessage(t *testing.T) {
+	d := &dynamoDBStreamsInput{
+		table:     "footable",
+		streamARN: "arn:foo",
+	}
+
+	created := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := d.recordToMessage("shard-1", &dynamodbstreams.Record{
+		EventID:   aws.String("event-1"),
+		EventName: aws.String("MODIFY"),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			ApproximateCreationDateTime: &created,
+			SequenceNumber:              aws.String("100"),
+			Keys: map[string]*dynamodb.AttributeValue{
+				"id": {S: aws.String("foo")},
+			},
+			NewImage: map[string]*dynamodb.AttributeValue{
+				"id":      {S: aws.String("foo")},
+				"count":   {N: aws.String("12.5")},
+				"data":    {B: []byte("hello")},
+				"enabled": {BOOL: aws.Bool(true)},
+				"nothing": {NULL: aws.Bool(true)},
+				"tags":    {SS: []*string{aws.String("a"), aws.String("b")}},
+				"scores":  {NS: []*string{aws.String("1"), aws.String("2")}},
+				"blobs":   {BS: [][]byte{[]byte("world")}},
+				"nested": {M: map[string]*dynamodb.AttributeValue{
+					"list": {L: []*dynamodb.AttributeValue{
+						{S: aws.String("bar")},
+						{N: aws.String("3")},
+					}},
+				}},
+			},
+			OldImage: map[string]*dynamodb.AttributeValue{
+				"id":    {S: aws.String("foo")},
+				"count": {N: aws.String("10")},
+			},
+		},
+	})
+
+	v, err := msg.AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"keys": map[string]interface{}{
+			"id": "foo",
+		},
+		"new_image": map[string]interface{}{
+			"id":      "foo",
+			"count":   json.Number("12.5"),
+			"data":    "aGVsbG8=",
+			"enabled": true,
+			"nothing": nil,
+			"tags":    []interface{}{"a", "b"},
+			"scores":  []interface{}{json.Number("1"), json.Number("2")},
+			"blobs":   []interface{}{"d29ybGQ="},
+			"nested": map[string]interface{}{
+				"list": []interface{}{"bar", json.Number("3")},
+			},
+		},
+		"old_image": map[string]interface{}{
+			"id":    "foo",
+			"count": json.Number("10"),
+		},
+	}, v)
+
+	for k, exp := range map[string]string{
+		"dynamodb_table":                     "footable",
+		"dynamodb_stream_arn":                "arn:foo",
+		"dynamodb_shard":                     "shard-1",
+		"dynamodb_sequence_number":           "100",
+		"dynamodb_event_id":                  "event-1",
+		"dynamodb_event_name":                "MODIFY",
+		"dynamodb_approximate_creation_time": "2022-01-02T03:04:05Z",
+	} {
+		act, _ := msg.MetaGet(k)
+		assert.Equal(t, exp, act, k)
+	}
+}
+
+func TestDynamoDBStreamsShardReady(t *testing.T) {
+	shard := func(id, parent string) *dynamodbstreams.Shard {
+		s := &dynamodbstreams.Shard{ShardId: aws.String(id)}
+		if parent != "" {
+			s.ParentShardId = aws.String(parent)
+		}
+		return s
+	}
+
+	present := map[string]struct{}{
+		"a": {}, "b": {}, "c": {},
+	}
+	finished := map[string]struct{}{
+		"a": {},
+	}
+
+	assert.True(t, dynamoDBStreamsShardReady(shard("a", ""), present, finished))
+	assert.True(t, dynamoDBStreamsShardReady(shard("b", "a"), present, finished))
+	assert.False(t, dynamoDBStreamsShardReady(shard("c", "b"), present, finished))
+	assert.True(t, dynamoDBStreamsShardReady(shard("d", "trimmed"), present, finished))
+}
+
+func TestDynamoDBStreamsConfigErrs(t *testing.T) {
+	pConf, err := dynamoDBStreamsInputSpec().ParseYAML(`
+table: foo
+dynamodb:
+  table: bar
+checkpoint_limit: 0
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newDynamoDBStreamsInputFromConfig(pConf, service.MockResources())
+	require.Error(t, err)
+}
+
+func TestDynamoDBStreamsStartLatest(t *testing.T) {
+	shard := func(id, parent string) *dynamodbstreams.Shard {
+		s := &dynamodbstreams.Shard{ShardId: aws.String(id)}
+		if parent != "" {
+			s.ParentShardId = aws.String(parent)
+		}
+		return s
+	}
+
+	initial := map[string]struct{}{
+		"a": {}, "b": {}, "c": {},
+	}
+	lineage := map[string]struct{}{
+		"a": {},
+	}
+
+	assert.False(t, dynamoDBStreamsStartLatest(shard("b", ""), true, initial, lineage))
+	assert.True(t, dynamoDBStreamsStartLatest(shard("b", ""), false, initial, lineage))
+	assert.True(t, dynamoDBStreamsStartLatest(shard("c", "b"), false, initial, lineage))
+	assert.False(t, dynamoDBStreamsStartLatest(shard("b", "a"), false, initial, lineage))
+	assert.False(t, dynamoDBStreamsStartLatest(shard("d", ""), false, initial, lineage))
+	assert.False(t, dynamoDBStreamsStartLatest(shard("d", "c"), false, initial, lineage))
+}
+
+//------------------------------------------------------------------------------
+
+type mockDynamoDBStreamsShard struct {
+	parent  string
+	records []string
+	closed  bool
+}
+
+// mockDynamoDBStreams serves shards where iterators take the form
+// shard:offset and the sequence number of each record is its offset.
+type mockDynamoDBStreams struct {
+	dynamodbstreamsiface.DynamoDBStreamsAPI
+
+	mut       sync.Mutex
+	order     []string
+	shards    map[string]*mockDynamoDBStreamsShard
+	iterTypes map[string]string
+}
+
+func newMockDynamoDBStreams() *mockDynamoDBStreams {
+	return &mockDynamoDBStreams{
+		shards:    map[string]*mockDynamoDBStreamsShard{},
+		iterTypes: map[string]string{},
+	}
+}
+
+func (m *mockDynamoDBStreams) addShard(id, parent string, closed bool, records ...string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.order = append(m.order, id)
+	m.shards[id] = &mockDynamoDBStreamsShard{parent: parent, records: records, closed: closed}
+}
+
+func (m *mockDynamoDBStreams) updateShard(id string, closed bool, records ...string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.shards[id].records = append(m.shards[id].records, records...)
+	m.shards[id].closed = closed
+}
+
+func (m *mockDynamoDBStreams) iterType(id string) string {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.iterTypes[id]
+}
+
+func (m *mockDynamoDBStreams) DescribeStreamWithContext(ctx context.Context, input *dynamodbstreams.DescribeStreamInput, opts ...request.Option) (*dynamodbstreams.DescribeStreamOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	var shards []*dynamodbstreams.Shard
+	for _, id := range m.order {
+		s := &dynamodbstreams.Shard{ShardId: aws.String(id)}
+		if parent := m.shards[id].parent; parent != "" {
+			s.ParentShardId = aws.String(parent)
+		}
+		shards = append(shards, s)
+	}
+	return &dynamodbstreams.DescribeStreamOutput{
+		StreamDescription: &dynamodbstreams.StreamDescription{Shards: shards},
+	}, nil
+}
+
+func (m *mockDynamoDBStreams) GetShardIteratorWithContext(ctx context.Context, input *dynamodbstreams.GetShardIteratorInput, opts ...request.Option) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	id := *input.ShardId
+	if _, exists := m.iterTypes[id]; !exists {
+		m.iterTypes[id] = *input.ShardIteratorType
+	}
+
+	var offset int
+	switch *input.ShardIteratorType {
+	case dynamodbstreams.ShardIteratorTypeLatest:
+		offset = len(m.shards[id].records)
+	case dynamodbstreams.ShardIteratorTypeAfterSequenceNumber:
+		seq, err := strconv.Atoi(*input.SequenceNumber)
+		if err != nil {
+			return nil, err
+		}
+		offset = seq + 1
+	}
+	return &dynamodbstreams.GetShardIteratorOutput{
+		ShardIterator: aws.String(fmt.Sprintf("%v:%v", id, offset)),
+	}, nil
+}
+
+func (m *mockDynamoDBStreams) GetRecordsWithContext(ctx context.Context, input *dynamodbstreams.GetRecordsInput, opts ...request.Option) (*dynamodbstreams.GetRecordsOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	id, offsetStr := (*input.ShardIterator)[:strings.LastIndex(*input.ShardIterator, ":")], (*input.ShardIterator)[strings.LastIndex(*input.ShardIterator, ":")+1:]
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		return nil, err
+	}
+
+	shard := m.shards[id]
+	res := &dynamodbstreams.GetRecordsOutput{}
+	for i := offset; i < len(shard.records); i++ {
+		res.Records = append(res.Records, &dynamodbstreams.Record{
+			EventID: aws.String(shard.records[i]),
+			Dynamodb: &dynamodbstreams.StreamRecord{
+				SequenceNumber: aws.String(strconv.Itoa(i)),
+			},
+		})
+	}
+	if !shard.closed {
+		res.NextShardIterator = aws.String(fmt.Sprintf("%v:%v", id, len(shard.records)))
+	}
+	return res, nil
+}
+
+type mockDynamoDBStreamsCheckpointer struct {
+	mut       sync.Mutex
+	clientID  string
+	sequences map[string]string
+	claims    map[string]string
+}
+
+func newMockDynamoDBStreamsCheckpointer(clientID string) *mockDynamoDBStreamsCheckpointer {
+	return &mockDynamoDBStreamsCheckpointer{
+		clientID:  clientID,
+		sequences: map[string]string{},
+		claims:    map[string]string{},
+	}
+}
+
+func (m *mockDynamoDBStreamsCheckpointer) sequence(shardID string) string {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.sequences[shardID]
+}
+
+func (m *mockDynamoDBStreamsCheckpointer) AllClaims(ctx context.Context, streamID string) (map[string][]awsKinesisClientClaim, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	claims := map[string][]awsKinesisClientClaim{}
+	for shardID, clientID := range m.claims {
+		claims[clientID] = append(claims[clientID], awsKinesisClientClaim{
+			ShardID:      shardID,
+			LeaseTimeout: time.Now().Add(time.Minute),
+		})
+	}
+	return claims, nil
+}
+
+func (m *mockDynamoDBStreamsCheckpointer) Claim(ctx context.Context, streamID, shardID, fromClientID string) (string, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if current, exists := m.claims[shardID]; exists && current != fromClientID {
+		return "", ErrLeaseNotAcquired
+	}
+	m.claims[shardID] = m.clientID
+	return m.sequences[shardID], nil
+}
+
+func (m *mockDynamoDBStreamsCheckpointer) Checkpoint(ctx context.Context, streamID, shardID, sequenceNumber string, final bool) (bool, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.claims[shardID] != m.clientID {
+		return false, nil
+	}
+	m.sequences[shardID] = sequenceNumber
+	if final {
+		delete(m.claims, shardID)
+	}
+	return true, nil
+}
+
+func (m *mockDynamoDBStreamsCheckpointer) Yield(ctx context.Context, streamID, shardID, sequenceNumber string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if sequenceNumber != "" {
+		m.sequences[shardID] = sequenceNumber
+	}
+	return nil
+}
+
+func testDynamoDBStreamsInput(t testing.TB, svc *mockDynamoDBStreams, checkpointer *mockDynamoDBStreamsCheckpointer, startFromOldest bool) *dynamoDBStreamsInput {
+	t.Helper()
+
+	d := &dynamoDBStreamsInput{
+		table:           "footable",
+		checkpointLimit: 1024,
+		commitPeriod:    time.Millisecond * 50,
+		discoveryPeriod: time.Millisecond * 50,
+		leasePeriod:     time.Second * 30,
+		startFromOldest: startFromOldest,
+		clientID:        checkpointer.clientID,
+		log:             service.MockResources().Logger(),
+		svc:             svc,
+		checkpointer:    checkpointer,
+		streamARN:       "arn:foo",
+		msgChan:         make(chan dynamoDBStreamsBatch),
+		closedChan:      make(chan struct{}),
+	}
+	d.ctx, d.done = context.WithCancel(context.Background())
+	go d.runShards()
+
+	t.Cleanup(func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		defer done()
+		assert.NoError(t, d.Close(ctx))
+	})
+	return d
+}
+
+func readDynamoDBStreamsBatch(t testing.TB, ctx context.Context, d *dynamoDBStreamsInput) []string {
+	t.Helper()
+
+	batch, ackFn, err := d.ReadBatch(ctx)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, msg := range batch {
+		id, _ := msg.MetaGet("dynamodb_event_id")
+		ids = append(ids, id)
+	}
+	require.NoError(t, ackFn(ctx, nil))
+	return ids
+}
+
+func TestDynamoDBStreamsConsumesLineageInOrder(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	svc := newMockDynamoDBStreams()
+	svc.addShard("a", "", true, "a0", "a1")
+	svc.addShard("b", "a", false, "b0")
+
+	checkpointer := newMockDynamoDBStreamsCheckpointer("foo")
+	d := testDynamoDBStreamsInput(t, svc, checkpointer, true)
+
+	assert.Equal(t, []string{"a0", "a1"}, readDynamoDBStreamsBatch(t, ctx, d))
+	assert.Equal(t, []string{"b0"}, readDynamoDBStreamsBatch(t, ctx, d))
+
+	assert.Eventually(t, func() bool {
+		return checkpointer.sequence("a") == dynamoDBStreamsShardEnd
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, dynamodbstreams.ShardIteratorTypeTrimHorizon, svc.iterType("a"))
+	assert.Equal(t, dynamodbstreams.ShardIteratorTypeTrimHorizon, svc.iterType("b"))
+
+	assert.Eventually(t, func() bool {
+		return checkpointer.sequence("b") == "0"
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestDynamoDBStreamsResumesFromCheckpoints(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	svc := newMockDynamoDBStreams()
+	svc.addShard("a", "", true, "a0", "a1")
+	svc.addShard("b", "a", true, "b0", "b1")
+	svc.addShard("c", "b", false, "c0", "c1")
+
+	checkpointer := newMockDynamoDBStreamsCheckpointer("foo")
+	checkpointer.sequences["a"] = dynamoDBStreamsShardEnd
+	checkpointer.sequences["b"] = "0"
+
+	// Without a stored sequence shard c would be consumed from its latest
+	// record, but as its parent has a stored sequence it must be consumed in
+	// full.
+	d := testDynamoDBStreamsInput(t, svc, checkpointer, false)
+
+	assert.Equal(t, []string{"b1"}, readDynamoDBStreamsBatch(t, ctx, d))
+	assert.Equal(t, []string{"c0", "c1"}, readDynamoDBStreamsBatch(t, ctx, d))
+
+	assert.Equal(t, "", svc.iterType("a"))
+	assert.Equal(t, dynamodbstreams.ShardIteratorTypeAfterSequenceNumber, svc.iterType("b"))
+	assert.Equal(t, dynamodbstreams.ShardIteratorTypeTrimHorizon, svc.iterType("c"))
+}
+
+func TestDynamoDBStreamsSkipsClaimedShards(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	svc := newMockDynamoDBStreams()
+	svc.addShard("a", "", false, "a0")
+	svc.addShard("b", "", false, "b0")
+
+	checkpointer := newMockDynamoDBStreamsCheckpointer("foo")
+	checkpointer.claims["a"] = "bar"
+
+	d := testDynamoDBStreamsInput(t, svc, checkpointer, true)
+
+	assert.Equal(t, []string{"b0"}, readDynamoDBStreamsBatch(t, ctx, d))
+
+	shortCtx, shortDone := context.WithTimeout(ctx, time.Millisecond*200)
+	defer shortDone()
+	_, _, err := d.ReadBatch(shortCtx)
+	require.Error(t, err)
+	assert.Equal(t, "", svc.iterType("a"))
+}
+
+func TestDynamoDBStreamsLatestOnlyForInitialShards(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	svc := newMockDynamoDBStreams()
+	svc.addShard("a", "", false, "a0")
+
+	checkpointer := newMockDynamoDBStreamsCheckpointer("foo")
+	d := testDynamoDBStreamsInput(t, svc, checkpointer, false)
+
+	require.Eventually(t, func() bool {
+		return svc.iterType("a") != ""
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, dynamodbstreams.ShardIteratorTypeLatest, svc.iterType("a"))
+
+	// Roll the shard over, where records written to the child before it is
+	// claimed must not be lost.
+	svc.updateShard("a", true, "a1")
+	svc.addShard("b", "a", false, "b0", "b1")
+
+	assert.Equal(t, []string{"a1"}, readDynamoDBStreamsBatch(t, ctx, d))
+	assert.Equal(t, []string{"b0", "b1"}, readDynamoDBStreamsBatch(t, ctx, d))
+	assert.Equal(t, dynamodbstreams.ShardIteratorTypeTrimHorizon, svc.iterType("b"))
+}
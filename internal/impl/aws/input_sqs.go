@@ -2,6 +2,7 @@ package aws
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -44,6 +45,12 @@ services. It's also possible to set them explicitly at the component level,
 allowing you to transfer data across accounts. You can find out more
 [in this document](/docs/guides/cloud/aws).
 
+### Visibility Timeouts
+
+Consumed messages are hidden from other consumers for the duration of the queue's visibility timeout, if processing takes longer than this period then the message will be redelivered. Setting ` + "`extend_visibility`" + ` to ` + "`true`" + ` causes the visibility timeout of messages that are still being processed to be extended periodically until they are acknowledged, up to a maximum period defined by ` + "`max_visibility_extension`" + `.
+
+When a message is rejected (nacked) and ` + "`reset_visibility`" + ` is ` + "`true`" + ` its visibility timeout is set to zero, allowing it to be retried immediately.
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -62,6 +69,9 @@ You can access these metadata fields using
 			docs.FieldBool("delete_message", "Whether to delete the consumed message once it is acked. Disabling allows you to handle the deletion using a different mechanism.").Advanced(),
 			docs.FieldBool("reset_visibility", "Whether to set the visibility timeout of the consumed message to zero once it is nacked. Disabling honors the preset visibility timeout specified for the queue.").AtVersion("3.58.0").Advanced(),
 			docs.FieldInt("max_number_of_messages", "The maximum number of messages to return on one poll. Valid values: 1 to 10.").Advanced(),
+			docs.FieldBool("extend_visibility", "Whether to periodically extend the visibility timeout of messages that have been consumed but not yet acknowledged, preventing long running processing from causing messages to be redelivered. Extensions are made at half the interval of the `visibility_timeout`.").AtVersion("4.0.0").Advanced(),
+			docs.FieldString("visibility_timeout", "The visibility timeout to apply to consumed messages when `extend_visibility` is enabled, this is set when messages are received and on each subsequent extension.").AtVersion("4.0.0").Advanced(),
+			docs.FieldString("max_visibility_extension", "The maximum period of time, measured from when a message was received, that its visibility timeout will be extended for when `extend_visibility` is enabled. SQS enforces its own limit of 12 hours.").AtVersion("4.0.0").Advanced(),
 		).WithChildren(sess.FieldSpecs()...).ChildDefaultAndTypesFromStruct(oinput.NewAWSSQSConfig()),
 		Categories: []string{
 			"Services",
@@ -78,9 +88,14 @@ You can access these metadata fields using
 type awsSQSReader struct {
 	conf oinput.AWSSQSConfig
 
+	visibilityTimeout      time.Duration
+	maxVisibilityExtension time.Duration
+
 	session *session.Session
 	sqs     *sqs.SQS
 
+	inFlight *sqsInFlightTracker
+
 	messagesChan     chan *sqs.Message
 	ackMessagesChan  chan sqsMessageHandle
 	nackMessagesChan chan sqsMessageHandle
@@ -90,14 +105,29 @@ type awsSQSReader struct {
 }
 
 func newAWSSQSReader(conf oinput.AWSSQSConfig, log log.Modular) (*awsSQSReader, error) {
-	return &awsSQSReader{
+	a := &awsSQSReader{
 		conf:             conf,
 		log:              log,
+		inFlight:         newSQSInFlightTracker(),
 		messagesChan:     make(chan *sqs.Message),
 		ackMessagesChan:  make(chan sqsMessageHandle),
 		nackMessagesChan: make(chan sqsMessageHandle),
 		closeSignal:      shutdown.NewSignaller(),
-	}, nil
+	}
+
+	if conf.ExtendVisibility {
+		var err error
+		if a.visibilityTimeout, err = time.ParseDuration(conf.VisibilityTimeout); err != nil {
+			return nil, fmt.Errorf("failed to parse visibility timeout: %w", err)
+		}
+		if a.visibilityTimeout < time.Second*2 {
+			return nil, fmt.Errorf("visibility timeout must be at least two seconds, got %v", a.visibilityTimeout)
+		}
+		if a.maxVisibilityExtension, err = time.ParseDuration(conf.MaxVisibilityExtension); err != nil {
+			return nil, fmt.Errorf("failed to parse max visibility extension: %w", err)
+		}
+	}
+	return a, nil
 }
 
 // ConnectWithContext attempts to establish a connection to the target SQS
@@ -119,6 +149,10 @@ func (a *awsSQSReader) ConnectWithContext(ctx context.Context) error {
 	wg.Add(2)
 	go a.readLoop(&wg)
 	go a.ackLoop(&wg)
+	if a.conf.ExtendVisibility {
+		wg.Add(1)
+		go a.visibilityLoop(&wg)
+	}
 	go func() {
 		wg.Wait()
 		a.closeSignal.ShutdownComplete()
@@ -190,6 +224,31 @@ ackLoop:
 	flushNacks()
 }
 
+// visibilityLoop periodically extends the visibility timeout of all messages
+// that have been received but not yet acknowledged.
+func (a *awsSQSReader) visibilityLoop(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	heartbeat := time.NewTicker(a.visibilityTimeout / 2)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-heartbeat.C:
+		case <-a.closeSignal.CloseAtLeisureChan():
+			return
+		}
+
+		ctx, done := a.closeSignal.CloseAtLeisureCtx(context.Background())
+		a.inFlight.Extend(time.Now().Add(-a.maxVisibilityExtension), func(msgs []sqsMessageHandle) {
+			if err := a.changeVisibility(ctx, a.visibilityTimeout, msgs...); err != nil {
+				a.log.Errorf("Failed to extend the visibility timeout of messages: %v", err)
+			}
+		})
+		done()
+	}
+}
+
 func (a *awsSQSReader) readLoop(wg *sync.WaitGroup) {
 	defer wg.Done()
 
@@ -201,10 +260,12 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup) {
 				if m.MessageId == nil || m.ReceiptHandle == nil {
 					continue
 				}
-				tmpNacks = append(tmpNacks, sqsMessageHandle{
+				h := sqsMessageHandle{
 					id:            *m.MessageId,
 					receiptHandle: *m.ReceiptHandle,
-				})
+				}
+				a.inFlight.Remove(h)
+				tmpNacks = append(tmpNacks, h)
 			}
 			ctx, done := a.closeSignal.CloseNowCtx(context.Background())
 			defer done()
@@ -221,12 +282,16 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup) {
 	getMsgs := func() {
 		ctx, done := a.closeSignal.CloseAtLeisureCtx(context.Background())
 		defer done()
-		res, err := a.sqs.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		input := &sqs.ReceiveMessageInput{
 			QueueUrl:              aws.String(a.conf.URL),
 			MaxNumberOfMessages:   aws.Int64(int64(a.conf.MaxNumberOfMessages)),
 			AttributeNames:        []*string{aws.String("All")},
 			MessageAttributeNames: []*string{aws.String("All")},
-		})
+		}
+		if a.conf.ExtendVisibility {
+			input.VisibilityTimeout = aws.Int64(int64(a.visibilityTimeout / time.Second))
+		}
+		res, err := a.sqs.ReceiveMessageWithContext(ctx, input)
 		if err != nil {
 			if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != request.CanceledErrorCode {
 				a.log.Errorf("Failed to pull new SQS messages: %v", aerr)
@@ -234,6 +299,17 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup) {
 			return
 		}
 		if len(res.Messages) > 0 {
+			if a.conf.ExtendVisibility {
+				receivedAt := time.Now()
+				for _, m := range res.Messages {
+					if m.MessageId != nil && m.ReceiptHandle != nil {
+						a.inFlight.Add(sqsMessageHandle{
+							id:            *m.MessageId,
+							receiptHandle: *m.ReceiptHandle,
+						}, receivedAt)
+					}
+				}
+			}
 			pendingMsgs = append(pendingMsgs, res.Messages...)
 			backoff.Reset()
 		}
@@ -297,7 +373,10 @@ func (a *awsSQSReader) resetMessages(ctx context.Context, msgs ...sqsMessageHand
 	if !a.conf.ResetVisibility {
 		return nil
 	}
+	return a.changeVisibility(ctx, 0, msgs...)
+}
 
+func (a *awsSQSReader) changeVisibility(ctx context.Context, timeout time.Duration, msgs ...sqsMessageHandle) error {
 	for len(msgs) > 0 {
 		input := sqs.ChangeMessageVisibilityBatchInput{
 			QueueUrl: aws.String(a.conf.URL),
@@ -308,7 +387,7 @@ func (a *awsSQSReader) resetMessages(ctx context.Context, msgs ...sqsMessageHand
 			input.Entries = append(input.Entries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
 				Id:                aws.String(msg.id),
 				ReceiptHandle:     aws.String(msg.receiptHandle),
-				VisibilityTimeout: aws.Int64(0),
+				VisibilityTimeout: aws.Int64(int64(timeout / time.Second)),
 			})
 			if len(input.Entries) == a.conf.MaxNumberOfMessages {
 				break
@@ -321,7 +400,7 @@ func (a *awsSQSReader) resetMessages(ctx context.Context, msgs ...sqsMessageHand
 			return err
 		}
 		for _, fail := range response.Failed {
-			a.log.Errorf("Failed to delete consumed SQS message '%v', response code: %v\n", *fail.Id, *fail.Code)
+			a.log.Errorf("Failed to change the visibility timeout of SQS message '%v', response code: %v\n", *fail.Id, *fail.Code)
 		}
 	}
 	return nil
@@ -380,6 +459,10 @@ func (a *awsSQSReader) ReadWithContext(ctx context.Context) (*message.Batch, rea
 			return nil
 		}
 
+		// Stop extending the visibility of the message, waiting for any
+		// extension in progress, before it is either deleted or reset.
+		a.inFlight.Remove(mHandle)
+
 		if res == nil {
 			if !a.conf.DeleteMessage {
 				return nil
@@ -428,3 +511,80 @@ func (a *awsSQSReader) WaitForClose(tout time.Duration) error {
 		return nil
 	}
 }
+
+//------------------------------------------------------------------------------
+
+// sqsInFlightTracker keeps track of messages that have been received and not
+// yet acknowledged in order to extend their visibility timeouts.
+type sqsInFlightTracker struct {
+	mut       sync.Mutex
+	extended  *sync.Cond
+	msgs      map[string]sqsInFlight
+	extending map[string]struct{}
+}
+
+type sqsInFlight struct {
+	handle     sqsMessageHandle
+	receivedAt time.Time
+}
+
+func newSQSInFlightTracker() *sqsInFlightTracker {
+	t := &sqsInFlightTracker{
+		msgs:      map[string]sqsInFlight{},
+		extending: map[string]struct{}{},
+	}
+	t.extended = sync.NewCond(&t.mut)
+	return t
+}
+
+// Add a message handle to the tracker.
+func (t *sqsInFlightTracker) Add(h sqsMessageHandle, receivedAt time.Time) {
+	t.mut.Lock()
+	t.msgs[h.receiptHandle] = sqsInFlight{handle: h, receivedAt: receivedAt}
+	t.mut.Unlock()
+}
+
+// Remove a message handle from the tracker. If the handle is currently being
+// extended then Remove blocks until the extension has finished, which ensures
+// that a message being deleted or reset is not extended afterwards.
+func (t *sqsInFlightTracker) Remove(h sqsMessageHandle) {
+	t.mut.Lock()
+	for {
+		if _, exists := t.extending[h.receiptHandle]; !exists {
+			break
+		}
+		t.extended.Wait()
+	}
+	delete(t.msgs, h.receiptHandle)
+	t.mut.Unlock()
+}
+
+// Extend calls fn with all tracked message handles that were received after a
+// cutoff. Handles received before the cutoff are no longer tracked. The
+// tracker is not locked for the duration of fn, but calls to Remove for any of
+// the handles given to fn are blocked until it returns.
+func (t *sqsInFlightTracker) Extend(cutoff time.Time, fn func(msgs []sqsMessageHandle)) {
+	t.mut.Lock()
+	handles := make([]sqsMessageHandle, 0, len(t.msgs))
+	for k, m := range t.msgs {
+		if m.receivedAt.Before(cutoff) {
+			delete(t.msgs, k)
+			continue
+		}
+		handles = append(handles, m.handle)
+		t.extending[k] = struct{}{}
+	}
+	t.mut.Unlock()
+
+	if len(handles) == 0 {
+		return
+	}
+	fn(handles)
+
+	t.mut.Lock()
+	for _, h := range handles {
+		delete(t.extending, h.receiptHandle)
+	}
+	t.extended.Broadcast()
+	t.mut.Unlock()
+}
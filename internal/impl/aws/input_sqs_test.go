@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/log"
+	oinput "github.com/benthosdev/benthos/v4/internal/old/input"
+)
+
+func TestSQSInFlightTracker(t *testing.T) {
+	tracker := newSQSInFlightTracker()
+
+	now := time.Now()
+	tracker.Add(sqsMessageHandle{id: "a", receiptHandle: "ra"}, now.Add(-time.Hour))
+	tracker.Add(sqsMessageHandle{id: "b", receiptHandle: "rb"}, now)
+	tracker.Add(sqsMessageHandle{id: "c", receiptHandle: "rc"}, now)
+
+	tracker.Remove(sqsMessageHandle{id: "c", receiptHandle: "rc"})
+
+	var extended []string
+	tracker.Extend(now.Add(-time.Minute), func(msgs []sqsMessageHandle) {
+		for _, m := range msgs {
+			extended = append(extended, m.id)
+		}
+	})
+	assert.Equal(t, []string{"b"}, extended)
+
+	// Messages beyond the cutoff are no longer tracked.
+	extended = nil
+	tracker.Extend(now.Add(-time.Hour*2), func(msgs []sqsMessageHandle) {
+		for _, m := range msgs {
+			extended = append(extended, m.id)
+		}
+	})
+	sort.Strings(extended)
+	assert.Equal(t, []string{"b"}, extended)
+
+	tracker.Remove(sqsMessageHandle{id: "b", receiptHandle: "rb"})
+	tracker.Extend(now.Add(-time.Hour*2), func(msgs []sqsMessageHandle) {
+		t.Error("extend should not be called without tracked messages")
+	})
+}
+
+func TestSQSInFlightTrackerExtendDoesNotBlock(t *testing.T) {
+	tracker := newSQSInFlightTracker()
+
+	now := time.Now()
+	tracker.Add(sqsMessageHandle{id: "a", receiptHandle: "ra"}, now)
+
+	tracker.Extend(now.Add(-time.Minute), func(msgs []sqsMessageHandle) {
+		done := make(chan struct{})
+		go func() {
+			tracker.Add(sqsMessageHandle{id: "b", receiptHandle: "rb"}, now)
+			tracker.Add(sqsMessageHandle{id: "c", receiptHandle: "rc"}, now)
+			tracker.Remove(sqsMessageHandle{id: "c", receiptHandle: "rc"})
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("tracker was locked during extension")
+		}
+	})
+
+	var extended []string
+	tracker.Extend(now.Add(-time.Minute), func(msgs []sqsMessageHandle) {
+		for _, m := range msgs {
+			extended = append(extended, m.id)
+		}
+	})
+	sort.Strings(extended)
+	assert.Equal(t, []string{"a", "b"}, extended)
+}
+
+func TestSQSInFlightTrackerRemoveAwaitsExtension(t *testing.T) {
+	tracker := newSQSInFlightTracker()
+
+	now := time.Now()
+	tracker.Add(sqsMessageHandle{id: "a", receiptHandle: "ra"}, now)
+
+	removed := make(chan struct{})
+	tracker.Extend(now.Add(-time.Minute), func(msgs []sqsMessageHandle) {
+		go func() {
+			tracker.Remove(sqsMessageHandle{id: "a", receiptHandle: "ra"})
+			close(removed)
+		}()
+		select {
+		case <-removed:
+			t.Error("handle was removed during its extension")
+		case <-time.After(time.Millisecond * 100):
+		}
+	})
+
+	select {
+	case <-removed:
+	case <-time.After(time.Second):
+		t.Fatal("handle was not removed after its extension")
+	}
+
+	tracker.Extend(now.Add(-time.Minute), func(msgs []sqsMessageHandle) {
+		t.Error("extend should not be called without tracked messages")
+	})
+}
+
+func TestSQSReaderVisibilityConfig(t *testing.T) {
+	conf := oinput.NewAWSSQSConfig()
+	conf.ExtendVisibility = true
+	conf.VisibilityTimeout = "1m"
+	conf.MaxVisibilityExtension = "1h"
+
+	r, err := newAWSSQSReader(conf, log.Noop())
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, r.visibilityTimeout)
+	assert.Equal(t, time.Hour, r.maxVisibilityExtension)
+
+	conf.VisibilityTimeout = "1s"
+	_, err = newAWSSQSReader(conf, log.Noop())
+	require.Error(t, err)
+
+	conf.VisibilityTimeout = "nope"
+	_, err = newAWSSQSReader(conf, log.Noop())
+	require.Error(t, err)
+
+	// Durations are not parsed when extensions are disabled.
+	conf.ExtendVisibility = false
+	_, err = newAWSSQSReader(conf, log.Noop())
+	require.NoError(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/url"
 	"sort"
 	"strings"
@@ -16,6 +17,7 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/batch/policy"
 	"github.com/benthosdev/benthos/v4/internal/bloblang/field"
 	"github.com/benthosdev/benthos/v4/internal/bundle"
+	"github.com/benthosdev/benthos/v4/internal/codec"
 	"github.com/benthosdev/benthos/v4/internal/component"
 	"github.com/benthosdev/benthos/v4/internal/component/output"
 	"github.com/benthosdev/benthos/v4/internal/docs"
@@ -26,6 +28,7 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/metadata"
 	ooutput "github.com/benthosdev/benthos/v4/internal/old/output"
 	"github.com/benthosdev/benthos/v4/internal/old/output/writer"
+	"github.com/benthosdev/benthos/v4/internal/shutdown"
 )
 
 func init() {
@@ -76,6 +79,25 @@ services. It's also possible to set them explicitly at the component level,
 allowing you to transfer data across accounts. You can find out more
 [in this document](/docs/guides/cloud/aws).
 
+### Streaming
+
+When `+"`streaming.enabled`"+` is set to `+"`true`"+` messages are written through a codec into long lived objects using multipart uploads, where an object is kept open for each unique value of `+"`path`"+` and committed once it reaches `+"`streaming.max_bytes`"+` in size or `+"`streaming.max_age`"+` in age. This allows you to, for example, produce newline delimited objects partitioned by the hour without batching messages in memory:
+
+`+"```yaml"+`
+output:
+  aws_s3:
+    bucket: TODO
+    path: dt=${!timestamp_utc("2006-01-02T15")}/part.jsonl
+    max_in_flight: 1000
+    streaming:
+      enabled: true
+      codec: lines
+      max_bytes: 104857600
+      max_age: 5m
+`+"```"+`
+
+The final key of each object is the path with a unix nanosecond timestamp inserted before the extension, e.g. `+"`dt=2022-01-02T15/part-1641135600000000000.jsonl`"+`. Messages are only acknowledged once the object containing them has been committed, and therefore `+"`max_in_flight`"+` should be large enough to fill an object within `+"`streaming.max_age`"+`. Object attributes such as the content type, metadata and tags are taken from the first message written to each object.
+
 ### Batching
 
 It's common to want to upload messages to S3 as batched archives, the easiest
@@ -144,8 +166,9 @@ output:
 			docs.FieldString("kms_key_id", "An optional server side encryption key.").Advanced(),
 			docs.FieldString("server_side_encryption", "An optional server side encryption algorithm.").AtVersion("3.63.0").Advanced(),
 			docs.FieldBool("force_path_style_urls", "Forces the client API to use path style URLs, which helps when connecting to custom endpoints.").Advanced(),
+			codec.StreamingDocs.AtVersion("4.0.0"),
 			docs.FieldInt("max_in_flight", "The maximum number of messages to have in flight at a given time. Increase this to improve throughput."),
-			docs.FieldString("timeout", "The maximum period to wait on an upload before abandoning it and reattempting.").Advanced(),
+			docs.FieldString("timeout", "The maximum period to wait on an upload before abandoning it and reattempting. This does not apply when streaming.").Advanced(),
 			policy.FieldSpec(),
 		).WithChildren(sess.FieldSpecs()...).ChildDefaultAndTypesFromStruct(ooutput.NewAmazonS3Config()),
 		Categories: []string{
@@ -181,7 +204,12 @@ type amazonS3Writer struct {
 	uploader *s3manager.Uploader
 	timeout  time.Duration
 
-	log log.Modular
+	streamer     *codec.ObjectStreamer
+	streamCtx    context.Context
+	streamCancel func()
+
+	log     log.Modular
+	shutSig *shutdown.Signaller
 }
 
 func newAmazonS3Writer(conf ooutput.AmazonS3Config, mgr interop.Manager) (*amazonS3Writer, error) {
@@ -196,8 +224,15 @@ func newAmazonS3Writer(conf ooutput.AmazonS3Config, mgr interop.Manager) (*amazo
 		conf:    conf,
 		log:     mgr.Logger(),
 		timeout: timeout,
+		shutSig: shutdown.NewSignaller(),
 	}
 	var err error
+	if conf.Streaming.Enabled {
+		if a.streamer, err = codec.NewObjectStreamer(conf.Streaming); err != nil {
+			return nil, fmt.Errorf("failed to create streaming writer: %w", err)
+		}
+		a.streamCtx, a.streamCancel = context.WithCancel(context.Background())
+	}
 	if a.path, err = mgr.BloblEnvironment().NewField(conf.Path); err != nil {
 		return nil, fmt.Errorf("failed to parse path expression: %v", err)
 	}
@@ -264,89 +299,175 @@ func (a *amazonS3Writer) ConnectWithContext(ctx context.Context) error {
 	return nil
 }
 
+func (a *amazonS3Writer) uploadInput(i int, msg *message.Batch) *s3manager.UploadInput {
+	p := msg.Get(i)
+
+	metadata := map[string]*string{}
+	a.metaFilter.Iter(p, func(k, v string) error {
+		metadata[k] = aws.String(v)
+		return nil
+	})
+
+	var contentEncoding *string
+	if ce := a.contentEncoding.String(i, msg); len(ce) > 0 {
+		contentEncoding = aws.String(ce)
+	}
+	var cacheControl *string
+	if ce := a.cacheControl.String(i, msg); len(ce) > 0 {
+		cacheControl = aws.String(ce)
+	}
+	var contentDisposition *string
+	if ce := a.contentDisposition.String(i, msg); len(ce) > 0 {
+		contentDisposition = aws.String(ce)
+	}
+	var contentLanguage *string
+	if ce := a.contentLanguage.String(i, msg); len(ce) > 0 {
+		contentLanguage = aws.String(ce)
+	}
+	var websiteRedirectLocation *string
+	if ce := a.websiteRedirectLocation.String(i, msg); len(ce) > 0 {
+		websiteRedirectLocation = aws.String(ce)
+	}
+
+	uploadInput := &s3manager.UploadInput{
+		Bucket:                  &a.conf.Bucket,
+		Key:                     aws.String(a.path.String(i, msg)),
+		ContentType:             aws.String(a.contentType.String(i, msg)),
+		ContentEncoding:         contentEncoding,
+		CacheControl:            cacheControl,
+		ContentDisposition:      contentDisposition,
+		ContentLanguage:         contentLanguage,
+		WebsiteRedirectLocation: websiteRedirectLocation,
+		StorageClass:            aws.String(a.storageClass.String(i, msg)),
+		Metadata:                metadata,
+	}
+
+	// Prepare tags, escaping keys and values to ensure they're valid query string parameters.
+	if len(a.tags) > 0 {
+		tags := make([]string, len(a.tags))
+		for j, pair := range a.tags {
+			tags[j] = url.QueryEscape(pair.key) + "=" + url.QueryEscape(pair.value.String(i, msg))
+		}
+		uploadInput.Tagging = aws.String(strings.Join(tags, "&"))
+	}
+
+	if a.conf.KMSKeyID != "" {
+		uploadInput.ServerSideEncryption = aws.String("aws:kms")
+		uploadInput.SSEKMSKeyId = &a.conf.KMSKeyID
+	}
+
+	// NOTE: This overrides the ServerSideEncryption set above. We need this to preserve
+	// backwards compatibility, where it is allowed to only set kms_key_id in the config and
+	// the ServerSideEncryption value of "aws:kms" is implied.
+	if a.conf.ServerSideEncryption != "" {
+		uploadInput.ServerSideEncryption = &a.conf.ServerSideEncryption
+	}
+	return uploadInput
+}
+
 func (a *amazonS3Writer) WriteWithContext(wctx context.Context, msg *message.Batch) error {
 	if a.session == nil {
 		return component.ErrNotConnected
 	}
 
+	if a.streamer != nil {
+		return a.writeStreamed(wctx, msg)
+	}
+
 	ctx, cancel := context.WithTimeout(
 		wctx, a.timeout,
 	)
 	defer cancel()
 
 	return writer.IterateBatchedSend(msg, func(i int, p *message.Part) error {
-		metadata := map[string]*string{}
-		a.metaFilter.Iter(p, func(k, v string) error {
-			metadata[k] = aws.String(v)
-			return nil
-		})
-
-		var contentEncoding *string
-		if ce := a.contentEncoding.String(i, msg); len(ce) > 0 {
-			contentEncoding = aws.String(ce)
-		}
-		var cacheControl *string
-		if ce := a.cacheControl.String(i, msg); len(ce) > 0 {
-			cacheControl = aws.String(ce)
-		}
-		var contentDisposition *string
-		if ce := a.contentDisposition.String(i, msg); len(ce) > 0 {
-			contentDisposition = aws.String(ce)
-		}
-		var contentLanguage *string
-		if ce := a.contentLanguage.String(i, msg); len(ce) > 0 {
-			contentLanguage = aws.String(ce)
-		}
-		var websiteRedirectLocation *string
-		if ce := a.websiteRedirectLocation.String(i, msg); len(ce) > 0 {
-			websiteRedirectLocation = aws.String(ce)
+		uploadInput := a.uploadInput(i, msg)
+		uploadInput.Body = bytes.NewReader(p.Get())
+		if _, err := a.uploader.UploadWithContext(ctx, uploadInput); err != nil {
+			return err
 		}
+		return nil
+	})
+}
 
-		uploadInput := &s3manager.UploadInput{
-			Bucket:                  &a.conf.Bucket,
-			Key:                     aws.String(a.path.String(i, msg)),
-			Body:                    bytes.NewReader(p.Get()),
-			ContentType:             aws.String(a.contentType.String(i, msg)),
-			ContentEncoding:         contentEncoding,
-			CacheControl:            cacheControl,
-			ContentDisposition:      contentDisposition,
-			ContentLanguage:         contentLanguage,
-			WebsiteRedirectLocation: websiteRedirectLocation,
-			StorageClass:            aws.String(a.storageClass.String(i, msg)),
-			Metadata:                metadata,
-		}
+//------------------------------------------------------------------------------
 
-		// Prepare tags, escaping keys and values to ensure they're valid query string parameters.
-		if len(a.tags) > 0 {
-			tags := make([]string, len(a.tags))
-			for j, pair := range a.tags {
-				tags[j] = url.QueryEscape(pair.key) + "=" + url.QueryEscape(pair.value.String(i, msg))
-			}
-			uploadInput.Tagging = aws.String(strings.Join(tags, "&"))
-		}
+// s3StreamedObject pipes writes into a multipart upload, which is completed
+// once the object is closed.
+type s3StreamedObject struct {
+	pw   *io.PipeWriter
+	done chan error
+}
 
-		if a.conf.KMSKeyID != "" {
-			uploadInput.ServerSideEncryption = aws.String("aws:kms")
-			uploadInput.SSEKMSKeyId = &a.conf.KMSKeyID
-		}
+func (a *amazonS3Writer) openStreamedObject(uploadInput *s3manager.UploadInput) *s3StreamedObject {
+	pr, pw := io.Pipe()
+	uploadInput.Body = pr
 
-		// NOTE: This overrides the ServerSideEncryption set above. We need this to preserve
-		// backwards compatibility, where it is allowed to only set kms_key_id in the config and
-		// the ServerSideEncryption value of "aws:kms" is implied.
-		if a.conf.ServerSideEncryption != "" {
-			uploadInput.ServerSideEncryption = &a.conf.ServerSideEncryption
+	o := &s3StreamedObject{pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := a.uploader.UploadWithContext(a.streamCtx, uploadInput)
+		// Unblock any pending writes in case the upload ended early.
+		if err != nil {
+			_ = pr.CloseWithError(err)
+		} else {
+			_ = pr.Close()
 		}
+		o.done <- err
+	}()
+	return o
+}
 
-		if _, err := a.uploader.UploadWithContext(ctx, uploadInput); err != nil {
-			return err
-		}
-		return nil
+func (o *s3StreamedObject) Write(p []byte) (int, error) {
+	return o.pw.Write(p)
+}
+
+func (o *s3StreamedObject) Close() error {
+	_ = o.pw.Close()
+	return <-o.done
+}
+
+func (o *s3StreamedObject) CloseWithError(err error) error {
+	_ = o.pw.CloseWithError(err)
+	<-o.done
+	return err
+}
+
+func (a *amazonS3Writer) writeStreamed(ctx context.Context, msg *message.Batch) error {
+	waits := make([]func(context.Context) error, msg.Len())
+	if err := msg.Iter(func(i int, p *message.Part) error {
+		var err error
+		waits[i], err = a.streamer.Write(ctx, a.path.String(i, msg), p, func(key string) (io.WriteCloser, error) {
+			uploadInput := a.uploadInput(i, msg)
+			uploadInput.Key = aws.String(key)
+			return a.openStreamedObject(uploadInput), nil
+		})
+		return err
+	}); err != nil {
+		return err
+	}
+	return writer.IterateBatchedSend(msg, func(i int, _ *message.Part) error {
+		return waits[i](ctx)
 	})
 }
 
 func (a *amazonS3Writer) CloseAsync() {
+	if a.streamer == nil {
+		a.shutSig.ShutdownComplete()
+		return
+	}
+	go func() {
+		if err := a.streamer.Close(a.streamCtx); err != nil {
+			a.log.Errorf("Failed to commit streamed objects: %v\n", err)
+		}
+		a.streamCancel()
+		a.shutSig.ShutdownComplete()
+	}()
 }
 
-func (a *amazonS3Writer) WaitForClose(time.Duration) error {
+func (a *amazonS3Writer) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-a.shutSig.HasClosedChan():
+	case <-time.After(timeout):
+		return component.ErrTimeout
+	}
 	return nil
 }
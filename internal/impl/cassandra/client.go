@@ -0,0 +1,171 @@
+package cassandra
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+
+	"github.com/benthosdev/benthos/v4/internal/impl/cassandra/shared"
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// clientFields returns the connection fields shared by all cassandra
+// components.
+func clientFields() []*service.ConfigField {
+	specs := shared.ClientFieldSpecs()
+	fields := make([]*service.ConfigField, 0, len(specs))
+	for _, spec := range specs {
+		fields = append(fields, service.NewInternalField(spec))
+	}
+	return fields
+}
+
+func clusterFromParsed(conf *service.ParsedConfig) (*gocql.ClusterConfig, error) {
+	addresses, err := conf.FieldStringList("addresses")
+	if err != nil {
+		return nil, err
+	}
+	cluster := gocql.NewCluster(addresses...)
+
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		cluster.SslOpts = &gocql.SslOptions{
+			Config: tlsConf,
+		}
+	}
+
+	authEnabled, err := conf.FieldBool("password_authenticator", "enabled")
+	if err != nil {
+		return nil, err
+	}
+	if authEnabled {
+		var auth gocql.PasswordAuthenticator
+		if auth.Username, err = conf.FieldString("password_authenticator", "username"); err != nil {
+			return nil, err
+		}
+		if auth.Password, err = conf.FieldString("password_authenticator", "password"); err != nil {
+			return nil, err
+		}
+		cluster.Authenticator = auth
+	}
+
+	if cluster.DisableInitialHostLookup, err = conf.FieldBool("disable_initial_host_lookup"); err != nil {
+		return nil, err
+	}
+
+	consistency, err := conf.FieldString("consistency")
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Consistency, err = gocql.ParseConsistencyWrapper(consistency); err != nil {
+		return nil, fmt.Errorf("parsing consistency: %w", err)
+	}
+
+	var retryPolicy gocql.ExponentialBackoffRetryPolicy
+	if retryPolicy.NumRetries, err = conf.FieldInt("max_retries"); err != nil {
+		return nil, err
+	}
+	if retryPolicy.Min, err = conf.FieldDuration("backoff", "initial_interval"); err != nil {
+		return nil, err
+	}
+	if retryPolicy.Max, err = conf.FieldDuration("backoff", "max_interval"); err != nil {
+		return nil, err
+	}
+	cluster.RetryPolicy = &retryPolicy
+
+	if cluster.Timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+//------------------------------------------------------------------------------
+
+// argsFromMessage executes an args mapping against a message of a batch and
+// returns the resulting array of query arguments.
+func argsFromMessage(batch service.MessageBatch, i int, mapping *bloblang.Executor) ([]interface{}, error) {
+	resMsg, err := batch.BloblangQuery(i, mapping)
+	if err != nil {
+		return nil, fmt.Errorf("executing bloblang mapping: %w", err)
+	}
+
+	iargs, err := resMsg.AsStructured()
+	if err != nil {
+		return nil, fmt.Errorf("parsing bloblang mapping result as json: %w", err)
+	}
+
+	args, ok := iargs.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected bloblang mapping result to be an array but was %T", iargs)
+	}
+	for j, v := range args {
+		args[j] = shared.GenericValue{V: v}
+	}
+	return args, nil
+}
+
+// cqlToStructured converts a value scanned from a row into a value that can be
+// serialised as JSON.
+func cqlToStructured(v interface{}) interface{} {
+	switch t := v.(type) {
+	case gocql.UUID:
+		return t.String()
+	case []byte:
+		return string(t)
+	case net.IP:
+		return t.String()
+	case *inf.Dec:
+		if t == nil {
+			return nil
+		}
+		return json.Number(t.String())
+	case *big.Int:
+		if t == nil {
+			return nil
+		}
+		return json.Number(t.String())
+	case time.Duration:
+		return t.String()
+	case gocql.Duration:
+		return map[string]interface{}{
+			"months":      t.Months,
+			"days":        t.Days,
+			"nanoseconds": t.Nanoseconds,
+		}
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = cqlToStructured(e)
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = cqlToStructured(e)
+		}
+		return t
+	}
+	return v
+}
+
+// rowsFromIter scans the remaining rows of the current page of an iterator as
+// structured objects.
+func rowsFromIter(iter *gocql.Iter, fn func(row map[string]interface{})) {
+	for {
+		row := map[string]interface{}{}
+		if !iter.MapScan(row) {
+			return
+		}
+		for k, v := range row {
+			row[k] = cqlToStructured(v)
+		}
+		fn(row)
+	}
+}
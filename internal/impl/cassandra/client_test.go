@@ -0,0 +1,97 @@
+package cassandra
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/inf.v0"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestClusterFromParsed(t *testing.T) {
+	pConf, err := processorConfigSpec().ParseYAML(`
+addresses: [ foo:9042, bar:9042 ]
+password_authenticator:
+  enabled: true
+  username: foouser
+  password: foopass
+consistency: LOCAL_ONE
+max_retries: 5
+backoff:
+  initial_interval: 2s
+  max_interval: 10s
+timeout: 1s
+query: SELECT * FROM foo
+`, nil)
+	require.NoError(t, err)
+
+	cluster, err := clusterFromParsed(pConf)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"foo:9042", "bar:9042"}, cluster.Hosts)
+	assert.Equal(t, gocql.PasswordAuthenticator{Username: "foouser", Password: "foopass"}, cluster.Authenticator)
+	assert.Equal(t, gocql.LocalOne, cluster.Consistency)
+	assert.Equal(t, &gocql.ExponentialBackoffRetryPolicy{
+		NumRetries: 5,
+		Min:        time.Second * 2,
+		Max:        time.Second * 10,
+	}, cluster.RetryPolicy)
+	assert.Equal(t, time.Second, cluster.Timeout)
+	assert.Nil(t, cluster.SslOpts)
+}
+
+func TestInputConfigErrs(t *testing.T) {
+	for _, conf := range []string{
+		`
+addresses: [ foo:9042 ]
+query: SELECT * FROM foo
+page_size: 0
+`,
+		`
+addresses: [ foo:9042 ]
+query: SELECT * FROM foo
+checkpoint_cache: nope
+`,
+	} {
+		pConf, err := inputConfigSpec().ParseYAML(conf, nil)
+		require.NoError(t, err)
+
+		_, err = newCassandraInputFromConfig(pConf, service.MockResources())
+		require.Error(t, err, conf)
+	}
+}
+
+func TestCQLToStructured(t *testing.T) {
+	uuid, err := gocql.ParseUUID("a0f9ea74-1b0d-11ec-9621-0242ac130002")
+	require.NoError(t, err)
+
+	row := map[string]interface{}{
+		"uuid":    uuid,
+		"blob":    []byte("hello"),
+		"inet":    net.ParseIP("10.0.0.1"),
+		"decimal": inf.NewDec(12345, 2),
+		"varint":  big.NewInt(1234567890),
+		"text":    "world",
+		"nested":  map[string]interface{}{"id": uuid},
+	}
+	for k, v := range row {
+		row[k] = cqlToStructured(v)
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"uuid":    "a0f9ea74-1b0d-11ec-9621-0242ac130002",
+		"blob":    "hello",
+		"inet":    "10.0.0.1",
+		"decimal": json.Number("123.45"),
+		"varint":  json.Number("1234567890"),
+		"text":    "world",
+		"nested":  map[string]interface{}{"id": "a0f9ea74-1b0d-11ec-9621-0242ac130002"},
+	}, row)
+}
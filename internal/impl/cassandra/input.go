@@ -0,0 +1,249 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gocql/gocql"
+
+	"github.com/benthosdev/benthos/v4/internal/checkpoint"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func inputConfigSpec() *service.ConfigSpec {
+	spec := service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Executes a CQL query and creates a message for each row received.").
+		Description(`
+The rows of the query are read one page at a time, where each page is consumed as a batch of messages, one for each row. Once the rows of the query are exhausted this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a [sequence](/docs/components/inputs/sequence) to execute).
+
+### Resuming
+
+If a ` + "`checkpoint_cache`" + ` is configured then the paging state of the next page is stored within it once a page, and all pages prior to it, have been acknowledged. When the input is restarted the query resumes from the stored paging state, and once all rows have been consumed and acknowledged the stored paging state is reset so that subsequent runs begin from the first page.
+
+A paging state is only valid for the exact query that produced it, and therefore the checkpoint key should be changed whenever the query is modified.`)
+
+	for _, f := range clientFields() {
+		spec = spec.Field(f)
+	}
+
+	return spec.
+		Field(service.NewStringField("query").
+			Description("A CQL query to execute.").
+			Example("SELECT * FROM foospace.footable")).
+		Field(service.NewIntField("page_size").
+			Description("The maximum number of rows to fetch per page.").
+			Default(5000).
+			Advanced()).
+		Field(service.NewStringField("checkpoint_cache").
+			Description("An optional [cache resource](/docs/components/caches/about) used to store the paging state of the latest acknowledged page, allowing the query to resume from where it left off after restarts.").
+			Optional()).
+		Field(service.NewStringField("checkpoint_key").
+			Description("The key used to store the paging state within the checkpoint cache.").
+			Advanced().
+			Default("cassandra_page_state")).
+		Example("Table Export", "Export all rows of a table, resuming from the last acknowledged page after a restart:", `
+input:
+  cassandra:
+    addresses: [ localhost:9042 ]
+    query: 'SELECT id, content, created_at FROM foospace.footable'
+    checkpoint_cache: pagestate
+
+cache_resources:
+  - label: pagestate
+    file:
+      directory: /var/lib/benthos/cassandra
+`)
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"cassandra", inputConfigSpec(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			i, err := newCassandraInputFromConfig(conf, mgr)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(i), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type cassandraInput struct {
+	cluster  *gocql.ClusterConfig
+	query    string
+	pageSize int
+
+	checkpointCache string
+	checkpointKey   string
+
+	mgr *service.Resources
+	log *service.Logger
+
+	sessionMut sync.Mutex
+	session    *gocql.Session
+	pageState  []byte
+	done       bool
+
+	checkpointMut sync.Mutex
+	checkpointer  *checkpoint.Type
+}
+
+func newCassandraInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*cassandraInput, error) {
+	c := &cassandraInput{
+		mgr:          mgr,
+		log:          mgr.Logger(),
+		checkpointer: checkpoint.New(),
+	}
+
+	var err error
+	if c.cluster, err = clusterFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if c.query, err = conf.FieldString("query"); err != nil {
+		return nil, err
+	}
+	if c.pageSize, err = conf.FieldInt("page_size"); err != nil {
+		return nil, err
+	}
+	if c.pageSize <= 0 {
+		return nil, errors.New("page_size must be greater than zero")
+	}
+
+	if conf.Contains("checkpoint_cache") {
+		if c.checkpointCache, err = conf.FieldString("checkpoint_cache"); err != nil {
+			return nil, err
+		}
+		if !mgr.HasCache(c.checkpointCache) {
+			return nil, fmt.Errorf("checkpoint cache resource '%v' was not found", c.checkpointCache)
+		}
+	}
+	if c.checkpointKey, err = conf.FieldString("checkpoint_key"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cassandraInput) getPageState(ctx context.Context) (state []byte, err error) {
+	if c.checkpointCache == "" {
+		return nil, nil
+	}
+	if cerr := c.mgr.AccessCache(ctx, c.checkpointCache, func(cache service.Cache) {
+		if state, err = cache.Get(ctx, c.checkpointKey); err == service.ErrKeyNotFound {
+			err = nil
+		}
+	}); cerr != nil {
+		return nil, cerr
+	}
+	return
+}
+
+func (c *cassandraInput) setPageState(ctx context.Context, state []byte) (err error) {
+	if c.checkpointCache == "" {
+		return nil
+	}
+	if cerr := c.mgr.AccessCache(ctx, c.checkpointCache, func(cache service.Cache) {
+		err = cache.Set(ctx, c.checkpointKey, state, nil)
+	}); cerr != nil {
+		return cerr
+	}
+	return
+}
+
+func (c *cassandraInput) Connect(ctx context.Context) error {
+	c.sessionMut.Lock()
+	defer c.sessionMut.Unlock()
+
+	if c.session != nil {
+		return nil
+	}
+
+	state, err := c.getPageState(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain page state: %w", err)
+	}
+
+	session, err := c.cluster.CreateSession()
+	if err != nil {
+		return fmt.Errorf("creating Cassandra session: %w", err)
+	}
+
+	c.session = session
+	c.pageState = state
+	c.log.Infof("Reading rows from Cassandra: %v", c.cluster.Hosts)
+	return nil
+}
+
+func (c *cassandraInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	c.sessionMut.Lock()
+	defer c.sessionMut.Unlock()
+
+	if c.session == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	var batch service.MessageBatch
+	var nextState []byte
+	for len(batch) == 0 {
+		if c.done {
+			return nil, nil, service.ErrEndOfInput
+		}
+
+		// Setting the page state disables automatic paging, and therefore the
+		// iterator only yields the rows of a single page.
+		iter := c.session.Query(c.query).
+			WithContext(ctx).
+			PageSize(c.pageSize).
+			PageState(c.pageState).
+			Iter()
+
+		nextState = iter.PageState()
+		rowsFromIter(iter, func(row map[string]interface{}) {
+			msg := service.NewMessage(nil)
+			msg.SetStructured(row)
+			batch = append(batch, msg)
+		})
+		if err := iter.Close(); err != nil {
+			return nil, nil, err
+		}
+
+		c.pageState = nextState
+		c.done = len(nextState) == 0
+	}
+
+	c.checkpointMut.Lock()
+	release := c.checkpointer.Track(nextState, int64(len(batch)))
+	c.checkpointMut.Unlock()
+
+	return batch, func(ctx context.Context, res error) error {
+		// Page states are written while holding the lock in order to prevent
+		// an older state from overwriting a newer one.
+		c.checkpointMut.Lock()
+		defer c.checkpointMut.Unlock()
+
+		highest, ok := release().([]byte)
+		if !ok {
+			return nil
+		}
+		return c.setPageState(ctx, highest)
+	}, nil
+}
+
+func (c *cassandraInput) Close(ctx context.Context) error {
+	c.sessionMut.Lock()
+	defer c.sessionMut.Unlock()
+
+	if c.session != nil {
+		c.session.Close()
+		c.session = nil
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/benthosdev/benthos/v4/internal/integration"
+	"github.com/benthosdev/benthos/v4/public/service"
 
 	// Bring in legacy definition
 	_ "github.com/benthosdev/benthos/v4/public/components/legacy"
@@ -195,4 +197,177 @@ output:
 			}),
 		)
 	})
+
+	t.Run("input and processor", func(t *testing.T) {
+		require.NoError(t, session.Query(
+			"CREATE TABLE testspace.readtable (id int primary key, content text);",
+		).Exec())
+		for i := 0; i < 25; i++ {
+			require.NoError(t, session.Query(
+				"INSERT INTO testspace.readtable (id, content) VALUES (?, ?);", i, fmt.Sprintf("hello world %v", i),
+			).Exec())
+		}
+
+		streamBuilder := service.NewStreamBuilder()
+		require.NoError(t, streamBuilder.SetLoggerYAML(`level: OFF`))
+		require.NoError(t, streamBuilder.AddInputYAML(fmt.Sprintf(`
+cassandra:
+  addresses: [ localhost:%v ]
+  query: 'SELECT id FROM testspace.readtable'
+  page_size: 10
+`, resource.GetPort("9042/tcp"))))
+		require.NoError(t, streamBuilder.AddProcessorYAML(fmt.Sprintf(`
+cassandra:
+  addresses: [ localhost:%v ]
+  query: 'SELECT id, content FROM testspace.readtable WHERE id = ?'
+  args_mapping: 'root = [ this.id ]'
+`, resource.GetPort("9042/tcp"))))
+
+		var resMut sync.Mutex
+		var results []string
+		require.NoError(t, streamBuilder.AddConsumerFunc(func(ctx context.Context, msg *service.Message) error {
+			mBytes, err := msg.AsBytes()
+			require.NoError(t, err)
+			resMut.Lock()
+			results = append(results, string(mBytes))
+			resMut.Unlock()
+			return nil
+		}))
+
+		stream, err := streamBuilder.Build()
+		require.NoError(t, err)
+
+		tCtx, done := context.WithTimeout(context.Background(), time.Minute)
+		defer done()
+		require.NoError(t, stream.Run(tCtx))
+
+		var expected []string
+		for i := 0; i < 25; i++ {
+			expected = append(expected, fmt.Sprintf(`[{"content":"hello world %v","id":%v}]`, i, i))
+		}
+		assert.ElementsMatch(t, expected, results)
+	})
+
+	t.Run("input resumes from checkpoint", func(t *testing.T) {
+		require.NoError(t, session.Query(
+			"CREATE TABLE testspace.resumetable (id int primary key);",
+		).Exec())
+		for i := 0; i < 25; i++ {
+			require.NoError(t, session.Query(
+				"INSERT INTO testspace.resumetable (id) VALUES (?);", i,
+			).Exec())
+		}
+
+		// Consume the first page directly in order to obtain the page state of
+		// the second page.
+		iter := session.Query("SELECT id FROM testspace.resumetable").PageSize(10).Iter()
+		state := iter.PageState()
+		firstPage := map[int]struct{}{}
+		for i := 0; i < iter.NumRows(); i++ {
+			var id int
+			require.True(t, iter.Scan(&id))
+			firstPage[id] = struct{}{}
+		}
+		require.NoError(t, iter.Close())
+		require.Len(t, firstPage, 10)
+		require.NotEmpty(t, state)
+
+		checkpoints := &testCheckpointCache{values: map[string][]byte{
+			"cassandra_page_state": state,
+		}}
+
+		env := service.NewEnvironment()
+		require.NoError(t, env.RegisterCache("test_checkpoint", service.NewConfigSpec(),
+			func(conf *service.ParsedConfig, mgr *service.Resources) (service.Cache, error) {
+				return checkpoints, nil
+			}))
+
+		streamBuilder := env.NewStreamBuilder()
+		require.NoError(t, streamBuilder.SetLoggerYAML(`level: OFF`))
+		require.NoError(t, streamBuilder.AddCacheYAML(`
+label: pagestate
+test_checkpoint: {}
+`))
+		require.NoError(t, streamBuilder.AddInputYAML(fmt.Sprintf(`
+cassandra:
+  addresses: [ localhost:%v ]
+  query: 'SELECT id FROM testspace.resumetable'
+  page_size: 10
+  checkpoint_cache: pagestate
+`, resource.GetPort("9042/tcp"))))
+
+		var resMut sync.Mutex
+		var results []string
+		require.NoError(t, streamBuilder.AddConsumerFunc(func(ctx context.Context, msg *service.Message) error {
+			mBytes, err := msg.AsBytes()
+			require.NoError(t, err)
+			resMut.Lock()
+			results = append(results, string(mBytes))
+			resMut.Unlock()
+			return nil
+		}))
+
+		stream, err := streamBuilder.Build()
+		require.NoError(t, err)
+
+		tCtx, done := context.WithTimeout(context.Background(), time.Minute)
+		defer done()
+		require.NoError(t, stream.Run(tCtx))
+
+		var expected []string
+		for i := 0; i < 25; i++ {
+			if _, exists := firstPage[i]; !exists {
+				expected = append(expected, fmt.Sprintf(`{"id":%v}`, i))
+			}
+		}
+		assert.ElementsMatch(t, expected, results)
+
+		// The page state is reset once all rows are consumed.
+		checkpoints.mut.Lock()
+		assert.Empty(t, checkpoints.values["cassandra_page_state"])
+		checkpoints.mut.Unlock()
+	})
+}
+
+type testCheckpointCache struct {
+	mut    sync.Mutex
+	values map[string][]byte
+}
+
+func (c *testCheckpointCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	v, exists := c.values[key]
+	if !exists {
+		return nil, service.ErrKeyNotFound
+	}
+	return v, nil
+}
+
+func (c *testCheckpointCache) Set(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	c.mut.Lock()
+	c.values[key] = value
+	c.mut.Unlock()
+	return nil
+}
+
+func (c *testCheckpointCache) Add(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if _, exists := c.values[key]; exists {
+		return service.ErrKeyAlreadyExists
+	}
+	c.values[key] = value
+	return nil
+}
+
+func (c *testCheckpointCache) Delete(ctx context.Context, key string) error {
+	c.mut.Lock()
+	delete(c.values, key)
+	c.mut.Unlock()
+	return nil
+}
+
+func (c *testCheckpointCache) Close(ctx context.Context) error {
+	return nil
 }
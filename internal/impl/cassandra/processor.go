@@ -0,0 +1,153 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gocql/gocql"
+
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func processorConfigSpec() *service.ConfigSpec {
+	spec := service.NewConfigSpec().
+		Beta().
+		Categories("Integration").
+		Version("4.0.0").
+		Summary("Runs a CQL query for each message and replaces the message with an array of objects, one for each row returned.").
+		Description(`
+If the query fails to execute then the message will remain unchanged and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).
+
+When populating timestamp arguments the value must either be a string in ISO 8601 format (2006-01-02T15:04:05Z07:00), or an integer representing unix time in seconds.`)
+
+	for _, f := range clientFields() {
+		spec = spec.Field(f)
+	}
+
+	return spec.
+		Field(service.NewStringField("query").
+			Description("A CQL query to execute for each message.").
+			Example("SELECT * FROM foospace.footable WHERE id = ?")).
+		Field(service.NewBloblangField("args_mapping").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an array of values matching in size to the number of placeholder arguments in the field `query`.").
+			Example("root = [ this.user.id ]").
+			Optional()).
+		Example("Table Lookup", "Here we query a table for rows that share an `id` with the message field `user.id`. A [`branch` processor](/docs/components/processors/branch) is used in order to insert the resulting array into the original message at the path `user_rows`.", `
+pipeline:
+  processors:
+    - branch:
+        processors:
+          - cassandra:
+              addresses: [ localhost:9042 ]
+              query: 'SELECT * FROM foospace.users WHERE id = ?'
+              args_mapping: 'root = [ this.user.id ]'
+        result_map: 'root.user_rows = this'
+`)
+}
+
+func init() {
+	err := service.RegisterBatchProcessor(
+		"cassandra", processorConfigSpec(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			return newCassandraProcessorFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type cassandraProcessor struct {
+	cluster     *gocql.ClusterConfig
+	query       string
+	argsMapping *bloblang.Executor
+
+	log *service.Logger
+
+	sessionMut sync.Mutex
+	session    *gocql.Session
+}
+
+func newCassandraProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*cassandraProcessor, error) {
+	c := &cassandraProcessor{
+		log: mgr.Logger(),
+	}
+
+	var err error
+	if c.cluster, err = clusterFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if c.query, err = conf.FieldString("query"); err != nil {
+		return nil, err
+	}
+	if conf.Contains("args_mapping") {
+		if c.argsMapping, err = conf.FieldBloblang("args_mapping"); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// getSession returns the current session, creating one if it does not yet
+// exist.
+func (c *cassandraProcessor) getSession() (*gocql.Session, error) {
+	c.sessionMut.Lock()
+	defer c.sessionMut.Unlock()
+
+	if c.session != nil {
+		return c.session, nil
+	}
+
+	session, err := c.cluster.CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("creating Cassandra session: %w", err)
+	}
+	c.session = session
+	return session, nil
+}
+
+func (c *cassandraProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	session, err := c.getSession()
+	if err != nil {
+		return nil, err
+	}
+
+	batch = batch.Copy()
+	for i, msg := range batch {
+		var args []interface{}
+		if c.argsMapping != nil {
+			if args, err = argsFromMessage(batch, i, c.argsMapping); err != nil {
+				c.log.Debugf("Arguments mapping failed: %v", err)
+				msg.SetError(err)
+				continue
+			}
+		}
+
+		rows := []interface{}{}
+		iter := session.Query(c.query, args...).WithContext(ctx).Iter()
+		rowsFromIter(iter, func(row map[string]interface{}) {
+			rows = append(rows, row)
+		})
+		if err := iter.Close(); err != nil {
+			c.log.Debugf("Failed to run query: %v", err)
+			msg.SetError(err)
+			continue
+		}
+		msg.SetStructured(rows)
+	}
+	return []service.MessageBatch{batch}, nil
+}
+
+func (c *cassandraProcessor) Close(ctx context.Context) error {
+	c.sessionMut.Lock()
+	defer c.sessionMut.Unlock()
+
+	if c.session != nil {
+		c.session.Close()
+		c.session = nil
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package shared contains docs fields and types that need to be shared across
+// old and new component implementations, it needs to be separate from the
+// parent package in order to avoid circular dependencies (for now).
+package shared
+
+import (
+	"github.com/gocql/gocql"
+
+	"github.com/benthosdev/benthos/v4/internal/docs"
+	btls "github.com/benthosdev/benthos/v4/internal/tls"
+)
+
+// ClientFieldSpecs returns the connection fields shared by all cassandra
+// components.
+func ClientFieldSpecs() docs.FieldSpecs {
+	specs := ConnectionFieldSpecs()
+	specs = append(specs, RequestFieldSpecs()...)
+	return append(specs, TimeoutFieldSpec())
+}
+
+// ConnectionFieldSpecs returns the fields used to establish a connection to a
+// cluster.
+func ConnectionFieldSpecs() docs.FieldSpecs {
+	return docs.FieldSpecs{
+		docs.FieldString(
+			"addresses",
+			"A list of Cassandra nodes to connect to. Multiple comma separated addresses can be specified on a single line.",
+			[]string{"localhost:9042"},
+			[]string{"foo:9042", "bar:9042"},
+			[]string{"foo:9042,bar:9042"},
+		).Array(),
+		btls.FieldSpec(),
+		docs.FieldObject(
+			"password_authenticator",
+			"An object containing the username and password.",
+		).WithChildren(
+			docs.FieldBool("enabled", "Whether to use password authentication.").HasDefault(false),
+			docs.FieldString("username", "A username.").HasDefault(""),
+			docs.FieldString("password", "A password.").HasDefault(""),
+		).Advanced(),
+		docs.FieldBool(
+			"disable_initial_host_lookup",
+			"If enabled the driver will not attempt to get host info from the system.peers table. This can speed up queries but will mean that data_centre, rack and token information will not be available.",
+		).HasDefault(false).Advanced(),
+	}
+}
+
+// RequestFieldSpecs returns the fields that customise the consistency and
+// retries of requests.
+func RequestFieldSpecs() docs.FieldSpecs {
+	return docs.FieldSpecs{
+		docs.FieldString(
+			"consistency",
+			"The consistency level to use.",
+		).HasOptions(
+			"ANY", "ONE", "TWO", "THREE", "QUORUM", "ALL", "LOCAL_QUORUM", "EACH_QUORUM", "LOCAL_ONE",
+		).HasDefault(gocql.Quorum.String()).Advanced(),
+		docs.FieldInt("max_retries", "The maximum number of retries before giving up on a request.").HasDefault(3).Advanced(),
+		docs.FieldObject("backoff", "Control time intervals between retry attempts.").WithChildren(
+			docs.FieldString("initial_interval", "The initial period to wait between retry attempts.").HasDefault("1s"),
+			docs.FieldString("max_interval", "The maximum period to wait between retry attempts.").HasDefault("5s"),
+			docs.FieldString("max_elapsed_time", "").HasDefault("").Deprecated(),
+		).Advanced(),
+	}
+}
+
+// TimeoutFieldSpec returns the field for the client connection timeout.
+func TimeoutFieldSpec() docs.FieldSpec {
+	return docs.FieldString("timeout", "The client connection timeout.").HasDefault("600ms")
+}
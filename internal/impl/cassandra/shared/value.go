@@ -0,0 +1,54 @@
+package shared
+
+import (
+	"encoding/json"
+
+	"github.com/gocql/gocql"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
+)
+
+// GenericValue wraps a value resulting from a mapping in order to marshal it
+// as a query argument.
+type GenericValue struct {
+	V interface{}
+}
+
+// MarshalCQL marshals the value for a given column type.
+//
+// We get typed values out of mappings. However, gocql performs type checking
+// and unfortunately does not like timestamp and some other values as strings:
+// https://github.com/gocql/gocql/blob/5913df4d474e0b2492a129d17bbb3c04537a15cd/marshal.go#L1160
+// it's also very strict on numerical types, so we need to do some magic here.
+func (g GenericValue) MarshalCQL(info gocql.TypeInfo) ([]byte, error) {
+	switch info.Type() {
+	case gocql.TypeTimestamp:
+		t, err := query.IGetTimestamp(g.V)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Marshal(info, t)
+	case gocql.TypeDouble:
+		f, err := query.IGetNumber(g.V)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Marshal(info, f)
+	case gocql.TypeFloat:
+		f, err := query.IGetFloat32(g.V)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Marshal(info, f)
+	case gocql.TypeVarchar:
+		return gocql.Marshal(info, query.IToString(g.V))
+	}
+	if _, isJSONNum := g.V.(json.Number); isJSONNum {
+		i, err := query.IGetInt(g.V)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Marshal(info, i)
+	}
+	return gocql.Marshal(info, g.V)
+}
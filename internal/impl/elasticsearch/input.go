@@ -0,0 +1,411 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+
+	"github.com/benthosdev/benthos/v4/internal/http/docs/auth"
+	sess "github.com/benthosdev/benthos/v4/internal/impl/aws/session"
+	"github.com/benthosdev/benthos/v4/internal/impl/elasticsearch/shared"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func elasticsearchInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Executes a search query against Elasticsearch and creates a message for each hit.").
+		Description(`
+Results are paged through using either a [point in time](https://www.elastic.co/guide/en/elasticsearch/reference/current/point-in-time-api.html) combined with ` + "`search_after`" + ` (the default, requires Elasticsearch 7.12 or newer) or the [scroll API](https://www.elastic.co/guide/en/elasticsearch/reference/current/paginate-search-results.html#scroll-search-results). Once all hits have been consumed this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a [sequence](/docs/components/inputs/sequence) to execute).
+
+The contents of each message is the ` + "`_source`" + ` of the hit.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```text" + `
+- elasticsearch_index
+- elasticsearch_id
+- elasticsearch_score
+` + "```" + `
+
+You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).
+
+### AWS
+
+It's possible to enable AWS connectivity with this input using the ` + "`aws`" + ` fields. However, you may need to set ` + "`sniff` and `healthcheck`" + ` to false for connections to succeed.`).
+		Field(service.NewStringListField("urls").
+			Description("A list of URLs to connect to. If an item of the list contains commas it will be expanded into multiple URLs.").
+			Example([]string{"http://localhost:9200"})).
+		Field(service.NewStringField("index").
+			Description("The index, or comma separated list of indexes, to search. Wildcard patterns are supported.").
+			Example("benthos-*")).
+		Field(service.NewBloblangField("query").
+			Description("An optional Bloblang mapping that results in an Elasticsearch query DSL object. When omitted all documents are matched.").
+			Optional().
+			Example(`root.range.timestamp.gte = "now-1d/d"`).
+			Example(`root.match.user = "benthos"`)).
+		Field(service.NewStringAnnotatedEnumField("pagination", map[string]string{
+			"point_in_time": "Page through results using a point in time and `search_after`.",
+			"scroll":        "Page through results using the scroll API.",
+		}).
+			Description("The mechanism used to page through results.").
+			Advanced().
+			Default("point_in_time")).
+		Field(service.NewIntField("batch_size").
+			Description("The maximum number of hits to obtain per request.").
+			Advanced().
+			Default(100)).
+		Field(service.NewStringField("keep_alive").
+			Description("The period of time to keep the point in time or scroll context alive between requests.").
+			Advanced().
+			Default("1m")).
+		Field(service.NewInternalField(shared.SniffFieldSpec())).
+		Field(service.NewInternalField(shared.HealthcheckFieldSpec())).
+		Field(service.NewStringField("timeout").
+			Description("The maximum time to wait before abandoning a request (and trying again).").
+			Advanced().
+			Default("5s")).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewInternalField(auth.BasicAuthFieldSpec())).
+		Field(service.NewInternalField(shared.AWSFieldSpec())).
+		Field(service.NewInternalField(shared.GzipFieldSpec()))
+}
+
+func init() {
+	err := service.RegisterInput(
+		"elasticsearch", elasticsearchInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			r, err := newElasticsearchReaderFromConfig(conf, mgr.Logger())
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacks(r), nil
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type elasticsearchReader struct {
+	clientConf shared.ClientConfig
+	index      []string
+	query      elastic.Query
+	scroll     bool
+	batchSize  int
+	keepAlive  string
+
+	log *service.Logger
+
+	cMut        sync.Mutex
+	client      *elastic.Client
+	scrollSvc   *elastic.ScrollService
+	pit         *elastic.PointInTime
+	searchAfter []interface{}
+	pending     []*elastic.SearchHit
+	exhausted   bool
+}
+
+func newElasticsearchReaderFromConfig(conf *service.ParsedConfig, log *service.Logger) (*elasticsearchReader, error) {
+	e := &elasticsearchReader{
+		log:   log,
+		query: elastic.NewMatchAllQuery(),
+	}
+
+	urlList, err := conf.FieldStringList("urls")
+	if err != nil {
+		return nil, err
+	}
+	e.clientConf.URLs = shared.SplitURLs(urlList)
+
+	indexStr, err := conf.FieldString("index")
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range strings.Split(indexStr, ",") {
+		if index = strings.TrimSpace(index); len(index) > 0 {
+			e.index = append(e.index, index)
+		}
+	}
+	if len(e.index) == 0 {
+		return nil, fmt.Errorf("an index must be specified")
+	}
+
+	if conf.Contains("query") {
+		queryExec, err := conf.FieldBloblang("query")
+		if err != nil {
+			return nil, err
+		}
+		query, err := queryExec.Query(struct{}{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query mapping: %w", err)
+		}
+		queryBytes, err := json.Marshal(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal query: %w", err)
+		}
+		e.query = elastic.NewRawStringQuery(string(queryBytes))
+	}
+
+	pagination, err := conf.FieldString("pagination")
+	if err != nil {
+		return nil, err
+	}
+	switch pagination {
+	case "point_in_time":
+	case "scroll":
+		e.scroll = true
+	default:
+		return nil, fmt.Errorf("pagination type %v was not recognised", pagination)
+	}
+
+	if e.batchSize, err = conf.FieldInt("batch_size"); err != nil {
+		return nil, err
+	}
+	if e.batchSize < 1 {
+		return nil, fmt.Errorf("batch_size must be greater than zero, got %v", e.batchSize)
+	}
+
+	if e.keepAlive, err = conf.FieldString("keep_alive"); err != nil {
+		return nil, err
+	}
+	if _, err = time.ParseDuration(e.keepAlive); err != nil {
+		return nil, fmt.Errorf("failed to parse keep_alive duration: %w", err)
+	}
+
+	if e.clientConf.Sniff, err = conf.FieldBool("sniff"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.Healthcheck, err = conf.FieldBool("healthcheck"); err != nil {
+		return nil, err
+	}
+
+	timeoutStr, err := conf.FieldString("timeout")
+	if err != nil {
+		return nil, err
+	}
+	if len(timeoutStr) > 0 {
+		if e.clientConf.Timeout, err = time.ParseDuration(timeoutStr); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout string: %v", err)
+		}
+	}
+
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		e.clientConf.TLS = tlsConf
+	}
+
+	if e.clientConf.Gzip, err = conf.FieldBool("gzip_compression"); err != nil {
+		return nil, err
+	}
+
+	if e.clientConf.AuthEnabled, err = conf.FieldBool("basic_auth", "enabled"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.Username, err = conf.FieldString("basic_auth", "username"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.Password, err = conf.FieldString("basic_auth", "password"); err != nil {
+		return nil, err
+	}
+
+	if e.clientConf.AWSEnabled, err = conf.FieldBool("aws", "enabled"); err != nil {
+		return nil, err
+	}
+	e.clientConf.AWS = sess.NewConfig()
+	if e.clientConf.AWS.Region, err = conf.FieldString("aws", "region"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.AWS.Endpoint, err = conf.FieldString("aws", "endpoint"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.AWS.Credentials.Profile, err = conf.FieldString("aws", "credentials", "profile"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.AWS.Credentials.ID, err = conf.FieldString("aws", "credentials", "id"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.AWS.Credentials.Secret, err = conf.FieldString("aws", "credentials", "secret"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.AWS.Credentials.Token, err = conf.FieldString("aws", "credentials", "token"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.AWS.Credentials.Role, err = conf.FieldString("aws", "credentials", "role"); err != nil {
+		return nil, err
+	}
+	if e.clientConf.AWS.Credentials.ExternalID, err = conf.FieldString("aws", "credentials", "role_external_id"); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (e *elasticsearchReader) Connect(ctx context.Context) error {
+	e.cMut.Lock()
+	defer e.cMut.Unlock()
+
+	if e.client != nil {
+		return nil
+	}
+	if e.exhausted {
+		return service.ErrEndOfInput
+	}
+
+	client, err := e.clientConf.NewClient()
+	if err != nil {
+		return err
+	}
+
+	if e.scroll {
+		e.scrollSvc = client.Scroll(e.index...).
+			Query(e.query).
+			Size(e.batchSize).
+			KeepAlive(e.keepAlive).
+			Sort("_doc", true)
+	} else {
+		pitRes, err := client.OpenPointInTime(e.index...).KeepAlive(e.keepAlive).Do(ctx)
+		if err != nil {
+			client.Stop()
+			return fmt.Errorf("failed to open point in time: %w", err)
+		}
+		e.pit = elastic.NewPointInTimeWithKeepAlive(pitRes.Id, e.keepAlive)
+		e.searchAfter = nil
+	}
+
+	e.client = client
+	e.log.Infof("Searching Elasticsearch index '%v' at urls: %s", strings.Join(e.index, ","), e.clientConf.URLs)
+	return nil
+}
+
+// nextPage obtains the next page of hits, returning an empty page once all
+// results have been consumed.
+func (e *elasticsearchReader) nextPage(ctx context.Context) ([]*elastic.SearchHit, error) {
+	if e.scroll {
+		res, err := e.scrollSvc.Do(ctx)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res.Hits == nil {
+			return nil, nil
+		}
+		return res.Hits.Hits, nil
+	}
+
+	search := e.client.Search().
+		PointInTime(e.pit).
+		Query(e.query).
+		Size(e.batchSize).
+		Sort("_shard_doc", true)
+	if len(e.searchAfter) > 0 {
+		search = search.SearchAfter(e.searchAfter...)
+	}
+
+	res, err := search.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The point in time ID may change between requests and the latest must
+	// always be used.
+	if res.PitId != "" {
+		e.pit = elastic.NewPointInTimeWithKeepAlive(res.PitId, e.keepAlive)
+	}
+	if res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return nil, nil
+	}
+
+	hits := res.Hits.Hits
+	e.searchAfter = hits[len(hits)-1].Sort
+	return hits, nil
+}
+
+func (e *elasticsearchReader) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	e.cMut.Lock()
+	defer e.cMut.Unlock()
+
+	if e.client == nil {
+		if e.exhausted {
+			return nil, nil, service.ErrEndOfInput
+		}
+		return nil, nil, service.ErrNotConnected
+	}
+
+	if len(e.pending) == 0 {
+		hits, err := e.nextPage(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(hits) == 0 {
+			e.exhausted = true
+			e.closeContext(ctx)
+			return nil, nil, service.ErrEndOfInput
+		}
+		e.pending = hits
+	}
+
+	hit := e.pending[0]
+	e.pending = e.pending[1:]
+
+	msg := service.NewMessage(hit.Source)
+	msg.MetaSet("elasticsearch_index", hit.Index)
+	msg.MetaSet("elasticsearch_id", hit.Id)
+	if hit.Score != nil {
+		msg.MetaSet("elasticsearch_score", strconv.FormatFloat(*hit.Score, 'f', -1, 64))
+	}
+
+	return msg, func(ctx context.Context, err error) error {
+		return nil
+	}, nil
+}
+
+// closeContext releases any server side resources held for paging through
+// results and stops the client.
+func (e *elasticsearchReader) closeContext(ctx context.Context) {
+	if e.client == nil {
+		return
+	}
+	if e.scrollSvc != nil {
+		if err := e.scrollSvc.Clear(ctx); err != nil {
+			e.log.Debugf("Failed to clear scroll context: %v", err)
+		}
+		e.scrollSvc = nil
+	}
+	if e.pit != nil {
+		if _, err := e.client.ClosePointInTime(e.pit.Id).Do(ctx); err != nil {
+			e.log.Debugf("Failed to close point in time: %v", err)
+		}
+		e.pit = nil
+	}
+	e.client.Stop()
+	e.client = nil
+}
+
+func (e *elasticsearchReader) Close(ctx context.Context) error {
+	e.cMut.Lock()
+	defer e.cMut.Unlock()
+
+	e.closeContext(ctx)
+	return nil
+}
@@ -0,0 +1,104 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestElasticsearchInputConfigParse(t *testing.T) {
+	spec := elasticsearchInputConfig()
+	env := service.NewEnvironment()
+
+	conf, err := spec.ParseYAML(`
+urls: [ "http://foo:9200,http://bar:9200" ]
+index: "foo-*, bar"
+query: 'root.match.user = "benthos"'
+pagination: scroll
+batch_size: 10
+basic_auth:
+  enabled: true
+  username: fooer
+  password: foopass
+aws:
+  enabled: true
+  region: eu-west-1
+`, env)
+	require.NoError(t, err)
+
+	e, err := newElasticsearchReaderFromConfig(conf, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"http://foo:9200", "http://bar:9200"}, e.clientConf.URLs)
+	assert.Equal(t, []string{"foo-*", "bar"}, e.index)
+	assert.True(t, e.scroll)
+	assert.Equal(t, 10, e.batchSize)
+	assert.Equal(t, "1m", e.keepAlive)
+	assert.True(t, e.clientConf.Sniff)
+	assert.True(t, e.clientConf.AuthEnabled)
+	assert.Equal(t, "fooer", e.clientConf.Username)
+	assert.Equal(t, "foopass", e.clientConf.Password)
+	assert.True(t, e.clientConf.AWSEnabled)
+	assert.Equal(t, "eu-west-1", e.clientConf.AWS.Region)
+
+	src, err := e.query.Source()
+	require.NoError(t, err)
+	srcBytes, err := json.Marshal(src)
+	require.NoError(t, err)
+	assert.Equal(t, `{"match":{"user":"benthos"}}`, string(srcBytes))
+}
+
+func TestElasticsearchInputConfigDefaults(t *testing.T) {
+	spec := elasticsearchInputConfig()
+	env := service.NewEnvironment()
+
+	conf, err := spec.ParseYAML(`
+urls: [ "http://localhost:9200" ]
+index: foo
+`, env)
+	require.NoError(t, err)
+
+	e, err := newElasticsearchReaderFromConfig(conf, nil)
+	require.NoError(t, err)
+
+	assert.False(t, e.scroll)
+	assert.Equal(t, 100, e.batchSize)
+	assert.False(t, e.clientConf.AuthEnabled)
+	assert.False(t, e.clientConf.AWSEnabled)
+	assert.IsType(t, &elastic.MatchAllQuery{}, e.query)
+}
+
+func TestElasticsearchInputConfigErrors(t *testing.T) {
+	spec := elasticsearchInputConfig()
+	env := service.NewEnvironment()
+
+	for name, conf := range map[string]string{
+		"empty index": `
+urls: [ "http://localhost:9200" ]
+index: ""
+`,
+		"bad batch size": `
+urls: [ "http://localhost:9200" ]
+index: foo
+batch_size: 0
+`,
+		"bad keep alive": `
+urls: [ "http://localhost:9200" ]
+index: foo
+keep_alive: nope
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			pConf, err := spec.ParseYAML(conf, env)
+			require.NoError(t, err)
+
+			_, err = newElasticsearchReaderFromConfig(pConf, nil)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package shared
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	aws "github.com/olivere/elastic/v7/aws/v4"
+
+	sess "github.com/benthosdev/benthos/v4/internal/impl/aws/session"
+)
+
+// ClientConfig contains the fields required in order to construct an
+// Elasticsearch client.
+type ClientConfig struct {
+	URLs        []string
+	Sniff       bool
+	Healthcheck bool
+	Timeout     time.Duration
+	TLS         *tls.Config
+	Gzip        bool
+
+	AuthEnabled bool
+	Username    string
+	Password    string
+
+	AWSEnabled bool
+	AWS        sess.Config
+}
+
+// SplitURLs expands a list of URLs where any item may contain multiple comma
+// separated URLs.
+func SplitURLs(urls []string) []string {
+	var split []string
+	for _, u := range urls {
+		for _, splitURL := range strings.Split(u, ",") {
+			if len(splitURL) > 0 {
+				split = append(split, splitURL)
+			}
+		}
+	}
+	return split
+}
+
+// NewClient creates an Elasticsearch client from the config.
+func (c ClientConfig) NewClient() (*elastic.Client, error) {
+	opts := []elastic.ClientOptionFunc{
+		elastic.SetURL(c.URLs...),
+		elastic.SetSniff(c.Sniff),
+		elastic.SetHealthcheck(c.Healthcheck),
+	}
+
+	if c.AuthEnabled {
+		opts = append(opts, elastic.SetBasicAuth(c.Username, c.Password))
+	}
+
+	if c.TLS != nil {
+		opts = append(opts, elastic.SetHttpClient(&http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: c.TLS,
+			},
+			Timeout: c.Timeout,
+		}))
+	} else {
+		opts = append(opts, elastic.SetHttpClient(&http.Client{
+			Timeout: c.Timeout,
+		}))
+	}
+
+	if c.AWSEnabled {
+		tsess, err := c.AWS.GetSession()
+		if err != nil {
+			return nil, err
+		}
+		signingClient := aws.NewV4SigningClient(tsess.Config.Credentials, c.AWS.Region)
+		opts = append(opts, elastic.SetHttpClient(signingClient))
+	}
+
+	if c.Gzip {
+		opts = append(opts, elastic.SetGzip(true))
+	}
+
+	return elastic.NewClient(opts...)
+}
@@ -0,0 +1,34 @@
+// Package shared contains docs fields and client construction that need to be
+// shared across old and new component implementations, it needs to be separate
+// from the parent package in order to avoid circular dependencies (for now).
+package shared
+
+import (
+	"github.com/benthosdev/benthos/v4/internal/docs"
+	sess "github.com/benthosdev/benthos/v4/internal/impl/aws/session"
+)
+
+// SniffFieldSpec returns the field spec for toggling node sniffing.
+func SniffFieldSpec() docs.FieldSpec {
+	return docs.FieldBool("sniff", "Prompts Benthos to sniff for brokers to connect to when establishing a connection.").HasDefault(true).Advanced()
+}
+
+// HealthcheckFieldSpec returns the field spec for toggling healthchecks.
+func HealthcheckFieldSpec() docs.FieldSpec {
+	return docs.FieldBool("healthcheck", "Whether to enable healthchecks.").HasDefault(true).Advanced()
+}
+
+// AWSFieldSpec returns the field spec for connecting to Amazon Elastic
+// Service.
+func AWSFieldSpec() docs.FieldSpec {
+	return docs.FieldObject("aws", "Enables and customises connectivity to Amazon Elastic Service.").WithChildren(
+		docs.FieldSpecs{
+			docs.FieldBool("enabled", "Whether to connect to Amazon Elastic Service.").HasDefault(false),
+		}.Merge(sess.FieldSpecs())...,
+	).Advanced()
+}
+
+// GzipFieldSpec returns the field spec for toggling request compression.
+func GzipFieldSpec() docs.FieldSpec {
+	return docs.FieldBool("gzip_compression", "Enable gzip compression on the request side.").HasDefault(false).Advanced()
+}
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"cloud.google.com/go/pubsub"
 	"cloud.google.com/go/storage"
 	"google.golang.org/api/iterator"
 
@@ -31,9 +32,16 @@ func init() {
 		if err != nil {
 			return nil, err
 		}
+		var rdr reader.Async = r
+		// If we're not consuming notifications from a Pub/Sub subscription
+		// then there's no concept of propagating nacks upstream, therefore
+		// wrap our reader within a preserver in order to retry indefinitely.
+		if c.GCPCloudStorage.PubSub.Subscription == "" {
+			rdr = reader.NewAsyncPreserver(rdr)
+		}
 		return input.NewAsyncReader(
 			input.TypeGCPCloudStorage, true,
-			reader.NewAsyncPreserver(r),
+			rdr,
 			nm.Logger(), nm.Metrics(),
 		)
 	}), docs.ComponentSpec{
@@ -43,8 +51,16 @@ func init() {
 		Version:    "3.43.0",
 		Categories: []string{"Services", "GCP"},
 		Summary: `
-Downloads objects within a Google Cloud Storage bucket, optionally filtered by a prefix.`,
+Downloads objects within a Google Cloud Storage bucket, optionally filtered by a prefix, either by walking the items in the bucket or by streaming upload notifications in realtime.`,
 		Description: `
+## Streaming Objects on Upload with Pub/Sub
+
+A common pattern for consuming GCS objects is to publish [Pub/Sub notifications](https://cloud.google.com/storage/docs/pubsub-notifications) from the bucket to a topic, and then have your consumer listen for events which prompt it to download the newly uploaded objects.
+
+Benthos is able to follow this pattern when you configure a ` + "`pubsub.subscription`" + `, where it consumes notifications from the subscription and only downloads the objects referenced by ` + "`OBJECT_FINALIZE`" + ` events, all other event types are acknowledged and ignored. The object name and bucket are taken from the ` + "`objectId` and `bucketId`" + ` attributes of each notification, and when the field ` + "`bucket`" + ` is set notifications from other buckets are ignored. Notifications of objects that no longer exist by the time they are consumed are also acknowledged and ignored.
+
+When Benthos consumes a GCS object the notification that triggered it is not acknowledged until all messages extracted from the object have been sent onwards, and if delivery fails the notification is nacked so that it is redelivered. This ensures at-least-once crash resiliency, but also means that if the object takes longer to process than the ack deadline of your subscription then the same objects might be processed multiple times.
+
 ## Downloading Large Files
 
 When downloading large files it's often necessary to process it in streamed parts in order to avoid loading the entire file in memory at a given time. In order to do this a ` + "[`codec`](#codec)" + ` can be specified that determines how to break the input into smaller individual messages.
@@ -70,10 +86,15 @@ You can access these metadata fields using [function interpolation](/docs/config
 By default Benthos will use a shared credentials file when connecting to GCP
 services. You can find out more [in this document](/docs/guides/cloud/gcp).`,
 		Config: docs.FieldComponent().WithChildren(
-			docs.FieldString("bucket", "The name of the bucket from which to download objects."),
+			docs.FieldString("bucket", "The name of the bucket from which to download objects. If the field `pubsub.subscription` is specified this field is optional."),
 			docs.FieldString("prefix", "An optional path prefix, if set only objects with the prefix are consumed."),
 			codec.ReaderDocs,
 			docs.FieldBool("delete_objects", "Whether to delete downloaded objects from the bucket once they are processed.").Advanced(),
+			docs.FieldObject("pubsub", "Consume Pub/Sub notifications in order to trigger object downloads.").WithChildren(
+				docs.FieldString("project", "The project ID of the target subscription."),
+				docs.FieldString("subscription", "An optional Pub/Sub subscription to consume notifications from. When specified this subscription will control which objects are downloaded."),
+				docs.FieldInt("max_outstanding_messages", "The maximum number of notifications that may be pending acknowledgement at a given time.").Advanced(),
+			).AtVersion("4.0.0"),
 		).ChildDefaultAndTypesFromStruct(input.NewGCPCloudStorageConfig()),
 	})
 }
@@ -83,17 +104,27 @@ const (
 )
 
 type gcpCloudStorageObjectTarget struct {
-	key   string
-	ackFn func(context.Context, error) error
+	key    string
+	bucket string
+	ackFn  func(context.Context, error) error
+
+	// skipFn, when set, discards the target without reading its object, and is
+	// used for targets of objects that no longer exist.
+	skipFn func()
 }
 
-func newGCPCloudStorageObjectTarget(key string, ackFn codec.ReaderAckFn) *gcpCloudStorageObjectTarget {
+func newGCPCloudStorageObjectTarget(key, bucket string, ackFn codec.ReaderAckFn) *gcpCloudStorageObjectTarget {
 	if ackFn == nil {
 		ackFn = func(context.Context, error) error {
 			return nil
 		}
 	}
-	return &gcpCloudStorageObjectTarget{key: key, ackFn: ackFn}
+	return &gcpCloudStorageObjectTarget{key: key, bucket: bucket, ackFn: ackFn}
+}
+
+type gcpCloudStorageObjectTargetReader interface {
+	Pop(ctx context.Context) (*gcpCloudStorageObjectTarget, error)
+	Close(ctx context.Context) error
 }
 
 //------------------------------------------------------------------------------
@@ -155,7 +186,7 @@ func newGCPCloudStorageTargetReader(
 		}
 
 		ackFn := deleteGCPCloudStorageObjectAckFn(bucket, obj.Name, conf.DeleteObjects, nil)
-		staticKeys.pending = append(staticKeys.pending, newGCPCloudStorageObjectTarget(obj.Name, ackFn))
+		staticKeys.pending = append(staticKeys.pending, newGCPCloudStorageObjectTarget(obj.Name, conf.Bucket, ackFn))
 	}
 
 	if len(staticKeys.pending) > 0 {
@@ -178,7 +209,7 @@ func (r *gcpCloudStorageTargetReader) Pop(ctx context.Context) (*gcpCloudStorage
 			}
 
 			ackFn := deleteGCPCloudStorageObjectAckFn(r.bucket, obj.Name, r.conf.DeleteObjects, nil)
-			r.pending = append(r.pending, newGCPCloudStorageObjectTarget(obj.Name, ackFn))
+			r.pending = append(r.pending, newGCPCloudStorageObjectTarget(obj.Name, r.conf.Bucket, ackFn))
 		}
 	}
 	if len(r.pending) == 0 {
@@ -195,13 +226,128 @@ func (r gcpCloudStorageTargetReader) Close(context.Context) error {
 
 //------------------------------------------------------------------------------
 
+// gcpCloudStorageTargetFromNotification extracts the bucket and object name
+// from the attributes of a GCS Pub/Sub notification, returning false if the
+// notification should not trigger a download.
+func gcpCloudStorageTargetFromNotification(conf input.GCPCloudStorageConfig, attrs map[string]string) (key, bucket string, ok bool) {
+	if attrs["eventType"] != "OBJECT_FINALIZE" {
+		return "", "", false
+	}
+	if key, bucket = attrs["objectId"], attrs["bucketId"]; key == "" || bucket == "" {
+		return "", "", false
+	}
+	if conf.Bucket != "" && conf.Bucket != bucket {
+		return "", "", false
+	}
+	if !strings.HasPrefix(key, conf.Prefix) {
+		return "", "", false
+	}
+	return key, bucket, true
+}
+
+type gcpCloudStoragePubSubTargetReader struct {
+	conf    input.GCPCloudStorageConfig
+	log     log.Modular
+	storage *storage.Client
+	client  *pubsub.Client
+
+	msgsChan  chan *pubsub.Message
+	closeFunc context.CancelFunc
+}
+
+func newGCPCloudStoragePubSubTargetReader(
+	ctx context.Context,
+	conf input.GCPCloudStorageConfig,
+	log log.Modular,
+	storageClient *storage.Client,
+) (*gcpCloudStoragePubSubTargetReader, error) {
+	client, err := pubsub.NewClient(ctx, conf.PubSub.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := client.Subscription(conf.PubSub.Subscription)
+	sub.ReceiveSettings.MaxOutstandingMessages = conf.PubSub.MaxOutstandingMessages
+
+	subCtx, cancel := context.WithCancel(context.Background())
+	msgsChan := make(chan *pubsub.Message, 1)
+
+	go func() {
+		rerr := sub.Receive(subCtx, func(ctx context.Context, m *pubsub.Message) {
+			select {
+			case msgsChan <- m:
+			case <-ctx.Done():
+				m.Nack()
+			}
+		})
+		if rerr != nil && rerr != context.Canceled {
+			log.Errorf("Subscription error: %v\n", rerr)
+		}
+		close(msgsChan)
+	}()
+
+	log.Infof("Receiving GCS notifications from project '%v' and subscription '%v'\n", conf.PubSub.Project, conf.PubSub.Subscription)
+	return &gcpCloudStoragePubSubTargetReader{
+		conf:      conf,
+		log:       log,
+		storage:   storageClient,
+		client:    client,
+		msgsChan:  msgsChan,
+		closeFunc: cancel,
+	}, nil
+}
+
+func (r *gcpCloudStoragePubSubTargetReader) Pop(ctx context.Context) (*gcpCloudStorageObjectTarget, error) {
+	for {
+		var m *pubsub.Message
+		var open bool
+		select {
+		case m, open = <-r.msgsChan:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !open {
+			return nil, component.ErrNotConnected
+		}
+
+		key, bucket, ok := gcpCloudStorageTargetFromNotification(r.conf, m.Attributes)
+		if !ok {
+			r.log.Tracef("Ignoring notification of event type '%v' for object '%v'\n", m.Attributes["eventType"], m.Attributes["objectId"])
+			m.Ack()
+			continue
+		}
+
+		ackFn := deleteGCPCloudStorageObjectAckFn(
+			r.storage.Bucket(bucket), key, r.conf.DeleteObjects,
+			func(_ context.Context, err error) error {
+				if err != nil {
+					m.Nack()
+				} else {
+					m.Ack()
+				}
+				return nil
+			},
+		)
+		target := newGCPCloudStorageObjectTarget(key, bucket, ackFn)
+		target.skipFn = m.Ack
+		return target, nil
+	}
+}
+
+func (r *gcpCloudStoragePubSubTargetReader) Close(context.Context) error {
+	r.closeFunc()
+	return r.client.Close()
+}
+
+//------------------------------------------------------------------------------
+
 // gcpCloudStorage is a benthos reader.Type implementation that reads messages
 // from a Google Cloud Storage bucket.
 type gcpCloudStorageInput struct {
 	conf input.GCPCloudStorageConfig
 
 	objectScannerCtor codec.ReaderConstructor
-	keyReader         *gcpCloudStorageTargetReader
+	keyReader         gcpCloudStorageObjectTargetReader
 
 	objectMut sync.Mutex
 	object    *gcpCloudStoragePendingObject
@@ -233,13 +379,22 @@ func newGCPCloudStorageInput(conf input.GCPCloudStorageConfig, log log.Modular,
 // ConnectWithContext attempts to establish a connection to the target Google
 // Cloud Storage bucket.
 func (g *gcpCloudStorageInput) ConnectWithContext(ctx context.Context) error {
+	if g.keyReader != nil {
+		_ = g.keyReader.Close(ctx)
+		g.keyReader = nil
+	}
+
 	var err error
 	g.client, err = storage.NewClient(context.Background())
 	if err != nil {
 		return err
 	}
 
-	g.keyReader, err = newGCPCloudStorageTargetReader(ctx, g.conf, g.log, g.client.Bucket(g.conf.Bucket))
+	if g.conf.PubSub.Subscription != "" {
+		g.keyReader, err = newGCPCloudStoragePubSubTargetReader(ctx, g.conf, g.log, g.client)
+	} else {
+		g.keyReader, err = newGCPCloudStorageTargetReader(ctx, g.conf, g.log, g.client.Bucket(g.conf.Bucket))
+	}
 	return err
 }
 
@@ -248,21 +403,30 @@ func (g *gcpCloudStorageInput) getObjectTarget(ctx context.Context) (*gcpCloudSt
 		return g.object, nil
 	}
 
-	target, err := g.keyReader.Pop(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	objReference := g.client.Bucket(g.conf.Bucket).Object(target.key)
+	var target *gcpCloudStorageObjectTarget
+	var objAttributes *storage.ObjectAttrs
+	var objReader *storage.Reader
+	var err error
+	for {
+		if target, err = g.keyReader.Pop(ctx); err != nil {
+			return nil, err
+		}
 
-	objAttributes, err := objReference.Attrs(ctx)
-	if err != nil {
-		_ = target.ackFn(ctx, err)
-		return nil, err
-	}
+		objReference := g.client.Bucket(target.bucket).Object(target.key)
+		if objAttributes, err = objReference.Attrs(ctx); err == nil {
+			objReader, err = objReference.NewReader(context.Background())
+		}
+		if err == nil {
+			break
+		}
 
-	objReader, err := objReference.NewReader(context.Background())
-	if err != nil {
+		// Notifications of objects that have since been deleted would
+		// otherwise be redelivered indefinitely.
+		if errors.Is(err, storage.ErrObjectNotExist) && target.skipFn != nil {
+			g.log.Warnf("Skipping notification of object '%v' in bucket '%v' as it no longer exists\n", target.key, target.bucket)
+			target.skipFn()
+			continue
+		}
 		_ = target.ackFn(ctx, err)
 		return nil, err
 	}
@@ -358,6 +522,11 @@ func (g *gcpCloudStorageInput) CloseAsync() {
 			g.object = nil
 		}
 
+		if g.keyReader != nil {
+			_ = g.keyReader.Close(context.Background())
+			g.keyReader = nil
+		}
+
 		if g.client != nil {
 			g.client.Close()
 			g.client = nil
@@ -0,0 +1,181 @@
+package gcp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+
+	"github.com/benthosdev/benthos/v4/internal/component/metrics"
+	"github.com/benthosdev/benthos/v4/internal/log"
+	"github.com/benthosdev/benthos/v4/internal/old/input"
+)
+
+func TestGCPCloudStorageTargetFromNotification(t *testing.T) {
+	tests := []struct {
+		name   string
+		bucket string
+		prefix string
+		attrs  map[string]string
+		key    string
+		ok     bool
+	}{
+		{
+			name: "finalize event",
+			attrs: map[string]string{
+				"eventType": "OBJECT_FINALIZE",
+				"bucketId":  "foo",
+				"objectId":  "bar/baz.json",
+			},
+			key: "bar/baz.json",
+			ok:  true,
+		},
+		{
+			name: "delete event",
+			attrs: map[string]string{
+				"eventType": "OBJECT_DELETE",
+				"bucketId":  "foo",
+				"objectId":  "bar/baz.json",
+			},
+		},
+		{
+			name: "missing object",
+			attrs: map[string]string{
+				"eventType": "OBJECT_FINALIZE",
+				"bucketId":  "foo",
+			},
+		},
+		{
+			name:   "matching bucket and prefix",
+			bucket: "foo",
+			prefix: "bar/",
+			attrs: map[string]string{
+				"eventType": "OBJECT_FINALIZE",
+				"bucketId":  "foo",
+				"objectId":  "bar/baz.json",
+			},
+			key: "bar/baz.json",
+			ok:  true,
+		},
+		{
+			name:   "different bucket",
+			bucket: "qux",
+			attrs: map[string]string{
+				"eventType": "OBJECT_FINALIZE",
+				"bucketId":  "foo",
+				"objectId":  "bar/baz.json",
+			},
+		},
+		{
+			name:   "different prefix",
+			prefix: "qux/",
+			attrs: map[string]string{
+				"eventType": "OBJECT_FINALIZE",
+				"bucketId":  "foo",
+				"objectId":  "bar/baz.json",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf := input.NewGCPCloudStorageConfig()
+			conf.Bucket = test.bucket
+			conf.Prefix = test.prefix
+
+			key, bucket, ok := gcpCloudStorageTargetFromNotification(conf, test.attrs)
+			assert.Equal(t, test.ok, ok)
+			if test.ok {
+				assert.Equal(t, test.key, key)
+				assert.Equal(t, test.attrs["bucketId"], bucket)
+			}
+		})
+	}
+}
+
+type gcpCloudStorageMockTargetReader struct {
+	targets []*gcpCloudStorageObjectTarget
+}
+
+func (r *gcpCloudStorageMockTargetReader) Pop(ctx context.Context) (*gcpCloudStorageObjectTarget, error) {
+	if len(r.targets) == 0 {
+		return nil, io.EOF
+	}
+	target := r.targets[0]
+	r.targets = r.targets[1:]
+	return target, nil
+}
+
+func (r *gcpCloudStorageMockTargetReader) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestGCPCloudStorageSkipsMissingObjects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/storage/v1/b/foo/o/exists":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"bucket":"foo","name":"exists","contentType":"text/plain"}`))
+			return
+		case "/foo/exists":
+			_, _ = w.Write([]byte("hello world"))
+			return
+		}
+		http.Error(w, `{"error":{"code":404,"message":"Not Found"}}`, http.StatusNotFound)
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(ts.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+
+	var skipped, nacked []string
+	newTarget := func(key string, skippable bool) *gcpCloudStorageObjectTarget {
+		target := newGCPCloudStorageObjectTarget(key, "foo", func(_ context.Context, err error) error {
+			if err != nil {
+				nacked = append(nacked, key)
+			}
+			return nil
+		})
+		if skippable {
+			target.skipFn = func() {
+				skipped = append(skipped, key)
+			}
+		}
+		return target
+	}
+
+	conf := input.NewGCPCloudStorageConfig()
+	g, err := newGCPCloudStorageInput(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	g.client = client
+	g.keyReader = &gcpCloudStorageMockTargetReader{
+		targets: []*gcpCloudStorageObjectTarget{
+			newTarget("deleted", true),
+			newTarget("exists", true),
+			newTarget("missing", false),
+		},
+	}
+
+	msg, ackFn, err := g.ReadWithContext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(msg.Get(0).Get()))
+	assert.Equal(t, "exists", msg.Get(0).MetaGet("gcs_key"))
+	require.NoError(t, ackFn(context.Background(), nil))
+
+	// Objects of targets that cannot be skipped are nacked.
+	_, _, err = g.ReadWithContext(context.Background())
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"deleted"}, skipped)
+	assert.Equal(t, []string{"missing"}, nacked)
+}
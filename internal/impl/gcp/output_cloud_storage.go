@@ -3,6 +3,7 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"io"
 	"path"
 	"sync"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/batch/policy"
 	"github.com/benthosdev/benthos/v4/internal/bloblang/field"
 	"github.com/benthosdev/benthos/v4/internal/bundle"
+	"github.com/benthosdev/benthos/v4/internal/codec"
 	"github.com/benthosdev/benthos/v4/internal/component"
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	ioutput "github.com/benthosdev/benthos/v4/internal/component/output"
@@ -21,6 +23,7 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/message"
 	"github.com/benthosdev/benthos/v4/internal/old/output"
 	"github.com/benthosdev/benthos/v4/internal/old/output/writer"
+	"github.com/benthosdev/benthos/v4/internal/shutdown"
 )
 
 func init() {
@@ -58,6 +61,25 @@ Metadata fields on messages will be sent as headers, in order to mutate these va
 By default Benthos will use a shared credentials file when connecting to GCP
 services. You can find out more [in this document](/docs/guides/cloud/gcp).
 
+### Streaming
+
+When `+"`streaming.enabled`"+` is set to `+"`true`"+` messages are written through a codec into long lived objects using resumable uploads, where an object is kept open for each unique value of `+"`path`"+` and committed once it reaches `+"`streaming.max_bytes`"+` in size or `+"`streaming.max_age`"+` in age. This allows you to, for example, produce newline delimited objects partitioned by the hour without batching messages in memory:
+
+`+"```yaml"+`
+output:
+  gcp_cloud_storage:
+    bucket: TODO
+    path: dt=${!timestamp_utc("2006-01-02T15")}/part.jsonl
+    max_in_flight: 1000
+    streaming:
+      enabled: true
+      codec: lines
+      max_bytes: 104857600
+      max_age: 5m
+`+"```"+`
+
+The final name of each object is the path with a unix nanosecond timestamp inserted before the extension, e.g. `+"`dt=2022-01-02T15/part-1641135600000000000.jsonl`"+`. Messages are only acknowledged once the object containing them has been committed, and therefore `+"`max_in_flight`"+` should be large enough to fill an object within `+"`streaming.max_age`"+`. Object attributes such as the content type and metadata are taken from the first message written to each object, and the field `+"`collision_mode`"+` is ignored.
+
 ### Batching
 
 It's common to want to upload messages to Google Cloud Storage as batched
@@ -117,6 +139,7 @@ output:
 				).AtVersion("3.53.0"),
 			docs.FieldString("content_encoding", "An optional content encoding to set for each object.").IsInterpolated().Advanced(),
 			docs.FieldInt("chunk_size", "An optional chunk size which controls the maximum number of bytes of the object that the Writer will attempt to send to the server in a single request. If ChunkSize is set to zero, chunking will be disabled.").Advanced(),
+			codec.StreamingDocs.AtVersion("4.0.0"),
 			docs.FieldInt("max_in_flight", "The maximum number of messages to have in flight at a given time. Increase this to improve throughput."),
 			policy.FieldSpec(),
 		).ChildDefaultAndTypesFromStruct(output.NewGCPCloudStorageConfig()),
@@ -135,8 +158,11 @@ type gcpCloudStorageOutput struct {
 	client  *storage.Client
 	connMut sync.RWMutex
 
-	log   log.Modular
-	stats metrics.Type
+	streamer *codec.ObjectStreamer
+
+	log     log.Modular
+	stats   metrics.Type
+	shutSig *shutdown.Signaller
 }
 
 // newGCPCloudStorageOutput creates a new GCP Cloud Storage bucket writer.Type.
@@ -147,14 +173,20 @@ func newGCPCloudStorageOutput(
 	stats metrics.Type,
 ) (*gcpCloudStorageOutput, error) {
 	g := &gcpCloudStorageOutput{
-		conf:  conf,
-		log:   log,
-		stats: stats,
+		conf:    conf,
+		log:     log,
+		stats:   stats,
+		shutSig: shutdown.NewSignaller(),
 	}
 
 	bEnv := mgr.BloblEnvironment()
 
 	var err error
+	if conf.Streaming.Enabled {
+		if g.streamer, err = codec.NewObjectStreamer(conf.Streaming); err != nil {
+			return nil, fmt.Errorf("failed to create streaming writer: %w", err)
+		}
+	}
 	if g.path, err = bEnv.NewField(conf.Path); err != nil {
 		return nil, fmt.Errorf("failed to parse path expression: %v", err)
 	}
@@ -195,6 +227,10 @@ func (g *gcpCloudStorageOutput) WriteWithContext(ctx context.Context, msg *messa
 		return component.ErrNotConnected
 	}
 
+	if g.streamer != nil {
+		return g.writeStreamed(ctx, client, msg)
+	}
+
 	return writer.IterateBatchedSend(msg, func(i int, p *message.Part) error {
 		metadata := map[string]string{}
 		_ = p.MetaIter(func(k, v string) error {
@@ -258,21 +294,85 @@ func (g *gcpCloudStorageOutput) WriteWithContext(ctx context.Context, msg *messa
 	})
 }
 
+// gcpCloudStorageStreamedObject writes to a resumable upload, which is
+// finalised once the object is closed.
+type gcpCloudStorageStreamedObject struct {
+	w      *storage.Writer
+	cancel func()
+}
+
+func (o *gcpCloudStorageStreamedObject) Write(p []byte) (int, error) {
+	return o.w.Write(p)
+}
+
+func (o *gcpCloudStorageStreamedObject) Close() error {
+	err := o.w.Close()
+	o.cancel()
+	return err
+}
+
+func (o *gcpCloudStorageStreamedObject) CloseWithError(err error) error {
+	// Cancelling the context before closing abandons the upload.
+	o.cancel()
+	_ = o.w.Close()
+	return err
+}
+
+func (g *gcpCloudStorageOutput) writeStreamed(ctx context.Context, client *storage.Client, msg *message.Batch) error {
+	waits := make([]func(context.Context) error, msg.Len())
+	if err := msg.Iter(func(i int, p *message.Part) error {
+		var err error
+		waits[i], err = g.streamer.Write(ctx, g.path.String(i, msg), p, func(key string) (io.WriteCloser, error) {
+			metadata := map[string]string{}
+			_ = p.MetaIter(func(k, v string) error {
+				metadata[k] = v
+				return nil
+			})
+
+			uploadCtx, cancel := context.WithCancel(context.Background())
+			w := client.Bucket(g.conf.Bucket).Object(key).NewWriter(uploadCtx)
+			w.ChunkSize = g.conf.ChunkSize
+			w.ContentType = g.contentType.String(i, msg)
+			w.ContentEncoding = g.contentEncoding.String(i, msg)
+			w.Metadata = metadata
+			return &gcpCloudStorageStreamedObject{w: w, cancel: cancel}, nil
+		})
+		return err
+	}); err != nil {
+		return err
+	}
+	return writer.IterateBatchedSend(msg, func(i int, _ *message.Part) error {
+		return waits[i](ctx)
+	})
+}
+
 // CloseAsync begins cleaning up resources used by this reader asynchronously.
 func (g *gcpCloudStorageOutput) CloseAsync() {
 	go func() {
+		if g.streamer != nil {
+			if err := g.streamer.Close(context.Background()); err != nil {
+				g.log.Errorf("Failed to commit streamed objects: %v\n", err)
+			}
+		}
+
 		g.connMut.Lock()
 		if g.client != nil {
 			g.client.Close()
 			g.client = nil
 		}
 		g.connMut.Unlock()
+		g.shutSig.ShutdownComplete()
 	}()
 }
 
 // WaitForClose will block until either the reader is closed or a specified
 // timeout occurs.
-func (g *gcpCloudStorageOutput) WaitForClose(time.Duration) error {
+func (g *gcpCloudStorageOutput) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-g.shutSig.HasClosedChan():
+	case <-time.After(timeout):
+		return component.ErrTimeout
+	}
 	return nil
 }
 
@@ -83,13 +83,43 @@ potentially disproportionate message allocations to those outputs. Each message
 is sent to a single output, which is determined by allowing outputs to claim
 messages as soon as they are able to process them. This results in certain
 faster outputs potentially processing more messages at the cost of slower
-outputs.`,
+outputs.
+
+### ` + "`consistent_hash`" + `
+
+With the consistent hash pattern each message is sent to a single output that is
+determined by the value of the ` + "`key`" + ` field, where messages that share a
+key are always sent to the same output and therefore their ordering is
+preserved. Messages of a batch are split by their key and the batch is only
+acknowledged once all outputs have responded. If an output fails to send a
+message then the error is propagated back to the source of the message.
+
+Keys are mapped onto outputs with a hash ring where each output is given a
+number of ` + "`virtual_nodes`" + `, which means that adding or removing an
+output only remaps a minimal share of keys. Outputs are placed on the ring by
+their label, or the name of the resource for resource outputs, and otherwise by
+their index within ` + "`outputs`" + `. Therefore removing an output without a
+label or resource from anywhere other than the end of the list also remaps the
+keys of the outputs that follow it:
+
+` + "```yaml" + `
+output:
+  broker:
+    pattern: consistent_hash
+    key: ${! json("user.id") }
+    outputs:
+      - resource: foo
+      - resource: bar
+      - resource: baz
+` + "```" + ``,
 		Config: docs.FieldComponent().WithChildren(
 			docs.FieldInt("copies", "The number of copies of each configured output to spawn.").Advanced().HasDefault(1),
 			docs.FieldString("pattern", "The brokering pattern to use.").HasOptions(
-				"fan_out", "fan_out_sequential", "round_robin", "greedy",
+				"fan_out", "fan_out_sequential", "round_robin", "greedy", "consistent_hash",
 			).HasDefault("fan_out"),
 			docs.FieldOutput("outputs", "A list of child outputs to broker.").Array().HasDefault([]interface{}{}),
+			docs.FieldInterpolatedString("key", "The key used to allocate messages to outputs with the `consistent_hash` pattern.", `${! meta("kafka_key") }`, `${! json("user.id") }`).HasDefault("").AtVersion("4.0.0"),
+			docs.FieldInt("virtual_nodes", "The number of points each output is given on the hash ring of the `consistent_hash` pattern. Higher values distribute keys more evenly across outputs.").Advanced().HasDefault(128).AtVersion("4.0.0"),
 			policy.FieldSpec(),
 		),
 		Categories: []string{
@@ -167,6 +197,8 @@ func newBroker(conf ooutput.Config, mgr bundle.NewManagement, pipelines ...proce
 		b, err = newRoundRobinOutputBroker(outputs)
 	case "greedy":
 		b, err = newGreedyOutputBroker(outputs)
+	case "consistent_hash":
+		b, err = newConsistentHashFromConfig(conf.Broker, mgr, outputs, consistentHashNames(outputConfs, conf.Broker.Copies))
 	default:
 		return nil, fmt.Errorf("broker pattern was not recognised: %v", conf.Broker.Pattern)
 	}
@@ -183,3 +215,46 @@ func newBroker(conf ooutput.Config, mgr bundle.NewManagement, pipelines ...proce
 	}
 	return b, err
 }
+
+// consistentHashNames returns the names that each output of a consistent_hash
+// broker is placed on the hash ring with, which is the label of the output, or
+// the name of the resource for resource outputs, and otherwise its index.
+func consistentHashNames(outputConfs []ooutput.Config, copies int) []string {
+	names := make([]string, 0, len(outputConfs)*copies)
+	for j := 0; j < copies; j++ {
+		for i, oConf := range outputConfs {
+			name := strconv.Itoa(i)
+			if oConf.Label != "" {
+				name = "label:" + oConf.Label
+			} else if oConf.Type == ooutput.TypeResource {
+				name = "resource:" + oConf.Resource
+			}
+			if j > 0 {
+				name += "#" + strconv.Itoa(j)
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func newConsistentHashFromConfig(conf ooutput.BrokerConfig, mgr bundle.NewManagement, outputs []output.Streamed, names []string) (output.Streamed, error) {
+	if conf.Key == "" {
+		return nil, errors.New("a key must be specified for the consistent_hash pattern")
+	}
+	if conf.VirtualNodes <= 0 {
+		return nil, errors.New("virtual_nodes must be greater than 0")
+	}
+	key, err := mgr.BloblEnvironment().NewField(conf.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key expression: %v", err)
+	}
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		if _, exists := seen[name]; exists {
+			return nil, fmt.Errorf("outputs of the consistent_hash pattern must have unique labels or resources, found duplicate %v", name)
+		}
+		seen[name] = struct{}{}
+	}
+	return newConsistentHashOutputBroker(outputs, names, key, conf.VirtualNodes)
+}
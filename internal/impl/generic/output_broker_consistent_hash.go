@@ -0,0 +1,253 @@
+package generic
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+
+	"github.com/benthosdev/benthos/v4/internal/batch"
+	"github.com/benthosdev/benthos/v4/internal/bloblang/field"
+	"github.com/benthosdev/benthos/v4/internal/component"
+	"github.com/benthosdev/benthos/v4/internal/component/output"
+	"github.com/benthosdev/benthos/v4/internal/message"
+	"github.com/benthosdev/benthos/v4/internal/shutdown"
+)
+
+// hashRing maps keys onto a set of nodes, where each node is given a number of
+// virtual points on the ring so that keys are spread evenly and adding or
+// removing a node only remaps the keys that fall between its own points.
+// Points are derived from the name of a node rather than its index so that
+// removing a node does not move the points of other nodes.
+type hashRing struct {
+	points []uint64
+	owners []int
+}
+
+func newHashRing(nodes []string, virtualNodes int) *hashRing {
+	type point struct {
+		hash  uint64
+		owner int
+	}
+
+	points := make([]point, 0, len(nodes)*virtualNodes)
+	for i, name := range nodes {
+		for v := 0; v < virtualNodes; v++ {
+			points = append(points, point{
+				hash:  xxhash.ChecksumString64(name + "-" + strconv.Itoa(v)),
+				owner: i,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].owner < points[j].owner
+		}
+		return points[i].hash < points[j].hash
+	})
+
+	r := &hashRing{
+		points: make([]uint64, len(points)),
+		owners: make([]int, len(points)),
+	}
+	for i, p := range points {
+		r.points[i] = p.hash
+		r.owners[i] = p.owner
+	}
+	return r
+}
+
+// get returns the node that owns a key, which is the owner of the first point
+// on the ring at or after the hash of the key.
+func (r *hashRing) get(key string) int {
+	h := xxhash.ChecksumString64(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i]
+}
+
+//------------------------------------------------------------------------------
+
+type consistentHashOutputBroker struct {
+	transactions <-chan message.Transaction
+
+	key  *field.Expression
+	ring *hashRing
+
+	outputTSChans []chan message.Transaction
+	outputs       []output.Streamed
+
+	shutSig *shutdown.Signaller
+}
+
+func newConsistentHashOutputBroker(outputs []output.Streamed, names []string, key *field.Expression, virtualNodes int) (*consistentHashOutputBroker, error) {
+	o := &consistentHashOutputBroker{
+		key:     key,
+		ring:    newHashRing(names, virtualNodes),
+		outputs: outputs,
+		shutSig: shutdown.NewSignaller(),
+	}
+	o.outputTSChans = make([]chan message.Transaction, len(o.outputs))
+	for i := range o.outputTSChans {
+		o.outputTSChans[i] = make(chan message.Transaction)
+		if err := o.outputs[i].Consume(o.outputTSChans[i]); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func (o *consistentHashOutputBroker) Consume(ts <-chan message.Transaction) error {
+	if o.transactions != nil {
+		return component.ErrAlreadyStarted
+	}
+	o.transactions = ts
+
+	go o.loop()
+	return nil
+}
+
+func (o *consistentHashOutputBroker) Connected() bool {
+	for _, out := range o.outputs {
+		if !out.Connected() {
+			return false
+		}
+	}
+	return true
+}
+
+// dispatch writes the messages of a transaction to the outputs that own their
+// keys, where the transaction is acknowledged once all outputs have responded.
+// Returns false if the broker was closed before all messages were written.
+func (o *consistentHashOutputBroker) dispatch(ts message.Transaction) bool {
+	group, trackedMsg := message.NewSortGroup(ts.Payload)
+
+	outputTargets := make([][]*message.Part, len(o.outputs))
+	_ = trackedMsg.Iter(func(i int, p *message.Part) error {
+		target := o.ring.get(o.key.String(i, trackedMsg))
+		outputTargets[target] = append(outputTargets[target], p)
+		return nil
+	})
+
+	var errLock sync.Mutex
+	var batchErr *batch.Error
+	var generalErr error
+	setErrForPart := func(p *message.Part, err error) {
+		errLock.Lock()
+		defer errLock.Unlock()
+
+		index := group.GetIndex(p)
+		if index == -1 {
+			generalErr = err
+			return
+		}
+		if batchErr == nil {
+			batchErr = batch.NewError(trackedMsg, err)
+		}
+		batchErr.Failed(index, err)
+	}
+	getErr := func() error {
+		errLock.Lock()
+		defer errLock.Unlock()
+		if batchErr != nil {
+			return batchErr
+		}
+		return generalErr
+	}
+
+	var pendingResponses int64
+	for _, parts := range outputTargets {
+		if len(parts) > 0 {
+			pendingResponses++
+		}
+	}
+	if pendingResponses == 0 {
+		ctx, done := o.shutSig.CloseAtLeisureCtx(context.Background())
+		defer done()
+		_ = ts.Ack(ctx, nil)
+		return true
+	}
+
+	for target, parts := range outputTargets {
+		if len(parts) == 0 {
+			continue
+		}
+
+		msgCopy := message.QuickBatch(nil)
+		msgCopy.SetAll(parts)
+
+		select {
+		case o.outputTSChans[target] <- message.NewTransactionFunc(msgCopy, func(ctx context.Context, err error) error {
+			if err != nil {
+				if bErr, ok := err.(*batch.Error); ok {
+					bErr.WalkParts(func(_ int, p *message.Part, e error) bool {
+						if e != nil {
+							setErrForPart(p, e)
+						}
+						return true
+					})
+				} else {
+					_ = msgCopy.Iter(func(_ int, p *message.Part) error {
+						setErrForPart(p, err)
+						return nil
+					})
+				}
+			}
+			if atomic.AddInt64(&pendingResponses, -1) <= 0 {
+				return ts.Ack(ctx, getErr())
+			}
+			return nil
+		}):
+		case <-o.shutSig.CloseAtLeisureChan():
+			return false
+		}
+	}
+	return true
+}
+
+func (o *consistentHashOutputBroker) loop() {
+	defer func() {
+		for _, c := range o.outputTSChans {
+			close(c)
+		}
+		closeAllOutputs(o.outputs)
+		o.shutSig.ShutdownComplete()
+	}()
+
+	for {
+		var ts message.Transaction
+		var open bool
+		select {
+		case ts, open = <-o.transactions:
+			if !open {
+				return
+			}
+		case <-o.shutSig.CloseAtLeisureChan():
+			return
+		}
+		if !o.dispatch(ts) {
+			return
+		}
+	}
+}
+
+func (o *consistentHashOutputBroker) CloseAsync() {
+	o.shutSig.CloseAtLeisure()
+}
+
+func (o *consistentHashOutputBroker) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-o.shutSig.HasClosedChan():
+	case <-time.After(timeout):
+		return component.ErrTimeout
+	}
+	return nil
+}
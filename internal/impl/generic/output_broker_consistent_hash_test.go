@@ -0,0 +1,199 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/batch"
+	"github.com/benthosdev/benthos/v4/internal/bloblang"
+	"github.com/benthosdev/benthos/v4/internal/bundle"
+	bmock "github.com/benthosdev/benthos/v4/internal/bundle/mock"
+	"github.com/benthosdev/benthos/v4/internal/component/output"
+	"github.com/benthosdev/benthos/v4/internal/manager/mock"
+	"github.com/benthosdev/benthos/v4/internal/message"
+	ooutput "github.com/benthosdev/benthos/v4/internal/old/output"
+)
+
+var _ output.Streamed = &consistentHashOutputBroker{}
+
+func TestConsistentHashBrokerConfigErrs(t *testing.T) {
+	conf := ooutput.NewConfig()
+	conf.Type = "broker"
+	conf.Broker.Pattern = "consistent_hash"
+	conf.Broker.Outputs = append(conf.Broker.Outputs, ooutput.NewConfig(), ooutput.NewConfig())
+
+	_, err := bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.Error(t, err)
+
+	conf.Broker.Key = `${! json("key") `
+	_, err = bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.Error(t, err)
+
+	conf.Broker.Key = `${! json("key") }`
+	conf.Broker.VirtualNodes = 0
+	_, err = bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.Error(t, err)
+
+	conf.Broker.VirtualNodes = 10
+	o, err := bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.NoError(t, err)
+	o.CloseAsync()
+}
+
+func TestHashRingDistribution(t *testing.T) {
+	ring := newHashRing([]string{"a", "b", "c", "d"}, 128)
+
+	counts := make([]int, 4)
+	for i := 0; i < 10000; i++ {
+		counts[ring.get(fmt.Sprintf("key-%v", i))]++
+	}
+	for i, c := range counts {
+		assert.Greater(t, c, 1500, "node %v", i)
+		assert.Less(t, c, 3500, "node %v", i)
+	}
+}
+
+func TestHashRingMinimalRemap(t *testing.T) {
+	before := newHashRing([]string{"a", "b", "c", "d"}, 128)
+	after := newHashRing([]string{"a", "b", "c", "d", "e"}, 128)
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%v", i)
+		from, to := before.get(key), after.get(key)
+		if from != to {
+			// Keys only ever move onto the new node.
+			assert.Equal(t, 4, to, key)
+			moved++
+		}
+	}
+	assert.Greater(t, moved, 1000)
+	assert.Less(t, moved, 3000)
+}
+
+func TestHashRingRemoveMiddle(t *testing.T) {
+	beforeNames, afterNames := []string{"a", "b", "c", "d"}, []string{"a", "c", "d"}
+	before, after := newHashRing(beforeNames, 128), newHashRing(afterNames, 128)
+
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%v", i)
+		from, to := beforeNames[before.get(key)], afterNames[after.get(key)]
+		if from != "b" {
+			// Only keys of the removed node are moved.
+			assert.Equal(t, from, to, key)
+		}
+	}
+}
+
+func TestConsistentHashNames(t *testing.T) {
+	labelled := ooutput.NewConfig()
+	labelled.Label = "foo"
+
+	res := ooutput.NewConfig()
+	res.Type = ooutput.TypeResource
+	res.Resource = "bar"
+
+	confs := []ooutput.Config{labelled, res, ooutput.NewConfig()}
+	assert.Equal(t, []string{
+		"label:foo", "resource:bar", "2",
+		"label:foo#1", "resource:bar#1", "2#1",
+	}, consistentHashNames(confs, 2))
+}
+
+func TestConsistentHashBroker(t *testing.T) {
+	tCtx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	key, err := bloblang.GlobalEnvironment().NewField(`${! json("key") }`)
+	require.NoError(t, err)
+
+	mockOutputs := []*mock.OutputChanneled{{}, {}, {}}
+	outputs := []output.Streamed{}
+	for _, o := range mockOutputs {
+		outputs = append(outputs, o)
+	}
+
+	names := []string{"0", "1", "2"}
+	oTM, err := newConsistentHashOutputBroker(outputs, names, key, 128)
+	require.NoError(t, err)
+
+	readChan := make(chan message.Transaction)
+	require.NoError(t, oTM.Consume(readChan))
+
+	ring := newHashRing(names, 128)
+	keys := []string{"foo", "bar", "baz", "buz", "qux", "quz"}
+
+	var content [][]byte
+	expected := make([][]string, 3)
+	for i, k := range keys {
+		doc := fmt.Sprintf(`{"key":%q,"n":%v}`, k, i)
+		content = append(content, []byte(doc))
+		target := ring.get(k)
+		expected[target] = append(expected[target], doc)
+	}
+
+	errFailed := errors.New("failed")
+	resChan := make(chan error, 1)
+	select {
+	case readChan <- message.NewTransaction(message.QuickBatch(content), resChan):
+	case <-tCtx.Done():
+		t.Fatal("timed out")
+	}
+
+	var failedDocs []string
+	for i, mOut := range mockOutputs {
+		if len(expected[i]) == 0 {
+			continue
+		}
+
+		var ts message.Transaction
+		select {
+		case ts = <-mOut.TChan:
+		case <-tCtx.Done():
+			t.Fatal("timed out")
+		}
+
+		var docs []string
+		_ = ts.Payload.Iter(func(_ int, p *message.Part) error {
+			docs = append(docs, string(p.Get()))
+			return nil
+		})
+		assert.Equal(t, expected[i], docs)
+
+		// Fail the first output that receives messages.
+		var ackErr error
+		if failedDocs == nil {
+			failedDocs = docs
+			ackErr = errFailed
+		}
+		require.NoError(t, ts.Ack(tCtx, ackErr))
+	}
+
+	var res error
+	select {
+	case res = <-resChan:
+	case <-tCtx.Done():
+		t.Fatal("timed out")
+	}
+
+	var bErr *batch.Error
+	require.True(t, errors.As(res, &bErr))
+
+	var gotFailed []string
+	bErr.WalkParts(func(_ int, p *message.Part, err error) bool {
+		if err != nil {
+			gotFailed = append(gotFailed, string(p.Get()))
+		}
+		return true
+	})
+	assert.Equal(t, failedDocs, gotFailed)
+
+	oTM.CloseAsync()
+	require.NoError(t, oTM.WaitForClose(time.Second*5))
+}
@@ -0,0 +1,435 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/internal/bundle"
+	"github.com/benthosdev/benthos/v4/internal/component"
+	"github.com/benthosdev/benthos/v4/internal/component/metrics"
+	"github.com/benthosdev/benthos/v4/internal/component/output"
+	"github.com/benthosdev/benthos/v4/internal/docs"
+	"github.com/benthosdev/benthos/v4/internal/log"
+	"github.com/benthosdev/benthos/v4/internal/message"
+	ooutput "github.com/benthosdev/benthos/v4/internal/old/output"
+	"github.com/benthosdev/benthos/v4/internal/shutdown"
+)
+
+func init() {
+	err := bundle.AllOutputs.Add(bundle.OutputConstructorFromSimple(func(conf ooutput.Config, mgr bundle.NewManagement) (output.Streamed, error) {
+		return circuitBreakerOutputFromConfig(conf.CircuitBreaker, mgr)
+	}), docs.ComponentSpec{
+		Name:    "circuit_breaker",
+		Status:  docs.StatusExperimental,
+		Version: "4.0.0",
+		Summary: `
+Writes messages to a child output and stops doing so for a cool-down period once
+the output begins to fail, optionally diverting messages to a secondary output
+in the meantime.`,
+		Description: `
+The circuit breaker begins in a closed state where all messages are written to
+the child ` + "`output`" + `. The results of these writes are tracked, and once
+either ` + "`max_consecutive_failures`" + ` writes fail in a row or the
+proportion of failed writes within the last ` + "`error_rate_window`" + ` writes
+reaches ` + "`error_rate_threshold`" + ` the circuit is opened. Errors are
+always propagated back to the source of the message as normal.
+
+Whilst the circuit is open no messages are written to the child output until the
+` + "`cool_down`" + ` period has passed, at which point the circuit becomes
+half-open and a single message is written to the child output as a probe. If the
+probe succeeds the circuit is closed again, otherwise it is reopened for another
+cool-down period.
+
+If an ` + "`open_output`" + ` is configured then messages that arrive whilst the
+circuit is open (or whilst a probe is pending) are written to it instead,
+otherwise they are held until the child output is attempted again:
+
+` + "```yaml" + `
+output:
+  circuit_breaker:
+    max_consecutive_failures: 5
+    cool_down: 30s
+    output:
+      http_client:
+        url: http://foo:4195/post/might/become/unreachable
+    open_output:
+      file:
+        path: /usr/local/benthos/unsent.jsonl
+` + "```" + `
+
+### Metrics
+
+The state of the circuit is exposed as the gauge ` + "`circuit_breaker_state`" + `,
+where ` + "`0`" + ` is closed, ` + "`1`" + ` is half-open and ` + "`2`" + ` is
+open.`,
+		Config: docs.FieldComponent().WithChildren(
+			docs.FieldInt("max_consecutive_failures", "The number of consecutive failed writes after which the circuit is opened. Set to `0` to disable.").HasDefault(5),
+			docs.FieldFloat("error_rate_threshold", "The proportion of failed writes, between `0` and `1`, within the last `error_rate_window` writes at which the circuit is opened. Set to `0` to disable.", 0.5).HasDefault(0.0),
+			docs.FieldInt("error_rate_window", "The number of most recent writes used to calculate the error rate.").HasDefault(20).Advanced(),
+			docs.FieldString("cool_down", "The period of time to wait after the circuit is opened before the child output is probed again.").HasDefault("30s"),
+			docs.FieldOutput("output", "A child output."),
+			docs.FieldOutput("open_output", "An optional output that messages are written to whilst the circuit is open.").Optional(),
+		),
+		Categories: []string{
+			"Utility",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func circuitBreakerOutputFromConfig(conf ooutput.CircuitBreakerConfig, mgr bundle.NewManagement) (output.Streamed, error) {
+	if conf.Output == nil {
+		return nil, errors.New("cannot create circuit_breaker output without a child")
+	}
+	if conf.MaxConsecutiveFailures <= 0 && conf.ErrorRateThreshold <= 0 {
+		return nil, errors.New("at least one of max_consecutive_failures or error_rate_threshold must be set")
+	}
+	if conf.ErrorRateThreshold > 1 {
+		return nil, fmt.Errorf("error_rate_threshold must be between 0 and 1, got %v", conf.ErrorRateThreshold)
+	}
+	if conf.ErrorRateThreshold > 0 && conf.ErrorRateWindow <= 0 {
+		return nil, errors.New("error_rate_window must be greater than 0")
+	}
+
+	coolDown, err := time.ParseDuration(conf.CoolDown)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cool_down: %w", err)
+	}
+
+	oMgr := mgr.IntoPath("circuit_breaker", "output").(bundle.NewManagement)
+	primary, err := oMgr.NewOutput(*conf.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	var secondary output.Streamed
+	if conf.OpenOutput != nil {
+		sMgr := mgr.IntoPath("circuit_breaker", "open_output").(bundle.NewManagement)
+		if secondary, err = sMgr.NewOutput(*conf.OpenOutput); err != nil {
+			return nil, err
+		}
+	}
+
+	return newCircuitBreaker(conf, coolDown, primary, secondary, mgr.Logger(), mgr.Metrics()), nil
+}
+
+//------------------------------------------------------------------------------
+
+type circuitBreakerState int
+
+const (
+	circuitBreakerClosed circuitBreakerState = iota
+	circuitBreakerHalfOpen
+	circuitBreakerOpen
+)
+
+func (s circuitBreakerState) String() string {
+	switch s {
+	case circuitBreakerClosed:
+		return "closed"
+	case circuitBreakerHalfOpen:
+		return "half-open"
+	}
+	return "open"
+}
+
+type circuitBreakerRoute int
+
+const (
+	circuitBreakerRoutePrimary circuitBreakerRoute = iota
+	circuitBreakerRouteProbe
+	circuitBreakerRouteSecondary
+	circuitBreakerRouteWait
+)
+
+// circuitBreaker is an output type that stops writing to a child output for a
+// cool-down period once writes to it begin to fail.
+type circuitBreaker struct {
+	primary   output.Streamed
+	secondary output.Streamed
+
+	maxConsecutive int
+	rateThreshold  float64
+	coolDown       time.Duration
+
+	log        log.Modular
+	stateGauge metrics.StatGauge
+
+	mut          sync.Mutex
+	state        circuitBreakerState
+	generation   uint64
+	consecutive  int
+	window       []bool
+	windowIdx    int
+	windowLen    int
+	windowFailed int
+	openedAt     time.Time
+	probing      bool
+	stateChanged chan struct{}
+
+	transactionsIn <-chan message.Transaction
+	primaryOut     chan message.Transaction
+	secondaryOut   chan message.Transaction
+
+	shutSig *shutdown.Signaller
+}
+
+func newCircuitBreaker(
+	conf ooutput.CircuitBreakerConfig,
+	coolDown time.Duration,
+	primary, secondary output.Streamed,
+	logger log.Modular,
+	stats metrics.Type,
+) *circuitBreaker {
+	c := &circuitBreaker{
+		primary:        primary,
+		secondary:      secondary,
+		maxConsecutive: conf.MaxConsecutiveFailures,
+		rateThreshold:  conf.ErrorRateThreshold,
+		coolDown:       coolDown,
+		log:            logger,
+		stateGauge:     stats.GetGauge("circuit_breaker_state"),
+		stateChanged:   make(chan struct{}),
+		primaryOut:     make(chan message.Transaction),
+		secondaryOut:   make(chan message.Transaction),
+		shutSig:        shutdown.NewSignaller(),
+	}
+	if c.rateThreshold > 0 {
+		c.window = make([]bool, conf.ErrorRateWindow)
+	}
+	c.stateGauge.Set(int64(circuitBreakerClosed))
+	return c
+}
+
+// setState transitions the circuit into a new state, must be called whilst
+// holding the mutex.
+func (c *circuitBreaker) setState(s circuitBreakerState) {
+	c.state = s
+	c.generation++
+	c.probing = false
+	c.consecutive = 0
+	c.windowIdx, c.windowLen, c.windowFailed = 0, 0, 0
+	if s == circuitBreakerOpen {
+		c.openedAt = time.Now()
+	}
+
+	close(c.stateChanged)
+	c.stateChanged = make(chan struct{})
+
+	c.stateGauge.Set(int64(s))
+	if s == circuitBreakerOpen {
+		c.log.Warnf("Circuit breaker is now %v\n", s)
+	} else {
+		c.log.Infof("Circuit breaker is now %v\n", s)
+	}
+}
+
+// route determines where the next message should be written. When the result
+// is to wait then the returned channel is closed on the next state change, and
+// a non-zero duration indicates how long until the cool-down period ends.
+func (c *circuitBreaker) route(now time.Time) (circuitBreakerRoute, uint64, <-chan struct{}, time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.state == circuitBreakerOpen {
+		if remaining := c.openedAt.Add(c.coolDown).Sub(now); remaining > 0 {
+			if c.secondary != nil {
+				return circuitBreakerRouteSecondary, c.generation, nil, 0
+			}
+			return circuitBreakerRouteWait, c.generation, c.stateChanged, remaining
+		}
+		c.setState(circuitBreakerHalfOpen)
+	}
+
+	if c.state == circuitBreakerHalfOpen {
+		if c.probing {
+			if c.secondary != nil {
+				return circuitBreakerRouteSecondary, c.generation, nil, 0
+			}
+			return circuitBreakerRouteWait, c.generation, c.stateChanged, 0
+		}
+		c.probing = true
+		return circuitBreakerRouteProbe, c.generation, nil, 0
+	}
+	return circuitBreakerRoutePrimary, c.generation, nil, 0
+}
+
+// recordResult updates the state of the circuit with the result of a write to
+// the child output. Results from writes made before the last state change are
+// ignored.
+func (c *circuitBreaker) recordResult(generation uint64, probe bool, err error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if probe {
+		if err == nil {
+			c.setState(circuitBreakerClosed)
+		} else {
+			c.setState(circuitBreakerOpen)
+		}
+		return
+	}
+
+	if err == nil {
+		c.consecutive = 0
+	} else {
+		c.consecutive++
+	}
+	if c.maxConsecutive > 0 && c.consecutive >= c.maxConsecutive {
+		c.setState(circuitBreakerOpen)
+		return
+	}
+
+	if c.window == nil {
+		return
+	}
+	if c.windowLen == len(c.window) {
+		if c.window[c.windowIdx] {
+			c.windowFailed--
+		}
+	} else {
+		c.windowLen++
+	}
+	c.window[c.windowIdx] = err != nil
+	if err != nil {
+		c.windowFailed++
+	}
+	c.windowIdx = (c.windowIdx + 1) % len(c.window)
+
+	if c.windowLen == len(c.window) && float64(c.windowFailed)/float64(c.windowLen) >= c.rateThreshold {
+		c.setState(circuitBreakerOpen)
+	}
+}
+
+// dispatch writes a transaction to either the child or the secondary output
+// depending on the state of the circuit, returns false if the output was shut
+// down before the transaction could be written.
+func (c *circuitBreaker) dispatch(tran message.Transaction) bool {
+	for {
+		route, generation, changed, waitFor := c.route(time.Now())
+
+		var tOut chan<- message.Transaction
+		var ackFn func(context.Context, error) error
+
+		switch route {
+		case circuitBreakerRouteWait:
+			var timer *time.Timer
+			var timerChan <-chan time.Time
+			if waitFor > 0 {
+				timer = time.NewTimer(waitFor)
+				timerChan = timer.C
+			}
+			closing := false
+			select {
+			case <-changed:
+			case <-timerChan:
+			case <-c.shutSig.CloseAtLeisureChan():
+				closing = true
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			if closing {
+				return false
+			}
+			continue
+		case circuitBreakerRouteSecondary:
+			tOut = c.secondaryOut
+			ackFn = tran.Ack
+		default:
+			probe := route == circuitBreakerRouteProbe
+			tOut = c.primaryOut
+			ackFn = func(ctx context.Context, err error) error {
+				c.recordResult(generation, probe, err)
+				return tran.Ack(ctx, err)
+			}
+		}
+
+		select {
+		case tOut <- message.NewTransactionFunc(tran.Payload, ackFn):
+			return true
+		case <-c.shutSig.CloseAtLeisureChan():
+			return false
+		}
+	}
+}
+
+func (c *circuitBreaker) loop() {
+	defer func() {
+		close(c.primaryOut)
+		close(c.secondaryOut)
+		outputs := []output.Streamed{c.primary}
+		if c.secondary != nil {
+			outputs = append(outputs, c.secondary)
+		}
+		closeAllOutputs(outputs)
+		c.shutSig.ShutdownComplete()
+	}()
+
+	for {
+		var tran message.Transaction
+		var open bool
+		select {
+		case tran, open = <-c.transactionsIn:
+			if !open {
+				return
+			}
+		case <-c.shutSig.CloseAtLeisureChan():
+			return
+		}
+
+		if !c.dispatch(tran) {
+			return
+		}
+	}
+}
+
+// Consume assigns a messages channel for the output to read.
+func (c *circuitBreaker) Consume(ts <-chan message.Transaction) error {
+	if c.transactionsIn != nil {
+		return component.ErrAlreadyStarted
+	}
+	if err := c.primary.Consume(c.primaryOut); err != nil {
+		return err
+	}
+	if c.secondary != nil {
+		if err := c.secondary.Consume(c.secondaryOut); err != nil {
+			return err
+		}
+	}
+	c.transactionsIn = ts
+	go c.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (c *circuitBreaker) Connected() bool {
+	return c.primary.Connected()
+}
+
+// CloseAsync shuts down the circuit breaker and stops processing requests.
+func (c *circuitBreaker) CloseAsync() {
+	c.shutSig.CloseAtLeisure()
+}
+
+// WaitForClose blocks until the circuit breaker has closed down.
+func (c *circuitBreaker) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-c.shutSig.HasClosedChan():
+	case <-time.After(timeout):
+		return component.ErrTimeout
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/bundle"
+	bmock "github.com/benthosdev/benthos/v4/internal/bundle/mock"
+	"github.com/benthosdev/benthos/v4/internal/component/metrics"
+	"github.com/benthosdev/benthos/v4/internal/component/output"
+	"github.com/benthosdev/benthos/v4/internal/log"
+	"github.com/benthosdev/benthos/v4/internal/manager/mock"
+	"github.com/benthosdev/benthos/v4/internal/message"
+	ooutput "github.com/benthosdev/benthos/v4/internal/old/output"
+)
+
+func TestCircuitBreakerConfigErrs(t *testing.T) {
+	conf := ooutput.NewConfig()
+	conf.Type = "circuit_breaker"
+
+	_, err := bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.Error(t, err)
+
+	oConf := ooutput.NewConfig()
+	conf.CircuitBreaker.Output = &oConf
+	conf.CircuitBreaker.CoolDown = "not a time period"
+
+	_, err = bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.Error(t, err)
+
+	conf.CircuitBreaker.CoolDown = "1s"
+	conf.CircuitBreaker.MaxConsecutiveFailures = 0
+	_, err = bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.Error(t, err)
+
+	conf.CircuitBreaker.ErrorRateThreshold = 1.5
+	_, err = bundle.AllOutputs.Init(conf, bmock.NewManager())
+	require.Error(t, err)
+}
+
+type circuitBreakerHarness struct {
+	t       *testing.T
+	cb      *circuitBreaker
+	stats   *metrics.Local
+	tChan   chan message.Transaction
+	primary *mock.OutputChanneled
+	open    *mock.OutputChanneled
+}
+
+func newCircuitBreakerHarness(t *testing.T, conf ooutput.CircuitBreakerConfig, coolDown time.Duration, withOpenOutput bool) *circuitBreakerHarness {
+	t.Helper()
+
+	h := &circuitBreakerHarness{
+		t:       t,
+		stats:   metrics.NewLocal(),
+		tChan:   make(chan message.Transaction),
+		primary: &mock.OutputChanneled{},
+	}
+
+	var secondary output.Streamed
+	if withOpenOutput {
+		h.open = &mock.OutputChanneled{}
+		secondary = h.open
+	}
+
+	h.cb = newCircuitBreaker(conf, coolDown, h.primary, secondary, log.Noop(), h.stats)
+	require.NoError(t, h.cb.Consume(h.tChan))
+
+	t.Cleanup(func() {
+		h.cb.CloseAsync()
+		require.NoError(t, h.cb.WaitForClose(time.Second*5))
+	})
+	return h
+}
+
+// send writes a message to the circuit breaker and returns the transaction
+// received by the provided output along with the channel that the result of
+// the original transaction is written to.
+func (h *circuitBreakerHarness) send(content string, out *mock.OutputChanneled) (message.Transaction, <-chan error) {
+	h.t.Helper()
+
+	resChan := make(chan error, 1)
+	select {
+	case h.tChan <- message.NewTransaction(message.QuickBatch([][]byte{[]byte(content)}), resChan):
+	case <-time.After(time.Second * 5):
+		h.t.Fatal("timed out")
+	}
+
+	var tran message.Transaction
+	select {
+	case tran = <-out.TChan:
+	case <-time.After(time.Second * 5):
+		h.t.Fatal("timed out")
+	}
+	assert.Equal(h.t, content, string(tran.Payload.Get(0).Get()))
+	return tran, resChan
+}
+
+func (h *circuitBreakerHarness) sendAndAck(content string, out *mock.OutputChanneled, err error) {
+	h.t.Helper()
+
+	tran, resChan := h.send(content, out)
+	require.NoError(h.t, tran.Ack(context.Background(), err))
+	select {
+	case res := <-resChan:
+		assert.Equal(h.t, err, res)
+	case <-time.After(time.Second * 5):
+		h.t.Fatal("timed out")
+	}
+}
+
+func (h *circuitBreakerHarness) state() int64 {
+	return h.stats.GetCounters()["circuit_breaker_state"]
+}
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	conf := ooutput.NewCircuitBreakerConfig()
+	conf.MaxConsecutiveFailures = 2
+
+	h := newCircuitBreakerHarness(t, conf, time.Millisecond*100, false)
+	errFailed := errors.New("failed")
+
+	h.sendAndAck("foo", h.primary, errFailed)
+	h.sendAndAck("bar", h.primary, nil)
+	h.sendAndAck("baz", h.primary, errFailed)
+	assert.Equal(t, int64(0), h.state())
+
+	h.sendAndAck("buz", h.primary, errFailed)
+	assert.Equal(t, int64(2), h.state())
+
+	// Messages are held until the cool-down ends, at which point one is sent as
+	// a probe.
+	start := time.Now()
+	probe, probeRes := h.send("probe1", h.primary)
+	assert.True(t, time.Since(start) >= time.Millisecond*50)
+	assert.Equal(t, int64(1), h.state())
+
+	require.NoError(t, probe.Ack(context.Background(), errFailed))
+	assert.Equal(t, errFailed, <-probeRes)
+	assert.Equal(t, int64(2), h.state())
+
+	probe, probeRes = h.send("probe2", h.primary)
+	require.NoError(t, probe.Ack(context.Background(), nil))
+	assert.NoError(t, <-probeRes)
+	assert.Equal(t, int64(0), h.state())
+
+	h.sendAndAck("qux", h.primary, nil)
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	conf := ooutput.NewCircuitBreakerConfig()
+	conf.MaxConsecutiveFailures = 0
+	conf.ErrorRateThreshold = 0.5
+	conf.ErrorRateWindow = 4
+
+	h := newCircuitBreakerHarness(t, conf, time.Hour, true)
+	errFailed := errors.New("failed")
+
+	h.sendAndAck("foo", h.primary, errFailed)
+	h.sendAndAck("bar", h.primary, nil)
+	h.sendAndAck("baz", h.primary, nil)
+	h.sendAndAck("buz", h.primary, nil)
+	h.sendAndAck("qux", h.primary, errFailed)
+	assert.Equal(t, int64(0), h.state())
+
+	h.sendAndAck("quz", h.primary, errFailed)
+	assert.Equal(t, int64(2), h.state())
+
+	// Whilst open messages are diverted to the open output, including errors.
+	h.sendAndAck("div1", h.open, nil)
+	h.sendAndAck("div2", h.open, errFailed)
+	assert.Equal(t, int64(2), h.state())
+}
+
+func TestCircuitBreakerHalfOpenDiverts(t *testing.T) {
+	conf := ooutput.NewCircuitBreakerConfig()
+	conf.MaxConsecutiveFailures = 1
+
+	h := newCircuitBreakerHarness(t, conf, time.Millisecond*10, true)
+
+	h.sendAndAck("foo", h.primary, errors.New("failed"))
+	<-time.After(time.Millisecond * 20)
+
+	probe, probeRes := h.send("probe", h.primary)
+	assert.Equal(t, int64(1), h.state())
+
+	// Whilst the probe is pending messages go to the open output.
+	h.sendAndAck("bar", h.open, nil)
+
+	require.NoError(t, probe.Ack(context.Background(), nil))
+	assert.NoError(t, <-probeRes)
+	assert.Equal(t, int64(0), h.state())
+
+	h.sendAndAck("baz", h.primary, nil)
+}
@@ -0,0 +1,95 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+)
+
+func init() {
+	// Note: The examples are run and tested from within
+	// ./internal/bloblang/query/parsed_test.go
+
+	parseLineSpec := bloblang.NewPluginSpec().
+		Category("Parsing").
+		Description("Parses an [InfluxDB line protocol](https://docs.influxdata.com/influxdb/v2.1/reference/syntax/line-protocol/) line into an object containing the fields `measurement`, `tags`, `fields` and, when the line contains one, `timestamp` as a string in RFC 3339 format. Integer fields are parsed as integers and float fields as floats.").
+		Param(bloblang.NewStringParam("precision").Description("The precision of the timestamp of the line, one of `ns`, `us`, `ms` or `s`.").Default("ns")).
+		Example("",
+			`root = content().string().parse_influx_line()`,
+			[2]string{
+				`cpu,host=foo,region=eu usage=0.64,cores=4i,ok=true 1641038400000000000`,
+				`{"fields":{"cores":4,"ok":true,"usage":0.64},"measurement":"cpu","tags":{"host":"foo","region":"eu"},"timestamp":"2022-01-01T12:00:00Z"}`,
+			}).
+		Example("",
+			`root = this.line.parse_influx_line("s").fields`,
+			[2]string{
+				`{"line":"weather,station=a temperature=12.5,summary=\"cloudy\" 1641038400"}`,
+				`{"summary":"cloudy","temperature":12.5}`,
+			})
+
+	if err := bloblang.RegisterMethodV2(
+		"parse_influx_line", parseLineSpec,
+		func(args *bloblang.ParsedParams) (bloblang.Method, error) {
+			precision, err := args.GetString("precision")
+			if err != nil {
+				return nil, err
+			}
+			switch precision {
+			case "ns", "ms", "s":
+			case "us":
+				precision = "u"
+			default:
+				return nil, fmt.Errorf("unrecognised precision: %v", precision)
+			}
+			return func(v interface{}) (interface{}, error) {
+				b, err := query.IGetBytes(v)
+				if err != nil {
+					return nil, err
+				}
+				return parseInfluxLine(b, precision)
+			}, nil
+		},
+	); err != nil {
+		panic(err)
+	}
+}
+
+func parseInfluxLine(line []byte, precision string) (interface{}, error) {
+	points, err := models.ParsePointsWithPrecision(line, time.Time{}, precision)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) != 1 {
+		return nil, errors.New("expected exactly one line protocol point")
+	}
+	p := points[0]
+
+	tags := map[string]interface{}{}
+	for _, t := range p.Tags() {
+		tags[string(t.Key)] = string(t.Value)
+	}
+
+	pFields, err := p.Fields()
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, len(pFields))
+	for k, v := range pFields {
+		fields[k] = v
+	}
+
+	obj := map[string]interface{}{
+		"measurement": string(p.Name()),
+		"tags":        tags,
+		"fields":      fields,
+	}
+	if ts := p.Time(); !ts.IsZero() {
+		obj["timestamp"] = ts.UTC().Format(time.RFC3339Nano)
+	}
+	return obj, nil
+}
@@ -0,0 +1,410 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
+	"github.com/benthosdev/benthos/v4/internal/impl/shared"
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func influxDBOutputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Writes messages as points to InfluxDB using the line protocol.").
+		Description(`
+Each message of a batch is converted into a point, where the measurement is resolved from the field ` + "`measurement`" + ` and the tags, fields and timestamp of the point are resolved from their respective mappings. The points of a batch are written to InfluxDB within a single request.
+
+Both the [InfluxDB 2.x write API](https://docs.influxdata.com/influxdb/v2.1/api/#operation/PostWrite) and the [InfluxDB 1.x write API](https://docs.influxdata.com/influxdb/v1.8/tools/api/#write-http-endpoint) are supported, which is selected with the field ` + "`api`" + `. When using the ` + "`v2`" + ` API the fields ` + "`org`" + `, ` + "`bucket`" + ` and ` + "`token`" + ` are used, and when using the ` + "`v1`" + ` API the fields ` + "`db`" + `, ` + "`retention_policy`" + `, ` + "`username`" + ` and ` + "`password`" + ` are used.
+
+### Field Types
+
+String, boolean and floating point values are written as their respective field types, and integer values are written as integer fields. Values parsed from JSON documents are floating point unless they are converted into integers within the mapping, e.g. with the ` + "`round`" + ` method. Since InfluxDB rejects writes where the type of a field differs from previous writes it is recommended that the types of fields are made explicit within the mapping. Null values are ignored, and objects or arrays are rejected.`).
+		Field(service.NewStringField("url").
+			Description("The base URL of the InfluxDB server.").
+			Example("http://localhost:8086")).
+		Field(service.NewStringEnumField("api", "v1", "v2").
+			Description("The version of the write API to use.").
+			Default("v2")).
+		Field(service.NewStringField("org").
+			Description("The organization to write to when using the `v2` API.").
+			Default("")).
+		Field(service.NewStringField("bucket").
+			Description("The bucket to write to when using the `v2` API.").
+			Default("")).
+		Field(service.NewStringField("token").
+			Description("An API token to authenticate with when using the `v2` API.").
+			Default("")).
+		Field(service.NewStringField("db").
+			Description("The database to write to when using the `v1` API.").
+			Default("")).
+		Field(service.NewStringField("retention_policy").
+			Description("An optional retention policy to write to when using the `v1` API.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringField("username").
+			Description("A username to authenticate with when using the `v1` API.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringField("password").
+			Description("A password to authenticate with when using the `v1` API.").
+			Default("").
+			Advanced()).
+		Field(service.NewInterpolatedStringField("measurement").
+			Description("The measurement of each point.").
+			Example("cpu").
+			Example(`${! meta("kafka_topic") }`)).
+		Field(service.NewBloblangField("tags_mapping").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an object of tags for each point, where values are converted into strings.").
+			Example(`root.host = this.host
+root.region = meta("region")`).
+			Optional()).
+		Field(service.NewBloblangField("fields_mapping").
+			Description("A [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an object of fields for each point, at least one field must be present.").
+			Example(`root.usage = this.cpu.usage
+root.count = this.count.round()`).
+			Default("root = this")).
+		Field(service.NewBloblangField("timestamp_mapping").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to the timestamp of each point, either as a string in RFC 3339 format or a number representing unix time in seconds. When not set the current time is used.").
+			Example(`root = this.timestamp`).
+			Optional()).
+		Field(service.NewStringEnumField("precision", "ns", "us", "ms", "s").
+			Description("The precision of timestamps written to InfluxDB.").
+			Default("ns").
+			Advanced()).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for a write request to complete.").
+			Default("5s").
+			Advanced()).
+		Field(service.NewIntField("max_in_flight").
+			Description("The maximum number of parallel message batches to have in flight at any given time.").
+			Default(64)).
+		Field(service.NewBatchPolicyField("batching")).
+		Example("Sensor Readings", "Writes sensor readings from JSON documents into the bucket `sensors`, where the sensor ID is a tag:", `
+output:
+  influxdb:
+    url: http://localhost:8086
+    org: fooorg
+    bucket: sensors
+    token: "${INFLUX_TOKEN}"
+    measurement: readings
+    tags_mapping: 'root.sensor = this.sensor_id'
+    fields_mapping: |
+      root.temperature = this.temperature
+      root.humidity = this.humidity
+    timestamp_mapping: 'root = this.read_at'
+    batching:
+      count: 100
+      period: 1s
+`)
+}
+
+func init() {
+	err := service.RegisterBatchOutput(
+		"influxdb", influxDBOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.BatchOutput, batchPolicy service.BatchPolicy, maxInFlight int, err error) {
+			if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
+				return
+			}
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			out, err = newInfluxDBOutputFromConfig(conf, mgr.Logger())
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type influxDBOutput struct {
+	writeURL string
+	token    string
+	username string
+	password string
+
+	precision   string
+	measurement *service.InterpolatedString
+	tags        *bloblang.Executor
+	fields      *bloblang.Executor
+	timestamp   *bloblang.Executor
+
+	client *http.Client
+	log    *service.Logger
+}
+
+func newInfluxDBOutputFromConfig(conf *service.ParsedConfig, log *service.Logger) (*influxDBOutput, error) {
+	i := &influxDBOutput{
+		log: log,
+	}
+
+	baseURL, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	api, err := conf.FieldString("api")
+	if err != nil {
+		return nil, err
+	}
+	if i.precision, err = conf.FieldString("precision"); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+	params := url.Values{}
+	if api == "v2" {
+		var org, bucket string
+		if org, err = conf.FieldString("org"); err != nil {
+			return nil, err
+		}
+		if bucket, err = conf.FieldString("bucket"); err != nil {
+			return nil, err
+		}
+		if org == "" || bucket == "" {
+			return nil, errors.New("both an org and bucket must be specified when using the v2 api")
+		}
+		if i.token, err = conf.FieldString("token"); err != nil {
+			return nil, err
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+		params.Set("org", org)
+		params.Set("bucket", bucket)
+		params.Set("precision", i.precision)
+	} else {
+		var db, rp string
+		if db, err = conf.FieldString("db"); err != nil {
+			return nil, err
+		}
+		if db == "" {
+			return nil, errors.New("a db must be specified when using the v1 api")
+		}
+		if rp, err = conf.FieldString("retention_policy"); err != nil {
+			return nil, err
+		}
+		if i.username, err = conf.FieldString("username"); err != nil {
+			return nil, err
+		}
+		if i.password, err = conf.FieldString("password"); err != nil {
+			return nil, err
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/write"
+		params.Set("db", db)
+		if rp != "" {
+			params.Set("rp", rp)
+		}
+		params.Set("precision", influxDBModelsPrecision(i.precision))
+	}
+	u.RawQuery = params.Encode()
+	i.writeURL = u.String()
+
+	if i.measurement, err = conf.FieldInterpolatedString("measurement"); err != nil {
+		return nil, err
+	}
+	if conf.Contains("tags_mapping") {
+		if i.tags, err = conf.FieldBloblang("tags_mapping"); err != nil {
+			return nil, err
+		}
+	}
+	if i.fields, err = conf.FieldBloblang("fields_mapping"); err != nil {
+		return nil, err
+	}
+	if conf.Contains("timestamp_mapping") {
+		if i.timestamp, err = conf.FieldBloblang("timestamp_mapping"); err != nil {
+			return nil, err
+		}
+	}
+
+	i.client = &http.Client{}
+	if i.client.Timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		i.client.Transport = &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+	}
+	return i, nil
+}
+
+// influxDBModelsPrecision converts a precision into the format expected by
+// the v1 API and the models package.
+func influxDBModelsPrecision(precision string) string {
+	if precision == "us" {
+		return "u"
+	}
+	return precision
+}
+
+//------------------------------------------------------------------------------
+
+func influxDBFieldValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string, bool, float64, int64:
+		return t, nil
+	case []byte:
+		return string(t), nil
+	case float32:
+		return float64(t), nil
+	case int:
+		return int64(t), nil
+	case int32:
+		return int64(t), nil
+	case uint32:
+		return int64(t), nil
+	case uint64:
+		if t > math.MaxInt64 {
+			return nil, fmt.Errorf("integer value %v exceeds the maximum of a signed 64 bit integer", t)
+		}
+		return int64(t), nil
+	case json.Number:
+		// Numbers parsed from JSON documents are untyped and therefore we
+		// always write them as floats in order to avoid type conflicts.
+		return t.Float64()
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+func (i *influxDBOutput) mapObject(batch service.MessageBatch, index int, mapping *bloblang.Executor) (map[string]interface{}, error) {
+	resMsg, err := batch.BloblangQuery(index, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if resMsg == nil {
+		return nil, nil
+	}
+	v, err := resMsg.AsStructured()
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected object result, got %T", v)
+	}
+	return obj, nil
+}
+
+func (i *influxDBOutput) pointFromMessage(batch service.MessageBatch, index int) (models.Point, error) {
+	measurement := batch.InterpolatedString(index, i.measurement)
+
+	var tags map[string]string
+	if i.tags != nil {
+		tagsObj, err := i.mapObject(batch, index, i.tags)
+		if err != nil {
+			return nil, fmt.Errorf("tags mapping failed: %w", err)
+		}
+		tags = make(map[string]string, len(tagsObj))
+		for k, v := range tagsObj {
+			if v != nil {
+				tags[k] = query.IToString(v)
+			}
+		}
+	}
+
+	fieldsObj, err := i.mapObject(batch, index, i.fields)
+	if err != nil {
+		return nil, fmt.Errorf("fields mapping failed: %w", err)
+	}
+	fields := make(models.Fields, len(fieldsObj))
+	for k, v := range fieldsObj {
+		if v == nil {
+			continue
+		}
+		if fields[k], err = influxDBFieldValue(v); err != nil {
+			return nil, fmt.Errorf("field %v: %w", k, err)
+		}
+	}
+
+	ts, err := shared.MappedTimestamp(batch, index, i.timestamp, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPoint(measurement, models.NewTags(tags), fields, ts)
+}
+
+//------------------------------------------------------------------------------
+
+func (i *influxDBOutput) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (i *influxDBOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
+	var buf bytes.Buffer
+	var batchErr *service.BatchError
+	precision := influxDBModelsPrecision(i.precision)
+	for index := range batch {
+		p, err := i.pointFromMessage(batch, index)
+		if err != nil {
+			// Messages that cannot be converted into a point will never
+			// succeed and are therefore failed individually.
+			i.log.Errorf("Failed to convert message %v into a point: %v", index, err)
+			if batchErr == nil {
+				batchErr = service.NewBatchError(batch, err)
+			}
+			batchErr.Failed(index, err)
+			continue
+		}
+		buf.WriteString(p.PrecisionString(precision))
+		buf.WriteByte('\n')
+	}
+	if batchErr != nil && batchErr.IndexedErrors() == len(batch) {
+		return batchErr
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", i.writeURL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.token != "" {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+	if i.username != "" || i.password != "" {
+		req.SetBasicAuth(i.username, i.password)
+	}
+
+	res, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("write request returned status %v: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+func (i *influxDBOutput) Close(ctx context.Context) error {
+	i.client.CloseIdleConnections()
+	return nil
+}
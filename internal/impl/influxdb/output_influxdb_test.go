@@ -0,0 +1,221 @@
+package influxdb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+type influxTestServer struct {
+	mut      sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	status   int
+}
+
+func (s *influxTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mut.Lock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	status := s.status
+	s.mut.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"code":"invalid","message":"nope"}`))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func testInfluxDBOutput(t *testing.T, conf string) *influxDBOutput {
+	t.Helper()
+
+	pConf, err := influxDBOutputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	out, err := newInfluxDBOutputFromConfig(pConf, nil)
+	require.NoError(t, err)
+	return out
+}
+
+func TestInfluxDBOutputV2(t *testing.T) {
+	s := &influxTestServer{}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	out := testInfluxDBOutput(t, `
+url: `+ts.URL+`
+org: fooorg
+bucket: foobucket
+token: footoken
+measurement: ${! meta("measurement") }
+tags_mapping: 'root.host = this.host'
+fields_mapping: |
+  root.usage = this.usage
+  root.cores = this.cores.round()
+  root.name = this.name
+  root.ok = this.ok
+  root.nope = null
+timestamp_mapping: 'root = this.ts'
+precision: s
+`)
+	require.NoError(t, out.Connect(context.Background()))
+
+	msgA := service.NewMessage([]byte(`{"host":"a b","usage":0.5,"cores":4,"name":"foo \"bar\"","ok":true,"ts":1641038400}`))
+	msgA.MetaSet("measurement", "cpu")
+	msgB := service.NewMessage([]byte(`{"host":"c","usage":1,"cores":8,"name":"baz","ok":false,"ts":"2022-01-01T12:00:01Z"}`))
+	msgB.MetaSet("measurement", "cpu,total")
+
+	require.NoError(t, out.WriteBatch(context.Background(), service.MessageBatch{msgA, msgB}))
+	require.NoError(t, out.Close(context.Background()))
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	require.Len(t, s.requests, 1)
+	req := s.requests[0]
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/api/v2/write", req.URL.Path)
+	assert.Equal(t, "fooorg", req.URL.Query().Get("org"))
+	assert.Equal(t, "foobucket", req.URL.Query().Get("bucket"))
+	assert.Equal(t, "s", req.URL.Query().Get("precision"))
+	assert.Equal(t, "Token footoken", req.Header.Get("Authorization"))
+	assert.Equal(t, `cpu,host=a\ b cores=4i,name="foo \"bar\"",ok=true,usage=0.5 1641038400
+cpu\,total,host=c cores=8i,name="baz",ok=false,usage=1 1641038401
+`, s.bodies[0])
+}
+
+func TestInfluxDBOutputV1(t *testing.T) {
+	s := &influxTestServer{}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	out := testInfluxDBOutput(t, `
+url: `+ts.URL+`
+api: v1
+db: foodb
+retention_policy: foorp
+username: foouser
+password: foopass
+measurement: cpu
+timestamp_mapping: 'root = this.ts'
+fields_mapping: 'root = this.without("ts")'
+precision: us
+`)
+
+	require.NoError(t, out.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"usage":0.5,"ts":"2022-01-01T12:00:00.000001Z"}`)),
+	}))
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	require.Len(t, s.requests, 1)
+	req := s.requests[0]
+	assert.Equal(t, "/write", req.URL.Path)
+	assert.Equal(t, "foodb", req.URL.Query().Get("db"))
+	assert.Equal(t, "foorp", req.URL.Query().Get("rp"))
+	assert.Equal(t, "u", req.URL.Query().Get("precision"))
+
+	user, pass, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "foouser", user)
+	assert.Equal(t, "foopass", pass)
+
+	assert.Equal(t, "cpu usage=0.5 1641038400000001\n", s.bodies[0])
+}
+
+func TestInfluxDBOutputErrors(t *testing.T) {
+	s := &influxTestServer{status: http.StatusBadRequest}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	out := testInfluxDBOutput(t, `
+url: `+ts.URL+`
+org: fooorg
+bucket: foobucket
+measurement: cpu
+`)
+
+	err := out.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"usage":0.5}`)),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+	assert.Contains(t, err.Error(), "nope")
+
+	err = out.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"nested":{"usage":0.5}}`)),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nested")
+
+	// Messages that cannot be converted are failed individually.
+	s.mut.Lock()
+	s.status = 0
+	s.mut.Unlock()
+
+	err = out.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"usage":0.5}`)),
+		service.NewMessage([]byte(`{"nested":{"usage":0.5}}`)),
+		service.NewMessage([]byte(`{"usage":1}`)),
+	})
+	require.Error(t, err)
+	bErr, ok := err.(*service.BatchError)
+	require.True(t, ok, "%T", err)
+	assert.Equal(t, 1, bErr.IndexedErrors())
+
+	s.mut.Lock()
+	require.Len(t, s.bodies, 2)
+	assert.Regexp(t, `^cpu usage=0.5 \d+\ncpu usage=1 \d+\n$`, s.bodies[1])
+	s.mut.Unlock()
+
+	for _, conf := range []string{
+		`
+url: http://localhost:8086
+measurement: cpu
+`,
+		`
+url: http://localhost:8086
+api: v1
+measurement: cpu
+`,
+	} {
+		pConf, err := influxDBOutputConfig().ParseYAML(conf, nil)
+		require.NoError(t, err)
+
+		_, err = newInfluxDBOutputFromConfig(pConf, nil)
+		require.Error(t, err, conf)
+	}
+}
+
+func TestParseInfluxLine(t *testing.T) {
+	v, err := parseInfluxLine([]byte(`cpu,host=foo usage=0.5,cores=4i,name="bar"`), "ns")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"measurement": "cpu",
+		"tags":        map[string]interface{}{"host": "foo"},
+		"fields": map[string]interface{}{
+			"usage": 0.5,
+			"cores": int64(4),
+			"name":  "bar",
+		},
+	}, v)
+
+	_, err = parseInfluxLine([]byte(`cpu,host=foo`), "ns")
+	require.Error(t, err)
+
+	_, err = parseInfluxLine([]byte("cpu usage=1\ncpu usage=2"), "ns")
+	require.Error(t, err)
+}
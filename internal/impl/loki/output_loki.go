@@ -0,0 +1,459 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/golang/snappy"
+
+	"github.com/benthosdev/benthos/v4/internal/impl/shared"
+	"github.com/benthosdev/benthos/v4/internal/message"
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func lokiOutputConfig() *service.ConfigSpec {
+	retriesDefaults := backoff.NewExponentialBackOff()
+	retriesDefaults.InitialInterval = time.Millisecond * 500
+	retriesDefaults.MaxInterval = time.Second * 5
+	retriesDefaults.MaxElapsedTime = time.Second * 30
+
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Pushes log lines to [Grafana Loki](https://grafana.com/oss/loki/).").
+		Description(`
+Each message of a batch becomes a log entry, where the contents of the message are the log line. Messages are grouped into streams by the label set resolved from the field ` + "`labels`" + `, which supports [interpolation functions](/docs/configuration/interpolation#bloblang-queries) within each value, and entries are ordered by their timestamp within each stream. Labels that resolve to an empty string are omitted.
+
+By default entries are encoded as snappy compressed protobuf. Encodings are not negotiated with the server, and therefore in order to push JSON encoded streams instead the ` + "`encoding`" + ` field must be set to ` + "`json`" + `.
+
+### Rejected Streams
+
+Each stream of a batch is pushed within its own request so that rejections are handled per stream. Streams rejected due to rate limiting (status code 429) are retried according to the ` + "`retries`" + ` field. Streams rejected because their entries are out of order (or too old) can never be accepted, and are therefore logged and dropped when ` + "`drop_out_of_order`" + ` is ` + "`true`" + `.
+
+Any other rejection only fails the messages of the rejected stream, and messages that cannot be converted into entries are failed individually, allowing the remaining messages of the batch to be acknowledged. However, some inputs can only retry an entire batch, in which case streams that were already accepted are sent again. Loki ignores entries identical to those it has already received for a stream, and therefore retried entries are deduplicated as long as their timestamps are the same each time they are sent, as described below.
+
+### Timestamps
+
+When a ` + "`timestamp_mapping`" + ` is set it always determines the timestamp of an entry. Otherwise the timestamp is read from the metadata field ` + "`loki_timestamp`" + ` in RFC 3339 format when present, and when absent the current time is used and written to that field the first time the message is sent so that it is reused when retried. This field is written to the message directly, and therefore it remains visible to any component that the message reaches after a failed attempt, such as the other outputs of a ` + "`fallback`" + ` or ` + "`switch`" + ` output. Messages that are reprocessed by the pipeline before being retried lose this field.`).
+		Field(service.NewStringField("url").
+			Description("The URL of the Loki push endpoint.").
+			Example("http://localhost:3100/loki/api/v1/push")).
+		Field(service.NewStringMapField("labels").
+			Description("A map of labels to add to each stream, where values support [interpolation functions](/docs/configuration/interpolation#bloblang-queries). At least one label must be resolved for each message.").
+			Example(map[string]interface{}{
+				"app": `${! meta("app") }`,
+				"env": "prod",
+			})).
+		Field(service.NewBloblangField("timestamp_mapping").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to the timestamp of each entry, either as a string in RFC 3339 format or a number representing unix time in seconds. When not set the metadata field `loki_timestamp` or the current time is used.").
+			Example(`root = this.timestamp`).
+			Optional()).
+		Field(service.NewStringEnumField("encoding", "protobuf", "json").
+			Description("The encoding of push requests.").
+			Default("protobuf").
+			Advanced()).
+		Field(service.NewStringField("tenant_id").
+			Description("An optional tenant ID to set as the `X-Scope-OrgID` header of requests, which is required for multi-tenant deployments of Loki.").
+			Default("")).
+		Field(service.NewStringMapField("headers").
+			Description("A map of headers to add to each request.").
+			Default(map[string]interface{}{}).
+			Advanced()).
+		Field(service.NewBoolField("drop_out_of_order").
+			Description("Whether to drop streams rejected because their entries are out of order or too old, rather than sending the batch again.").
+			Default(true).
+			Advanced()).
+		Field(service.NewBackOffField("retries", false, retriesDefaults).
+			Description("Determines how streams rejected due to rate limiting are retried.").
+			Advanced()).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for a request to complete.").
+			Default("5s").
+			Advanced()).
+		Field(service.NewIntField("max_in_flight").
+			Description("The maximum number of parallel message batches to have in flight at any given time.").
+			Default(64)).
+		Field(service.NewBatchPolicyField("batching"))
+}
+
+func init() {
+	err := service.RegisterBatchOutput(
+		"loki", lokiOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.BatchOutput, batchPolicy service.BatchPolicy, maxInFlight int, err error) {
+			if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
+				return
+			}
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			out, err = newLokiOutputFromConfig(conf, mgr.Logger())
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type lokiOutput struct {
+	url            string
+	labels         map[string]*service.InterpolatedString
+	timestamp      *bloblang.Executor
+	json           bool
+	tenantID       string
+	headers        map[string]string
+	dropOutOfOrder bool
+	boffPool       sync.Pool
+	client         *http.Client
+	log            *service.Logger
+}
+
+func newLokiOutputFromConfig(conf *service.ParsedConfig, log *service.Logger) (*lokiOutput, error) {
+	l := &lokiOutput{
+		labels: map[string]*service.InterpolatedString{},
+		client: &http.Client{},
+		log:    log,
+	}
+
+	var err error
+	if l.url, err = conf.FieldString("url"); err != nil {
+		return nil, err
+	}
+
+	labels, err := conf.FieldStringMap("labels")
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, errors.New("at least one label must be specified")
+	}
+	for k, v := range labels {
+		if l.labels[k], err = service.NewInterpolatedString(v); err != nil {
+			return nil, fmt.Errorf("failed to parse label %v: %w", k, err)
+		}
+	}
+
+	if conf.Contains("timestamp_mapping") {
+		if l.timestamp, err = conf.FieldBloblang("timestamp_mapping"); err != nil {
+			return nil, err
+		}
+	}
+
+	encoding, err := conf.FieldString("encoding")
+	if err != nil {
+		return nil, err
+	}
+	l.json = encoding == "json"
+
+	if l.tenantID, err = conf.FieldString("tenant_id"); err != nil {
+		return nil, err
+	}
+	if l.headers, err = conf.FieldStringMap("headers"); err != nil {
+		return nil, err
+	}
+	if l.dropOutOfOrder, err = conf.FieldBool("drop_out_of_order"); err != nil {
+		return nil, err
+	}
+
+	boff, err := conf.FieldBackOff("retries")
+	if err != nil {
+		return nil, err
+	}
+	l.boffPool = sync.Pool{
+		New: func() interface{} {
+			bo := *boff
+			bo.Reset()
+			return &bo
+		},
+	}
+
+	if l.client.Timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		l.client.Transport = &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+	}
+	return l, nil
+}
+
+//------------------------------------------------------------------------------
+
+// lokiLabelsString formats a label set in the Prometheus style expected by the
+// push API, with label names sorted.
+func lokiLabelsString(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// lokiTimestampMeta is the metadata field used to pin the timestamp of an
+// entry when a timestamp mapping is not set, so that retried entries are
+// deduplicated by Loki.
+const lokiTimestampMeta = "loki_timestamp"
+
+func (l *lokiOutput) entryTimestamp(batch service.MessageBatch, index int) (time.Time, error) {
+	if l.timestamp != nil {
+		return shared.MappedTimestamp(batch, index, l.timestamp, time.Now())
+	}
+
+	msg := batch[index]
+	if v, exists := msg.MetaGet(lokiTimestampMeta); exists {
+		if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return ts, nil
+		}
+	}
+
+	// Setting the metadata via the message itself would only modify a copy,
+	// and therefore we set it on the underlying part, which is reused when the
+	// batch is retried.
+	ts := time.Now()
+	if uw, ok := msg.XUnwrapper().(interface {
+		Unwrap() *message.Part
+	}); ok {
+		uw.Unwrap().MetaSet(lokiTimestampMeta, ts.Format(time.RFC3339Nano))
+	}
+	return ts, nil
+}
+
+// lokiBatchStream is a stream along with the indexes of the messages of the
+// batch that its entries were created from.
+type lokiBatchStream struct {
+	lokiStream
+	indexes []int
+}
+
+// streamsFromBatch groups the messages of a batch into streams by their
+// labels, where the entries of each stream are ordered by timestamp and the
+// streams are ordered by their labels. Messages that cannot be converted into
+// entries are added to the returned batch error.
+func (l *lokiOutput) streamsFromBatch(batch service.MessageBatch) ([]lokiBatchStream, *service.BatchError) {
+	type indexedEntry struct {
+		entry lokiEntry
+		index int
+	}
+
+	streamIndexes := map[string]int{}
+	var labelSets []map[string]string
+	var streamEntries [][]indexedEntry
+	var batchErr *service.BatchError
+
+	for i, msg := range batch {
+		entry, labels, err := l.entryFromMessage(batch, i, msg)
+		if err != nil {
+			err = fmt.Errorf("message %v: %w", i, err)
+			if batchErr == nil {
+				batchErr = service.NewBatchError(batch, err)
+			}
+			batchErr.Failed(i, err)
+			continue
+		}
+
+		key := lokiLabelsString(labels)
+		index, exists := streamIndexes[key]
+		if !exists {
+			index = len(labelSets)
+			streamIndexes[key] = index
+			labelSets = append(labelSets, labels)
+			streamEntries = append(streamEntries, nil)
+		}
+		streamEntries[index] = append(streamEntries[index], indexedEntry{entry: entry, index: i})
+	}
+
+	streams := make([]lokiBatchStream, len(labelSets))
+	for i, entries := range streamEntries {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].entry.Timestamp.Before(entries[j].entry.Timestamp)
+		})
+		streams[i].Labels = labelSets[i]
+		for _, e := range entries {
+			streams[i].Entries = append(streams[i].Entries, e.entry)
+			streams[i].indexes = append(streams[i].indexes, e.index)
+		}
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		return lokiLabelsString(streams[i].Labels) < lokiLabelsString(streams[j].Labels)
+	})
+	return streams, batchErr
+}
+
+func (l *lokiOutput) entryFromMessage(batch service.MessageBatch, index int, msg *service.Message) (lokiEntry, map[string]string, error) {
+	labels := map[string]string{}
+	for k, v := range l.labels {
+		if s := batch.InterpolatedString(index, v); s != "" {
+			labels[k] = s
+		}
+	}
+	if len(labels) == 0 {
+		return lokiEntry{}, nil, errors.New("no labels were resolved")
+	}
+
+	line, err := msg.AsBytes()
+	if err != nil {
+		return lokiEntry{}, nil, err
+	}
+	ts, err := l.entryTimestamp(batch, index)
+	if err != nil {
+		return lokiEntry{}, nil, err
+	}
+	return lokiEntry{Timestamp: ts, Line: string(line)}, labels, nil
+}
+
+//------------------------------------------------------------------------------
+
+type lokiPushError struct {
+	status int
+	body   string
+}
+
+func (e *lokiPushError) Error() string {
+	return fmt.Sprintf("push request returned status %v: %v", e.status, e.body)
+}
+
+// outOfOrder returns true if the rejection was caused by entries being out of
+// order or too old, which can never succeed when retried.
+func (e *lokiPushError) outOfOrder() bool {
+	return e.status == http.StatusBadRequest &&
+		(strings.Contains(e.body, "out of order") || strings.Contains(e.body, "too far behind") || strings.Contains(e.body, "too old"))
+}
+
+func (l *lokiOutput) push(ctx context.Context, stream lokiStream) error {
+	var body []byte
+	contentType := "application/x-protobuf"
+	if l.json {
+		var err error
+		if body, err = marshalLokiPushRequestJSON(stream); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	} else {
+		body = snappy.Encode(nil, marshalLokiPushRequest(stream))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range l.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if l.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", l.tenantID)
+	}
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return &lokiPushError{status: res.StatusCode, body: string(bytes.TrimSpace(resBody))}
+	}
+	return nil
+}
+
+// pushStream pushes a stream, retrying when rate limited.
+func (l *lokiOutput) pushStream(ctx context.Context, stream lokiStream) error {
+	boff := l.boffPool.Get().(backoff.BackOff)
+	defer func() {
+		boff.Reset()
+		l.boffPool.Put(boff)
+	}()
+
+	for {
+		err := l.push(ctx, stream)
+		pErr, isPushErr := err.(*lokiPushError)
+		if err == nil || !isPushErr || pErr.status != http.StatusTooManyRequests {
+			return err
+		}
+
+		wait := boff.NextBackOff()
+		if wait == backoff.Stop {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (l *lokiOutput) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (l *lokiOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
+	streams, batchErr := l.streamsFromBatch(batch)
+	if batchErr != nil {
+		l.log.Errorf("Failed to convert %v messages into entries: %v", batchErr.IndexedErrors(), batchErr)
+	}
+
+	for _, s := range streams {
+		err := l.pushStream(ctx, s.lokiStream)
+		if err == nil {
+			continue
+		}
+		labels := lokiLabelsString(s.Labels)
+		if pErr, ok := err.(*lokiPushError); ok && pErr.outOfOrder() && l.dropOutOfOrder {
+			l.log.Warnf("Dropping %v entries of stream %v: %v", len(s.Entries), labels, err)
+			continue
+		}
+
+		err = fmt.Errorf("failed to push stream %v: %w", labels, err)
+		if batchErr == nil {
+			batchErr = service.NewBatchError(batch, err)
+		}
+		for _, i := range s.indexes {
+			batchErr.Failed(i, err)
+		}
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+func (l *lokiOutput) Close(ctx context.Context) error {
+	l.client.CloseIdleConnections()
+	return nil
+}
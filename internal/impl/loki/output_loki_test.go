@@ -0,0 +1,388 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+type testLokiStream struct {
+	Labels  string
+	Entries []lokiEntry
+}
+
+// consumeTestFields walks the fields of a protobuf message, only supporting
+// the wire types used by push requests.
+func consumeTestFields(t *testing.T, b []byte, fn func(num protowire.Number, v []byte, n uint64)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		require.True(t, l > 0)
+		b = b[l:]
+		switch typ {
+		case protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b)
+			require.True(t, l > 0)
+			fn(num, v, 0)
+			b = b[l:]
+		case protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b)
+			require.True(t, l > 0)
+			fn(num, nil, v)
+			b = b[l:]
+		default:
+			t.Fatalf("unexpected wire type: %v", typ)
+		}
+	}
+}
+
+func decodeTestPushRequest(t *testing.T, b []byte) []testLokiStream {
+	t.Helper()
+
+	var streams []testLokiStream
+	consumeTestFields(t, b, func(_ protowire.Number, sb []byte, _ uint64) {
+		var s testLokiStream
+		consumeTestFields(t, sb, func(num protowire.Number, v []byte, _ uint64) {
+			if num == 1 {
+				s.Labels = string(v)
+				return
+			}
+			var e lokiEntry
+			var secs, nanos uint64
+			consumeTestFields(t, v, func(num protowire.Number, v []byte, _ uint64) {
+				if num == 1 {
+					consumeTestFields(t, v, func(num protowire.Number, _ []byte, n uint64) {
+						if num == 1 {
+							secs = n
+						} else {
+							nanos = n
+						}
+					})
+					return
+				}
+				e.Line = string(v)
+			})
+			e.Timestamp = time.Unix(int64(secs), int64(nanos)).UTC()
+			s.Entries = append(s.Entries, e)
+		})
+		streams = append(streams, s)
+	})
+	return streams
+}
+
+func testLokiOutput(t *testing.T, conf string) *lokiOutput {
+	t.Helper()
+
+	pConf, err := lokiOutputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	l, err := newLokiOutputFromConfig(pConf, service.MockResources().Logger())
+	require.NoError(t, err)
+	require.NoError(t, l.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, l.Close(context.Background()))
+	})
+	return l
+}
+
+func testLokiBatch() service.MessageBatch {
+	var batch service.MessageBatch
+	for _, d := range []struct {
+		app  string
+		line string
+		ts   string
+	}{
+		{app: "foo", line: "second", ts: "2022-01-01T00:00:02Z"},
+		{app: "bar", line: "only", ts: "2022-01-01T00:00:05Z"},
+		{app: "foo", line: "first", ts: "2022-01-01T00:00:01.5Z"},
+	} {
+		msg := service.NewMessage([]byte(d.line))
+		msg.MetaSet("app", d.app)
+		msg.MetaSet("ts", d.ts)
+		batch = append(batch, msg)
+	}
+	return batch
+}
+
+func TestLokiOutputProtobuf(t *testing.T) {
+	var mut sync.Mutex
+	var streams []testLokiStream
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "footenant", r.Header.Get("X-Scope-OrgID"))
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		b, err = snappy.Decode(nil, b)
+		require.NoError(t, err)
+
+		mut.Lock()
+		streams = append(streams, decodeTestPushRequest(t, b)...)
+		mut.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	l := testLokiOutput(t, `
+url: `+ts.URL+`
+tenant_id: footenant
+labels:
+  app: ${! meta("app") }
+  env: prod
+  empty: ""
+timestamp_mapping: 'root = meta("ts")'
+`)
+
+	require.NoError(t, l.WriteBatch(context.Background(), testLokiBatch()))
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.Equal(t, []testLokiStream{
+		{
+			Labels: `{app="bar", env="prod"}`,
+			Entries: []lokiEntry{
+				{Timestamp: time.Date(2022, 1, 1, 0, 0, 5, 0, time.UTC), Line: "only"},
+			},
+		},
+		{
+			Labels: `{app="foo", env="prod"}`,
+			Entries: []lokiEntry{
+				{Timestamp: time.Date(2022, 1, 1, 0, 0, 1, 500000000, time.UTC), Line: "first"},
+				{Timestamp: time.Date(2022, 1, 1, 0, 0, 2, 0, time.UTC), Line: "second"},
+			},
+		},
+	}, streams)
+}
+
+func TestLokiOutputJSON(t *testing.T) {
+	var mut sync.Mutex
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mut.Lock()
+		reqs = append(reqs, string(b))
+		mut.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	l := testLokiOutput(t, `
+url: `+ts.URL+`
+encoding: json
+labels:
+  app: ${! meta("app") }
+timestamp_mapping: 'root = meta("ts")'
+`)
+
+	require.NoError(t, l.WriteBatch(context.Background(), testLokiBatch()))
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.Equal(t, []string{
+		`{"streams":[{"stream":{"app":"bar"},"values":[["1640995205000000000","only"]]}]}`,
+		`{"streams":[{"stream":{"app":"foo"},"values":[["1640995201500000000","first"],["1640995202000000000","second"]]}]}`,
+	}, reqs)
+}
+
+func TestLokiOutputRejections(t *testing.T) {
+	var mut sync.Mutex
+	attempts := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Streams []struct {
+				Stream map[string]string `json:"stream"`
+			} `json:"streams"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		app := req.Streams[0].Stream["app"]
+
+		mut.Lock()
+		attempts[app]++
+		n := attempts[app]
+		mut.Unlock()
+
+		switch app {
+		case "limited":
+			if n < 3 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "old":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`entry with timestamp 2022-01-01 00:00:00 +0000 UTC ignored, reason: 'entry out of order' for stream: {app="old"}`))
+			return
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	l := testLokiOutput(t, `
+url: `+ts.URL+`
+encoding: json
+labels:
+  app: ${! content() }
+retries:
+  initial_interval: 1ms
+  max_interval: 1ms
+`)
+
+	batch := service.MessageBatch{
+		service.NewMessage([]byte("limited")),
+		service.NewMessage([]byte("old")),
+		service.NewMessage([]byte("fine")),
+	}
+	require.NoError(t, l.WriteBatch(context.Background(), batch))
+
+	mut.Lock()
+	assert.Equal(t, map[string]int{"limited": 3, "old": 1, "fine": 1}, attempts)
+	mut.Unlock()
+
+	err := l.WriteBatch(context.Background(), append(batch, service.NewMessage([]byte("broken"))))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `{app="broken"}`)
+	assert.NotContains(t, err.Error(), `{app="old"}`)
+
+	var bErr *service.BatchError
+	require.True(t, errors.As(err, &bErr))
+	assert.Equal(t, 1, bErr.IndexedErrors())
+
+	l.dropOutOfOrder = false
+	err = l.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte("old"))})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of order")
+}
+
+func TestLokiOutputConfigErrors(t *testing.T) {
+	pConf, err := lokiOutputConfig().ParseYAML(`
+url: http://localhost:3100/loki/api/v1/push
+labels: {}
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newLokiOutputFromConfig(pConf, service.MockResources().Logger())
+	require.Error(t, err)
+
+	l := testLokiOutput(t, `
+url: http://localhost:3100/loki/api/v1/push
+labels:
+  app: ${! meta("app").or("") }
+`)
+	streams, bErr := l.streamsFromBatch(service.MessageBatch{
+		service.NewMessage([]byte("hello")),
+	})
+	require.NotNil(t, bErr)
+	assert.Equal(t, 1, bErr.IndexedErrors())
+	assert.Empty(t, streams)
+}
+
+func TestLokiOutputPinnedTimestamps(t *testing.T) {
+	var mut sync.Mutex
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mut.Lock()
+		reqs = append(reqs, string(b))
+		n := len(reqs)
+		mut.Unlock()
+
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	l := testLokiOutput(t, `
+url: `+ts.URL+`
+encoding: json
+labels:
+  app: foo
+`)
+
+	batch := service.MessageBatch{service.NewMessage([]byte("hello"))}
+	require.Error(t, l.WriteBatch(context.Background(), batch))
+
+	<-time.After(time.Millisecond * 5)
+	require.NoError(t, l.WriteBatch(context.Background(), batch))
+
+	_, exists := batch[0].MetaGet("loki_timestamp")
+	assert.True(t, exists)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Len(t, reqs, 2)
+	assert.Equal(t, reqs[0], reqs[1])
+}
+
+func TestLokiOutputTimestampPrecedence(t *testing.T) {
+	var mut sync.Mutex
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mut.Lock()
+		reqs = append(reqs, string(b))
+		mut.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	newBatch := func() service.MessageBatch {
+		msg := service.NewMessage([]byte("hello"))
+		msg.MetaSet("ts", "2022-01-01T00:00:01Z")
+		msg.MetaSet("loki_timestamp", "2022-01-01T00:00:02Z")
+		return service.MessageBatch{msg}
+	}
+
+	l := testLokiOutput(t, `
+url: `+ts.URL+`
+encoding: json
+labels:
+  app: foo
+timestamp_mapping: 'root = meta("ts")'
+`)
+	batch := newBatch()
+	require.NoError(t, l.WriteBatch(context.Background(), batch))
+
+	v, _ := batch[0].MetaGet("loki_timestamp")
+	assert.Equal(t, "2022-01-01T00:00:02Z", v)
+
+	l = testLokiOutput(t, `
+url: `+ts.URL+`
+encoding: json
+labels:
+  app: foo
+`)
+	require.NoError(t, l.WriteBatch(context.Background(), newBatch()))
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.Equal(t, []string{
+		`{"streams":[{"stream":{"app":"foo"},"values":[["1640995201000000000","hello"]]}]}`,
+		`{"streams":[{"stream":{"app":"foo"},"values":[["1640995202000000000","hello"]]}]}`,
+	}, reqs)
+}
@@ -0,0 +1,79 @@
+package loki
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type lokiEntry struct {
+	Timestamp time.Time
+	Line      string
+}
+
+type lokiStream struct {
+	Labels  map[string]string
+	Entries []lokiEntry
+}
+
+// The push API only requires a small subset of the Loki protobuf schema
+// (logproto), and therefore we encode it by hand rather than pulling in the
+// entire Loki module.
+//
+// message PushRequest { repeated StreamAdapter streams = 1; }
+// message StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+// message EntryAdapter { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+// message Timestamp { int64 seconds = 1; int32 nanos = 2; }
+
+func appendLokiEntry(b []byte, e lokiEntry) []byte {
+	var tb []byte
+	tb = protowire.AppendTag(tb, 1, protowire.VarintType)
+	tb = protowire.AppendVarint(tb, uint64(e.Timestamp.Unix()))
+	tb = protowire.AppendTag(tb, 2, protowire.VarintType)
+	tb = protowire.AppendVarint(tb, uint64(e.Timestamp.Nanosecond()))
+
+	var eb []byte
+	eb = protowire.AppendTag(eb, 1, protowire.BytesType)
+	eb = protowire.AppendBytes(eb, tb)
+	eb = protowire.AppendTag(eb, 2, protowire.BytesType)
+	eb = protowire.AppendString(eb, e.Line)
+	return protowire.AppendBytes(b, eb)
+}
+
+func marshalLokiPushRequest(streams ...lokiStream) []byte {
+	var b []byte
+	for _, s := range streams {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.BytesType)
+		sb = protowire.AppendString(sb, lokiLabelsString(s.Labels))
+		for _, e := range s.Entries {
+			sb = protowire.AppendTag(sb, 2, protowire.BytesType)
+			sb = appendLokiEntry(sb, e)
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+	return b
+}
+
+func marshalLokiPushRequestJSON(streams ...lokiStream) ([]byte, error) {
+	type jsonStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	req := struct {
+		Streams []jsonStream `json:"streams"`
+	}{}
+	for _, s := range streams {
+		js := jsonStream{Stream: s.Labels}
+		for _, e := range s.Entries {
+			js.Values = append(js.Values, [2]string{
+				strconv.FormatInt(e.Timestamp.UnixNano(), 10), e.Line,
+			})
+		}
+		req.Streams = append(req.Streams, js)
+	}
+	return json.Marshal(req)
+}
@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -16,28 +17,86 @@ func mongoConfigSpec() *service.ConfigSpec {
 		// Stable(). TODO
 		Version("3.64.0").
 		Categories("Services").
-		Summary("Executes a find query and creates a message for each row received.").
-		Description(`Once the rows from the query are exhausted this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a [sequence](/docs/components/inputs/sequence) to execute).`).
+		Summary("Executes a find query and creates a message for each row received, or consumes a change stream.").
+		Description(`
+### Find
+
+When the field ` + "`mode`" + ` is set to ` + "`find`" + ` (the default) the query is executed once. Once the rows from the query are exhausted this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a [sequence](/docs/components/inputs/sequence) to execute).
+
+### Change Streams
+
+When the field ` + "`mode`" + ` is set to ` + "`change_stream`" + ` this input instead opens a [change stream](https://docs.mongodb.com/manual/changeStreams/) and creates a message for each change event received. The scope of the stream is determined by the ` + "`database`" + ` and ` + "`collection`" + ` fields: when both are set a single collection is watched, when only ` + "`database`" + ` is set all collections of that database are watched, and when neither are set the entire cluster is watched.
+
+Each message is the change event document, which includes the fields ` + "`operationType`" + `, ` + "`ns`" + `, ` + "`documentKey`" + ` and, depending on the operation and the ` + "`change_stream.full_document`" + ` field, ` + "`fullDocument`" + `.
+
+If a ` + "`change_stream.checkpoint_cache`" + ` is configured then the resume token of each change event is stored within it once the event, and all events prior to it, have been acknowledged. When the input is restarted the change stream resumes from the stored token.
+
+### Metadata
+
+When consuming a change stream this input adds the following metadata fields to each message:
+
+` + "```text" + `
+- mongodb_operation_type
+- mongodb_database
+- mongodb_collection
+- mongodb_document_key
+` + "```" + `
+
+The field ` + "`mongodb_document_key`" + ` is the document key of the change event formatted as relaxed extended JSON.
+
+You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).`).
 		Field(urlField).
-		Field(service.NewStringField("database").Description("The name of the target MongoDB database.")).
-		Field(service.NewStringField("collection").Description("The collection to select from.")).
+		Field(service.NewStringField("database").
+			Description("The name of the target MongoDB database. When consuming a change stream this can be left empty in order to watch the entire cluster.").
+			Default("")).
+		Field(service.NewStringField("collection").
+			Description("The collection to select from. When consuming a change stream this can be left empty in order to watch all collections of the database.").
+			Default("")).
 		Field(service.NewStringField("username").Description("The username to connect to the database.").Default("")).
 		Field(service.NewStringField("password").Description("The password to connect to the database.").Default("")).
-		Field(queryField)
+		Field(service.NewStringAnnotatedEnumField("mode", map[string]string{
+			"find":          "Execute a find query once and shut down once the results are exhausted.",
+			"change_stream": "Consume change events from a change stream.",
+		}).
+			Description("The mode in which to consume data.").
+			Version("4.0.0").
+			Default("find")).
+		Field(queryField).
+		Field(service.NewObjectField("change_stream",
+			service.NewBloblangField("pipeline").
+				Description("An optional Bloblang mapping that results in an array of aggregation pipeline stages used to filter or modify change events.").
+				Optional().
+				Example(`root = [ { "$match": { "operationType": { "$in": [ "insert", "update" ] } } } ]`),
+			service.NewStringAnnotatedEnumField("full_document", map[string]string{
+				"default":      "Do not include the current version of the document in update events.",
+				"updateLookup": "Include a copy of the current majority-committed version of the document in update events.",
+			}).
+				Description("Determines whether update events include the full document.").
+				Default("default"),
+			service.NewStringField("checkpoint_cache").
+				Description("An optional [cache resource](/docs/components/caches/about) used to store the resume token of the latest acknowledged change event, allowing the change stream to resume from where it left off after restarts.").
+				Optional(),
+			service.NewStringField("checkpoint_key").
+				Description("The key used to store the resume token within the checkpoint cache.").
+				Advanced().
+				Default("mongodb_change_stream_resume_token"),
+		).
+			Description("Options specific to the `change_stream` mode.").
+			Version("4.0.0"))
 }
 
 func init() {
 	err := service.RegisterInput(
 		"mongodb", mongoConfigSpec(),
 		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
-			return newMongoInput(conf)
+			return newMongoInput(conf, mgr)
 		})
 	if err != nil {
 		panic(err)
 	}
 }
 
-func newMongoInput(conf *service.ParsedConfig) (service.Input, error) {
+func newMongoInput(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
 	url, err := conf.FieldString("url")
 	if err != nil {
 		return nil, err
@@ -58,6 +117,32 @@ func newMongoInput(conf *service.ParsedConfig) (service.Input, error) {
 	if err != nil {
 		return nil, err
 	}
+	config := client.Config{
+		URL:        url,
+		Database:   database,
+		Collection: collection,
+		Username:   username,
+		Password:   password,
+	}
+
+	mode, err := conf.FieldString("mode")
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case "find":
+	case "change_stream":
+		return newMongoChangeStreamInput(config, conf.Namespace("change_stream"), mgr)
+	default:
+		return nil, fmt.Errorf("mode %v was not recognised", mode)
+	}
+
+	if database == "" || collection == "" {
+		return nil, errors.New("both a database and collection must be specified in find mode")
+	}
+	if !conf.Contains("query") {
+		return nil, errors.New("a query must be specified in find mode")
+	}
 	queryExecutor, err := conf.FieldBloblang("query")
 	if err != nil {
 		return nil, err
@@ -70,13 +155,6 @@ func newMongoInput(conf *service.ParsedConfig) (service.Input, error) {
 	if err != nil {
 		return nil, err
 	}
-	config := client.Config{
-		URL:        url,
-		Database:   database,
-		Collection: collection,
-		Username:   username,
-		Password:   password,
-	}
 	return service.AutoRetryNacks(&mongoInput{
 		queryBSON,
 		config, nil, nil}), nil
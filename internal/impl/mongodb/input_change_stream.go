@@ -0,0 +1,246 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/benthosdev/benthos/v4/internal/checkpoint"
+	"github.com/benthosdev/benthos/v4/internal/impl/mongodb/client"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+type mongoChangeStreamInput struct {
+	config          client.Config
+	pipeline        interface{}
+	fullDocument    options.FullDocument
+	checkpointCache string
+	checkpointKey   string
+
+	mgr *service.Resources
+	log *service.Logger
+
+	cMut   sync.Mutex
+	client *mongo.Client
+	stream *mongo.ChangeStream
+
+	checkpointMut sync.Mutex
+	checkpointer  *checkpoint.Type
+}
+
+func newMongoChangeStreamInput(config client.Config, conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+	m := &mongoChangeStreamInput{
+		config:       config,
+		pipeline:     mongo.Pipeline{},
+		mgr:          mgr,
+		log:          mgr.Logger(),
+		checkpointer: checkpoint.New(),
+	}
+
+	if conf.Contains("pipeline") {
+		pipelineExecutor, err := conf.FieldBloblang("pipeline")
+		if err != nil {
+			return nil, err
+		}
+		pipeline, err := pipelineExecutor.Query(struct{}{})
+		if err != nil {
+			return nil, err
+		}
+		stages, ok := pipeline.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("pipeline mapping must result in an array, got %T", pipeline)
+		}
+		m.pipeline = stages
+	}
+
+	fullDocument, err := conf.FieldString("full_document")
+	if err != nil {
+		return nil, err
+	}
+	m.fullDocument = options.FullDocument(fullDocument)
+
+	if conf.Contains("checkpoint_cache") {
+		if m.checkpointCache, err = conf.FieldString("checkpoint_cache"); err != nil {
+			return nil, err
+		}
+		if !mgr.HasCache(m.checkpointCache) {
+			return nil, fmt.Errorf("checkpoint cache resource '%v' was not found", m.checkpointCache)
+		}
+	}
+	if m.checkpointKey, err = conf.FieldString("checkpoint_key"); err != nil {
+		return nil, err
+	}
+	return service.AutoRetryNacks(m), nil
+}
+
+func (m *mongoChangeStreamInput) getResumeToken(ctx context.Context) (token bson.Raw, err error) {
+	if m.checkpointCache == "" {
+		return nil, nil
+	}
+	if cerr := m.mgr.AccessCache(ctx, m.checkpointCache, func(c service.Cache) {
+		var tokenBytes []byte
+		if tokenBytes, err = c.Get(ctx, m.checkpointKey); err == service.ErrKeyNotFound {
+			err = nil
+			return
+		}
+		token = tokenBytes
+	}); cerr != nil {
+		return nil, cerr
+	}
+	return
+}
+
+func (m *mongoChangeStreamInput) setResumeToken(ctx context.Context, token bson.Raw) (err error) {
+	if m.checkpointCache == "" {
+		return nil
+	}
+	if cerr := m.mgr.AccessCache(ctx, m.checkpointCache, func(c service.Cache) {
+		err = c.Set(ctx, m.checkpointKey, token, nil)
+	}); cerr != nil {
+		return cerr
+	}
+	return
+}
+
+func (m *mongoChangeStreamInput) Connect(ctx context.Context) error {
+	m.cMut.Lock()
+	defer m.cMut.Unlock()
+
+	if m.stream != nil {
+		return nil
+	}
+
+	token, err := m.getResumeToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain resume token: %w", err)
+	}
+
+	mClient, err := m.config.Client()
+	if err != nil {
+		return err
+	}
+	if err = mClient.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	if err = mClient.Ping(ctx, nil); err != nil {
+		_ = mClient.Disconnect(ctx)
+		return fmt.Errorf("ping failed: %v", err)
+	}
+
+	opts := options.ChangeStream().SetFullDocument(m.fullDocument)
+	if len(token) > 0 {
+		opts = opts.SetResumeAfter(token)
+	}
+
+	var stream *mongo.ChangeStream
+	switch {
+	case m.config.Database == "":
+		stream, err = mClient.Watch(ctx, m.pipeline, opts)
+	case m.config.Collection == "":
+		stream, err = mClient.Database(m.config.Database).Watch(ctx, m.pipeline, opts)
+	default:
+		stream, err = mClient.Database(m.config.Database).Collection(m.config.Collection).Watch(ctx, m.pipeline, opts)
+	}
+	if err != nil {
+		_ = mClient.Disconnect(ctx)
+		return err
+	}
+
+	m.client = mClient
+	m.stream = stream
+	return nil
+}
+
+func (m *mongoChangeStreamInput) disconnect(ctx context.Context) error {
+	m.cMut.Lock()
+	defer m.cMut.Unlock()
+
+	if m.stream != nil {
+		_ = m.stream.Close(ctx)
+		m.stream = nil
+	}
+	if m.client != nil {
+		err := m.client.Disconnect(ctx)
+		m.client = nil
+		return err
+	}
+	return nil
+}
+
+func (m *mongoChangeStreamInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	m.cMut.Lock()
+	stream := m.stream
+	m.cMut.Unlock()
+
+	if stream == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	if !stream.Next(ctx) {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		err := stream.Err()
+		m.log.Errorf("Change stream closed: %v", err)
+		_ = m.disconnect(ctx)
+		return nil, nil, service.ErrNotConnected
+	}
+
+	var event map[string]interface{}
+	if err := stream.Decode(&event); err != nil {
+		return nil, nil, err
+	}
+
+	msg := service.NewMessage(nil)
+	msg.SetStructured(event)
+
+	var eventMeta struct {
+		OperationType string `bson:"operationType"`
+		NS            struct {
+			DB   string `bson:"db"`
+			Coll string `bson:"coll"`
+		} `bson:"ns"`
+		DocumentKey bson.Raw `bson:"documentKey"`
+	}
+	if err := stream.Decode(&eventMeta); err != nil {
+		return nil, nil, err
+	}
+	msg.MetaSet("mongodb_operation_type", eventMeta.OperationType)
+	msg.MetaSet("mongodb_database", eventMeta.NS.DB)
+	msg.MetaSet("mongodb_collection", eventMeta.NS.Coll)
+	if len(eventMeta.DocumentKey) > 0 {
+		if keyJSON, err := bson.MarshalExtJSON(eventMeta.DocumentKey, false, false); err == nil {
+			msg.MetaSet("mongodb_document_key", string(keyJSON))
+		}
+	}
+
+	// The resume token buffer is reused by the driver and therefore must be
+	// copied before being tracked.
+	token := make(bson.Raw, len(stream.ResumeToken()))
+	copy(token, stream.ResumeToken())
+
+	m.checkpointMut.Lock()
+	release := m.checkpointer.Track(token, 1)
+	m.checkpointMut.Unlock()
+
+	return msg, func(ctx context.Context, res error) error {
+		// Resume tokens are written while holding the lock in order to
+		// prevent an older token from overwriting a newer one.
+		m.checkpointMut.Lock()
+		defer m.checkpointMut.Unlock()
+
+		highest, _ := release().(bson.Raw)
+		if highest == nil {
+			return nil
+		}
+		return m.setResumeToken(ctx, highest)
+	}, nil
+}
+
+func (m *mongoChangeStreamInput) Close(ctx context.Context) error {
+	return m.disconnect(ctx)
+}
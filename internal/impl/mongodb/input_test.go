@@ -27,7 +27,83 @@ query: |
 	mongoConfig, err := spec.ParseYAML(conf, env)
 	require.NoError(t, err)
 
-	selectInput, err := newMongoInput(mongoConfig)
+	selectInput, err := newMongoInput(mongoConfig, service.MockResources())
 	require.NoError(t, err)
 	require.NoError(t, selectInput.Close(context.Background()))
 }
+
+func TestMongoInputFindRequiresQuery(t *testing.T) {
+	conf := `
+url: "mongodb://localhost:27017"
+database: "foo"
+collection: "bar"
+`
+
+	spec := mongoConfigSpec()
+	env := service.NewEnvironment()
+
+	mongoConfig, err := spec.ParseYAML(conf, env)
+	require.NoError(t, err)
+
+	_, err = newMongoInput(mongoConfig, service.MockResources())
+	require.Error(t, err)
+}
+
+func TestMongoChangeStreamInputConfig(t *testing.T) {
+	conf := `
+url: "mongodb://localhost:27017"
+database: "foo"
+mode: change_stream
+change_stream:
+  full_document: updateLookup
+  pipeline: |
+    root = [ { "$match": { "operationType": "insert" } } ]
+`
+
+	spec := mongoConfigSpec()
+	env := service.NewEnvironment()
+
+	mongoConfig, err := spec.ParseYAML(conf, env)
+	require.NoError(t, err)
+
+	csInput, err := newMongoInput(mongoConfig, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, csInput.Close(context.Background()))
+}
+
+func TestMongoChangeStreamInputBadPipeline(t *testing.T) {
+	conf := `
+url: "mongodb://localhost:27017"
+mode: change_stream
+change_stream:
+  pipeline: |
+    root = { "$match": { "operationType": "insert" } }
+`
+
+	spec := mongoConfigSpec()
+	env := service.NewEnvironment()
+
+	mongoConfig, err := spec.ParseYAML(conf, env)
+	require.NoError(t, err)
+
+	_, err = newMongoInput(mongoConfig, service.MockResources())
+	require.Error(t, err)
+}
+
+func TestMongoChangeStreamInputMissingCache(t *testing.T) {
+	conf := `
+url: "mongodb://localhost:27017"
+mode: change_stream
+change_stream:
+  checkpoint_cache: nope
+`
+
+	spec := mongoConfigSpec()
+	env := service.NewEnvironment()
+
+	mongoConfig, err := spec.ParseYAML(conf, env)
+	require.NoError(t, err)
+
+	_, err = newMongoInput(mongoConfig, service.MockResources())
+	require.Error(t, err)
+}
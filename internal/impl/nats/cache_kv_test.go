@@ -0,0 +1,34 @@
+package nats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestCacheKVConfigParse(t *testing.T) {
+	spec := natsKVCacheConfig()
+	env := service.NewEnvironment()
+
+	cacheConfig := `
+urls: [ url1, url2 ]
+bucket: testbucket
+auth:
+  nkey_file: test auth n key file
+  user_credentials_file: test auth user creds file
+`
+
+	conf, err := spec.ParseYAML(cacheConfig, env)
+	require.NoError(t, err)
+
+	c, err := newKVCacheFromConfig(conf, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "url1,url2", c.urls)
+	assert.Equal(t, "testbucket", c.bucket)
+	assert.Equal(t, "test auth n key file", c.authConf.NKeyFile)
+	assert.Equal(t, "test auth user creds file", c.authConf.UserCredentialsFile)
+}
@@ -0,0 +1,57 @@
+package nats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestInputKVConfigParse(t *testing.T) {
+	spec := natsKVInputConfig()
+	env := service.NewEnvironment()
+
+	inputConfig := `
+urls: [ url1, url2 ]
+bucket: testbucket
+key: foo.*
+ignore_deletes: true
+auth:
+  nkey_file: test auth n key file
+  user_credentials_file: test auth user creds file
+`
+
+	conf, err := spec.ParseYAML(inputConfig, env)
+	require.NoError(t, err)
+
+	e, err := newKVReaderFromConfig(conf, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "url1,url2", e.urls)
+	assert.Equal(t, "testbucket", e.bucket)
+	assert.Equal(t, "foo.*", e.key)
+	assert.True(t, e.ignoreDeletes)
+	assert.False(t, e.includeHistory)
+	assert.False(t, e.metaOnly)
+	assert.Equal(t, "test auth n key file", e.authConf.NKeyFile)
+	assert.Equal(t, "test auth user creds file", e.authConf.UserCredentialsFile)
+}
+
+func TestInputKVConfigDefaults(t *testing.T) {
+	spec := natsKVInputConfig()
+	env := service.NewEnvironment()
+
+	conf, err := spec.ParseYAML(`
+urls: [ url1 ]
+bucket: testbucket
+`, env)
+	require.NoError(t, err)
+
+	e, err := newKVReaderFromConfig(conf, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, ">", e.key)
+	assert.False(t, e.ignoreDeletes)
+}
@@ -0,0 +1,219 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/integration"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestIntegrationNatsKVCache(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	pool.MaxWait = time.Second * 30
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "nats",
+		Tag:        "latest",
+		Cmd:        []string{"--js"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Purge(resource))
+	})
+
+	var natsConn *nats.Conn
+	resource.Expire(900)
+	require.NoError(t, pool.Retry(func() error {
+		natsConn, err = nats.Connect(fmt.Sprintf("tcp://localhost:%v", resource.GetPort("4222/tcp")))
+		return err
+	}))
+	t.Cleanup(func() {
+		natsConn.Close()
+	})
+
+	template := `
+cache_resources:
+  - label: testcache
+    nats_kv:
+      urls: [ nats://localhost:$PORT ]
+      bucket: bucket-$ID
+`
+	suite := integration.CacheTests(
+		integration.CacheTestOpenClose(),
+		integration.CacheTestMissingKey(),
+		integration.CacheTestDoubleAdd(),
+		integration.CacheTestDelete(),
+		integration.CacheTestGetAndSet(50),
+	)
+	suite.Run(
+		t, template,
+		integration.CacheTestOptPreTest(func(t testing.TB, ctx context.Context, testID string, vars *integration.CacheTestConfigVars) {
+			js, err := natsConn.JetStream()
+			require.NoError(t, err)
+
+			_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+				Bucket: "bucket-" + testID,
+			})
+			require.NoError(t, err)
+		}),
+		integration.CacheTestOptPort(resource.GetPort("4222/tcp")),
+	)
+}
+
+func TestIntegrationNatsKVInput(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	pool.MaxWait = time.Second * 30
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "nats",
+		Tag:        "latest",
+		Cmd:        []string{"--js"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Purge(resource))
+	})
+
+	var natsConn *nats.Conn
+	resource.Expire(900)
+	require.NoError(t, pool.Retry(func() error {
+		natsConn, err = nats.Connect(fmt.Sprintf("tcp://localhost:%v", resource.GetPort("4222/tcp")))
+		return err
+	}))
+	t.Cleanup(func() {
+		natsConn.Close()
+	})
+
+	js, err := natsConn.JetStream()
+	require.NoError(t, err)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:  "inputbucket",
+		History: 5,
+	})
+	require.NoError(t, err)
+
+	_, err = kv.Put("foo", []byte("bar1"))
+	require.NoError(t, err)
+	_, err = kv.Put("foo", []byte("bar2"))
+	require.NoError(t, err)
+	_, err = kv.Put("baz", []byte("buz"))
+	require.NoError(t, err)
+	require.NoError(t, kv.Delete("baz"))
+
+	type kvMsg struct {
+		key       string
+		value     string
+		revision  string
+		operation string
+	}
+
+	readMessages := func(t *testing.T, extraConf string, count int) []kvMsg {
+		t.Helper()
+
+		conf, err := natsKVInputConfig().ParseYAML(fmt.Sprintf(`
+urls: [ nats://localhost:%v ]
+bucket: inputbucket
+%v
+`, resource.GetPort("4222/tcp"), extraConf), nil)
+		require.NoError(t, err)
+
+		r, err := newKVReaderFromConfig(conf, service.MockResources().Logger())
+		require.NoError(t, err)
+
+		ctx, done := context.WithTimeout(context.Background(), time.Second*30)
+		defer done()
+
+		require.NoError(t, r.Connect(ctx))
+		t.Cleanup(func() {
+			assert.NoError(t, r.Close(context.Background()))
+		})
+
+		var msgs []kvMsg
+		for i := 0; i < count; i++ {
+			msg, ackFn, err := r.Read(ctx)
+			require.NoError(t, err)
+			require.NoError(t, ackFn(ctx, nil))
+
+			b, err := msg.AsBytes()
+			require.NoError(t, err)
+
+			bucket, _ := msg.MetaGet("nats_kv_bucket")
+			assert.Equal(t, "inputbucket", bucket)
+			created, _ := msg.MetaGet("nats_kv_created")
+			_, err = time.Parse(time.RFC3339Nano, created)
+			assert.NoError(t, err)
+			_, exists := msg.MetaGet("nats_kv_delta")
+			assert.True(t, exists)
+
+			key, _ := msg.MetaGet("nats_kv_key")
+			revision, _ := msg.MetaGet("nats_kv_revision")
+			operation, _ := msg.MetaGet("nats_kv_operation")
+			msgs = append(msgs, kvMsg{
+				key:       key,
+				value:     string(b),
+				revision:  revision,
+				operation: operation,
+			})
+		}
+
+		// Nothing beyond the expected messages should be emitted.
+		emptyCtx, emptyDone := context.WithTimeout(context.Background(), time.Millisecond*500)
+		defer emptyDone()
+		_, _, err = r.Read(emptyCtx)
+		assert.Equal(t, context.DeadlineExceeded, err)
+
+		return msgs
+	}
+
+	t.Run("latest", func(t *testing.T) {
+		assert.Equal(t, []kvMsg{
+			{key: "foo", value: "bar2", revision: "2", operation: "PUT"},
+			{key: "baz", value: "", revision: "4", operation: "DELETE"},
+		}, readMessages(t, "", 2))
+	})
+
+	t.Run("ignore deletes", func(t *testing.T) {
+		assert.Equal(t, []kvMsg{
+			{key: "foo", value: "bar2", revision: "2", operation: "PUT"},
+		}, readMessages(t, "ignore_deletes: true", 1))
+	})
+
+	t.Run("include history", func(t *testing.T) {
+		assert.Equal(t, []kvMsg{
+			{key: "foo", value: "bar1", revision: "1", operation: "PUT"},
+			{key: "foo", value: "bar2", revision: "2", operation: "PUT"},
+			{key: "baz", value: "buz", revision: "3", operation: "PUT"},
+			{key: "baz", value: "", revision: "4", operation: "DELETE"},
+		}, readMessages(t, "include_history: true", 4))
+	})
+
+	t.Run("meta only", func(t *testing.T) {
+		assert.Equal(t, []kvMsg{
+			{key: "foo", value: "", revision: "2", operation: "PUT"},
+			{key: "baz", value: "", revision: "4", operation: "DELETE"},
+		}, readMessages(t, "meta_only: true", 2))
+	})
+
+	t.Run("key filter", func(t *testing.T) {
+		assert.Equal(t, []kvMsg{
+			{key: "foo", value: "bar2", revision: "2", operation: "PUT"},
+		}, readMessages(t, "key: foo", 1))
+	})
+}
@@ -0,0 +1,310 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func anyValueToInterface(v *commonv1.AnyValue) interface{} {
+	switch t := v.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return t.StringValue
+	case *commonv1.AnyValue_BoolValue:
+		return t.BoolValue
+	case *commonv1.AnyValue_IntValue:
+		return t.IntValue
+	case *commonv1.AnyValue_DoubleValue:
+		return t.DoubleValue
+	case *commonv1.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(t.BytesValue)
+	case *commonv1.AnyValue_ArrayValue:
+		arr := make([]interface{}, len(t.ArrayValue.GetValues()))
+		for i, e := range t.ArrayValue.GetValues() {
+			arr[i] = anyValueToInterface(e)
+		}
+		return arr
+	case *commonv1.AnyValue_KvlistValue:
+		return attributesToMap(t.KvlistValue.GetValues())
+	}
+	return nil
+}
+
+func attributesToMap(attrs []*commonv1.KeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		m[kv.GetKey()] = anyValueToInterface(kv.GetValue())
+	}
+	return m
+}
+
+func resourceToMap(r *resourcev1.Resource, schemaURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"attributes": attributesToMap(r.GetAttributes()),
+		"schema_url": schemaURL,
+	}
+}
+
+func scopeToMap(s *commonv1.InstrumentationScope, schemaURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       s.GetName(),
+		"version":    s.GetVersion(),
+		"attributes": attributesToMap(s.GetAttributes()),
+		"schema_url": schemaURL,
+	}
+}
+
+func newSignalMessage(signal string, v map[string]interface{}) *service.Message {
+	msg := service.NewMessage(nil)
+	msg.MetaSet("otlp_signal", signal)
+	msg.SetStructured(v)
+	return msg
+}
+
+//------------------------------------------------------------------------------
+
+// logsToBatches converts each resource of a logs request into a batch
+// containing a message per log record.
+func logsToBatches(resLogs []*logsv1.ResourceLogs) []service.MessageBatch {
+	batches := make([]service.MessageBatch, 0, len(resLogs))
+	for _, rl := range resLogs {
+		var batch service.MessageBatch
+		for _, sl := range rl.GetScopeLogs() {
+			for _, lr := range sl.GetLogRecords() {
+				batch = append(batch, newSignalMessage("logs", map[string]interface{}{
+					"resource":                resourceToMap(rl.GetResource(), rl.GetSchemaUrl()),
+					"scope":                   scopeToMap(sl.GetScope(), sl.GetSchemaUrl()),
+					"time_unix_nano":          int64(lr.GetTimeUnixNano()),
+					"observed_time_unix_nano": int64(lr.GetObservedTimeUnixNano()),
+					"severity_number":         int64(lr.GetSeverityNumber()),
+					"severity_text":           lr.GetSeverityText(),
+					"body":                    anyValueToInterface(lr.GetBody()),
+					"attributes":              attributesToMap(lr.GetAttributes()),
+					"flags":                   int64(lr.GetFlags()),
+					"trace_id":                hex.EncodeToString(lr.GetTraceId()),
+					"span_id":                 hex.EncodeToString(lr.GetSpanId()),
+				}))
+			}
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// spansToBatches converts each resource of a traces request into a batch
+// containing a message per span.
+func spansToBatches(resSpans []*tracev1.ResourceSpans) []service.MessageBatch {
+	batches := make([]service.MessageBatch, 0, len(resSpans))
+	for _, rs := range resSpans {
+		var batch service.MessageBatch
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				events := make([]interface{}, len(span.GetEvents()))
+				for i, e := range span.GetEvents() {
+					events[i] = map[string]interface{}{
+						"name":           e.GetName(),
+						"time_unix_nano": int64(e.GetTimeUnixNano()),
+						"attributes":     attributesToMap(e.GetAttributes()),
+					}
+				}
+				links := make([]interface{}, len(span.GetLinks()))
+				for i, l := range span.GetLinks() {
+					links[i] = map[string]interface{}{
+						"trace_id":    hex.EncodeToString(l.GetTraceId()),
+						"span_id":     hex.EncodeToString(l.GetSpanId()),
+						"trace_state": l.GetTraceState(),
+						"attributes":  attributesToMap(l.GetAttributes()),
+					}
+				}
+				batch = append(batch, newSignalMessage("traces", map[string]interface{}{
+					"resource":             resourceToMap(rs.GetResource(), rs.GetSchemaUrl()),
+					"scope":                scopeToMap(ss.GetScope(), ss.GetSchemaUrl()),
+					"trace_id":             hex.EncodeToString(span.GetTraceId()),
+					"span_id":              hex.EncodeToString(span.GetSpanId()),
+					"parent_span_id":       hex.EncodeToString(span.GetParentSpanId()),
+					"trace_state":          span.GetTraceState(),
+					"name":                 span.GetName(),
+					"kind":                 span.GetKind().String(),
+					"start_time_unix_nano": int64(span.GetStartTimeUnixNano()),
+					"end_time_unix_nano":   int64(span.GetEndTimeUnixNano()),
+					"attributes":           attributesToMap(span.GetAttributes()),
+					"events":               events,
+					"links":                links,
+					"status": map[string]interface{}{
+						"code":    span.GetStatus().GetCode().String(),
+						"message": span.GetStatus().GetMessage(),
+					},
+				}))
+			}
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+//------------------------------------------------------------------------------
+
+func optionalFloat(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+func uint64sToInterfaces(s []uint64) []interface{} {
+	arr := make([]interface{}, len(s))
+	for i, v := range s {
+		arr[i] = int64(v)
+	}
+	return arr
+}
+
+func float64sToInterfaces(s []float64) []interface{} {
+	arr := make([]interface{}, len(s))
+	for i, v := range s {
+		arr[i] = v
+	}
+	return arr
+}
+
+func exponentialBucketsToMap(b *metricsv1.ExponentialHistogramDataPoint_Buckets) map[string]interface{} {
+	return map[string]interface{}{
+		"offset":        int64(b.GetOffset()),
+		"bucket_counts": uint64sToInterfaces(b.GetBucketCounts()),
+	}
+}
+
+// metricDataPoints returns the type of a metric along with a structured
+// representation of each of its data points, where the fields common to all
+// data points (attributes and timestamps) are added by the caller.
+func metricDataPoints(m *metricsv1.Metric) (string, map[string]interface{}, []map[string]interface{}, []dataPointCommon) {
+	var points []map[string]interface{}
+	var commons []dataPointCommon
+
+	addNumberPoints := func(dps []*metricsv1.NumberDataPoint) {
+		for _, dp := range dps {
+			var value interface{}
+			switch t := dp.GetValue().(type) {
+			case *metricsv1.NumberDataPoint_AsInt:
+				value = t.AsInt
+			case *metricsv1.NumberDataPoint_AsDouble:
+				value = t.AsDouble
+			}
+			points = append(points, map[string]interface{}{
+				"value": value,
+			})
+			commons = append(commons, dp)
+		}
+	}
+
+	switch {
+	case m.GetGauge() != nil:
+		addNumberPoints(m.GetGauge().GetDataPoints())
+		return "gauge", map[string]interface{}{}, points, commons
+	case m.GetSum() != nil:
+		sum := m.GetSum()
+		addNumberPoints(sum.GetDataPoints())
+		return "sum", map[string]interface{}{
+			"aggregation_temporality": sum.GetAggregationTemporality().String(),
+			"is_monotonic":            sum.GetIsMonotonic(),
+		}, points, commons
+	case m.GetHistogram() != nil:
+		hist := m.GetHistogram()
+		for _, dp := range hist.GetDataPoints() {
+			points = append(points, map[string]interface{}{
+				"count":           int64(dp.GetCount()),
+				"sum":             optionalFloat(dp.Sum),
+				"min":             optionalFloat(dp.Min),
+				"max":             optionalFloat(dp.Max),
+				"bucket_counts":   uint64sToInterfaces(dp.GetBucketCounts()),
+				"explicit_bounds": float64sToInterfaces(dp.GetExplicitBounds()),
+			})
+			commons = append(commons, dp)
+		}
+		return "histogram", map[string]interface{}{
+			"aggregation_temporality": hist.GetAggregationTemporality().String(),
+		}, points, commons
+	case m.GetExponentialHistogram() != nil:
+		hist := m.GetExponentialHistogram()
+		for _, dp := range hist.GetDataPoints() {
+			points = append(points, map[string]interface{}{
+				"count":      int64(dp.GetCount()),
+				"sum":        optionalFloat(dp.Sum),
+				"min":        optionalFloat(dp.Min),
+				"max":        optionalFloat(dp.Max),
+				"scale":      int64(dp.GetScale()),
+				"zero_count": int64(dp.GetZeroCount()),
+				"positive":   exponentialBucketsToMap(dp.GetPositive()),
+				"negative":   exponentialBucketsToMap(dp.GetNegative()),
+			})
+			commons = append(commons, dp)
+		}
+		return "exponential_histogram", map[string]interface{}{
+			"aggregation_temporality": hist.GetAggregationTemporality().String(),
+		}, points, commons
+	case m.GetSummary() != nil:
+		for _, dp := range m.GetSummary().GetDataPoints() {
+			quantiles := make([]interface{}, len(dp.GetQuantileValues()))
+			for i, q := range dp.GetQuantileValues() {
+				quantiles[i] = map[string]interface{}{
+					"quantile": q.GetQuantile(),
+					"value":    q.GetValue(),
+				}
+			}
+			points = append(points, map[string]interface{}{
+				"count":           int64(dp.GetCount()),
+				"sum":             dp.GetSum(),
+				"quantile_values": quantiles,
+			})
+			commons = append(commons, dp)
+		}
+		return "summary", map[string]interface{}{}, points, commons
+	}
+	return "", nil, nil, nil
+}
+
+type dataPointCommon interface {
+	GetAttributes() []*commonv1.KeyValue
+	GetStartTimeUnixNano() uint64
+	GetTimeUnixNano() uint64
+	GetFlags() uint32
+}
+
+// metricsToBatches converts each resource of a metrics request into a batch
+// containing a message per data point.
+func metricsToBatches(resMetrics []*metricsv1.ResourceMetrics) []service.MessageBatch {
+	batches := make([]service.MessageBatch, 0, len(resMetrics))
+	for _, rm := range resMetrics {
+		var batch service.MessageBatch
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				metricType, metricFields, points, commons := metricDataPoints(m)
+				for i, point := range points {
+					point["resource"] = resourceToMap(rm.GetResource(), rm.GetSchemaUrl())
+					point["scope"] = scopeToMap(sm.GetScope(), sm.GetSchemaUrl())
+					point["name"] = m.GetName()
+					point["description"] = m.GetDescription()
+					point["unit"] = m.GetUnit()
+					point["type"] = metricType
+					for k, v := range metricFields {
+						point[k] = v
+					}
+					point["attributes"] = attributesToMap(commons[i].GetAttributes())
+					point["start_time_unix_nano"] = int64(commons[i].GetStartTimeUnixNano())
+					point["time_unix_nano"] = int64(commons[i].GetTimeUnixNano())
+					point["flags"] = int64(commons[i].GetFlags())
+					batch = append(batch, newSignalMessage("metrics", point))
+				}
+			}
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
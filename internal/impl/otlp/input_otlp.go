@@ -0,0 +1,541 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"time"
+
+	collogsv1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricsv1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/benthosdev/benthos/v4/internal/impl/shared"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func otlpInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Receives logs, traces and metrics via the OpenTelemetry Protocol (OTLP) over gRPC and HTTP.").
+		Description(`
+OTLP requests are accepted over gRPC at the `+"`grpc_address`"+`, and over HTTP with either protobuf (`+"`application/x-protobuf`"+`) or JSON (`+"`application/json`"+`) encoded bodies at the paths `+"`logs_path`, `traces_path` and `metrics_path`"+`. If the `+"`http_address`"+` field is left blank the [service-wide HTTP server](/docs/components/http/about) will be used.
+
+### Message Format
+
+A message is created for each log record, span and metric data point, where the attributes of the resource and instrumentation scope of the record are preserved within the fields `+"`resource` and `scope`"+`:
+
+`+"```json"+`
+{
+  "resource": {
+    "attributes": { "service.name": "checkout" },
+    "schema_url": ""
+  },
+  "scope": {
+    "name": "io.opentelemetry.contrib.mongodb",
+    "version": "1.0.0",
+    "attributes": {},
+    "schema_url": ""
+  },
+  "time_unix_nano": 1641038400000000000,
+  "observed_time_unix_nano": 1641038400000000000,
+  "severity_number": 9,
+  "severity_text": "INFO",
+  "body": "payment accepted",
+  "attributes": { "order.id": "abc123" },
+  "flags": 0,
+  "trace_id": "5b8efff798038103d269b633813fc60c",
+  "span_id": "eee19b7ec3c1b174"
+}
+`+"```"+`
+
+Trace and span identifiers are hex encoded, timestamps are nanoseconds since the unix epoch, and enums such as span kinds and status codes are represented by their names. Spans are flattened in the same way, with the fields `+"`trace_id`, `span_id`, `parent_span_id`, `trace_state`, `name`, `kind`, `start_time_unix_nano`, `end_time_unix_nano`, `attributes`, `events`, `links` and `status`"+`.
+
+Each data point of a metric is flattened along with the `+"`name`, `description` and `unit`"+` of the metric and a `+"`type`"+` of either `+"`gauge`, `sum`, `histogram`, `exponential_histogram` or `summary`"+`. Gauge and sum data points have a numeric `+"`value`"+`, whereas the remaining types contain the fields of their respective data point types.
+
+The type of signal (`+"`logs`, `traces` or `metrics`"+`) is added to each message as the metadata field `+"`otlp_signal`"+`.
+
+### Acknowledgements
+
+The records of each resource within a request are consumed as a batch, and a response is only returned to the client once all batches of the request have been acknowledged or the `+"`timeout`"+` is reached. When only some of the batches are rejected the response indicates a partial success along with the number of rejected records, in which case the client will not retry the request. When all batches are rejected a retryable error is returned instead (`+"`UNAVAILABLE`"+` for gRPC and `+"`503`"+` for HTTP).`).
+		Field(service.NewStringField("grpc_address").
+			Description("The address to listen for OTLP gRPC requests on. Set to an empty string in order to disable gRPC.").
+			Default("0.0.0.0:4317")).
+		Field(service.NewStringField("http_address").
+			Description("An alternative address to host OTLP HTTP endpoints from. If left empty the service wide address is used.").
+			Default("")).
+		Field(service.NewStringField("logs_path").
+			Description("The HTTP endpoint path to receive logs from.").
+			Default("/v1/logs")).
+		Field(service.NewStringField("traces_path").
+			Description("The HTTP endpoint path to receive traces from.").
+			Default("/v1/traces")).
+		Field(service.NewStringField("metrics_path").
+			Description("The HTTP endpoint path to receive metrics from.").
+			Default("/v1/metrics")).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for the messages of a request to be acknowledged before responding with an error.").
+			Default("5s").
+			Advanced()).
+		Field(service.NewStringField("cert_file").
+			Description("Enable TLS by specifying a certificate and key file. Applies to the gRPC server and to HTTP only when a custom `http_address` is specified.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringField("key_file").
+			Description("Enable TLS by specifying a certificate and key file. Applies to the gRPC server and to HTTP only when a custom `http_address` is specified.").
+			Default("").
+			Advanced()).
+		Field(shared.HTTPReceiverMaxBodySizeField()).
+		Example("Error Spans", "Receive traces from applications and forward only the spans that resulted in an error:", `
+input:
+  otlp:
+    grpc_address: 0.0.0.0:4317
+
+pipeline:
+  processors:
+    - bloblang: |
+        root = if meta("otlp_signal") != "traces" || this.status.code != "STATUS_CODE_ERROR" { deleted() }
+
+output:
+  stdout: {}
+`)
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"otlp", otlpInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			return newOTLPInputFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// otlpSignal describes how the export requests and responses of a signal type
+// are created and converted, and is shared by the gRPC and HTTP servers.
+type otlpSignal struct {
+	newRequest  func() proto.Message
+	toBatches   func(req proto.Message) []service.MessageBatch
+	newResponse func(rejected int64, errMsg string) proto.Message
+}
+
+var (
+	otlpLogsSignal = otlpSignal{
+		newRequest: func() proto.Message {
+			return &collogsv1.ExportLogsServiceRequest{}
+		},
+		toBatches: func(req proto.Message) []service.MessageBatch {
+			return logsToBatches(req.(*collogsv1.ExportLogsServiceRequest).GetResourceLogs())
+		},
+		newResponse: func(rejected int64, errMsg string) proto.Message {
+			res := &collogsv1.ExportLogsServiceResponse{}
+			if rejected > 0 {
+				res.PartialSuccess = &collogsv1.ExportLogsPartialSuccess{
+					RejectedLogRecords: rejected,
+					ErrorMessage:       errMsg,
+				}
+			}
+			return res
+		},
+	}
+	otlpTracesSignal = otlpSignal{
+		newRequest: func() proto.Message {
+			return &coltracev1.ExportTraceServiceRequest{}
+		},
+		toBatches: func(req proto.Message) []service.MessageBatch {
+			return spansToBatches(req.(*coltracev1.ExportTraceServiceRequest).GetResourceSpans())
+		},
+		newResponse: func(rejected int64, errMsg string) proto.Message {
+			res := &coltracev1.ExportTraceServiceResponse{}
+			if rejected > 0 {
+				res.PartialSuccess = &coltracev1.ExportTracePartialSuccess{
+					RejectedSpans: rejected,
+					ErrorMessage:  errMsg,
+				}
+			}
+			return res
+		},
+	}
+	otlpMetricsSignal = otlpSignal{
+		newRequest: func() proto.Message {
+			return &colmetricsv1.ExportMetricsServiceRequest{}
+		},
+		toBatches: func(req proto.Message) []service.MessageBatch {
+			return metricsToBatches(req.(*colmetricsv1.ExportMetricsServiceRequest).GetResourceMetrics())
+		},
+		newResponse: func(rejected int64, errMsg string) proto.Message {
+			res := &colmetricsv1.ExportMetricsServiceResponse{}
+			if rejected > 0 {
+				res.PartialSuccess = &colmetricsv1.ExportMetricsPartialSuccess{
+					RejectedDataPoints: rejected,
+					ErrorMessage:       errMsg,
+				}
+			}
+			return res
+		},
+	}
+)
+
+//------------------------------------------------------------------------------
+
+type otlpInput struct {
+	grpcAddress string
+	paths       map[string]otlpSignal
+	timeout     time.Duration
+
+	log          *service.Logger
+	grpcServer   *grpc.Server
+	grpcListener net.Listener
+	receiver     *shared.HTTPReceiver
+}
+
+func newOTLPInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*otlpInput, error) {
+	o := &otlpInput{
+		log:   mgr.Logger(),
+		paths: map[string]otlpSignal{},
+	}
+
+	var err error
+	if o.grpcAddress, err = conf.FieldString("grpc_address"); err != nil {
+		return nil, err
+	}
+	for field, signal := range map[string]otlpSignal{
+		"logs_path":    otlpLogsSignal,
+		"traces_path":  otlpTracesSignal,
+		"metrics_path": otlpMetricsSignal,
+	} {
+		path, err := conf.FieldString(field)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := o.paths[path]; exists {
+			return nil, fmt.Errorf("path %v is used by more than one signal", path)
+		}
+		o.paths[path] = signal
+	}
+	if o.timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	certFile, err := conf.FieldString("cert_file")
+	if err != nil {
+		return nil, err
+	}
+	keyFile, err := conf.FieldString("key_file")
+	if err != nil {
+		return nil, err
+	}
+
+	if o.grpcAddress != "" {
+		var opts []grpc.ServerOption
+		if certFile != "" || keyFile != "" {
+			creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load TLS credentials: %w", err)
+			}
+			opts = append(opts, grpc.Creds(creds))
+		}
+		o.grpcServer = grpc.NewServer(opts...)
+		collogsv1.RegisterLogsServiceServer(o.grpcServer, otlpLogsServer{o: o})
+		coltracev1.RegisterTraceServiceServer(o.grpcServer, otlpTraceServer{o: o})
+		colmetricsv1.RegisterMetricsServiceServer(o.grpcServer, otlpMetricsServer{o: o})
+	}
+
+	rConf := shared.HTTPReceiverConfig{
+		Name:        "OTLP HTTP",
+		Description: "Receive OTLP export requests.",
+		Endpoints:   map[string]http.HandlerFunc{},
+	}
+	for path, signal := range o.paths {
+		rConf.Endpoints[path] = o.httpHandler(signal)
+	}
+	if rConf.Address, err = conf.FieldString("http_address"); err != nil {
+		return nil, err
+	}
+	if rConf.Address != "" {
+		// The TLS files also apply to the gRPC server and are therefore only
+		// used for HTTP when hosting a dedicated server.
+		rConf.CertFile, rConf.KeyFile = certFile, keyFile
+	}
+	maxBodySize, err := conf.FieldInt("max_body_size")
+	if err != nil {
+		return nil, err
+	}
+	rConf.MaxBodySize = int64(maxBodySize)
+
+	if o.receiver, err = shared.NewHTTPReceiver(rConf, mgr); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+//------------------------------------------------------------------------------
+
+var errOTLPTimeout = errors.New("timed out waiting for acknowledgement")
+
+// export sends each batch through the pipeline and waits for them to be
+// acknowledged, returning the total number of records and the number that
+// were rejected along with the last error encountered.
+func (o *otlpInput) export(ctx context.Context, batches []service.MessageBatch) (total, rejected int64, err error) {
+	ctx, done := context.WithTimeout(ctx, o.timeout)
+	defer done()
+
+	type pendingBatch struct {
+		size    int64
+		resChan <-chan error
+	}
+	var pending []pendingBatch
+
+	reject := func(size int64, e error) {
+		if errors.Is(e, context.DeadlineExceeded) {
+			e = errOTLPTimeout
+		}
+		rejected += size
+		err = e
+	}
+
+	for _, batch := range batches {
+		if len(batch) == 0 {
+			continue
+		}
+		size := int64(len(batch))
+		total += size
+
+		resChan, sendErr := o.receiver.Send(ctx, batch)
+		if sendErr != nil {
+			reject(size, sendErr)
+			continue
+		}
+		pending = append(pending, pendingBatch{size: size, resChan: resChan})
+	}
+
+	for _, p := range pending {
+		if ackErr := o.receiver.Await(ctx, p.resChan); ackErr != nil {
+			reject(p.size, ackErr)
+		}
+	}
+	return
+}
+
+// handleExport converts and exports a request, returning either a response,
+// which indicates a partial success when some of the records were rejected,
+// or an error when all of the records were rejected.
+func (o *otlpInput) handleExport(ctx context.Context, signal otlpSignal, req proto.Message) (proto.Message, error) {
+	total, rejected, err := o.export(ctx, signal.toBatches(req))
+	if rejected > 0 && rejected == total {
+		return nil, err
+	}
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+	}
+	return signal.newResponse(rejected, errMsg), nil
+}
+
+//------------------------------------------------------------------------------
+
+type otlpLogsServer struct {
+	collogsv1.UnimplementedLogsServiceServer
+	o *otlpInput
+}
+
+func (s otlpLogsServer) Export(ctx context.Context, req *collogsv1.ExportLogsServiceRequest) (*collogsv1.ExportLogsServiceResponse, error) {
+	res, err := s.o.handleExport(ctx, otlpLogsSignal, req)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return res.(*collogsv1.ExportLogsServiceResponse), nil
+}
+
+type otlpTraceServer struct {
+	coltracev1.UnimplementedTraceServiceServer
+	o *otlpInput
+}
+
+func (s otlpTraceServer) Export(ctx context.Context, req *coltracev1.ExportTraceServiceRequest) (*coltracev1.ExportTraceServiceResponse, error) {
+	res, err := s.o.handleExport(ctx, otlpTracesSignal, req)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return res.(*coltracev1.ExportTraceServiceResponse), nil
+}
+
+type otlpMetricsServer struct {
+	colmetricsv1.UnimplementedMetricsServiceServer
+	o *otlpInput
+}
+
+func (s otlpMetricsServer) Export(ctx context.Context, req *colmetricsv1.ExportMetricsServiceRequest) (*colmetricsv1.ExportMetricsServiceResponse, error) {
+	res, err := s.o.handleExport(ctx, otlpMetricsSignal, req)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return res.(*colmetricsv1.ExportMetricsServiceResponse), nil
+}
+
+//------------------------------------------------------------------------------
+
+var otlpJSONIDFields = map[string]struct{}{
+	"traceId": {}, "trace_id": {},
+	"spanId": {}, "span_id": {},
+	"parentSpanId": {}, "parent_span_id": {},
+}
+
+// otlpJSONFixIDs walks a decoded OTLP/JSON document and converts trace and
+// span identifiers from the hex encoding used by OTLP/JSON into the base64
+// encoding expected by the standard protobuf JSON mapping.
+func otlpJSONFixIDs(v interface{}) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if _, isID := otlpJSONIDFields[k]; isID {
+				if str, ok := e.(string); ok {
+					b, err := hex.DecodeString(str)
+					if err != nil {
+						return fmt.Errorf("field %v: %w", k, err)
+					}
+					t[k] = base64.StdEncoding.EncodeToString(b)
+					continue
+				}
+			}
+			if err := otlpJSONFixIDs(e); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			if err := otlpJSONFixIDs(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func unmarshalOTLPJSON(b []byte, m proto.Message) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if err := otlpJSONFixIDs(v); err != nil {
+		return err
+	}
+	fixed, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(fixed, m)
+}
+
+func (o *otlpInput) httpHandler(signal otlpSignal) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var isJSON bool
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/x-protobuf":
+		case "application/json":
+			isJSON = true
+		default:
+			http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		reqBytes, err := o.receiver.ReadBody(r)
+		if err != nil {
+			if errors.Is(err, shared.ErrHTTPBodyTooLarge) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Failed to read request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req := signal.newRequest()
+		if isJSON {
+			err = unmarshalOTLPJSON(reqBytes, req)
+		} else {
+			err = proto.Unmarshal(reqBytes, req)
+		}
+		if err != nil {
+			http.Error(w, "Failed to decode request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res, err := o.handleExport(r.Context(), signal, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		var resBytes []byte
+		if isJSON {
+			resBytes, err = protojson.Marshal(res)
+		} else {
+			resBytes, err = proto.Marshal(res)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mediaType)
+		_, _ = w.Write(resBytes)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (o *otlpInput) Connect(ctx context.Context) error {
+	if o.grpcServer != nil && o.grpcListener == nil {
+		lis, err := net.Listen("tcp", o.grpcAddress)
+		if err != nil {
+			return err
+		}
+		o.grpcListener = lis
+		o.log.Infof("Receiving OTLP gRPC requests at: %v", lis.Addr())
+		go func() {
+			if err := o.grpcServer.Serve(lis); err != nil {
+				o.log.Errorf("gRPC server error: %v", err)
+			}
+		}()
+	}
+	return o.receiver.Connect(ctx)
+}
+
+func (o *otlpInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	return o.receiver.ReadBatch(ctx)
+}
+
+func (o *otlpInput) Close(ctx context.Context) error {
+	// Closing the receiver first rejects any pending gRPC exports.
+	err := o.receiver.Close(ctx)
+	if o.grpcServer != nil {
+		o.grpcServer.Stop()
+	}
+	return err
+}
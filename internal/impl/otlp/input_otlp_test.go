@@ -0,0 +1,376 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogsv1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricsv1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func testOTLPInput(t *testing.T, conf string) *otlpInput {
+	t.Helper()
+
+	pConf, err := otlpInputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	o, err := newOTLPInputFromConfig(pConf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, o.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, o.Close(context.Background()))
+	})
+	return o
+}
+
+func readOTLPBatch(t *testing.T, o *otlpInput) ([]string, service.AckFunc) {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	batch, ackFn, err := o.ReadBatch(ctx)
+	require.NoError(t, err)
+
+	var docs []string
+	for _, msg := range batch {
+		signal, _ := msg.MetaGet("otlp_signal")
+		assert.NotEmpty(t, signal)
+
+		b, err := msg.AsBytes()
+		require.NoError(t, err)
+		docs = append(docs, string(b))
+	}
+	return docs, ackFn
+}
+
+func strAttr(k, v string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{
+		Key:   k,
+		Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: v}},
+	}
+}
+
+func testResourceLogs(serviceName string, bodies ...string) *logsv1.ResourceLogs {
+	var records []*logsv1.LogRecord
+	for _, b := range bodies {
+		records = append(records, &logsv1.LogRecord{
+			TimeUnixNano:   1000,
+			SeverityNumber: logsv1.SeverityNumber_SEVERITY_NUMBER_INFO,
+			SeverityText:   "INFO",
+			Body:           &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: b}},
+			Attributes: []*commonv1.KeyValue{
+				{Key: "count", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_IntValue{IntValue: 5}}},
+			},
+			TraceId: []byte{0x01, 0x02},
+			SpanId:  []byte{0x03},
+		})
+	}
+	return &logsv1.ResourceLogs{
+		Resource: &resourcev1.Resource{
+			Attributes: []*commonv1.KeyValue{strAttr("service.name", serviceName)},
+		},
+		ScopeLogs: []*logsv1.ScopeLogs{
+			{
+				Scope:      &commonv1.InstrumentationScope{Name: "testlib", Version: "1.0.0"},
+				LogRecords: records,
+			},
+		},
+	}
+}
+
+func TestOTLPGRPCLogsPartialSuccess(t *testing.T) {
+	o := testOTLPInput(t, `grpc_address: 127.0.0.1:0`)
+
+	conn, err := grpc.Dial(o.grpcListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	type exportRes struct {
+		res *collogsv1.ExportLogsServiceResponse
+		err error
+	}
+	resChan := make(chan exportRes, 1)
+	go func() {
+		res, err := collogsv1.NewLogsServiceClient(conn).Export(context.Background(), &collogsv1.ExportLogsServiceRequest{
+			ResourceLogs: []*logsv1.ResourceLogs{
+				testResourceLogs("foo", "hello world"),
+				testResourceLogs("bar", "first", "second"),
+			},
+		})
+		resChan <- exportRes{res: res, err: err}
+	}()
+
+	docs, ackFn := readOTLPBatch(t, o)
+	require.Len(t, docs, 1)
+	assert.JSONEq(t, `{
+  "resource": {"attributes": {"service.name": "foo"}, "schema_url": ""},
+  "scope": {"name": "testlib", "version": "1.0.0", "attributes": {}, "schema_url": ""},
+  "time_unix_nano": 1000,
+  "observed_time_unix_nano": 0,
+  "severity_number": 9,
+  "severity_text": "INFO",
+  "body": "hello world",
+  "attributes": {"count": 5},
+  "flags": 0,
+  "trace_id": "0102",
+  "span_id": "03"
+}`, docs[0])
+	require.NoError(t, ackFn(context.Background(), nil))
+
+	docs, ackFn = readOTLPBatch(t, o)
+	require.Len(t, docs, 2)
+	require.NoError(t, ackFn(context.Background(), errors.New("nope")))
+
+	res := <-resChan
+	require.NoError(t, res.err)
+	assert.Equal(t, int64(2), res.res.GetPartialSuccess().GetRejectedLogRecords())
+	assert.Equal(t, "nope", res.res.GetPartialSuccess().GetErrorMessage())
+}
+
+func TestOTLPGRPCLogsRejected(t *testing.T) {
+	o := testOTLPInput(t, `grpc_address: 127.0.0.1:0`)
+
+	conn, err := grpc.Dial(o.grpcListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := collogsv1.NewLogsServiceClient(conn).Export(context.Background(), &collogsv1.ExportLogsServiceRequest{
+			ResourceLogs: []*logsv1.ResourceLogs{testResourceLogs("foo", "hello world")},
+		})
+		errChan <- err
+	}()
+
+	_, ackFn := readOTLPBatch(t, o)
+	require.NoError(t, ackFn(context.Background(), errors.New("nope")))
+
+	err = <-errChan
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestOTLPHTTPJSONTraces(t *testing.T) {
+	o := testOTLPInput(t, `grpc_address: ""`)
+
+	ts := httptest.NewServer(o.httpHandler(otlpTracesSignal))
+	t.Cleanup(ts.Close)
+
+	type postRes struct {
+		status int
+		body   string
+	}
+	resChan := make(chan postRes, 1)
+	go func() {
+		res, err := http.Post(ts.URL, "application/json", bytes.NewReader([]byte(`{
+  "resourceSpans": [{
+    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "foo"}}]},
+    "scopeSpans": [{
+      "scope": {"name": "testlib"},
+      "spans": [{
+        "traceId": "5b8efff798038103d269b633813fc60c",
+        "spanId": "eee19b7ec3c1b174",
+        "name": "checkout",
+        "kind": 2,
+        "startTimeUnixNano": "1000",
+        "endTimeUnixNano": "2000",
+        "attributes": [{"key": "tags", "value": {"arrayValue": {"values": [{"stringValue": "a"}, {"boolValue": true}]}}}],
+        "events": [{"name": "retry", "timeUnixNano": "1500"}],
+        "status": {"code": 2, "message": "boom"}
+      }]
+    }]
+  }]
+}`)))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		resChan <- postRes{status: res.StatusCode, body: string(body)}
+	}()
+
+	docs, ackFn := readOTLPBatch(t, o)
+	require.Len(t, docs, 1)
+	assert.JSONEq(t, `{
+  "resource": {"attributes": {"service.name": "foo"}, "schema_url": ""},
+  "scope": {"name": "testlib", "version": "", "attributes": {}, "schema_url": ""},
+  "trace_id": "5b8efff798038103d269b633813fc60c",
+  "span_id": "eee19b7ec3c1b174",
+  "parent_span_id": "",
+  "trace_state": "",
+  "name": "checkout",
+  "kind": "SPAN_KIND_SERVER",
+  "start_time_unix_nano": 1000,
+  "end_time_unix_nano": 2000,
+  "attributes": {"tags": ["a", true]},
+  "events": [{"name": "retry", "time_unix_nano": 1500, "attributes": {}}],
+  "links": [],
+  "status": {"code": "STATUS_CODE_ERROR", "message": "boom"}
+}`, docs[0])
+	require.NoError(t, ackFn(context.Background(), nil))
+
+	res := <-resChan
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, `{}`, res.body)
+}
+
+func TestOTLPHTTPProtobufMetrics(t *testing.T) {
+	o := testOTLPInput(t, `grpc_address: ""`)
+
+	ts := httptest.NewServer(o.httpHandler(otlpMetricsSignal))
+	t.Cleanup(ts.Close)
+
+	histSum := 10.5
+	reqBytes, err := proto.Marshal(&colmetricsv1.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricsv1.ResourceMetrics{{
+			ScopeMetrics: []*metricsv1.ScopeMetrics{{
+				Metrics: []*metricsv1.Metric{
+					{
+						Name: "requests",
+						Unit: "1",
+						Data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{
+							AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+							IsMonotonic:            true,
+							DataPoints: []*metricsv1.NumberDataPoint{{
+								Attributes:   []*commonv1.KeyValue{strAttr("code", "200")},
+								TimeUnixNano: 1000,
+								Value:        &metricsv1.NumberDataPoint_AsInt{AsInt: 7},
+							}},
+						}},
+					},
+					{
+						Name: "latency",
+						Data: &metricsv1.Metric_Histogram{Histogram: &metricsv1.Histogram{
+							AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+							DataPoints: []*metricsv1.HistogramDataPoint{{
+								TimeUnixNano:   2000,
+								Count:          3,
+								Sum:            &histSum,
+								BucketCounts:   []uint64{1, 2},
+								ExplicitBounds: []float64{5},
+							}},
+						}},
+					},
+				},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+
+	type postRes struct {
+		status int
+		res    *colmetricsv1.ExportMetricsServiceResponse
+	}
+	resChan := make(chan postRes, 1)
+	go func() {
+		res, err := http.Post(ts.URL, "application/x-protobuf", bytes.NewReader(reqBytes))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+		pRes := &colmetricsv1.ExportMetricsServiceResponse{}
+		_ = proto.Unmarshal(body, pRes)
+		resChan <- postRes{status: res.StatusCode, res: pRes}
+	}()
+
+	docs, ackFn := readOTLPBatch(t, o)
+	require.Len(t, docs, 2)
+	assert.JSONEq(t, `{
+  "resource": {"attributes": {}, "schema_url": ""},
+  "scope": {"name": "", "version": "", "attributes": {}, "schema_url": ""},
+  "name": "requests",
+  "description": "",
+  "unit": "1",
+  "type": "sum",
+  "aggregation_temporality": "AGGREGATION_TEMPORALITY_CUMULATIVE",
+  "is_monotonic": true,
+  "attributes": {"code": "200"},
+  "start_time_unix_nano": 0,
+  "time_unix_nano": 1000,
+  "flags": 0,
+  "value": 7
+}`, docs[0])
+	assert.JSONEq(t, `{
+  "resource": {"attributes": {}, "schema_url": ""},
+  "scope": {"name": "", "version": "", "attributes": {}, "schema_url": ""},
+  "name": "latency",
+  "description": "",
+  "unit": "",
+  "type": "histogram",
+  "aggregation_temporality": "AGGREGATION_TEMPORALITY_DELTA",
+  "attributes": {},
+  "start_time_unix_nano": 0,
+  "time_unix_nano": 2000,
+  "flags": 0,
+  "count": 3,
+  "sum": 10.5,
+  "min": null,
+  "max": null,
+  "bucket_counts": [1, 2],
+  "explicit_bounds": [5]
+}`, docs[1])
+	require.NoError(t, ackFn(context.Background(), nil))
+
+	res := <-resChan
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Nil(t, res.res.GetPartialSuccess())
+}
+
+func TestOTLPHTTPErrors(t *testing.T) {
+	o := testOTLPInput(t, `
+grpc_address: ""
+timeout: 10ms
+`)
+
+	ts := httptest.NewServer(o.httpHandler(otlpLogsSignal))
+	t.Cleanup(ts.Close)
+
+	res, err := http.Post(ts.URL, "text/plain", bytes.NewReader([]byte(`hello`)))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+
+	res, err = http.Post(ts.URL, "application/json", bytes.NewReader([]byte(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"traceId":"nothex"}]}]}]}`)))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// Nothing is consuming from the input and therefore the request times out.
+	res, err = http.Post(ts.URL, "application/json", bytes.NewReader([]byte(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"severityText":"INFO"}]}]}]}`)))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestOTLPDuplicatePaths(t *testing.T) {
+	pConf, err := otlpInputConfig().ParseYAML(`
+logs_path: /foo
+traces_path: /foo
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newOTLPInputFromConfig(pConf, service.MockResources())
+	require.Error(t, err)
+}
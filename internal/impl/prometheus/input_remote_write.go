@@ -0,0 +1,288 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/benthosdev/benthos/v4/internal/impl/shared"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func remoteWriteInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Receives Prometheus [remote write](https://prometheus.io/docs/concepts/remote_write_spec/) requests and creates a message for each sample or series.").
+		Description(`
+If the `+"`address`"+` field is left blank the [service-wide HTTP server](/docs/components/http/about) will be used.
+
+The samples of each request are consumed as a single batch, and a response is only returned to the client once the batch has been acknowledged. If the batch is rejected, or is not acknowledged within the `+"`timeout`"+`, a 5XX status code is returned in order for the client to retry the request.
+
+### Message Format
+
+By default a message is created for each sample, with the following structure:
+
+`+"```json"+`
+{
+  "name": "http_requests_total",
+  "labels": { "job": "api", "instance": "foo:8080" },
+  "value": 1027,
+  "timestamp": 1641038400000
+}
+`+"```"+`
+
+Where the `+"`name`"+` is the value of the `+"`__name__`"+` label, which is omitted from `+"`labels`"+`, and the `+"`timestamp`"+` is in milliseconds since the unix epoch. Special float values are represented as the strings `+"`NaN`"+`, `+"`+Inf`"+` and `+"`-Inf`"+`, with staleness markers represented as the string `+"`StaleNaN`"+` in order to distinguish them from regular `+"`NaN`"+` values.
+
+When `+"`split_samples`"+` is set to `+"`false`"+` a message is created for each series instead, where the `+"`value`"+` and `+"`timestamp`"+` fields are replaced with a `+"`samples`"+` array of objects containing them.
+
+This format matches the format expected by the `+"[`prometheus_remote_write` output](/docs/components/outputs/prometheus_remote_write)"+`, and therefore series can be modified with Bloblang and then forwarded to another remote write endpoint.`).
+		Field(service.NewStringField("address").
+			Description("An alternative address to host from. If left empty the service wide address is used.").
+			Default("")).
+		Field(service.NewStringField("path").
+			Description("The endpoint path to receive remote write requests from.").
+			Default("/api/v1/write")).
+		Field(service.NewBoolField("split_samples").
+			Description("Whether to create a message for each sample rather than each series.").
+			Default(true)).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for the messages of a request to be acknowledged before responding with an error.").
+			Default("5s").
+			Advanced()).
+		Field(service.NewStringField("cert_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with a custom `address`.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringField("key_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with a custom `address`.").
+			Default("").
+			Advanced()).
+		Field(shared.HTTPReceiverMaxBodySizeField()).
+		Example("Drop Debug Series", "Receive remote write requests from Prometheus, drop series with a `level` label of `debug` and forward the remainder to another remote write endpoint:", `
+input:
+  prometheus_remote_write:
+    path: /receive
+
+pipeline:
+  processors:
+    - bloblang: |
+        root = if this.labels.level == "debug" { deleted() }
+
+output:
+  prometheus_remote_write:
+    url: http://localhost:9090/api/v1/write
+`)
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"prometheus_remote_write", remoteWriteInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			return newRemoteWriteInputFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type remoteWriteInput struct {
+	path         string
+	splitSamples bool
+	timeout      time.Duration
+	maxBodySize  int64
+
+	receiver *shared.HTTPReceiver
+}
+
+func newRemoteWriteInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*remoteWriteInput, error) {
+	r := &remoteWriteInput{}
+
+	var err error
+	if r.path, err = conf.FieldString("path"); err != nil {
+		return nil, err
+	}
+	if r.splitSamples, err = conf.FieldBool("split_samples"); err != nil {
+		return nil, err
+	}
+	if r.timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+
+	rConf := shared.HTTPReceiverConfig{
+		Name:        "Prometheus remote write",
+		Description: "Receive Prometheus remote write requests.",
+		Endpoints: map[string]http.HandlerFunc{
+			r.path: r.handler,
+		},
+	}
+	if rConf.Address, err = conf.FieldString("address"); err != nil {
+		return nil, err
+	}
+	if rConf.CertFile, err = conf.FieldString("cert_file"); err != nil {
+		return nil, err
+	}
+	if rConf.KeyFile, err = conf.FieldString("key_file"); err != nil {
+		return nil, err
+	}
+	maxBodySize, err := conf.FieldInt("max_body_size")
+	if err != nil {
+		return nil, err
+	}
+	r.maxBodySize = int64(maxBodySize)
+	rConf.MaxBodySize = r.maxBodySize
+
+	if r.receiver, err = shared.NewHTTPReceiver(rConf, mgr); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//------------------------------------------------------------------------------
+
+func promFloatToValue(f float64) interface{} {
+	switch {
+	case isPromStaleNaN(f):
+		return "StaleNaN"
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return f
+}
+
+func (r *remoteWriteInput) seriesToBatch(series []promTimeSeries) service.MessageBatch {
+	var batch service.MessageBatch
+	for _, s := range series {
+		var name string
+		labels := make(map[string]interface{}, len(s.Labels))
+		for _, l := range s.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			labels[l.Name] = l.Value
+		}
+
+		if !r.splitSamples {
+			samples := make([]interface{}, len(s.Samples))
+			for i, smpl := range s.Samples {
+				samples[i] = map[string]interface{}{
+					"value":     promFloatToValue(smpl.Value),
+					"timestamp": smpl.Timestamp,
+				}
+			}
+			msg := service.NewMessage(nil)
+			msg.SetStructured(map[string]interface{}{
+				"name":    name,
+				"labels":  labels,
+				"samples": samples,
+			})
+			batch = append(batch, msg)
+			continue
+		}
+
+		for _, smpl := range s.Samples {
+			sampleLabels := make(map[string]interface{}, len(labels))
+			for k, v := range labels {
+				sampleLabels[k] = v
+			}
+			msg := service.NewMessage(nil)
+			msg.SetStructured(map[string]interface{}{
+				"name":      name,
+				"labels":    sampleLabels,
+				"value":     promFloatToValue(smpl.Value),
+				"timestamp": smpl.Timestamp,
+			})
+			batch = append(batch, msg)
+		}
+	}
+	return batch
+}
+
+func (r *remoteWriteInput) handler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	compressed, err := io.ReadAll(req.Body)
+	if err != nil {
+		if errors.Is(err, shared.ErrHTTPBodyTooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Decoding allocates the length declared by the request up front, and so
+	// it is checked before decoding.
+	decodedLen, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		http.Error(w, "Failed to decompress request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.maxBodySize > 0 && int64(decodedLen) > r.maxBodySize {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	reqBytes, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, "Failed to decompress request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	series, err := unmarshalPromWriteRequest(reqBytes)
+	if err != nil {
+		http.Error(w, "Failed to decode request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batch := r.seriesToBatch(series)
+	if len(batch) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ctx, done := context.WithTimeout(req.Context(), r.timeout)
+	defer done()
+
+	if err := r.receiver.SendAndAwait(ctx, batch); err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			http.Error(w, "Request timed out", http.StatusServiceUnavailable)
+		case errors.Is(err, service.ErrNotConnected):
+			http.Error(w, "Server closing", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//------------------------------------------------------------------------------
+
+func (r *remoteWriteInput) Connect(ctx context.Context) error {
+	return r.receiver.Connect(ctx)
+}
+
+func (r *remoteWriteInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	return r.receiver.ReadBatch(ctx)
+}
+
+func (r *remoteWriteInput) Close(ctx context.Context) error {
+	return r.receiver.Close(ctx)
+}
@@ -0,0 +1,322 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func remoteWriteOutputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Writes samples to a Prometheus [remote write](https://prometheus.io/docs/concepts/remote_write_spec/) endpoint.").
+		Description(`
+Each message must be an object describing either a single sample or a series of samples, matching the format of messages created by the ` + "[`prometheus_remote_write` input](/docs/components/inputs/prometheus_remote_write)" + `:
+
+` + "```json" + `
+{
+  "name": "http_requests_total",
+  "labels": { "job": "api", "instance": "foo:8080" },
+  "value": 1027,
+  "timestamp": 1641038400000
+}
+` + "```" + `
+
+Where ` + "`timestamp`" + ` is in milliseconds since the unix epoch, and defaults to the current time when omitted. A series is described by replacing the ` + "`value`" + ` and ` + "`timestamp`" + ` fields with a ` + "`samples`" + ` array of objects containing them. Values can also be the strings ` + "`NaN`" + `, ` + "`+Inf`" + ` and ` + "`-Inf`" + `, or ` + "`StaleNaN`" + ` in order to write a staleness marker.
+
+The messages of a batch are written within a single request, where samples that share the same name and labels are grouped into a single series ordered by their timestamps. Messages that cannot be converted into samples are failed individually, and the samples of the remaining messages are still written.
+
+Requests rejected with a 4xx status other than 429 (Too Many Requests) contain data that the endpoint will never accept, and therefore retrying them would block the pipeline indefinitely. Instead the batch is dropped and the rejection is logged as an error. Any other failed request is retried.`).
+		Field(service.NewStringField("url").
+			Description("The URL of the remote write endpoint.").
+			Example("http://localhost:9090/api/v1/write")).
+		Field(service.NewStringMapField("headers").
+			Description("A map of headers to add to each request.").
+			Example(map[string]interface{}{
+				"Authorization": "Bearer ${PROM_TOKEN}",
+			}).
+			Default(map[string]interface{}{})).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for a request to complete.").
+			Default("5s").
+			Advanced()).
+		Field(service.NewIntField("max_in_flight").
+			Description("The maximum number of parallel message batches to have in flight at any given time.").
+			Default(64)).
+		Field(service.NewBatchPolicyField("batching"))
+}
+
+func init() {
+	err := service.RegisterBatchOutput(
+		"prometheus_remote_write", remoteWriteOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.BatchOutput, batchPolicy service.BatchPolicy, maxInFlight int, err error) {
+			if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
+				return
+			}
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			out, err = newRemoteWriteOutputFromConfig(conf, mgr)
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type remoteWriteOutput struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	log     *service.Logger
+}
+
+func newRemoteWriteOutputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*remoteWriteOutput, error) {
+	r := &remoteWriteOutput{
+		client: &http.Client{},
+		log:    mgr.Logger(),
+	}
+
+	var err error
+	if r.url, err = conf.FieldString("url"); err != nil {
+		return nil, err
+	}
+	if r.headers, err = conf.FieldStringMap("headers"); err != nil {
+		return nil, err
+	}
+	if r.client.Timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		r.client.Transport = &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+	}
+	return r, nil
+}
+
+//------------------------------------------------------------------------------
+
+func promStringToFloat(s string) (float64, error) {
+	if s == "StaleNaN" {
+		return math.Float64frombits(promStaleNaNBits), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func promValueToFloat(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case string:
+		return promStringToFloat(t)
+	case []byte:
+		return promStringToFloat(string(t))
+	}
+	return query.IGetNumber(v)
+}
+
+func promSampleFromObject(obj map[string]interface{}) (promSample, error) {
+	var s promSample
+	var err error
+
+	v, exists := obj["value"]
+	if !exists {
+		return s, errors.New("missing field value")
+	}
+	if s.Value, err = promValueToFloat(v); err != nil {
+		return s, fmt.Errorf("field value: %w", err)
+	}
+
+	if ts, exists := obj["timestamp"]; exists && ts != nil {
+		if s.Timestamp, err = query.IGetInt(ts); err != nil {
+			return s, fmt.Errorf("field timestamp: %w", err)
+		}
+	} else {
+		s.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	return s, nil
+}
+
+// promSeriesFromMessage extracts the labels and samples of a message, where
+// labels are sorted by name as required by the remote write protocol.
+func promSeriesFromMessage(msg *service.Message) (promTimeSeries, error) {
+	var series promTimeSeries
+
+	v, err := msg.AsStructured()
+	if err != nil {
+		return series, err
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return series, fmt.Errorf("expected object, got %T", v)
+	}
+
+	name, _ := obj["name"].(string)
+	if name == "" {
+		return series, errors.New("missing field name")
+	}
+	series.Labels = append(series.Labels, promLabel{Name: "__name__", Value: name})
+
+	if labels, exists := obj["labels"]; exists && labels != nil {
+		labelsObj, ok := labels.(map[string]interface{})
+		if !ok {
+			return series, fmt.Errorf("expected field labels to be an object, got %T", labels)
+		}
+		for k, v := range labelsObj {
+			if k == "__name__" || v == nil {
+				continue
+			}
+			series.Labels = append(series.Labels, promLabel{Name: k, Value: query.IToString(v)})
+		}
+	}
+	sort.Slice(series.Labels, func(i, j int) bool {
+		return series.Labels[i].Name < series.Labels[j].Name
+	})
+
+	if samples, exists := obj["samples"]; exists {
+		samplesArr, ok := samples.([]interface{})
+		if !ok {
+			return series, fmt.Errorf("expected field samples to be an array, got %T", samples)
+		}
+		for i, s := range samplesArr {
+			sObj, ok := s.(map[string]interface{})
+			if !ok {
+				return series, fmt.Errorf("expected sample %v to be an object, got %T", i, s)
+			}
+			smpl, err := promSampleFromObject(sObj)
+			if err != nil {
+				return series, fmt.Errorf("sample %v: %w", i, err)
+			}
+			series.Samples = append(series.Samples, smpl)
+		}
+		return series, nil
+	}
+
+	smpl, err := promSampleFromObject(obj)
+	if err != nil {
+		return series, err
+	}
+	series.Samples = append(series.Samples, smpl)
+	return series, nil
+}
+
+func promSeriesKey(labels []promLabel) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.Name)
+		b.WriteByte(0xff)
+		b.WriteString(l.Value)
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
+
+// promSeriesFromBatch groups the samples of a batch into series by their
+// labels. Messages that cannot be converted into samples are added to the
+// returned batch error.
+func promSeriesFromBatch(batch service.MessageBatch) ([]promTimeSeries, *service.BatchError) {
+	var series []promTimeSeries
+	var batchErr *service.BatchError
+	seriesIndexes := map[string]int{}
+
+	for i, msg := range batch {
+		s, err := promSeriesFromMessage(msg)
+		if err != nil {
+			err = fmt.Errorf("message %v: %w", i, err)
+			if batchErr == nil {
+				batchErr = service.NewBatchError(batch, err)
+			}
+			batchErr.Failed(i, err)
+			continue
+		}
+		key := promSeriesKey(s.Labels)
+		if index, exists := seriesIndexes[key]; exists {
+			series[index].Samples = append(series[index].Samples, s.Samples...)
+			continue
+		}
+		seriesIndexes[key] = len(series)
+		series = append(series, s)
+	}
+
+	for _, s := range series {
+		sort.SliceStable(s.Samples, func(i, j int) bool {
+			return s.Samples[i].Timestamp < s.Samples[j].Timestamp
+		})
+	}
+	return series, batchErr
+}
+
+//------------------------------------------------------------------------------
+
+func (r *remoteWriteOutput) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (r *remoteWriteOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
+	// Messages that cannot be converted into samples will never succeed and
+	// are therefore failed individually, and the remaining samples are sent.
+	series, batchErr := promSeriesFromBatch(batch)
+	if batchErr != nil {
+		r.log.Errorf("Failed to convert %v messages into samples: %v", batchErr.IndexedErrors(), batchErr)
+		if len(series) == 0 {
+			return batchErr
+		}
+	}
+
+	body := snappy.Encode(nil, marshalPromWriteRequest(series))
+	req, err := http.NewRequestWithContext(ctx, "POST", r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+			r.log.Errorf("Dropping batch of %v messages rejected by remote write endpoint with status %v: %s", len(batch), res.StatusCode, bytes.TrimSpace(resBody))
+		} else {
+			return fmt.Errorf("remote write request returned status %v: %s", res.StatusCode, bytes.TrimSpace(resBody))
+		}
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+func (r *remoteWriteOutput) Close(ctx context.Context) error {
+	r.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,206 @@
+package prometheus
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The remote write protocol is a small subset of the Prometheus protobuf
+// schema (prompb), and therefore we encode and decode it by hand rather than
+// pulling in the entire Prometheus module. Fields we do not care about, such as
+// exemplars and metadata, are skipped when decoding.
+//
+// message WriteRequest { repeated TimeSeries timeseries = 1; }
+// message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+// message Label { string name = 1; string value = 2; }
+// message Sample { double value = 1; int64 timestamp = 2; }
+
+type promLabel struct {
+	Name  string
+	Value string
+}
+
+type promSample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type promTimeSeries struct {
+	Labels  []promLabel
+	Samples []promSample
+}
+
+// promStaleNaNBits is the bit pattern of the NaN value Prometheus uses to mark
+// a series as stale (value.StaleNaN), which must be preserved exactly in order
+// to be distinguished from a regular NaN.
+const promStaleNaNBits uint64 = 0x7ff0000000000002
+
+func isPromStaleNaN(f float64) bool {
+	return math.Float64bits(f) == promStaleNaNBits
+}
+
+func appendPromLabel(b []byte, l promLabel) []byte {
+	var lb []byte
+	lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+	lb = protowire.AppendString(lb, l.Name)
+	lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+	lb = protowire.AppendString(lb, l.Value)
+	return protowire.AppendBytes(b, lb)
+}
+
+func appendPromSample(b []byte, s promSample) []byte {
+	var sb []byte
+	sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+	sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+	sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+	sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+	return protowire.AppendBytes(b, sb)
+}
+
+func marshalPromWriteRequest(series []promTimeSeries) []byte {
+	var b []byte
+	for _, s := range series {
+		var tb []byte
+		for _, l := range s.Labels {
+			tb = protowire.AppendTag(tb, 1, protowire.BytesType)
+			tb = appendPromLabel(tb, l)
+		}
+		for _, smpl := range s.Samples {
+			tb = protowire.AppendTag(tb, 2, protowire.BytesType)
+			tb = appendPromSample(tb, smpl)
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, tb)
+	}
+	return b
+}
+
+//------------------------------------------------------------------------------
+
+var errPromMalformed = errors.New("malformed remote write request")
+
+// walkPromFields calls fn for each field of a protobuf message, skipping the
+// values of fields for which fn returns a negative length.
+func walkPromFields(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if n, err := fn(num, typ, b); err != nil {
+			return err
+		} else if n >= 0 {
+			b = b[n:]
+			continue
+		}
+
+		if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumePromBytes(typ protowire.Type, b []byte) ([]byte, int, error) {
+	if typ != protowire.BytesType {
+		return nil, 0, errPromMalformed
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return nil, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func unmarshalPromLabel(b []byte) (l promLabel, err error) {
+	err = walkPromFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != 1 && num != 2 {
+			return -1, nil
+		}
+		v, n, err := consumePromBytes(typ, b)
+		if err != nil {
+			return 0, err
+		}
+		if num == 1 {
+			l.Name = string(v)
+		} else {
+			l.Value = string(v)
+		}
+		return n, nil
+	})
+	return
+}
+
+func unmarshalPromSample(b []byte) (s promSample, err error) {
+	err = walkPromFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			s.Value = math.Float64frombits(v)
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			s.Timestamp = int64(v)
+			return n, nil
+		}
+		return -1, nil
+	})
+	return
+}
+
+func unmarshalPromTimeSeries(b []byte) (s promTimeSeries, err error) {
+	err = walkPromFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != 1 && num != 2 {
+			return -1, nil
+		}
+		v, n, err := consumePromBytes(typ, b)
+		if err != nil {
+			return 0, err
+		}
+		if num == 1 {
+			l, err := unmarshalPromLabel(v)
+			if err != nil {
+				return 0, err
+			}
+			s.Labels = append(s.Labels, l)
+		} else {
+			smpl, err := unmarshalPromSample(v)
+			if err != nil {
+				return 0, err
+			}
+			s.Samples = append(s.Samples, smpl)
+		}
+		return n, nil
+	})
+	return
+}
+
+func unmarshalPromWriteRequest(b []byte) (series []promTimeSeries, err error) {
+	err = walkPromFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != 1 {
+			return -1, nil
+		}
+		v, n, err := consumePromBytes(typ, b)
+		if err != nil {
+			return 0, err
+		}
+		s, err := unmarshalPromTimeSeries(v)
+		if err != nil {
+			return 0, err
+		}
+		series = append(series, s)
+		return n, nil
+	})
+	return
+}
@@ -0,0 +1,278 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestPromWriteRequestRoundTrip(t *testing.T) {
+	series := []promTimeSeries{
+		{
+			Labels: []promLabel{
+				{Name: "__name__", Value: "foo"},
+				{Name: "job", Value: "bar"},
+			},
+			Samples: []promSample{
+				{Value: 1.5, Timestamp: 1000},
+				{Value: -2, Timestamp: -5},
+			},
+		},
+		{
+			Labels: []promLabel{
+				{Name: "__name__", Value: "baz"},
+			},
+			Samples: []promSample{
+				{Value: math.Inf(1), Timestamp: 2000},
+				{Value: math.Float64frombits(promStaleNaNBits), Timestamp: 3000},
+			},
+		},
+	}
+
+	res, err := unmarshalPromWriteRequest(marshalPromWriteRequest(series))
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Len(t, res[1].Samples, 2)
+	assert.True(t, isPromStaleNaN(res[1].Samples[1].Value))
+
+	// NaN values never compare equal, so swap the marker before comparing.
+	res[1].Samples[1].Value, series[1].Samples[1].Value = 0, 0
+	assert.Equal(t, series, res)
+
+	_, err = unmarshalPromWriteRequest([]byte{0x0a, 0xff})
+	require.Error(t, err)
+}
+
+func testRemoteWritePair(t *testing.T, inputConf string) (*remoteWriteInput, *remoteWriteOutput) {
+	t.Helper()
+
+	inConf, err := remoteWriteInputConfig().ParseYAML(inputConf, nil)
+	require.NoError(t, err)
+
+	in, err := newRemoteWriteInputFromConfig(inConf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, in.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, in.Close(context.Background()))
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(in.handler))
+	t.Cleanup(ts.Close)
+
+	outConf, err := remoteWriteOutputConfig().ParseYAML(`url: `+ts.URL, nil)
+	require.NoError(t, err)
+
+	out, err := newRemoteWriteOutputFromConfig(outConf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, out.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, out.Close(context.Background()))
+	})
+	return in, out
+}
+
+func writeRemoteWriteAsync(out *remoteWriteOutput, docs ...string) <-chan error {
+	var batch service.MessageBatch
+	for _, d := range docs {
+		batch = append(batch, service.NewMessage([]byte(d)))
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- out.WriteBatch(context.Background(), batch)
+	}()
+	return errChan
+}
+
+func readRemoteWriteBatch(t *testing.T, in *remoteWriteInput) ([]string, service.AckFunc) {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	batch, ackFn, err := in.ReadBatch(ctx)
+	require.NoError(t, err)
+
+	var docs []string
+	for _, msg := range batch {
+		b, err := msg.AsBytes()
+		require.NoError(t, err)
+		docs = append(docs, string(b))
+	}
+	return docs, ackFn
+}
+
+func TestRemoteWriteSamples(t *testing.T) {
+	in, out := testRemoteWritePair(t, `path: /write`)
+
+	errChan := writeRemoteWriteAsync(out,
+		`{"name":"foo","labels":{"job":"a","instance":"b"},"value":2,"timestamp":2000}`,
+		`{"name":"bar","value":"NaN","timestamp":1000}`,
+		`{"name":"foo","labels":{"instance":"b","job":"a"},"value":1.5,"timestamp":1000}`,
+		`{"name":"bar","value":"StaleNaN","timestamp":2000}`,
+	)
+
+	docs, ackFn := readRemoteWriteBatch(t, in)
+	assert.Equal(t, []string{
+		`{"labels":{"instance":"b","job":"a"},"name":"foo","timestamp":1000,"value":1.5}`,
+		`{"labels":{"instance":"b","job":"a"},"name":"foo","timestamp":2000,"value":2}`,
+		`{"labels":{},"name":"bar","timestamp":1000,"value":"NaN"}`,
+		`{"labels":{},"name":"bar","timestamp":2000,"value":"StaleNaN"}`,
+	}, docs)
+
+	require.NoError(t, ackFn(context.Background(), nil))
+	require.NoError(t, <-errChan)
+}
+
+func TestRemoteWriteSeries(t *testing.T) {
+	in, out := testRemoteWritePair(t, `split_samples: false`)
+
+	errChan := writeRemoteWriteAsync(out,
+		`{"name":"foo","labels":{"job":"a"},"samples":[{"value":2,"timestamp":2000},{"value":1,"timestamp":1000}]}`,
+	)
+
+	docs, ackFn := readRemoteWriteBatch(t, in)
+	assert.Equal(t, []string{
+		`{"labels":{"job":"a"},"name":"foo","samples":[{"timestamp":1000,"value":1},{"timestamp":2000,"value":2}]}`,
+	}, docs)
+
+	require.NoError(t, ackFn(context.Background(), nil))
+	require.NoError(t, <-errChan)
+}
+
+func TestRemoteWriteNack(t *testing.T) {
+	in, out := testRemoteWritePair(t, ``)
+
+	errChan := writeRemoteWriteAsync(out, `{"name":"foo","value":1}`)
+
+	_, ackFn := readRemoteWriteBatch(t, in)
+	require.NoError(t, ackFn(context.Background(), errors.New("nope")))
+
+	err := <-errChan
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "500")
+}
+
+func TestRemoteWriteInputDecodedSizeLimit(t *testing.T) {
+	conf, err := remoteWriteInputConfig().ParseYAML(`max_body_size: 1024`, nil)
+	require.NoError(t, err)
+
+	in, err := newRemoteWriteInputFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(in.handler))
+	t.Cleanup(ts.Close)
+
+	post := func(body []byte) int {
+		t.Helper()
+		res, err := http.Post(ts.URL, "application/x-protobuf", bytes.NewReader(body))
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	// A request that compresses well is within the limit until it is decoded.
+	compressed := snappy.Encode(nil, marshalPromWriteRequest([]promTimeSeries{
+		{
+			Labels:  []promLabel{{Name: "__name__", Value: strings.Repeat("a", 4096)}},
+			Samples: []promSample{{Value: 1, Timestamp: 1000}},
+		},
+	}))
+	require.Less(t, len(compressed), 1024)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(compressed))
+
+	// The declared length of a request is checked before anything is
+	// allocated for it.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post([]byte{0xff, 0xff, 0xff, 0xff, 0x0f, 0x00}))
+}
+
+func TestRemoteWriteOutputErrors(t *testing.T) {
+	for _, doc := range []string{
+		`{"value":1}`,
+		`{"name":"foo"}`,
+		`{"name":"foo","value":"nope"}`,
+		`{"name":"foo","labels":"nope","value":1}`,
+		`{"name":"foo","samples":[{"timestamp":1}]}`,
+		`[]`,
+	} {
+		_, err := promSeriesFromBatch(service.MessageBatch{service.NewMessage([]byte(doc))})
+		assert.NotNil(t, err, doc)
+	}
+}
+
+func TestRemoteWriteOutputPartialFailure(t *testing.T) {
+	in, out := testRemoteWritePair(t, ``)
+
+	errChan := writeRemoteWriteAsync(out,
+		`{"name":"foo","value":1,"timestamp":1000}`,
+		`{"name":"foo","value":"nope"}`,
+		`{"name":"bar","value":2,"timestamp":1000}`,
+	)
+
+	docs, ackFn := readRemoteWriteBatch(t, in)
+	assert.Equal(t, []string{
+		`{"labels":{},"name":"foo","timestamp":1000,"value":1}`,
+		`{"labels":{},"name":"bar","timestamp":1000,"value":2}`,
+	}, docs)
+	require.NoError(t, ackFn(context.Background(), nil))
+
+	err := <-errChan
+	var bErr *service.BatchError
+	require.True(t, errors.As(err, &bErr), "%T", err)
+	assert.Equal(t, 1, bErr.IndexedErrors())
+
+	var failed []int
+	bErr.WalkMessages(func(i int, _ *service.Message, err error) bool {
+		if err != nil {
+			failed = append(failed, i)
+		}
+		return true
+	})
+	assert.Equal(t, []int{1}, failed)
+
+	// A batch where no messages can be converted is not sent at all.
+	err = <-writeRemoteWriteAsync(out, `{"name":"foo","value":"nope"}`)
+	require.True(t, errors.As(err, &bErr), "%T", err)
+	assert.Equal(t, 1, bErr.IndexedErrors())
+}
+
+func TestRemoteWriteOutputStatusCodes(t *testing.T) {
+	for _, test := range []struct {
+		status  int
+		retried bool
+	}{
+		{status: http.StatusNoContent},
+		{status: http.StatusBadRequest},
+		{status: http.StatusTooManyRequests, retried: true},
+		{status: http.StatusInternalServerError, retried: true},
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", test.status)
+		}))
+
+		outConf, err := remoteWriteOutputConfig().ParseYAML(`url: `+ts.URL, nil)
+		require.NoError(t, err)
+
+		out, err := newRemoteWriteOutputFromConfig(outConf, service.MockResources())
+		require.NoError(t, err)
+
+		err = <-writeRemoteWriteAsync(out, `{"name":"foo","value":1}`)
+		if test.retried {
+			assert.Error(t, err, test.status)
+		} else {
+			assert.NoError(t, err, test.status)
+		}
+		ts.Close()
+	}
+}
@@ -0,0 +1,318 @@
+package shared
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"github.com/benthosdev/benthos/v4/internal/bundle"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// ErrHTTPBodyTooLarge is returned when reading a request body that exceeds the
+// maximum body size of an HTTPReceiver.
+var ErrHTTPBodyTooLarge = errors.New("request body too large")
+
+// HTTPReceiverMaxBodySizeField returns a config field for the maximum size of
+// request bodies accepted by an HTTPReceiver.
+func HTTPReceiverMaxBodySizeField() *service.ConfigField {
+	return service.NewIntField("max_body_size").
+		Description("The maximum size in bytes of a request body, where requests with larger bodies are rejected. The limit also applies to compressed bodies once they are decompressed. Set to zero in order to accept bodies of any size.").
+		Default(10 * 1024 * 1024).
+		Advanced()
+}
+
+// HTTPReceiverConfig describes the endpoints of an HTTPReceiver and how they
+// are hosted.
+type HTTPReceiverConfig struct {
+	// Name describes the requests that are received within logs.
+	Name string
+
+	// Description is used when registering endpoints with the service-wide
+	// HTTP server.
+	Description string
+
+	// Address to host a dedicated server from, when empty the endpoints are
+	// registered with the service-wide HTTP server instead.
+	Address string
+
+	// CertFile and KeyFile enable TLS for a dedicated server.
+	CertFile string
+	KeyFile  string
+
+	// MaxBodySize is the maximum size in bytes of request bodies, where zero
+	// means there is no limit.
+	MaxBodySize int64
+
+	// Endpoints maps paths to the handler of requests made to them.
+	Endpoints map[string]http.HandlerFunc
+}
+
+type httpReceiverRequest struct {
+	batch   service.MessageBatch
+	resChan chan error
+}
+
+// HTTPReceiver hosts the endpoints of an input that receives message batches
+// from HTTP requests, and hands those batches over to ReadBatch where the
+// result of their acknowledgement is returned to the handler of the request.
+type HTTPReceiver struct {
+	name        string
+	description string
+	address     string
+	certFile    string
+	keyFile     string
+	maxBodySize int64
+	endpoints   map[string]http.HandlerFunc
+
+	log    *service.Logger
+	server *http.Server
+	nm     bundle.NewManagement
+
+	connMut   sync.Mutex
+	connected bool
+
+	requests  chan httpReceiverRequest
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewHTTPReceiver creates an HTTPReceiver from a config. The endpoints are
+// not served until Connect is called.
+func NewHTTPReceiver(conf HTTPReceiverConfig, mgr *service.Resources) (*HTTPReceiver, error) {
+	r := &HTTPReceiver{
+		name:        conf.Name,
+		description: conf.Description,
+		address:     conf.Address,
+		certFile:    conf.CertFile,
+		keyFile:     conf.KeyFile,
+		maxBodySize: conf.MaxBodySize,
+		endpoints:   make(map[string]http.HandlerFunc, len(conf.Endpoints)),
+		log:         mgr.Logger(),
+		requests:    make(chan httpReceiverRequest),
+		closed:      make(chan struct{}),
+	}
+	for path, handler := range conf.Endpoints {
+		r.endpoints[path] = limitHTTPBody(conf.MaxBodySize, handler)
+	}
+
+	if r.address != "" {
+		m := mux.NewRouter()
+		for path, handler := range r.endpoints {
+			m.HandleFunc(path, handler)
+		}
+		r.server = &http.Server{Addr: r.address, Handler: m}
+		return r, nil
+	}
+
+	if r.certFile != "" || r.keyFile != "" {
+		return nil, errors.New("a cert_file and key_file can only be specified with a custom address")
+	}
+	uw, ok := mgr.XUnwrapper().(interface {
+		Unwrap() bundle.NewManagement
+	})
+	if !ok {
+		return nil, errors.New("the service-wide HTTP server is not available, an address must be specified")
+	}
+	r.nm = uw.Unwrap()
+	return r, nil
+}
+
+//------------------------------------------------------------------------------
+
+type httpBodyLimiter struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *httpBodyLimiter) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Only fail once we know there's data beyond the limit, as otherwise a
+		// body of exactly the maximum size would be rejected.
+		var b [1]byte
+		n, err := l.ReadCloser.Read(b[:])
+		if n > 0 {
+			return 0, ErrHTTPBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+func limitHTTPBody(maxSize int64, handler http.HandlerFunc) http.HandlerFunc {
+	if maxSize <= 0 {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxSize {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = &httpBodyLimiter{ReadCloser: r.Body, remaining: maxSize}
+		handler(w, r)
+	}
+}
+
+// ReadBody reads the body of a request, decompressing it when it is encoded with
+// gzip. The maximum body size of the receiver also applies to the decompressed
+// body, as otherwise a small body could expand without bounds, and when it is
+// exceeded ErrHTTPBodyTooLarge is returned.
+func (r *HTTPReceiver) ReadBody(req *http.Request) ([]byte, error) {
+	if req.Header.Get("Content-Encoding") != "gzip" {
+		return io.ReadAll(req.Body)
+	}
+
+	gr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	var body io.Reader = gr
+	if r.maxBodySize > 0 {
+		body = &httpBodyLimiter{ReadCloser: gr, remaining: r.maxBodySize}
+	}
+	return io.ReadAll(body)
+}
+
+//------------------------------------------------------------------------------
+
+// Send passes a batch to ReadBatch and returns a channel that receives the
+// result of its acknowledgement, which should be awaited with Await.
+func (r *HTTPReceiver) Send(ctx context.Context, batch service.MessageBatch) (<-chan error, error) {
+	resChan := make(chan error, 1)
+	select {
+	case r.requests <- httpReceiverRequest{batch: batch, resChan: resChan}:
+		return resChan, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-r.closed:
+		return nil, service.ErrNotConnected
+	}
+}
+
+// Await blocks until a batch passed to Send has been acknowledged, returning
+// the error it was acknowledged with.
+func (r *HTTPReceiver) Await(ctx context.Context, resChan <-chan error) error {
+	select {
+	case err := <-resChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.closed:
+		return service.ErrNotConnected
+	}
+}
+
+// SendAndAwait passes a batch to ReadBatch and blocks until it has been
+// acknowledged, returning the error it was acknowledged with.
+func (r *HTTPReceiver) SendAndAwait(ctx context.Context, batch service.MessageBatch) error {
+	resChan, err := r.Send(ctx, batch)
+	if err != nil {
+		return err
+	}
+	return r.Await(ctx, resChan)
+}
+
+//------------------------------------------------------------------------------
+
+// Connect starts serving the endpoints of the receiver, returning an error
+// when a dedicated server fails to load its certificate or bind to its
+// address.
+func (r *HTTPReceiver) Connect(ctx context.Context) error {
+	r.connMut.Lock()
+	defer r.connMut.Unlock()
+
+	if r.connected {
+		return nil
+	}
+	if r.server == nil {
+		for path, handler := range r.endpoints {
+			r.nm.RegisterEndpoint(path, r.description, handler)
+		}
+		r.connected = true
+		return nil
+	}
+
+	useTLS := r.certFile != "" || r.keyFile != ""
+	if useTLS {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return err
+		}
+		r.server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	lis, err := net.Listen("tcp", r.address)
+	if err != nil {
+		return err
+	}
+	r.connected = true
+
+	if useTLS {
+		r.log.Infof("Receiving %v requests at: https://%v", r.name, lis.Addr())
+	} else {
+		r.log.Infof("Receiving %v requests at: http://%v", r.name, lis.Addr())
+	}
+	go func() {
+		var err error
+		if useTLS {
+			err = r.server.ServeTLS(lis, "", "")
+		} else {
+			err = r.server.Serve(lis)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			r.log.Errorf("Server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// ReadBatch returns the next batch received, along with a function that
+// returns the result of its acknowledgement to the handler of the request.
+func (r *HTTPReceiver) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	select {
+	case req := <-r.requests:
+		return req.batch, func(ctx context.Context, err error) error {
+			req.resChan <- err
+			return nil
+		}, nil
+	case <-r.closed:
+		return nil, nil, service.ErrEndOfInput
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// Close stops serving the endpoints of the receiver, any requests that are
+// pending are rejected.
+func (r *HTTPReceiver) Close(ctx context.Context) error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+	})
+	if r.server != nil {
+		return r.server.Shutdown(ctx)
+	}
+
+	r.connMut.Lock()
+	defer r.connMut.Unlock()
+	if r.connected {
+		for path := range r.endpoints {
+			r.nm.RegisterEndpoint(path, "Does nothing.", http.NotFound)
+		}
+		r.connected = false
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package shared
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestHTTPReceiverBindError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		lis.Close()
+	})
+
+	r, err := NewHTTPReceiver(HTTPReceiverConfig{
+		Name:    "test",
+		Address: lis.Addr().String(),
+	}, service.MockResources())
+	require.NoError(t, err)
+
+	require.Error(t, r.Connect(context.Background()))
+	require.NoError(t, r.Close(context.Background()))
+}
+
+func TestHTTPReceiverTLSWithoutAddress(t *testing.T) {
+	_, err := NewHTTPReceiver(HTTPReceiverConfig{
+		Name:     "test",
+		CertFile: "foo.pem",
+	}, service.MockResources())
+	require.Error(t, err)
+}
+
+func TestHTTPReceiverMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(limitHTTPBody(5, func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if errors.Is(err, ErrHTTPBodyTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(ts.Close)
+
+	post := func(body io.Reader) (int, string) {
+		t.Helper()
+		res, err := http.Post(ts.URL, "text/plain", body)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, strings.TrimSpace(string(b))
+	}
+
+	status, body := post(strings.NewReader("hello"))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", body)
+
+	status, _ = post(strings.NewReader("hello world"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	// Wrapping the reader hides the content length and forces a chunked body.
+	status, _ = post(io.MultiReader(strings.NewReader("hello world")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+}
+
+func TestHTTPReceiverReadBodyGzip(t *testing.T) {
+	r, err := NewHTTPReceiver(HTTPReceiverConfig{
+		Name:        "test",
+		MaxBodySize: 32 * 1024,
+	}, service.MockResources())
+	require.NoError(t, err)
+
+	gzipped := func(b []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write(b)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	readBody := func(body []byte) (b []byte, err error) {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", "gzip")
+		limitHTTPBody(r.maxBodySize, func(w http.ResponseWriter, req *http.Request) {
+			b, err = r.ReadBody(req)
+		})(httptest.NewRecorder(), req)
+		return
+	}
+
+	b, err := readBody(gzipped([]byte("hello world")))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+
+	b, err = readBody(gzipped(bytes.Repeat([]byte("a"), 32*1024)))
+	require.NoError(t, err)
+	assert.Len(t, b, 32*1024)
+
+	// A highly compressible payload is well within the limit until it is
+	// decompressed.
+	bomb := gzipped(make([]byte, 10*1024*1024))
+	require.Less(t, len(bomb), 32*1024)
+	_, err = readBody(bomb)
+	assert.ErrorIs(t, err, ErrHTTPBodyTooLarge)
+
+	_, err = readBody([]byte("not gzipped"))
+	assert.Error(t, err)
+}
+
+func TestHTTPReceiverSendAndAwait(t *testing.T) {
+	r, err := NewHTTPReceiver(HTTPReceiverConfig{
+		Name: "test",
+	}, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, r.Connect(context.Background()))
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- r.SendAndAwait(ctx, service.MessageBatch{service.NewMessage([]byte("foo"))})
+	}()
+
+	batch, ackFn, err := r.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	require.NoError(t, ackFn(ctx, errors.New("nope")))
+	assert.EqualError(t, <-errChan, "nope")
+
+	require.NoError(t, r.Close(ctx))
+
+	_, _, err = r.ReadBatch(ctx)
+	assert.Equal(t, service.ErrEndOfInput, err)
+	assert.Equal(t, service.ErrNotConnected, r.SendAndAwait(ctx, nil))
+}
@@ -0,0 +1,43 @@
+// Package shared contains utilities that are shared across the component
+// implementations of different families.
+package shared
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// MappedTimestamp executes a timestamp mapping against a message of a batch,
+// returning the fallback timestamp when the mapping is nil or deletes the
+// message. The result of the mapping can be a unix timestamp or a string
+// following RFC 3339.
+func MappedTimestamp(batch service.MessageBatch, index int, mapping *bloblang.Executor, fallback time.Time) (time.Time, error) {
+	if mapping == nil {
+		return fallback, nil
+	}
+	resMsg, err := batch.BloblangQuery(index, mapping)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp mapping failed: %w", err)
+	}
+	if resMsg == nil {
+		return fallback, nil
+	}
+
+	// String results are not valid JSON documents and therefore we fall back
+	// to the raw contents of the result.
+	v, err := resMsg.AsStructured()
+	if err != nil {
+		if v, err = resMsg.AsBytes(); err != nil {
+			return time.Time{}, fmt.Errorf("timestamp mapping failed: %w", err)
+		}
+	}
+	ts, err := query.IGetTimestamp(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp mapping failed: %w", err)
+	}
+	return ts, nil
+}
@@ -0,0 +1,600 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func smtpOutputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Sends each message as an email via an SMTP server.").
+		Description(`
+The contents of each message become the body of an email, where the field `+"`body_type`"+` determines whether the body is sent as plain text or HTML. The fields `+"`from`, `to`, `cc`, `subject` and the values of `headers`"+` support [interpolation functions](/docs/configuration/interpolation#bloblang-queries), and the fields `+"`to` and `cc`"+` can contain a comma separated list of addresses.
+
+The emails of a batch are sent over a single connection. When an email is rejected only its message is failed and the remaining emails of the batch are still sent, therefore retrying the failed messages does not send the accepted emails again.
+
+### Attachments
+
+When `+"`attachments.enabled`"+` is set to `+"`true`"+` each batch is sent as a single email instead, where the first message of the batch is the body of the email and the remaining messages are added as attachments. The interpolated fields of the email are resolved from the first message, and the file name and content type of each attachment are resolved from the message of the attachment.
+
+### TLS
+
+The field `+"`tls_mode`"+` determines how connections are secured, with `+"`starttls`"+` upgrading a plain connection (usually on port 587), `+"`implicit`"+` connecting with TLS from the start (usually on port 465) and `+"`none`"+` disabling TLS entirely. In both TLS modes the connection is configured by the field `+"`tls`"+`.`).
+		Field(service.NewStringField("address").
+			Description("The address of the SMTP server.").
+			Example("smtp.example.com:587")).
+		Field(service.NewInterpolatedStringField("from").
+			Description("The address to send emails from.").
+			Example("Benthos <alerts@example.com>")).
+		Field(service.NewInterpolatedStringField("to").
+			Description("A comma separated list of addresses to send emails to.").
+			Example("ops@example.com").
+			Example(`${! meta("owner") }`)).
+		Field(service.NewInterpolatedStringField("cc").
+			Description("An optional comma separated list of addresses to copy emails to.").
+			Default("")).
+		Field(service.NewInterpolatedStringField("subject").
+			Description("The subject of emails.").
+			Example(`Alert: ${! json("name") }`)).
+		Field(service.NewStringMapField("headers").
+			Description("A map of additional headers to add to emails, where values support [interpolation functions](/docs/configuration/interpolation#bloblang-queries).").
+			Default(map[string]interface{}{}).
+			Advanced()).
+		Field(service.NewStringEnumField("body_type", "text", "html").
+			Description("Whether the body of emails is plain text or HTML.").
+			Default("text")).
+		Field(service.NewObjectField("attachments",
+			service.NewBoolField("enabled").
+				Description("Whether to send each batch as a single email with attachments.").
+				Default(false),
+			service.NewInterpolatedStringField("filename").
+				Description("The file name of each attachment.").
+				Default(`attachment-${! batch_index() }`),
+			service.NewInterpolatedStringField("content_type").
+				Description("The content type of each attachment.").
+				Default("application/octet-stream"),
+		).
+			Description("Configures sending the messages of a batch as attachments.").
+			Advanced()).
+		Field(service.NewStringEnumField("tls_mode", "starttls", "implicit", "none").
+			Description("How connections to the server are secured.").
+			Default("starttls")).
+		Field(service.NewTLSField("tls").
+			Advanced()).
+		Field(service.NewObjectField("auth",
+			service.NewStringEnumField("mechanism", "none", "plain", "login").
+				Description("The authentication mechanism to use.").
+				Default("none"),
+			service.NewStringField("username").
+				Description("The username to authenticate with.").
+				Default(""),
+			service.NewStringField("password").
+				Description("The password to authenticate with.").
+				Default(""),
+		).
+			Description("Configures authentication with the server.")).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for a batch of emails to be sent, including establishing a connection to the server.").
+			Default("10s").
+			Advanced()).
+		Field(service.NewIntField("max_in_flight").
+			Description("The maximum number of parallel message batches to have in flight at any given time.").
+			Default(1)).
+		Field(service.NewBatchPolicyField("batching")).
+		Example("Alerts", "Sends an HTML email for each alert, addressed to the owner of the alert:", `
+output:
+  smtp:
+    address: smtp.example.com:587
+    from: Benthos <alerts@example.com>
+    to: ${! json("owner") }
+    subject: 'Alert: ${! json("name") }'
+    body_type: html
+    auth:
+      mechanism: plain
+      username: alerts@example.com
+      password: ${SMTP_PASSWORD}
+  processors:
+    - bloblang: |
+        root = "<h1>%s</h1><p>%s</p>".format(this.name, this.description)
+`)
+}
+
+func init() {
+	err := service.RegisterBatchOutput(
+		"smtp", smtpOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.BatchOutput, batchPolicy service.BatchPolicy, maxInFlight int, err error) {
+			if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
+				return
+			}
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			out, err = newSMTPOutputFromConfig(conf)
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type smtpOutput struct {
+	address string
+	host    string
+	from    *service.InterpolatedString
+	to      *service.InterpolatedString
+	cc      *service.InterpolatedString
+	subject *service.InterpolatedString
+	headers map[string]*service.InterpolatedString
+	html    bool
+
+	attachmentsEnabled bool
+	attachmentName     *service.InterpolatedString
+	attachmentType     *service.InterpolatedString
+
+	tlsMode string
+	tlsConf *tls.Config
+
+	authMechanism string
+	username      string
+	password      string
+
+	timeout time.Duration
+}
+
+func newSMTPOutputFromConfig(conf *service.ParsedConfig) (*smtpOutput, error) {
+	s := &smtpOutput{
+		headers: map[string]*service.InterpolatedString{},
+	}
+
+	var err error
+	if s.address, err = conf.FieldString("address"); err != nil {
+		return nil, err
+	}
+	if s.host, _, err = net.SplitHostPort(s.address); err != nil {
+		return nil, fmt.Errorf("failed to parse address: %w", err)
+	}
+	if s.from, err = conf.FieldInterpolatedString("from"); err != nil {
+		return nil, err
+	}
+	if s.to, err = conf.FieldInterpolatedString("to"); err != nil {
+		return nil, err
+	}
+	if s.cc, err = conf.FieldInterpolatedString("cc"); err != nil {
+		return nil, err
+	}
+	if s.subject, err = conf.FieldInterpolatedString("subject"); err != nil {
+		return nil, err
+	}
+
+	headers, err := conf.FieldStringMap("headers")
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		if s.headers[k], err = service.NewInterpolatedString(v); err != nil {
+			return nil, fmt.Errorf("failed to parse header %v: %w", k, err)
+		}
+	}
+
+	bodyType, err := conf.FieldString("body_type")
+	if err != nil {
+		return nil, err
+	}
+	s.html = bodyType == "html"
+
+	attConf := conf.Namespace("attachments")
+	if s.attachmentsEnabled, err = attConf.FieldBool("enabled"); err != nil {
+		return nil, err
+	}
+	if s.attachmentName, err = attConf.FieldInterpolatedString("filename"); err != nil {
+		return nil, err
+	}
+	if s.attachmentType, err = attConf.FieldInterpolatedString("content_type"); err != nil {
+		return nil, err
+	}
+
+	if s.tlsMode, err = conf.FieldString("tls_mode"); err != nil {
+		return nil, err
+	}
+	if s.tlsConf, err = conf.FieldTLS("tls"); err != nil {
+		return nil, err
+	}
+	if s.tlsConf == nil {
+		s.tlsConf = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if s.tlsConf.ServerName == "" {
+		s.tlsConf.ServerName = s.host
+	}
+
+	authConf := conf.Namespace("auth")
+	if s.authMechanism, err = authConf.FieldString("mechanism"); err != nil {
+		return nil, err
+	}
+	if s.username, err = authConf.FieldString("username"); err != nil {
+		return nil, err
+	}
+	if s.password, err = authConf.FieldString("password"); err != nil {
+		return nil, err
+	}
+
+	if s.timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// loginAuth implements the LOGIN authentication mechanism, which isn't
+// provided by net/smtp but is still commonly required.
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+}
+
+func (s *smtpOutput) dial(ctx context.Context) (*smtp.Client, error) {
+	dialer := &net.Dialer{Timeout: s.timeout}
+
+	var conn net.Conn
+	var err error
+	if s.tlsMode == "implicit" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConf}).DialContext(ctx, "tcp", s.address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The deadline covers the entire exchange with the server so that a stalled
+	// server cannot block a batch indefinitely.
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if s.tlsMode == "starttls" {
+		if err := client.StartTLS(s.tlsConf); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	var auth smtp.Auth
+	switch s.authMechanism {
+	case "plain":
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	case "login":
+		auth = &loginAuth{username: s.username, password: s.password}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	return client, nil
+}
+
+//------------------------------------------------------------------------------
+
+type smtpEmail struct {
+	from       string
+	recipients []string
+	data       []byte
+}
+
+func parseAddressList(field, list string) ([]*mail.Address, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	addrs, err := mail.ParseAddressList(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v addresses: %w", field, err)
+	}
+	return addrs, nil
+}
+
+func formatAddressList(addrs []*mail.Address) string {
+	strs := make([]string, len(addrs))
+	for i, a := range addrs {
+		strs[i] = a.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+func writeBase64Lines(buf *bytes.Buffer, b []byte) {
+	enc := base64.StdEncoding.EncodeToString(b)
+	for len(enc) > 76 {
+		buf.WriteString(enc[:76])
+		buf.WriteString("\r\n")
+		enc = enc[76:]
+	}
+	buf.WriteString(enc)
+	buf.WriteString("\r\n")
+}
+
+// buildEmail creates an email from the message at the index of a batch, where
+// the messages in attachments are attached to the email.
+func (s *smtpOutput) buildEmail(batch service.MessageBatch, index int, attachments []int) (*smtpEmail, error) {
+	from, err := mail.ParseAddress(batch.InterpolatedString(index, s.from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse from address: %w", err)
+	}
+	to, err := parseAddressList("to", batch.InterpolatedString(index, s.to))
+	if err != nil {
+		return nil, err
+	}
+	cc, err := parseAddressList("cc", batch.InterpolatedString(index, s.cc))
+	if err != nil {
+		return nil, err
+	}
+	if len(to)+len(cc) == 0 {
+		return nil, errors.New("no recipients were resolved")
+	}
+
+	email := &smtpEmail{from: from.Address}
+	for _, a := range append(to, cc...) {
+		email.recipients = append(email.recipients, a.Address)
+	}
+
+	body, err := batch[index].AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(k, v string) {
+		buf.WriteString(k)
+		buf.WriteString(": ")
+		buf.WriteString(v)
+		buf.WriteString("\r\n")
+	}
+
+	writeHeader("From", from.String())
+	if len(to) > 0 {
+		writeHeader("To", formatAddressList(to))
+	}
+	if len(cc) > 0 {
+		writeHeader("Cc", formatAddressList(cc))
+	}
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", batch.InterpolatedString(index, s.subject)))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+
+	headerKeys := make([]string, 0, len(s.headers))
+	for k := range s.headers {
+		headerKeys = append(headerKeys, k)
+	}
+	sort.Strings(headerKeys)
+	for _, k := range headerKeys {
+		writeHeader(textproto.CanonicalMIMEHeaderKey(k), mime.QEncoding.Encode("utf-8", batch.InterpolatedString(index, s.headers[k])))
+	}
+
+	bodyType := "text/plain; charset=utf-8"
+	if s.html {
+		bodyType = "text/html; charset=utf-8"
+	}
+
+	var mw *multipart.Writer
+	bodyHeader := textproto.MIMEHeader{}
+	bodyHeader.Set("Content-Type", bodyType)
+	bodyHeader.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	if len(attachments) > 0 {
+		mw = multipart.NewWriter(&buf)
+		writeHeader("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		buf.WriteString("\r\n")
+		if _, err := mw.CreatePart(bodyHeader); err != nil {
+			return nil, err
+		}
+	} else {
+		writeHeader("Content-Type", bodyType)
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+	}
+
+	qw := quotedprintable.NewWriter(&buf)
+	if _, err := qw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := qw.Close(); err != nil {
+		return nil, err
+	}
+
+	if mw != nil {
+		for _, i := range attachments {
+			content, err := batch[i].AsBytes()
+			if err != nil {
+				return nil, err
+			}
+			partHeader := textproto.MIMEHeader{}
+			partHeader.Set("Content-Type", batch.InterpolatedString(i, s.attachmentType))
+			partHeader.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+				"filename": batch.InterpolatedString(i, s.attachmentName),
+			}))
+			partHeader.Set("Content-Transfer-Encoding", "base64")
+			if _, err := mw.CreatePart(partHeader); err != nil {
+				return nil, err
+			}
+			writeBase64Lines(&buf, content)
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	email.data = buf.Bytes()
+	return email, nil
+}
+
+// emailsFromBatch creates the emails of a batch. Without attachments an email
+// is created for each message, where the emails of messages that could not be
+// converted are nil and their errors are added to the returned batch error.
+func (s *smtpOutput) emailsFromBatch(batch service.MessageBatch) ([]*smtpEmail, *service.BatchError) {
+	if s.attachmentsEnabled {
+		attachments := make([]int, 0, len(batch)-1)
+		for i := 1; i < len(batch); i++ {
+			attachments = append(attachments, i)
+		}
+		email, err := s.buildEmail(batch, 0, attachments)
+		if err != nil {
+			return nil, service.NewBatchError(batch, err)
+		}
+		return []*smtpEmail{email}, nil
+	}
+
+	emails := make([]*smtpEmail, len(batch))
+	var batchErr *service.BatchError
+	for i := range batch {
+		var err error
+		if emails[i], err = s.buildEmail(batch, i, nil); err != nil {
+			err = fmt.Errorf("message %v: %w", i, err)
+			if batchErr == nil {
+				batchErr = service.NewBatchError(batch, err)
+			}
+			batchErr.Failed(i, err)
+		}
+	}
+	return emails, batchErr
+}
+
+func sendEmail(client *smtp.Client, email *smtpEmail) error {
+	if err := client.Mail(email.from); err != nil {
+		return err
+	}
+	for _, r := range email.recipients {
+		if err := client.Rcpt(r); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(email.data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+//------------------------------------------------------------------------------
+
+func (s *smtpOutput) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (s *smtpOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
+	emails, batchErr := s.emailsFromBatch(batch)
+
+	pending := 0
+	for _, email := range emails {
+		if email != nil {
+			pending++
+		}
+	}
+	if pending == 0 {
+		if batchErr != nil {
+			return batchErr
+		}
+		return nil
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for i, email := range emails {
+		if email == nil {
+			continue
+		}
+		err := sendEmail(client, email)
+		if err == nil {
+			continue
+		}
+		if s.attachmentsEnabled {
+			return err
+		}
+		if batchErr == nil {
+			batchErr = service.NewBatchError(batch, err)
+		}
+		batchErr.Failed(i, err)
+
+		// The failed transaction must be aborted before sending the remaining
+		// emails, and if that isn't possible the connection is unusable.
+		if rErr := client.Reset(); rErr != nil {
+			for j := i + 1; j < len(emails); j++ {
+				if emails[j] != nil {
+					batchErr.Failed(j, rErr)
+				}
+			}
+			return batchErr
+		}
+	}
+
+	// Emails have already been accepted by the server at this point, and
+	// therefore failing to quit cleanly is not a reason to send them again.
+	_ = client.Quit()
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+func (s *smtpOutput) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,383 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+type testEmail struct {
+	auth       []string
+	from       string
+	recipients []string
+	data       string
+}
+
+// testSMTPServer is a minimal SMTP server that records the emails it receives
+// without supporting TLS.
+type testSMTPServer struct {
+	ln net.Listener
+
+	mut    sync.Mutex
+	emails []testEmail
+}
+
+func newTestSMTPServer(t *testing.T) *testSMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &testSMTPServer{ln: ln}
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = io.WriteString(conn, line+"\r\n")
+	}
+	readLine := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimRight(line, "\r\n"), true
+	}
+
+	var email testEmail
+	reply("220 localhost ESMTP")
+	for {
+		line, ok := readLine()
+		if !ok {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN LOGIN")
+		case "AUTH":
+			args := strings.Fields(line)[1:]
+			if args[0] == "PLAIN" {
+				email.auth = append(email.auth, args...)
+			} else {
+				email.auth = append(email.auth, "LOGIN")
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				user, _ := readLine()
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				pass, _ := readLine()
+				email.auth = append(email.auth, user, pass)
+			}
+			reply("235 Authentication successful")
+		case "MAIL":
+			email.from = strings.TrimSuffix(strings.TrimPrefix(line[5:], "FROM:<"), ">")
+			reply("250 OK")
+		case "RCPT":
+			rcpt := strings.TrimSuffix(strings.TrimPrefix(line[5:], "TO:<"), ">")
+			if strings.HasPrefix(rcpt, "rejected") {
+				reply("550 No such user")
+				continue
+			}
+			email.recipients = append(email.recipients, rcpt)
+			reply("250 OK")
+		case "RSET":
+			email = testEmail{auth: email.auth}
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				dline, ok := readLine()
+				if !ok {
+					return
+				}
+				if dline == "." {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dline, "."))
+				data.WriteString("\r\n")
+			}
+			email.data = data.String()
+			s.mut.Lock()
+			s.emails = append(s.emails, email)
+			s.mut.Unlock()
+			email = testEmail{auth: email.auth}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *testSMTPServer) received() []testEmail {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]testEmail(nil), s.emails...)
+}
+
+func testSMTPOutput(t *testing.T, conf string) *smtpOutput {
+	t.Helper()
+
+	pConf, err := smtpOutputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	s, err := newSMTPOutputFromConfig(pConf)
+	require.NoError(t, err)
+	require.NoError(t, s.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, s.Close(context.Background()))
+	})
+	return s
+}
+
+func TestSMTPOutputEmails(t *testing.T) {
+	server := newTestSMTPServer(t)
+
+	s := testSMTPOutput(t, `
+address: `+server.ln.Addr().String()+`
+from: Benthos <benthos@example.com>
+to: ${! meta("to") }
+cc: ops@example.com
+subject: 'Alert: ${! json("name") }'
+headers:
+  x-alert-id: ${! json("id") }
+body_type: html
+tls_mode: none
+auth:
+  mechanism: plain
+  username: foo
+  password: bar
+`)
+
+	msgA := service.NewMessage([]byte(`{"id":"a","name":"disk full"}`))
+	msgA.MetaSet("to", "Alice <alice@example.com>, bob@example.com")
+	msgB := service.NewMessage([]byte(`{"id":"b","name":"café"}`))
+	msgB.MetaSet("to", "carol@example.com")
+
+	require.NoError(t, s.WriteBatch(context.Background(), service.MessageBatch{msgA, msgB}))
+
+	emails := server.received()
+	require.Len(t, emails, 2)
+
+	plain := base64.StdEncoding.EncodeToString([]byte("\x00foo\x00bar"))
+	assert.Equal(t, []string{"PLAIN", plain}, emails[0].auth)
+	assert.Equal(t, "benthos@example.com", emails[0].from)
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com", "ops@example.com"}, emails[0].recipients)
+	assert.Equal(t, []string{"carol@example.com", "ops@example.com"}, emails[1].recipients)
+
+	msg, err := mail.ReadMessage(strings.NewReader(emails[0].data))
+	require.NoError(t, err)
+	assert.Equal(t, `"Alice" <alice@example.com>, <bob@example.com>`, msg.Header.Get("To"))
+	assert.Equal(t, "<ops@example.com>", msg.Header.Get("Cc"))
+	assert.Equal(t, "Alert: disk full", msg.Header.Get("Subject"))
+	assert.Equal(t, "a", msg.Header.Get("X-Alert-Id"))
+	assert.Equal(t, "text/html; charset=utf-8", msg.Header.Get("Content-Type"))
+	body, err := io.ReadAll(msg.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","name":"disk full"}`, strings.TrimSpace(string(body)))
+
+	msg, err = mail.ReadMessage(strings.NewReader(emails[1].data))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Alert: café", subject)
+}
+
+func TestSMTPOutputAttachments(t *testing.T) {
+	server := newTestSMTPServer(t)
+
+	s := testSMTPOutput(t, `
+address: `+server.ln.Addr().String()+`
+from: benthos@example.com
+to: ops@example.com
+subject: Daily report
+attachments:
+  enabled: true
+  filename: ${! meta("name") }
+  content_type: text/csv
+tls_mode: none
+auth:
+  mechanism: login
+  username: foo
+  password: bar
+`)
+
+	attA := service.NewMessage([]byte("a,b\n1,2\n"))
+	attA.MetaSet("name", "first.csv")
+	attB := service.NewMessage([]byte(strings.Repeat("x", 100)))
+	attB.MetaSet("name", "second.csv")
+
+	require.NoError(t, s.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte("See attached.")), attA, attB,
+	}))
+
+	emails := server.received()
+	require.Len(t, emails, 1)
+	assert.Equal(t, []string{
+		"LOGIN",
+		base64.StdEncoding.EncodeToString([]byte("foo")),
+		base64.StdEncoding.EncodeToString([]byte("bar")),
+	}, emails[0].auth)
+
+	msg, err := mail.ReadMessage(strings.NewReader(emails[0].data))
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	type part struct {
+		contentType string
+		filename    string
+		content     string
+	}
+	var parts []part
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(p)
+		require.NoError(t, err)
+		if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+			b, err = base64.StdEncoding.DecodeString(strings.ReplaceAll(string(b), "\r\n", ""))
+			require.NoError(t, err)
+		}
+		parts = append(parts, part{
+			contentType: p.Header.Get("Content-Type"),
+			filename:    p.FileName(),
+			content:     string(b),
+		})
+	}
+
+	assert.Equal(t, []part{
+		{contentType: "text/plain; charset=utf-8", content: "See attached."},
+		{contentType: "text/csv", filename: "first.csv", content: "a,b\n1,2\n"},
+		{contentType: "text/csv", filename: "second.csv", content: strings.Repeat("x", 100)},
+	}, parts)
+}
+
+func TestSMTPOutputErrors(t *testing.T) {
+	server := newTestSMTPServer(t)
+
+	s := testSMTPOutput(t, `
+address: `+server.ln.Addr().String()+`
+from: benthos@example.com
+to: ${! meta("to").or("") }
+subject: nope
+tls_mode: none
+`)
+
+	err := s.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte("hello"))})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no recipients")
+
+	msg := service.NewMessage([]byte("hello"))
+	msg.MetaSet("to", "not an address")
+	err = s.WriteBatch(context.Background(), service.MessageBatch{msg})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "to addresses")
+
+	assert.Empty(t, server.received())
+}
+
+func TestSMTPOutputPartialFailure(t *testing.T) {
+	server := newTestSMTPServer(t)
+
+	s := testSMTPOutput(t, `
+address: `+server.ln.Addr().String()+`
+from: benthos@example.com
+to: ${! meta("to").or("") }
+subject: hello
+tls_mode: none
+`)
+
+	var batch service.MessageBatch
+	for _, to := range []string{"alice@example.com", "rejected@example.com", "", "bob@example.com"} {
+		msg := service.NewMessage([]byte("hello " + to))
+		msg.MetaSet("to", to)
+		batch = append(batch, msg)
+	}
+
+	err := s.WriteBatch(context.Background(), batch)
+	require.Error(t, err)
+
+	var bErr *service.BatchError
+	require.True(t, errors.As(err, &bErr))
+	assert.Equal(t, 2, bErr.IndexedErrors())
+
+	var recipients []string
+	for _, e := range server.received() {
+		recipients = append(recipients, e.recipients...)
+	}
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, recipients)
+}
+
+func TestSMTPOutputTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ln.Close()
+	})
+
+	// Accept connections without ever greeting the client.
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	s := testSMTPOutput(t, `
+address: `+ln.Addr().String()+`
+from: benthos@example.com
+to: alice@example.com
+subject: hello
+tls_mode: none
+timeout: 100ms
+`)
+
+	start := time.Now()
+	err = s.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte("hello"))})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second*5)
+}
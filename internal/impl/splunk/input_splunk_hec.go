@@ -0,0 +1,391 @@
+package splunk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
+	"github.com/benthosdev/benthos/v4/internal/impl/shared"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func hecInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Receives events sent to a Splunk [HTTP Event Collector](https://docs.splunk.com/Documentation/Splunk/latest/Data/UsetheHTTPEventCollector) (HEC) compatible server.").
+		Description(`
+The endpoints ` + "`/services/collector/event` (and its alias `/services/collector`), `/services/collector/raw` and `/services/collector/health`" + ` are registered under the ` + "`path_prefix`" + `. If the ` + "`address`" + ` field is left blank the [service-wide HTTP server](/docs/components/http/about) will be used.
+
+Requests must contain a token within the header ` + "`Authorization: Splunk <token>`" + `, which is checked against the list of ` + "`tokens`" + ` when it isn't empty. Request bodies compressed with gzip are supported.
+
+The events of each request are consumed as a single batch, and a response is only returned to the client once the batch has been acknowledged. If the batch is rejected, or is not acknowledged within the ` + "`timeout`" + `, a 503 status code is returned in order for the client to retry the request.
+
+### Events
+
+Requests to the event endpoint consist of one or more JSON event objects, and a message is created for each event. When the ` + "`event`" + ` field of an event is a string the message contains the raw string, otherwise the message contains the JSON value.
+
+Requests to the raw endpoint create a message for each line of the body, where the fields ` + "`host`, `source`, `sourcetype` and `index`" + ` are taken from the query parameters of the request.
+
+### Metadata
+
+This input adds the following metadata fields to each message when they are present:
+
+` + "```text" + `
+- splunk_hec_host
+- splunk_hec_source
+- splunk_hec_sourcetype
+- splunk_hec_index
+- splunk_hec_time
+- splunk_hec_channel
+` + "```" + `
+
+The indexed fields of an event (within the object ` + "`fields`" + `) are added as metadata with their key prefixed with ` + "`splunk_hec_field_`" + `.`).
+		Field(service.NewStringField("address").
+			Description("An alternative address to host from. If left empty the service wide address is used.").
+			Default("")).
+		Field(service.NewStringField("path_prefix").
+			Description("A prefix to add to the paths of the collector endpoints.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringListField("tokens").
+			Description("A list of tokens that are accepted. If empty requests with any token are accepted, which is not recommended outside of testing.").
+			Default([]string{})).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for the messages of a request to be acknowledged before responding with an error.").
+			Default("5s").
+			Advanced()).
+		Field(service.NewStringField("cert_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with a custom `address`.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringField("key_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with a custom `address`.").
+			Default("").
+			Advanced()).
+		Field(shared.HTTPReceiverMaxBodySizeField())
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"splunk_hec", hecInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			return newHECInputFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Status codes and messages returned by Splunk HEC.
+const (
+	hecCodeSuccess       = 0
+	hecCodeTokenRequired = 2
+	hecCodeInvalidAuth   = 3
+	hecCodeInvalidToken  = 4
+	hecCodeNoData        = 5
+	hecCodeInvalidFormat = 6
+	hecCodeServerBusy    = 9
+	hecCodeEventRequired = 12
+	hecCodeHealthy       = 17
+)
+
+type hecInput struct {
+	pathPrefix string
+	tokens     [][]byte
+	timeout    time.Duration
+
+	log      *service.Logger
+	receiver *shared.HTTPReceiver
+}
+
+func newHECInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*hecInput, error) {
+	h := &hecInput{
+		log: mgr.Logger(),
+	}
+
+	var err error
+	if h.pathPrefix, err = conf.FieldString("path_prefix"); err != nil {
+		return nil, err
+	}
+	h.pathPrefix = strings.TrimSuffix(h.pathPrefix, "/")
+	tokens, err := conf.FieldStringList("tokens")
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		h.tokens = append(h.tokens, []byte(t))
+	}
+	if len(h.tokens) == 0 {
+		h.log.Warn("No tokens have been configured for the splunk_hec input, requests with any token will be accepted")
+	}
+	if h.timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+
+	rConf := shared.HTTPReceiverConfig{
+		Name:        "Splunk HEC",
+		Description: "Receive Splunk HEC requests.",
+		Endpoints:   h.handlers(),
+	}
+	if rConf.Address, err = conf.FieldString("address"); err != nil {
+		return nil, err
+	}
+	if rConf.CertFile, err = conf.FieldString("cert_file"); err != nil {
+		return nil, err
+	}
+	if rConf.KeyFile, err = conf.FieldString("key_file"); err != nil {
+		return nil, err
+	}
+	maxBodySize, err := conf.FieldInt("max_body_size")
+	if err != nil {
+		return nil, err
+	}
+	rConf.MaxBodySize = int64(maxBodySize)
+
+	if h.receiver, err = shared.NewHTTPReceiver(rConf, mgr); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *hecInput) handlers() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		h.pathPrefix + "/services/collector":        h.eventHandler,
+		h.pathPrefix + "/services/collector/event":  h.eventHandler,
+		h.pathPrefix + "/services/collector/raw":    h.rawHandler,
+		h.pathPrefix + "/services/collector/health": h.healthHandler,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func writeHECResponse(w http.ResponseWriter, status int, code int, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"text": text,
+		"code": code,
+	})
+}
+
+// authorize checks the token of a request and writes an error response when
+// the request is not authorized.
+func (h *hecInput) authorize(w http.ResponseWriter, r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		writeHECResponse(w, http.StatusUnauthorized, hecCodeTokenRequired, "Token is required")
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Splunk ")
+	if token == auth {
+		writeHECResponse(w, http.StatusUnauthorized, hecCodeInvalidAuth, "Invalid authorization")
+		return false
+	}
+	if len(h.tokens) > 0 && !h.validToken([]byte(token)) {
+		writeHECResponse(w, http.StatusForbidden, hecCodeInvalidToken, "Invalid token")
+		return false
+	}
+	return true
+}
+
+// validToken returns whether a token matches any of the configured tokens,
+// comparing against all of them in constant time.
+func (h *hecInput) validToken(token []byte) bool {
+	valid := 0
+	for _, t := range h.tokens {
+		valid |= subtle.ConstantTimeCompare(t, token)
+	}
+	return valid == 1
+}
+
+// readBody reads the body of a request, decompressing it when required, and
+// writes an error response when the body could not be read or is empty.
+func (h *hecInput) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	b, err := h.receiver.ReadBody(r)
+	if err != nil {
+		if errors.Is(err, shared.ErrHTTPBodyTooLarge) {
+			writeHECResponse(w, http.StatusRequestEntityTooLarge, hecCodeInvalidFormat, "Content length too large")
+			return nil, false
+		}
+		writeHECResponse(w, http.StatusBadRequest, hecCodeInvalidFormat, "Invalid data format")
+		return nil, false
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		writeHECResponse(w, http.StatusBadRequest, hecCodeNoData, "No data")
+		return nil, false
+	}
+	return b, true
+}
+
+func hecRequestChannel(r *http.Request) string {
+	if c := r.Header.Get("X-Splunk-Request-Channel"); c != "" {
+		return c
+	}
+	return r.URL.Query().Get("channel")
+}
+
+var errHECEventRequired = errors.New("event field is required")
+
+// parseHECEvents parses a stream of concatenated JSON events into a message
+// per event.
+func parseHECEvents(b []byte, channel string) (service.MessageBatch, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var batch service.MessageBatch
+	for {
+		var event map[string]interface{}
+		if err := dec.Decode(&event); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		e, exists := event["event"]
+		if !exists || e == nil {
+			return nil, errHECEventRequired
+		}
+
+		var msg *service.Message
+		if str, isStr := e.(string); isStr {
+			if str == "" {
+				return nil, errHECEventRequired
+			}
+			msg = service.NewMessage([]byte(str))
+		} else {
+			msg = service.NewMessage(nil)
+			msg.SetStructured(e)
+		}
+
+		for _, k := range []string{"host", "source", "sourcetype", "index", "time"} {
+			if v, exists := event[k]; exists && v != nil {
+				msg.MetaSet("splunk_hec_"+k, query.IToString(v))
+			}
+		}
+		if fields, ok := event["fields"].(map[string]interface{}); ok {
+			for k, v := range fields {
+				msg.MetaSet("splunk_hec_field_"+k, query.IToString(v))
+			}
+		}
+		if channel != "" {
+			msg.MetaSet("splunk_hec_channel", channel)
+		}
+		batch = append(batch, msg)
+	}
+	return batch, nil
+}
+
+// parseHECRaw creates a message for each line of a raw request.
+func parseHECRaw(b []byte, r *http.Request, channel string) (service.MessageBatch, error) {
+	params := r.URL.Query()
+
+	var batch service.MessageBatch
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, len(b)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		msg := service.NewMessage(append([]byte(nil), line...))
+		for _, k := range []string{"host", "source", "sourcetype", "index"} {
+			if v := params.Get(k); v != "" {
+				msg.MetaSet("splunk_hec_"+k, v)
+			}
+		}
+		if channel != "" {
+			msg.MetaSet("splunk_hec_channel", channel)
+		}
+		batch = append(batch, msg)
+	}
+	return batch, scanner.Err()
+}
+
+// sendBatch sends a batch through the pipeline and writes a response once it
+// has been acknowledged.
+func (h *hecInput) sendBatch(w http.ResponseWriter, r *http.Request, batch service.MessageBatch) {
+	ctx, done := context.WithTimeout(r.Context(), h.timeout)
+	defer done()
+
+	if err := h.receiver.SendAndAwait(ctx, batch); err != nil {
+		h.log.Debugf("Batch rejected: %v", err)
+		writeHECResponse(w, http.StatusServiceUnavailable, hecCodeServerBusy, "Server is busy")
+		return
+	}
+	writeHECResponse(w, http.StatusOK, hecCodeSuccess, "Success")
+}
+
+func (h *hecInput) eventHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r) {
+		return
+	}
+	b, ok := h.readBody(w, r)
+	if !ok {
+		return
+	}
+	batch, err := parseHECEvents(b, hecRequestChannel(r))
+	if err != nil {
+		if errors.Is(err, errHECEventRequired) {
+			writeHECResponse(w, http.StatusBadRequest, hecCodeEventRequired, "Event field is required")
+		} else {
+			writeHECResponse(w, http.StatusBadRequest, hecCodeInvalidFormat, "Invalid data format")
+		}
+		return
+	}
+	h.sendBatch(w, r, batch)
+}
+
+func (h *hecInput) rawHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r) {
+		return
+	}
+	b, ok := h.readBody(w, r)
+	if !ok {
+		return
+	}
+	batch, err := parseHECRaw(b, r, hecRequestChannel(r))
+	if err != nil {
+		writeHECResponse(w, http.StatusBadRequest, hecCodeInvalidFormat, "Invalid data format")
+		return
+	}
+	h.sendBatch(w, r, batch)
+}
+
+func (h *hecInput) healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeHECResponse(w, http.StatusOK, hecCodeHealthy, "HEC is healthy")
+}
+
+//------------------------------------------------------------------------------
+
+func (h *hecInput) Connect(ctx context.Context) error {
+	return h.receiver.Connect(ctx)
+}
+
+func (h *hecInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	return h.receiver.ReadBatch(ctx)
+}
+
+func (h *hecInput) Close(ctx context.Context) error {
+	return h.receiver.Close(ctx)
+}
@@ -0,0 +1,388 @@
+package splunk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func hecOutputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services").
+		Version("4.0.0").
+		Summary("Writes events to a Splunk [HTTP Event Collector](https://docs.splunk.com/Documentation/Splunk/latest/Data/UsetheHTTPEventCollector) (HEC).").
+		Description(`
+Each message is sent as the ` + "`event`" + ` of a HEC event, where messages containing valid JSON are sent as structured events and all other messages are sent as strings. The messages of a batch are sent within a single request to the ` + "`/services/collector/event`" + ` endpoint.
+
+The fields ` + "`index`, `source`, `sourcetype` and `host`" + ` support [interpolation functions](/docs/configuration/interpolation#bloblang-queries) and are resolved for each message individually. When a field resolves to an empty string it is omitted from the event, in which case Splunk uses the defaults configured for the token.
+
+### Indexer Acknowledgement
+
+When ` + "`indexer_acknowledgement.enabled`" + ` is set to ` + "`true`" + ` the output waits until each request has been indexed before the batch is acknowledged, by polling the ` + "`/services/collector/ack`" + ` endpoint. This requires indexer acknowledgement to be enabled for the token in Splunk. If a request is not acknowledged within ` + "`indexer_acknowledgement.timeout`" + ` it is considered failed and the batch is sent again.
+
+If a response does not contain an ` + "`ackId`" + `, which happens when indexer acknowledgement is not enabled for the token, then the events have already been accepted and so the batch is not failed. Instead an error is logged and indexer acknowledgement is disabled for the remainder of the output's lifetime.`).
+		Field(service.NewStringField("url").
+			Description("The base URL of the HTTP Event Collector.").
+			Example("https://localhost:8088")).
+		Field(service.NewStringField("token").
+			Description("The HEC token used to authenticate requests.")).
+		Field(service.NewInterpolatedStringField("index").
+			Description("The index to write each event to.").
+			Default("")).
+		Field(service.NewInterpolatedStringField("source").
+			Description("The source of each event.").
+			Default("")).
+		Field(service.NewInterpolatedStringField("sourcetype").
+			Description("The sourcetype of each event.").
+			Example("_json").
+			Example(`${! meta("sourcetype") }`).
+			Default("")).
+		Field(service.NewInterpolatedStringField("host").
+			Description("The host of each event.").
+			Default("")).
+		Field(service.NewBoolField("gzip").
+			Description("Whether to compress request bodies with gzip.").
+			Default(false)).
+		Field(service.NewObjectField("indexer_acknowledgement",
+			service.NewBoolField("enabled").
+				Description("Whether to wait for events to be indexed before acknowledging batches.").
+				Default(false),
+			service.NewStringField("channel").
+				Description("The channel identifier to send requests with. If left empty a random identifier is generated.").
+				Default(""),
+			service.NewDurationField("poll_period").
+				Description("The period between acknowledgement status requests.").
+				Default("1s"),
+			service.NewDurationField("timeout").
+				Description("The maximum period to wait for a request to be indexed.").
+				Default("30s"),
+		).
+			Description("Configures polling for the indexer acknowledgement of requests.").
+			Advanced()).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period to wait for a request to complete.").
+			Default("5s").
+			Advanced()).
+		Field(service.NewIntField("max_in_flight").
+			Description("The maximum number of parallel message batches to have in flight at any given time.").
+			Default(64)).
+		Field(service.NewBatchPolicyField("batching"))
+}
+
+func init() {
+	err := service.RegisterBatchOutput(
+		"splunk_hec", hecOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.BatchOutput, batchPolicy service.BatchPolicy, maxInFlight int, err error) {
+			if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
+				return
+			}
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			out, err = newHECOutputFromConfig(conf, mgr)
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type hecOutput struct {
+	url        string
+	token      string
+	index      *service.InterpolatedString
+	source     *service.InterpolatedString
+	sourcetype *service.InterpolatedString
+	host       *service.InterpolatedString
+	gzip       bool
+
+	ackEnabled    bool
+	ackChannel    string
+	ackPollPeriod time.Duration
+	ackTimeout    time.Duration
+
+	// acksDisabled is set when responses do not contain an ackId.
+	acksDisabled    int32
+	acksDisableOnce sync.Once
+
+	log    *service.Logger
+	client *http.Client
+}
+
+func newHECOutputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*hecOutput, error) {
+	h := &hecOutput{
+		log:    mgr.Logger(),
+		client: &http.Client{},
+	}
+
+	var err error
+	if h.url, err = conf.FieldString("url"); err != nil {
+		return nil, err
+	}
+	h.url = strings.TrimSuffix(h.url, "/")
+	if h.token, err = conf.FieldString("token"); err != nil {
+		return nil, err
+	}
+	if h.index, err = conf.FieldInterpolatedString("index"); err != nil {
+		return nil, err
+	}
+	if h.source, err = conf.FieldInterpolatedString("source"); err != nil {
+		return nil, err
+	}
+	if h.sourcetype, err = conf.FieldInterpolatedString("sourcetype"); err != nil {
+		return nil, err
+	}
+	if h.host, err = conf.FieldInterpolatedString("host"); err != nil {
+		return nil, err
+	}
+	if h.gzip, err = conf.FieldBool("gzip"); err != nil {
+		return nil, err
+	}
+
+	ackConf := conf.Namespace("indexer_acknowledgement")
+	if h.ackEnabled, err = ackConf.FieldBool("enabled"); err != nil {
+		return nil, err
+	}
+	if h.ackChannel, err = ackConf.FieldString("channel"); err != nil {
+		return nil, err
+	}
+	if h.ackPollPeriod, err = ackConf.FieldDuration("poll_period"); err != nil {
+		return nil, err
+	}
+	if h.ackTimeout, err = ackConf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	if h.ackEnabled && h.ackChannel == "" {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate channel identifier: %w", err)
+		}
+		h.ackChannel = id.String()
+	}
+
+	if h.client.Timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		h.client.Transport = &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+	}
+	return h, nil
+}
+
+//------------------------------------------------------------------------------
+
+// hecResponse is the body returned by HEC endpoints, where the ackId is only
+// present when indexer acknowledgement is enabled for the token.
+type hecResponse struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+func (h *hecOutput) encodeBatch(batch service.MessageBatch) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i, msg := range batch {
+		var event interface{}
+		if v, err := msg.AsStructured(); err == nil {
+			event = v
+		} else {
+			b, err := msg.AsBytes()
+			if err != nil {
+				return nil, err
+			}
+			event = string(b)
+		}
+
+		obj := map[string]interface{}{"event": event}
+		for k, f := range map[string]*service.InterpolatedString{
+			"index":      h.index,
+			"source":     h.source,
+			"sourcetype": h.sourcetype,
+			"host":       h.host,
+		} {
+			if v := batch.InterpolatedString(i, f); v != "" {
+				obj[k] = v
+			}
+		}
+		if err := enc.Encode(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	if !h.gzip {
+		return buf.Bytes(), nil
+	}
+
+	var gzipBuf bytes.Buffer
+	zw := gzip.NewWriter(&gzipBuf)
+	if _, err := zw.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return gzipBuf.Bytes(), nil
+}
+
+func (h *hecOutput) postEvents(ctx context.Context, body []byte) (*hecResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", h.url+"/services/collector/event", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Splunk "+h.token)
+	req.Header.Set("Content-Type", "application/json")
+	if h.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if h.acksActive() {
+		req.Header.Set("X-Splunk-Request-Channel", h.ackChannel)
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var hecRes hecResponse
+	if err := json.Unmarshal(resBytes, &hecRes); err != nil && res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if hecRes.Text == "" {
+			hecRes.Text = string(bytes.TrimSpace(resBytes))
+		}
+		return nil, fmt.Errorf("HEC request returned status %v: %v", res.StatusCode, hecRes.Text)
+	}
+	return &hecRes, nil
+}
+
+// waitForAck polls the acknowledgement endpoint until the request with the
+// provided ackId has been indexed.
+func (h *hecOutput) waitForAck(ctx context.Context, ackID int64) error {
+	ctx, done := context.WithTimeout(ctx, h.ackTimeout)
+	defer done()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"acks": []int64{ackID},
+	})
+	if err != nil {
+		return err
+	}
+	path := "/services/collector/ack?channel=" + h.ackChannel
+
+	ticker := time.NewTicker(h.ackPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for indexer acknowledgement of request %v", ackID)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", h.url+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Splunk "+h.token)
+		req.Header.Set("X-Splunk-Request-Channel", h.ackChannel)
+
+		res, err := h.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("timed out waiting for indexer acknowledgement of request %v", ackID)
+			}
+			return err
+		}
+
+		var ackRes struct {
+			Acks map[string]bool `json:"acks"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&ackRes)
+		res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("acknowledgement request returned status %v", res.StatusCode)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse acknowledgement response: %w", err)
+		}
+		if ackRes.Acks[strconv.FormatInt(ackID, 10)] {
+			return nil
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (h *hecOutput) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (h *hecOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
+	body, err := h.encodeBatch(batch)
+	if err != nil {
+		return err
+	}
+
+	waitForAck := h.acksActive()
+	res, err := h.postEvents(ctx, body)
+	if err != nil {
+		return err
+	}
+	if !waitForAck {
+		return nil
+	}
+	if res.AckID == nil {
+		// The events have already been accepted, and so failing the batch
+		// would only duplicate them.
+		h.disableAcks()
+		return nil
+	}
+	return h.waitForAck(ctx, *res.AckID)
+}
+
+func (h *hecOutput) acksActive() bool {
+	return h.ackEnabled && atomic.LoadInt32(&h.acksDisabled) == 0
+}
+
+func (h *hecOutput) disableAcks() {
+	h.acksDisableOnce.Do(func() {
+		h.log.Error("Response did not contain an ackId, indexer acknowledgement may not be enabled for the token and will be disabled")
+		atomic.StoreInt32(&h.acksDisabled, 1)
+	})
+}
+
+func (h *hecOutput) Close(ctx context.Context) error {
+	h.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,414 @@
+package splunk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func testHECOutput(t *testing.T, conf string) *hecOutput {
+	t.Helper()
+
+	pConf, err := hecOutputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	h, err := newHECOutputFromConfig(pConf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, h.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, h.Close(context.Background()))
+	})
+	return h
+}
+
+func TestHECOutputEvents(t *testing.T) {
+	var reqBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/services/collector/event", r.URL.Path)
+		assert.Equal(t, "Splunk footoken", r.Header.Get("Authorization"))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+		zr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		b, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		reqBody = string(b)
+
+		_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	h := testHECOutput(t, `
+url: `+ts.URL+`
+token: footoken
+gzip: true
+index: main
+sourcetype: ${! meta("st").or("") }
+`)
+
+	msgA := service.NewMessage([]byte(`{"foo":"bar"}`))
+	msgA.MetaSet("st", "_json")
+	msgB := service.NewMessage([]byte(`hello world`))
+
+	require.NoError(t, h.WriteBatch(context.Background(), service.MessageBatch{msgA, msgB}))
+	assert.Equal(t, `{"event":{"foo":"bar"},"index":"main","sourcetype":"_json"}
+{"event":"hello world","index":"main"}
+`, reqBody)
+}
+
+func TestHECOutputErrorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"text":"Invalid token","code":4}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	h := testHECOutput(t, `
+url: `+ts.URL+`
+token: footoken
+`)
+
+	err := h.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte(`hello`))})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid token")
+}
+
+func TestHECOutputIndexerAck(t *testing.T) {
+	var mut sync.Mutex
+	var ackPolls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "foochannel", r.Header.Get("X-Splunk-Request-Channel"))
+
+		switch r.URL.Path {
+		case "/services/collector/event":
+			_, _ = w.Write([]byte(`{"text":"Success","code":0,"ackId":7}`))
+		case "/services/collector/ack":
+			assert.Equal(t, "foochannel", r.URL.Query().Get("channel"))
+			b, _ := io.ReadAll(r.Body)
+			assert.Equal(t, `{"acks":[7]}`, string(b))
+
+			mut.Lock()
+			ackPolls++
+			indexed := ackPolls >= 3
+			mut.Unlock()
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"acks": map[string]bool{"7": indexed},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	h := testHECOutput(t, `
+url: `+ts.URL+`
+token: footoken
+indexer_acknowledgement:
+  enabled: true
+  channel: foochannel
+  poll_period: 1ms
+`)
+
+	require.NoError(t, h.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte(`hello`))}))
+
+	mut.Lock()
+	assert.Equal(t, 3, ackPolls)
+	ackPolls = -1000
+	mut.Unlock()
+
+	h.ackTimeout = time.Millisecond * 50
+	err := h.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte(`hello`))})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestHECOutputIndexerAckMissing(t *testing.T) {
+	var mut sync.Mutex
+	var events, ackPolls int
+	var channels []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+
+		switch r.URL.Path {
+		case "/services/collector/event":
+			events++
+			channels = append(channels, r.Header.Get("X-Splunk-Request-Channel"))
+			_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+		case "/services/collector/ack":
+			ackPolls++
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	h := testHECOutput(t, `
+url: `+ts.URL+`
+token: footoken
+indexer_acknowledgement:
+  enabled: true
+  channel: foochannel
+  poll_period: 1ms
+`)
+
+	// The events were accepted and so the batch must not be retried, and
+	// acknowledgements are no longer requested.
+	require.NoError(t, h.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte(`hello`))}))
+	require.NoError(t, h.WriteBatch(context.Background(), service.MessageBatch{service.NewMessage([]byte(`world`))}))
+
+	mut.Lock()
+	assert.Equal(t, 2, events)
+	assert.Equal(t, 0, ackPolls)
+	assert.Equal(t, []string{"foochannel", ""}, channels)
+	mut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+func testHECInput(t *testing.T, conf string) *hecInput {
+	t.Helper()
+
+	pConf, err := hecInputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	h, err := newHECInputFromConfig(pConf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, h.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, h.Close(context.Background()))
+	})
+	return h
+}
+
+type hecTestResponse struct {
+	status int
+	body   string
+}
+
+func postHECAsync(t *testing.T, url, token, body string) <-chan hecTestResponse {
+	t.Helper()
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Splunk "+token)
+	}
+	req.Header.Set("X-Splunk-Request-Channel", "foochannel")
+
+	resChan := make(chan hecTestResponse, 1)
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			resChan <- hecTestResponse{body: err.Error()}
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		resChan <- hecTestResponse{status: res.StatusCode, body: string(bytes.TrimSpace(b))}
+	}()
+	return resChan
+}
+
+func readHECBatch(t *testing.T, h *hecInput) (service.MessageBatch, service.AckFunc) {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	batch, ackFn, err := h.ReadBatch(ctx)
+	require.NoError(t, err)
+	return batch, ackFn
+}
+
+func TestHECInputEvents(t *testing.T) {
+	h := testHECInput(t, `tokens: [ footoken ]`)
+
+	ts := httptest.NewServer(http.HandlerFunc(h.eventHandler))
+	t.Cleanup(ts.Close)
+
+	resChan := postHECAsync(t, ts.URL, "footoken", `{"event":"hello world","host":"foo","time":1641038400.5}
+{"event":{"msg":"structured"},"sourcetype":"_json","fields":{"region":"eu"}}`)
+
+	batch, ackFn := readHECBatch(t, h)
+	require.Len(t, batch, 2)
+
+	b, err := batch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+	v, _ := batch[0].MetaGet("splunk_hec_host")
+	assert.Equal(t, "foo", v)
+	v, _ = batch[0].MetaGet("splunk_hec_time")
+	assert.Equal(t, "1641038400.5", v)
+	v, _ = batch[0].MetaGet("splunk_hec_channel")
+	assert.Equal(t, "foochannel", v)
+
+	b, err = batch[1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"msg":"structured"}`, string(b))
+	v, _ = batch[1].MetaGet("splunk_hec_sourcetype")
+	assert.Equal(t, "_json", v)
+	v, _ = batch[1].MetaGet("splunk_hec_field_region")
+	assert.Equal(t, "eu", v)
+
+	require.NoError(t, ackFn(context.Background(), nil))
+	assert.Equal(t, hecTestResponse{status: 200, body: `{"code":0,"text":"Success"}`}, <-resChan)
+}
+
+func TestHECInputRaw(t *testing.T) {
+	h := testHECInput(t, ``)
+
+	ts := httptest.NewServer(http.HandlerFunc(h.rawHandler))
+	t.Cleanup(ts.Close)
+
+	resChan := postHECAsync(t, ts.URL+"?sourcetype=access_log&index=web", "anything", "first line\n\nsecond line\n")
+
+	batch, ackFn := readHECBatch(t, h)
+	require.Len(t, batch, 2)
+
+	b, err := batch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "first line", string(b))
+	b, err = batch[1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "second line", string(b))
+
+	v, _ := batch[1].MetaGet("splunk_hec_sourcetype")
+	assert.Equal(t, "access_log", v)
+	v, _ = batch[1].MetaGet("splunk_hec_index")
+	assert.Equal(t, "web", v)
+
+	require.NoError(t, ackFn(context.Background(), errors.New("nope")))
+	assert.Equal(t, hecTestResponse{status: 503, body: `{"code":9,"text":"Server is busy"}`}, <-resChan)
+}
+
+func TestHECInputErrors(t *testing.T) {
+	h := testHECInput(t, `tokens: [ footoken, baztoken ]`)
+
+	ts := httptest.NewServer(http.HandlerFunc(h.eventHandler))
+	t.Cleanup(ts.Close)
+
+	for _, test := range []struct {
+		token string
+		body  string
+		res   hecTestResponse
+	}{
+		{
+			body: `{"event":"hello"}`,
+			res:  hecTestResponse{status: 401, body: `{"code":2,"text":"Token is required"}`},
+		},
+		{
+			token: "bartoken",
+			body:  `{"event":"hello"}`,
+			res:   hecTestResponse{status: 403, body: `{"code":4,"text":"Invalid token"}`},
+		},
+		{
+			token: "footoke",
+			body:  `{"event":"hello"}`,
+			res:   hecTestResponse{status: 403, body: `{"code":4,"text":"Invalid token"}`},
+		},
+		{
+			token: "baztoken",
+			body:  ` `,
+			res:   hecTestResponse{status: 400, body: `{"code":5,"text":"No data"}`},
+		},
+		{
+			token: "footoken",
+			body:  ` `,
+			res:   hecTestResponse{status: 400, body: `{"code":5,"text":"No data"}`},
+		},
+		{
+			token: "footoken",
+			body:  `{"event":"hello"} nope`,
+			res:   hecTestResponse{status: 400, body: `{"code":6,"text":"Invalid data format"}`},
+		},
+		{
+			token: "footoken",
+			body:  `{"host":"foo"}`,
+			res:   hecTestResponse{status: 400, body: `{"code":12,"text":"Event field is required"}`},
+		},
+	} {
+		assert.Equal(t, test.res, <-postHECAsync(t, ts.URL, test.token, test.body), test.body)
+	}
+}
+
+func TestHECInputGzipBomb(t *testing.T) {
+	h := testHECInput(t, `max_body_size: 65536`)
+
+	ts := httptest.NewServer(http.HandlerFunc(h.eventHandler))
+	t.Cleanup(ts.Close)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(`{"event":"`))
+	require.NoError(t, err)
+	_, err = zw.Write(bytes.Repeat([]byte("a"), 10*1024*1024))
+	require.NoError(t, err)
+	_, err = zw.Write([]byte(`"}`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.Less(t, buf.Len(), 65536)
+
+	req, err := http.NewRequest("POST", ts.URL, &buf)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Splunk footoken")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+	assert.Equal(t, `{"code":6,"text":"Content length too large"}`, string(bytes.TrimSpace(b)))
+}
+
+func TestHECRoundTrip(t *testing.T) {
+	in := testHECInput(t, ``)
+
+	ts := httptest.NewServer(http.HandlerFunc(in.eventHandler))
+	t.Cleanup(ts.Close)
+
+	out := testHECOutput(t, `
+url: `+ts.URL+`
+token: footoken
+gzip: true
+host: ${! meta("host") }
+`)
+
+	msg := service.NewMessage([]byte(`{"foo":"bar"}`))
+	msg.MetaSet("host", "baz")
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- out.WriteBatch(context.Background(), service.MessageBatch{msg})
+	}()
+
+	batch, ackFn := readHECBatch(t, in)
+	require.Len(t, batch, 1)
+
+	b, err := batch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"foo":"bar"}`, string(b))
+	v, _ := batch[0].MetaGet("splunk_hec_host")
+	assert.Equal(t, "baz", v)
+
+	require.NoError(t, ackFn(context.Background(), nil))
+	require.NoError(t, <-errChan)
+}
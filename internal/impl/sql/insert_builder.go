@@ -0,0 +1,772 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func insertFields() []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewObjectField("upsert",
+			service.NewBoolField("enabled").
+				Description("Whether to insert rows as upserts.").
+				Default(false),
+			service.NewStringListField("conflict_columns").
+				Description("The columns of a unique key or primary key that identify conflicting rows. The `mysql` driver always uses the unique keys of the table and the `clickhouse` driver relies on the table engine, so for these drivers the conflict columns are only used when creating a table with `auto_schema`.").
+				Default([]string{}),
+			service.NewStringListField("update_columns").
+				Description("An optional list of columns to update when a row conflicts, when empty all inserted columns that are not conflict columns are updated.").
+				Default([]string{}),
+		).
+			Description("Insert rows as upserts, where rows that conflict with an existing row update it instead. The statement generated depends on the driver as outlined [in this section](#upserts).").
+			Advanced().
+			Version("4.0.0"),
+		service.NewBoolField("auto_schema").
+			Description("Whether to create the target table if it does not exist, and to add new nullable columns whenever messages contain fields that are not yet present in the table. When enabled the result of `args_mapping` (or the message itself when no mapping is set) must be an object where each key is a column, and `columns` is not used. Column types are derived from the first value seen for each field.").
+			Default(false).
+			Advanced().
+			Version("4.0.0"),
+		service.NewBoolField("bulk").
+			Description("Whether to write each batch with a bulk load, which is only supported by the `postgres` and `clickhouse` drivers. A batch is written atomically so that either all rows are inserted or none are. The bulk load used depends on the driver as outlined [in this section](#bulk-loads).").
+			Default(false).
+			Advanced().
+			Version("4.0.0"),
+	}
+}
+
+const insertDescription = `
+### Upserts
+
+When ` + "`upsert.enabled`" + ` is set rows that conflict with an existing row update it instead, with a statement that depends on the driver:
+
+| Driver | Statement |
+|---|---|
+` + "| `postgres` | `INSERT ... ON CONFLICT (conflict_columns) DO UPDATE SET ...` |" + `
+` + "| `sqlite` | `INSERT ... ON CONFLICT (conflict_columns) DO UPDATE SET ...` |" + `
+` + "| `mysql` | `INSERT ... ON DUPLICATE KEY UPDATE ...` |" + `
+` + "| `mssql` | `MERGE INTO ... WHEN MATCHED THEN UPDATE ... WHEN NOT MATCHED THEN INSERT ...` |" + `
+` + "| `clickhouse` | A plain `INSERT`, where rows are deduplicated by a `ReplacingMergeTree` table ordered by the conflict columns. |" + `
+
+### Automatic Schemas
+
+When ` + "`auto_schema`" + ` is enabled the target table is created if it does not already exist, with a primary key (or ` + "`ORDER BY`" + ` key for ` + "`clickhouse`" + `) of the upsert conflict columns when set. Columns are added to the table as new fields are seen, and are always nullable. Field names must only contain alphanumeric characters and underscores.
+
+### Bulk Loads
+
+When ` + "`bulk`" + ` is enabled each batch is written with a bulk load that is considerably faster than a multi-row ` + "`INSERT`" + ` statement:
+
+| Driver | Bulk Load |
+|---|---|
+` + "| `postgres` | `COPY table (columns) FROM STDIN` within a transaction, where the table and column names are quoted and are therefore case sensitive. |" + `
+` + "| `clickhouse` | A single native columnar block regardless of the `block_size` parameter of the DSN, which ClickHouse inserts atomically. |" + `
+
+Without ` + "`bulk`" + ` the ` + "`clickhouse`" + ` driver writes a batch with a prepared statement, where rows are sent to the server in native blocks of up to ` + "`block_size`" + ` rows (a DSN parameter), and therefore a batch larger than a single block is not guaranteed to be inserted atomically.
+
+Bulk loads cannot be combined with upserts or the ` + "`prefix` and `suffix`" + ` fields. The rows of a batch must be inserted or rejected as a whole, and therefore when any row fails all messages of the batch are considered failed.`
+
+//------------------------------------------------------------------------------
+
+var sqlIdentifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// insertBuilder generates and executes insert statements for a table.
+type insertBuilder struct {
+	driver  string
+	table   string
+	columns []string
+	prefix  string
+	suffix  string
+
+	upsert          bool
+	conflictColumns []string
+	updateColumns   []string
+
+	autoSchema   bool
+	schemaMut    sync.Mutex
+	knownColumns map[string]struct{}
+
+	bulk bool
+}
+
+func insertBuilderFromParsed(conf *service.ParsedConfig, driver string) (*insertBuilder, error) {
+	b := &insertBuilder{driver: driver}
+
+	var err error
+	if b.table, err = conf.FieldString("table"); err != nil {
+		return nil, err
+	}
+	if b.autoSchema, err = conf.FieldBool("auto_schema"); err != nil {
+		return nil, err
+	}
+	if conf.Contains("columns") {
+		if b.columns, err = conf.FieldStringList("columns"); err != nil {
+			return nil, err
+		}
+	}
+	if !b.autoSchema && len(b.columns) == 0 {
+		return nil, errors.New("at least one column must be specified when auto_schema is disabled")
+	}
+
+	if conf.Contains("prefix") {
+		if b.prefix, err = conf.FieldString("prefix"); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains("suffix") {
+		if b.suffix, err = conf.FieldString("suffix"); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.upsert, err = conf.FieldBool("upsert", "enabled"); err != nil {
+		return nil, err
+	}
+	if b.upsert {
+		if b.conflictColumns, err = conf.FieldStringList("upsert", "conflict_columns"); err != nil {
+			return nil, err
+		}
+		if b.updateColumns, err = conf.FieldStringList("upsert", "update_columns"); err != nil {
+			return nil, err
+		}
+		if len(b.conflictColumns) == 0 {
+			return nil, errors.New("upsert conflict_columns must not be empty")
+		}
+	}
+
+	if b.bulk, err = conf.FieldBool("bulk"); err != nil {
+		return nil, err
+	}
+	if b.bulk {
+		if b.driver != "postgres" && b.driver != "clickhouse" {
+			return nil, fmt.Errorf("bulk loads are not supported by the %v driver", b.driver)
+		}
+		if b.upsert {
+			return nil, errors.New("bulk loads cannot be combined with upserts")
+		}
+		if b.prefix != "" || b.suffix != "" {
+			return nil, errors.New("bulk loads cannot be combined with a prefix or suffix")
+		}
+	}
+
+	if b.autoSchema {
+		if !sqlIdentifierRegexp.MatchString(b.table) {
+			return nil, fmt.Errorf("table name %q is not supported with auto_schema", b.table)
+		}
+		for _, c := range b.conflictColumns {
+			if !sqlIdentifierRegexp.MatchString(c) {
+				return nil, fmt.Errorf("conflict column %q is not supported with auto_schema", c)
+			}
+		}
+	}
+	return b, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (b *insertBuilder) placeholderFormat() squirrel.PlaceholderFormat {
+	if b.driver == "postgres" {
+		return squirrel.Dollar
+	}
+	return squirrel.Question
+}
+
+func (b *insertBuilder) isConflictColumn(c string) bool {
+	for _, cc := range b.conflictColumns {
+		if strings.EqualFold(cc, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// upsertUpdateColumns returns the columns that should be updated on conflict
+// when inserting a given set of columns.
+func (b *insertBuilder) upsertUpdateColumns(columns []string) []string {
+	if len(b.updateColumns) > 0 {
+		return b.updateColumns
+	}
+	var updates []string
+	for _, c := range columns {
+		if !b.isConflictColumn(c) {
+			updates = append(updates, c)
+		}
+	}
+	return updates
+}
+
+// upsertSuffix returns the clause appended to an insert statement in order to
+// turn it into an upsert for drivers that support it.
+func (b *insertBuilder) upsertSuffix(columns []string) string {
+	updates := b.upsertUpdateColumns(columns)
+	switch b.driver {
+	case "postgres", "sqlite":
+		if len(updates) == 0 {
+			return "ON CONFLICT (" + strings.Join(b.conflictColumns, ", ") + ") DO NOTHING"
+		}
+		sets := make([]string, len(updates))
+		for i, c := range updates {
+			sets[i] = c + " = EXCLUDED." + c
+		}
+		return "ON CONFLICT (" + strings.Join(b.conflictColumns, ", ") + ") DO UPDATE SET " + strings.Join(sets, ", ")
+	case "mysql":
+		if len(updates) == 0 {
+			// Updating a column to itself is a no-op that ignores the conflict.
+			updates = columns[:1]
+		}
+		sets := make([]string, len(updates))
+		for i, c := range updates {
+			sets[i] = c + " = VALUES(" + c + ")"
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	return ""
+}
+
+// mergeStatement returns an MSSQL MERGE statement that upserts rows.
+func (b *insertBuilder) mergeStatement(columns []string, rows [][]interface{}) (string, []interface{}) {
+	var args []interface{}
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = "(" + strings.TrimSuffix(strings.Repeat("?, ", len(row)), ", ") + ")"
+		args = append(args, row...)
+	}
+
+	matches := make([]string, len(b.conflictColumns))
+	for i, c := range b.conflictColumns {
+		matches[i] = "target." + c + " = source." + c
+	}
+
+	sourceColumns := make([]string, len(columns))
+	for i, c := range columns {
+		sourceColumns[i] = "source." + c
+	}
+
+	var sb strings.Builder
+	if b.prefix != "" {
+		sb.WriteString(b.prefix + " ")
+	}
+	sb.WriteString("MERGE INTO " + b.table + " WITH (HOLDLOCK) AS target")
+	sb.WriteString(" USING (VALUES " + strings.Join(values, ", ") + ") AS source (" + strings.Join(columns, ", ") + ")")
+	sb.WriteString(" ON " + strings.Join(matches, " AND "))
+	if updates := b.upsertUpdateColumns(columns); len(updates) > 0 {
+		sets := make([]string, len(updates))
+		for i, c := range updates {
+			sets[i] = "target." + c + " = source." + c
+		}
+		sb.WriteString(" WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", "))
+	}
+	sb.WriteString(" WHEN NOT MATCHED THEN INSERT (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(sourceColumns, ", ") + ")")
+	if b.suffix != "" {
+		sb.WriteString(" " + b.suffix)
+	}
+	sb.WriteString(";")
+	return sb.String(), args
+}
+
+// insertStatement returns a builder for an insert statement of a given set of
+// columns without any values.
+func (b *insertBuilder) insertStatement(columns []string) squirrel.InsertBuilder {
+	builder := squirrel.Insert(b.table).Columns(columns...).PlaceholderFormat(b.placeholderFormat())
+	if b.prefix != "" {
+		builder = builder.Prefix(b.prefix)
+	}
+	if b.upsert {
+		if s := b.upsertSuffix(columns); s != "" {
+			builder = builder.Suffix(s)
+		}
+	}
+	if b.suffix != "" {
+		builder = builder.Suffix(b.suffix)
+	}
+	return builder
+}
+
+// insertRows inserts rows of values that match a set of columns.
+func (b *insertBuilder) insertRows(ctx context.Context, conn sqlConn, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if b.driver == "clickhouse" {
+		// The clickhouse driver only supports batched inserts via a prepared
+		// statement within a transaction, where rows are sent as native blocks
+		// of up to block_size rows. This is not atomic when a batch spans
+		// multiple blocks, and so bulk loads write the block directly.
+		sqlStr, _, err := b.insertStatement(columns).Values().ToSql()
+		if err != nil {
+			return err
+		}
+		if b.bulk {
+			return execClickhouseBlock(ctx, conn, sqlStr, rows)
+		}
+		return execBatch(ctx, conn, sqlStr, rows, false)
+	}
+
+	if b.bulk {
+		return execBatch(ctx, conn, b.copyStatement(columns), rows, true)
+	}
+
+	if b.upsert && b.driver == "mssql" {
+		sqlStr, args := b.mergeStatement(columns, rows)
+		_, err := conn.ExecContext(ctx, sqlStr, args...)
+		return err
+	}
+
+	builder := b.insertStatement(columns)
+	for _, args := range rows {
+		builder = builder.Values(args...)
+	}
+	sqlStr, args, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// copyStatement returns a COPY FROM STDIN statement for a given set of columns,
+// where the table name may be qualified with a schema.
+func (b *insertBuilder) copyStatement(columns []string) string {
+	if i := strings.Index(b.table, "."); i > 0 {
+		return pq.CopyInSchema(b.table[:i], b.table[i+1:], columns...)
+	}
+	return pq.CopyIn(b.table, columns...)
+}
+
+// execBatch executes a prepared statement for each row within a transaction,
+// where a new transaction is started unless conn is already a transaction.
+// When flush is true the statement is executed once more without arguments
+// before it is closed, which is required in order to complete a COPY FROM
+// STDIN.
+func execBatch(ctx context.Context, conn sqlConn, query string, rows [][]interface{}, flush bool) error {
+	db, ok := conn.(*sql.DB)
+	if !ok {
+		return execStmtRows(ctx, conn, query, rows, flush)
+	}
+	return txSettings{enabled: true}.run(ctx, db, func(conn sqlConn) error {
+		return execStmtRows(ctx, conn, query, rows, flush)
+	})
+}
+
+func execStmtRows(ctx context.Context, conn sqlConn, query string, rows [][]interface{}, flush bool) error {
+	stmt, err := conn.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, args := range rows {
+		if _, err = stmt.ExecContext(ctx, args...); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+	}
+	if flush {
+		if _, err = stmt.ExecContext(ctx); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+	}
+	return stmt.Close()
+}
+
+// execClickhouseBlock inserts rows as a single native block, which the server
+// inserts atomically. The block is only sent once all rows have been appended
+// to it, and therefore a row that cannot be encoded fails the whole batch
+// without any rows being inserted.
+func execClickhouseBlock(ctx context.Context, conn sqlConn, query string, rows [][]interface{}) error {
+	db, ok := conn.(*sql.DB)
+	if !ok {
+		return errors.New("clickhouse bulk loads cannot be executed within a transaction")
+	}
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Raw(func(driverConn interface{}) error {
+		ch, ok := driverConn.(clickhouse.Clickhouse)
+		if !ok {
+			return fmt.Errorf("connection of type %T does not support clickhouse blocks", driverConn)
+		}
+		if _, err := ch.Begin(); err != nil {
+			return err
+		}
+		if _, err := ch.Prepare(query); err != nil {
+			_ = ch.Rollback()
+			return err
+		}
+		block, err := ch.Block()
+		if err != nil {
+			_ = ch.Rollback()
+			return err
+		}
+		for _, args := range rows {
+			values, err := clickhouseDriverValues(driverConn, args)
+			if err == nil {
+				err = block.AppendRow(values)
+			}
+			if err != nil {
+				_ = ch.Rollback()
+				return err
+			}
+		}
+		return ch.Commit()
+	})
+}
+
+// clickhouseDriverValues converts the arguments of a row into driver values in
+// the same way as database/sql does for a prepared statement.
+func clickhouseDriverValues(driverConn interface{}, args []interface{}) ([]driver.Value, error) {
+	checker, _ := driverConn.(driver.NamedValueChecker)
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		nv := driver.NamedValue{Ordinal: i + 1, Value: arg}
+		err := driver.ErrSkip
+		if checker != nil {
+			err = checker.CheckNamedValue(&nv)
+		}
+		if err == driver.ErrSkip {
+			nv.Value, err = driver.DefaultParameterConverter.ConvertValue(arg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %v: %w", i, err)
+		}
+		values[i] = nv.Value
+	}
+	return values, nil
+}
+
+//------------------------------------------------------------------------------
+
+// rowsFromObjects returns the columns and rows of values to insert from
+// structured objects, where the keys of each object are columns, creating the
+// table and adding columns as required.
+func (b *insertBuilder) rowsFromObjects(ctx context.Context, db *sql.DB, objs []map[string]interface{}) ([]string, [][]interface{}, error) {
+	if len(objs) == 0 {
+		return nil, nil, nil
+	}
+
+	// Collect the columns of all rows, along with the first non-null value of
+	// each for deriving column types.
+	samples := map[string]interface{}{}
+	var columns []string
+	for _, obj := range objs {
+		for k, v := range obj {
+			if !sqlIdentifierRegexp.MatchString(k) {
+				return nil, nil, fmt.Errorf("field name %q is not a supported column name", k)
+			}
+			existing, exists := samples[k]
+			if !exists {
+				columns = append(columns, k)
+			}
+			if existing == nil {
+				samples[k] = v
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	if err := b.ensureColumns(ctx, db, columns, samples); err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([][]interface{}, len(objs))
+	for i, obj := range objs {
+		row := make([]interface{}, len(columns))
+		for j, c := range columns {
+			row[j] = sqlValue(obj[c])
+		}
+		rows[i] = row
+	}
+	return columns, rows, nil
+}
+
+// ensureColumns creates the table if it does not exist and adds any columns
+// that are not yet present in it.
+func (b *insertBuilder) ensureColumns(ctx context.Context, db *sql.DB, columns []string, samples map[string]interface{}) error {
+	b.schemaMut.Lock()
+	defer b.schemaMut.Unlock()
+
+	if b.knownColumns == nil {
+		if err := b.loadColumns(ctx, db); err != nil {
+			if err = b.createTable(ctx, db, columns, samples); err != nil {
+				return err
+			}
+			if err = b.loadColumns(ctx, db); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range columns {
+		if _, exists := b.knownColumns[strings.ToLower(c)]; exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, b.addColumnStatement(c, samples[c])); err != nil {
+			// The column may have been added by another writer, in which case
+			// we carry on.
+			if lErr := b.loadColumns(ctx, db); lErr != nil {
+				return err
+			}
+			if _, exists := b.knownColumns[strings.ToLower(c)]; !exists {
+				return fmt.Errorf("failed to add column %v: %w", c, err)
+			}
+			continue
+		}
+		b.knownColumns[strings.ToLower(c)] = struct{}{}
+	}
+	return nil
+}
+
+func (b *insertBuilder) loadColumns(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+b.table+" WHERE 1 = 0")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	b.knownColumns = make(map[string]struct{}, len(columns))
+	for _, c := range columns {
+		b.knownColumns[strings.ToLower(c)] = struct{}{}
+	}
+	return nil
+}
+
+func (b *insertBuilder) createTable(ctx context.Context, db *sql.DB, columns []string, samples map[string]interface{}) error {
+	_, err := db.ExecContext(ctx, b.createTableStatement(columns, samples))
+	return err
+}
+
+func (b *insertBuilder) createTableStatement(columns []string, samples map[string]interface{}) string {
+	// Conflict columns are included in the table even if they're missing from
+	// the first messages as they form the key.
+	definitions := make([]string, 0, len(columns)+len(b.conflictColumns))
+	for _, c := range b.conflictColumns {
+		def := c + " " + sqlKeyColumnType(b.driver, samples[c])
+		if b.driver != "clickhouse" {
+			// Clickhouse columns are not nullable unless declared as such.
+			def += " NOT NULL"
+		}
+		definitions = append(definitions, def)
+	}
+	for _, c := range columns {
+		if b.isConflictColumn(c) {
+			continue
+		}
+		definitions = append(definitions, c+" "+sqlColumnType(b.driver, samples[c]))
+	}
+
+	var stmt string
+	switch b.driver {
+	case "mssql":
+		stmt = "IF OBJECT_ID(N'" + b.table + "', N'U') IS NULL CREATE TABLE " + b.table + " (" + strings.Join(definitions, ", ")
+	default:
+		stmt = "CREATE TABLE IF NOT EXISTS " + b.table + " (" + strings.Join(definitions, ", ")
+	}
+
+	if b.driver == "clickhouse" {
+		stmt += ")"
+		if len(b.conflictColumns) > 0 {
+			stmt += " ENGINE = ReplacingMergeTree ORDER BY (" + strings.Join(b.conflictColumns, ", ") + ")"
+		} else {
+			stmt += " ENGINE = MergeTree ORDER BY tuple()"
+		}
+		return stmt
+	}
+
+	if len(b.conflictColumns) > 0 {
+		stmt += ", PRIMARY KEY (" + strings.Join(b.conflictColumns, ", ") + ")"
+	}
+	return stmt + ")"
+}
+
+func (b *insertBuilder) addColumnStatement(column string, sample interface{}) string {
+	colType := sqlColumnType(b.driver, sample)
+	switch b.driver {
+	case "mssql":
+		return "ALTER TABLE " + b.table + " ADD " + column + " " + colType + " NULL"
+	case "clickhouse":
+		return "ALTER TABLE " + b.table + " ADD COLUMN IF NOT EXISTS " + column + " " + colType
+	case "postgres":
+		return "ALTER TABLE " + b.table + " ADD COLUMN IF NOT EXISTS " + column + " " + colType + " NULL"
+	}
+	return "ALTER TABLE " + b.table + " ADD COLUMN " + column + " " + colType + " NULL"
+}
+
+//------------------------------------------------------------------------------
+
+// insertArgsFromMessage returns the arguments of a row to insert from a
+// message of a batch.
+func insertArgsFromMessage(batch service.MessageBatch, i int, mapping *bloblang.Executor) ([]interface{}, error) {
+	resMsg, err := batch.BloblangQuery(i, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	iargs, err := resMsg.AsStructured()
+	if err != nil {
+		return nil, fmt.Errorf("mapping returned non-structured result: %w", err)
+	}
+
+	args, ok := iargs.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("mapping returned non-array result: %T", iargs)
+	}
+	return args, nil
+}
+
+// insertObjectFromMessage returns an object of columns to insert from a
+// message of a batch, which is the message itself when there is no mapping.
+func insertObjectFromMessage(batch service.MessageBatch, i int, mapping *bloblang.Executor) (map[string]interface{}, error) {
+	msg := batch[i]
+	if mapping != nil {
+		var err error
+		if msg, err = batch.BloblangQuery(i, mapping); err != nil {
+			return nil, err
+		}
+	}
+
+	iobj, err := msg.AsStructured()
+	if err != nil {
+		return nil, fmt.Errorf("message is not structured: %w", err)
+	}
+
+	obj, ok := iobj.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got: %T", iobj)
+	}
+	return obj, nil
+}
+
+//------------------------------------------------------------------------------
+
+type sqlValueKind int
+
+const (
+	sqlValueText sqlValueKind = iota
+	sqlValueInt
+	sqlValueFloat
+	sqlValueBool
+)
+
+func sqlKindOf(v interface{}) sqlValueKind {
+	switch t := v.(type) {
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return sqlValueInt
+		}
+		return sqlValueFloat
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return sqlValueInt
+	case float32, float64:
+		return sqlValueFloat
+	case bool:
+		return sqlValueBool
+	}
+	return sqlValueText
+}
+
+// sqlColumnType returns the type of a nullable column derived from a sample
+// value.
+func sqlColumnType(driver string, sample interface{}) string {
+	kind := sqlKindOf(sample)
+	switch driver {
+	case "clickhouse":
+		return "Nullable(" + sqlKeyColumnType(driver, sample) + ")"
+	case "mssql":
+		switch kind {
+		case sqlValueInt:
+			return "BIGINT"
+		case sqlValueFloat:
+			return "FLOAT"
+		case sqlValueBool:
+			return "BIT"
+		}
+		return "NVARCHAR(MAX)"
+	case "mysql":
+		switch kind {
+		case sqlValueInt:
+			return "BIGINT"
+		case sqlValueFloat:
+			return "DOUBLE"
+		case sqlValueBool:
+			return "BOOLEAN"
+		}
+		return "TEXT"
+	}
+	switch kind {
+	case sqlValueInt:
+		return "BIGINT"
+	case sqlValueFloat:
+		return "DOUBLE PRECISION"
+	case sqlValueBool:
+		return "BOOLEAN"
+	}
+	return "TEXT"
+}
+
+// sqlKeyColumnType returns the type of a column that forms part of a key,
+// which for some drivers must be bounded in size.
+func sqlKeyColumnType(driver string, sample interface{}) string {
+	kind := sqlKindOf(sample)
+	switch driver {
+	case "clickhouse":
+		switch kind {
+		case sqlValueInt:
+			return "Int64"
+		case sqlValueFloat:
+			return "Float64"
+		case sqlValueBool:
+			return "UInt8"
+		}
+		return "String"
+	case "mssql":
+		if kind == sqlValueText {
+			return "NVARCHAR(450)"
+		}
+	case "mysql":
+		if kind == sqlValueText {
+			return "VARCHAR(255)"
+		}
+	}
+	return sqlColumnType(driver, sample)
+}
+
+// sqlValue converts a structured value into an argument for a statement, where
+// objects and arrays are serialised as JSON.
+func sqlValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	}
+	return v
+}
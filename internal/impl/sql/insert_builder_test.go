@@ -0,0 +1,292 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+
+	_ "modernc.org/sqlite"
+)
+
+func parseInsertConfig(t *testing.T, driver, conf string) *service.ParsedConfig {
+	t.Helper()
+
+	pConf, err := sqlInsertOutputConfig().ParseYAML("driver: "+driver+"\ndsn: foo\n"+conf, service.NewEnvironment())
+	require.NoError(t, err)
+	return pConf
+}
+
+func testInsertBuilder(t *testing.T, driver, conf string) *insertBuilder {
+	t.Helper()
+
+	b, err := insertBuilderFromParsed(parseInsertConfig(t, driver, conf), driver)
+	require.NoError(t, err)
+	return b
+}
+
+func TestInsertBuilderConfigErrs(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		driver string
+		conf   string
+	}{
+		{
+			name:   "no columns",
+			driver: "mysql",
+			conf:   "table: foo",
+		},
+		{
+			name:   "upsert without conflict columns",
+			driver: "mysql",
+			conf: `
+table: foo
+columns: [ id, name ]
+upsert:
+  enabled: true
+`,
+		},
+		{
+			name:   "bulk unsupported driver",
+			driver: "mysql",
+			conf: `
+table: foo
+columns: [ id, name ]
+bulk: true
+`,
+		},
+		{
+			name:   "bulk with upsert",
+			driver: "postgres",
+			conf: `
+table: foo
+columns: [ id, name ]
+upsert:
+  enabled: true
+  conflict_columns: [ id ]
+bulk: true
+`,
+		},
+		{
+			name:   "bulk with suffix",
+			driver: "postgres",
+			conf: `
+table: foo
+columns: [ id, name ]
+suffix: RETURNING id
+bulk: true
+`,
+		},
+		{
+			name:   "auto schema bad table",
+			driver: "postgres",
+			conf: `
+table: foo; DROP TABLE bar
+auto_schema: true
+`,
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := insertBuilderFromParsed(parseInsertConfig(t, test.driver, test.conf), test.driver)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestInsertBuilderUpsertStatements(t *testing.T) {
+	conf := `
+table: foo
+columns: [ id, name, age ]
+upsert:
+  enabled: true
+  conflict_columns: [ id ]
+`
+
+	for _, test := range []struct {
+		driver string
+		output string
+	}{
+		{
+			driver: "postgres",
+			output: "INSERT INTO foo (id,name,age) VALUES ($1,$2,$3),($4,$5,$6) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, age = EXCLUDED.age",
+		},
+		{
+			driver: "mysql",
+			output: "INSERT INTO foo (id,name,age) VALUES (?,?,?),(?,?,?) ON DUPLICATE KEY UPDATE name = VALUES(name), age = VALUES(age)",
+		},
+		{
+			driver: "clickhouse",
+			output: "INSERT INTO foo (id,name,age) VALUES (?,?,?),(?,?,?)",
+		},
+	} {
+		b := testInsertBuilder(t, test.driver, conf)
+
+		sqlStr, args, err := b.insertStatement(b.columns).
+			Values(1, "a", 10).
+			Values(2, "b", 20).
+			ToSql()
+		require.NoError(t, err, test.driver)
+		assert.Equal(t, test.output, sqlStr, test.driver)
+		assert.Equal(t, []interface{}{1, "a", 10, 2, "b", 20}, args, test.driver)
+	}
+}
+
+func TestInsertBuilderUpsertNoUpdates(t *testing.T) {
+	conf := `
+table: foo
+columns: [ id ]
+upsert:
+  enabled: true
+  conflict_columns: [ id ]
+`
+
+	b := testInsertBuilder(t, "postgres", conf)
+	assert.Equal(t, "ON CONFLICT (id) DO NOTHING", b.upsertSuffix(b.columns))
+
+	b = testInsertBuilder(t, "mysql", conf)
+	assert.Equal(t, "ON DUPLICATE KEY UPDATE id = VALUES(id)", b.upsertSuffix(b.columns))
+}
+
+func TestInsertBuilderMergeStatement(t *testing.T) {
+	b := testInsertBuilder(t, "mssql", `
+table: foo
+columns: [ id, name, age ]
+upsert:
+  enabled: true
+  conflict_columns: [ id ]
+  update_columns: [ name ]
+`)
+
+	sqlStr, args := b.mergeStatement(b.columns, [][]interface{}{
+		{1, "a", 10},
+		{2, "b", 20},
+	})
+	assert.Equal(t, "MERGE INTO foo WITH (HOLDLOCK) AS target USING (VALUES (?, ?, ?), (?, ?, ?)) AS source (id, name, age) ON target.id = source.id WHEN MATCHED THEN UPDATE SET target.name = source.name WHEN NOT MATCHED THEN INSERT (id, name, age) VALUES (source.id, source.name, source.age);", sqlStr)
+	assert.Equal(t, []interface{}{1, "a", 10, 2, "b", 20}, args)
+}
+
+func TestInsertBuilderSchemaStatements(t *testing.T) {
+	conf := `
+table: foo
+auto_schema: true
+upsert:
+  enabled: true
+  conflict_columns: [ id ]
+`
+
+	samples := map[string]interface{}{
+		"id":    "abc",
+		"count": json.Number("5"),
+		"ratio": 0.5,
+		"ok":    true,
+		"doc":   map[string]interface{}{},
+	}
+	columns := []string{"count", "doc", "id", "ok", "ratio"}
+
+	for _, test := range []struct {
+		driver    string
+		create    string
+		addColumn string
+	}{
+		{
+			driver:    "postgres",
+			create:    "CREATE TABLE IF NOT EXISTS foo (id TEXT NOT NULL, count BIGINT, doc TEXT, ok BOOLEAN, ratio DOUBLE PRECISION, PRIMARY KEY (id))",
+			addColumn: "ALTER TABLE foo ADD COLUMN IF NOT EXISTS count BIGINT NULL",
+		},
+		{
+			driver:    "mysql",
+			create:    "CREATE TABLE IF NOT EXISTS foo (id VARCHAR(255) NOT NULL, count BIGINT, doc TEXT, ok BOOLEAN, ratio DOUBLE, PRIMARY KEY (id))",
+			addColumn: "ALTER TABLE foo ADD COLUMN count BIGINT NULL",
+		},
+		{
+			driver:    "mssql",
+			create:    "IF OBJECT_ID(N'foo', N'U') IS NULL CREATE TABLE foo (id NVARCHAR(450) NOT NULL, count BIGINT, doc NVARCHAR(MAX), ok BIT, ratio FLOAT, PRIMARY KEY (id))",
+			addColumn: "ALTER TABLE foo ADD count BIGINT NULL",
+		},
+		{
+			driver:    "clickhouse",
+			create:    "CREATE TABLE IF NOT EXISTS foo (id String, count Nullable(Int64), doc Nullable(String), ok Nullable(UInt8), ratio Nullable(Float64)) ENGINE = ReplacingMergeTree ORDER BY (id)",
+			addColumn: "ALTER TABLE foo ADD COLUMN IF NOT EXISTS count Nullable(Int64)",
+		},
+	} {
+		b := testInsertBuilder(t, test.driver, conf)
+		assert.Equal(t, test.create, b.createTableStatement(columns, samples), test.driver)
+		assert.Equal(t, test.addColumn, b.addColumnStatement("count", samples["count"]), test.driver)
+	}
+}
+
+func TestInsertBuilderCopyStatement(t *testing.T) {
+	b := testInsertBuilder(t, "postgres", `
+table: foo
+columns: [ id, name ]
+bulk: true
+`)
+	assert.Equal(t, `COPY "foo" ("id", "name") FROM STDIN`, b.copyStatement(b.columns))
+
+	b.table = "bar.foo"
+	assert.Equal(t, `COPY "bar"."foo" ("id", "name") FROM STDIN`, b.copyStatement(b.columns))
+}
+
+func TestInsertBuilderSQLiteUpsertAutoSchema(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "foo.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	b := testInsertBuilder(t, "sqlite", `
+table: foo
+auto_schema: true
+upsert:
+  enabled: true
+  conflict_columns: [ id ]
+`)
+
+	insertObjects := func(objs ...map[string]interface{}) {
+		t.Helper()
+		columns, rows, err := b.rowsFromObjects(ctx, db, objs)
+		require.NoError(t, err)
+		require.NoError(t, b.insertRows(ctx, db, columns, rows))
+	}
+
+	insertObjects(
+		map[string]interface{}{"id": "a", "count": json.Number("1")},
+		map[string]interface{}{"id": "b", "count": json.Number("2")},
+	)
+	insertObjects(
+		map[string]interface{}{"id": "a", "count": json.Number("3"), "tags": []interface{}{"x"}},
+	)
+
+	rows, err := db.Query("SELECT id, count, tags FROM foo ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var results []string
+	for rows.Next() {
+		var id string
+		var count int64
+		var tags sql.NullString
+		require.NoError(t, rows.Scan(&id, &count, &tags))
+		results = append(results, fmt.Sprintf("%v:%v:%v", id, count, tags.String))
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{`a:3:["x"]`, "b:2:"}, results)
+}
+
+func TestSQLValue(t *testing.T) {
+	assert.Equal(t, int64(5), sqlValue(json.Number("5")))
+	assert.Equal(t, 5.5, sqlValue(json.Number("5.5")))
+	assert.Equal(t, `{"a":[1,2]}`, sqlValue(map[string]interface{}{"a": []interface{}{1, 2}}))
+	assert.Equal(t, "foo", sqlValue("foo"))
+	assert.Nil(t, sqlValue(nil))
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
@@ -26,6 +27,10 @@ import (
 type testFn func(t *testing.T, driver, dsn, table string)
 
 func testProcessors(name string, fn func(t *testing.T, insertProc, selectProc service.BatchProcessor)) testFn {
+	return testProcessorsWithInsertConf(name, "", fn)
+}
+
+func testProcessorsWithInsertConf(name, extraInsertConf string, fn func(t *testing.T, insertProc, selectProc service.BatchProcessor)) testFn {
 	return func(t *testing.T, driver, dsn, table string) {
 		t.Run(name, func(t *testing.T) {
 			insertConf := fmt.Sprintf(`
@@ -34,7 +39,8 @@ dsn: %v
 table: %v
 columns: [ foo, bar, baz ]
 args_mapping: 'root = [ this.foo, this.bar.floor(), this.baz ]'
-`, driver, dsn, table)
+%v
+`, driver, dsn, table, extraInsertConf)
 
 			queryConf := fmt.Sprintf(`
 driver: %v
@@ -160,7 +166,11 @@ result_codec: json_array
 	}
 }
 
-var testBatchProcessorBasic = testProcessors("basic", func(t *testing.T, insertProc, selectProc service.BatchProcessor) {
+var testBatchProcessorBasic = testProcessors("basic", batchProcessorBasic)
+
+var testBatchProcessorBulk = testProcessorsWithInsertConf("bulk", "bulk: true", batchProcessorBasic)
+
+func batchProcessorBasic(t *testing.T, insertProc, selectProc service.BatchProcessor) {
 	var insertBatch service.MessageBatch
 	for i := 0; i < 10; i++ {
 		insertBatch = append(insertBatch, service.NewMessage([]byte(fmt.Sprintf(`{
@@ -196,6 +206,45 @@ var testBatchProcessorBasic = testProcessors("basic", func(t *testing.T, insertP
 
 		assert.Equal(t, exp, string(actBytes))
 	}
+}
+
+var testBatchProcessorBulkAtomic = testProcessorsWithInsertConf("bulk atomic", "bulk: true", func(t *testing.T, insertProc, selectProc service.BatchProcessor) {
+	var insertBatch service.MessageBatch
+	for i := 0; i < 10; i++ {
+		insertBatch = append(insertBatch, service.NewMessage([]byte(fmt.Sprintf(`{
+  "foo": "doc-%v",
+  "bar": %v,
+  "baz": "and this"
+}`, i, i))))
+	}
+
+	// The final row cannot be inserted and so the whole batch must be
+	// rejected.
+	insertBatch[9] = service.NewMessage([]byte(`{"foo":"doc-9","bar":9,"baz":{"not":"a string"}}`))
+
+	resBatches, err := insertProc.ProcessBatch(context.Background(), insertBatch)
+	require.NoError(t, err)
+	require.Len(t, resBatches, 1)
+	require.Len(t, resBatches[0], len(insertBatch))
+	for _, v := range resBatches[0] {
+		require.Error(t, v.GetError())
+	}
+
+	var queryBatch service.MessageBatch
+	for i := 0; i < 10; i++ {
+		queryBatch = append(queryBatch, service.NewMessage([]byte(fmt.Sprintf(`{"id":"doc-%v"}`, i))))
+	}
+
+	resBatches, err = selectProc.ProcessBatch(context.Background(), queryBatch)
+	require.NoError(t, err)
+	require.Len(t, resBatches, 1)
+	for _, v := range resBatches[0] {
+		require.NoError(t, v.GetError())
+
+		actBytes, err := v.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, `[]`, string(actBytes))
+	}
 })
 
 var testBatchProcessorParallel = testProcessors("parallel", func(t *testing.T, insertProc, selectProc service.BatchProcessor) {
@@ -512,15 +561,15 @@ processors:
 	})
 }
 
-func testSuite(t *testing.T, driver, dsn string, createTableFn func(string) error) {
-	for _, fn := range []testFn{
+func testSuite(t *testing.T, driver, dsn string, createTableFn func(string) error, extraFns ...testFn) {
+	for _, fn := range append([]testFn{
 		testBatchProcessorBasic,
 		testBatchProcessorParallel,
 		testBatchInputOutputBatch,
 		testBatchInputOutputRaw,
 		testRawProcessorsBasic,
 		testDeprecatedProcessorsBasic,
-	} {
+	}, extraFns...) {
 		tableName, err := gonanoid.Generate("abcdefghijklmnopqrstuvwxyz", 40)
 		require.NoError(t, err)
 
@@ -540,6 +589,28 @@ func TestIntegration(t *testing.T) {
 	t.Run("mssql", msSQLIntegration)
 }
 
+func TestSQLiteIntegration(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	createTable := func(name string) error {
+		_, err := db.Exec(fmt.Sprintf(`create table %v (
+  foo varchar(50) not null,
+  bar integer not null,
+  baz varchar(50) not null,
+  primary key (foo)
+);`, name))
+		return err
+	}
+
+	testSuite(t, "sqlite", dsn, createTable)
+}
+
 func clickhouseIntegration(t *testing.T) {
 	if runtime.GOOS == "darwin" {
 		t.Skip("skipping test on macos")
@@ -595,7 +666,11 @@ func clickhouseIntegration(t *testing.T) {
 		return nil
 	}))
 
-	testSuite(t, "clickhouse", dsn, createTable)
+	testSuite(t, "clickhouse", dsn, createTable, testBatchProcessorBulk, func(t *testing.T, driver, dsn, table string) {
+		// A block size lower than the batch size would otherwise split the
+		// batch across multiple blocks.
+		testBatchProcessorBulkAtomic(t, driver, dsn+"?block_size=2", table)
+	})
 }
 
 func postgresIntegration(t *testing.T) {
@@ -655,7 +730,7 @@ func postgresIntegration(t *testing.T) {
 		return nil
 	}))
 
-	testSuite(t, "postgres", dsn, createTable)
+	testSuite(t, "postgres", dsn, createTable, testBatchProcessorBulk, testBatchProcessorBulkAtomic)
 }
 
 func mySQLIntegration(t *testing.T) {
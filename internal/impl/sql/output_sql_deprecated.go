@@ -83,5 +83,6 @@ func newSQLDeprecatedOutputFromConfig(conf *service.ParsedConfig, logger *servic
 	if err != nil {
 		return nil, err
 	}
-	return newSQLRawOutput(logger, driverStr, dsnStr, useTxStmt, queryStatic, argsMapping, connSettings), nil
+	queries := []rawQuery{{static: queryStatic, argsMapping: argsMapping}}
+	return newSQLRawOutput(logger, driverStr, dsnStr, useTxStmt, queries, txSettings{}, connSettings), nil
 }
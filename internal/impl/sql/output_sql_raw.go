@@ -3,11 +3,9 @@ package sql
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"sync"
 
 	"github.com/benthosdev/benthos/v4/internal/shutdown"
-	"github.com/benthosdev/benthos/v4/public/bloblang"
 	"github.com/benthosdev/benthos/v4/public/service"
 )
 
@@ -18,15 +16,14 @@ func sqlRawOutputConfig() *service.ConfigSpec {
 		Summary("Executes an arbitrary SQL query for each message.").
 		Description(``).
 		Field(driverField).
-		Field(dsnField).
-		Field(service.NewStringField("query").
-			Description("The query to execute.").
-			Example("INSERT INTO footable (foo, bar, baz) VALUES (?, ?, ?);")).
-		Field(service.NewBloblangField("args_mapping").
-			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an array of values matching in size to the number of placeholder arguments in the field `query`.").
-			Example("root = [ this.cat.meow, this.doc.woofs[0] ]").
-			Example(`root = [ meta("user.id") ]`).
-			Optional()).
+		Field(dsnField)
+
+	for _, f := range rawQueryFields() {
+		spec = spec.Field(f)
+	}
+
+	spec = spec.Field(rawQueriesField()).
+		Field(txField()).
 		Field(service.NewIntField("max_in_flight").
 			Description("The maximum number of inserts to run in parallel.").
 			Default(64))
@@ -84,10 +81,10 @@ type sqlRawOutput struct {
 	db     *sql.DB
 	dbMut  sync.RWMutex
 
-	queryStatic string
+	queries []rawQuery
 
-	useTxStmt   bool
-	argsMapping *bloblang.Executor
+	useTxStmt bool
+	tx        txSettings
 
 	connSettings connSettings
 
@@ -106,35 +103,33 @@ func newSQLRawOutputFromConfig(conf *service.ParsedConfig, logger *service.Logge
 		return nil, err
 	}
 
-	queryStatic, err := conf.FieldString("query")
+	queries, err := rawQueriesFromParsed(conf, false)
 	if err != nil {
 		return nil, err
 	}
 
-	var argsMapping *bloblang.Executor
-	if conf.Contains("args_mapping") {
-		if argsMapping, err = conf.FieldBloblang("args_mapping"); err != nil {
-			return nil, err
-		}
-	}
-
 	_, useTxStmt := map[string]struct{}{
 		"clickhouse": {},
 	}[driverStr]
 
+	tx, err := txSettingsFromParsed(conf, driverStr)
+	if err != nil {
+		return nil, err
+	}
+
 	connSettings, err := connSettingsFromParsed(conf)
 	if err != nil {
 		return nil, err
 	}
-	return newSQLRawOutput(logger, driverStr, dsnStr, useTxStmt, queryStatic, argsMapping, connSettings), nil
+	return newSQLRawOutput(logger, driverStr, dsnStr, useTxStmt, queries, tx, connSettings), nil
 }
 
 func newSQLRawOutput(
 	logger *service.Logger,
 	driverStr, dsnStr string,
 	useTxStmt bool,
-	queryStatic string,
-	argsMapping *bloblang.Executor,
+	queries []rawQuery,
+	tx txSettings,
 	connSettings connSettings,
 ) *sqlRawOutput {
 	return &sqlRawOutput{
@@ -143,8 +138,8 @@ func newSQLRawOutput(
 		driver:       driverStr,
 		dsn:          dsnStr,
 		useTxStmt:    useTxStmt,
-		queryStatic:  queryStatic,
-		argsMapping:  argsMapping,
+		queries:      queries,
+		tx:           tx,
 		connSettings: connSettings,
 	}
 }
@@ -180,50 +175,45 @@ func (s *sqlRawOutput) WriteBatch(ctx context.Context, batch service.MessageBatc
 	s.dbMut.RLock()
 	defer s.dbMut.RUnlock()
 
-	var tx *sql.Tx
-	var stmt *sql.Stmt
+	tx := s.tx
 	if s.useTxStmt {
-		var err error
-		if tx, err = s.db.Begin(); err != nil {
-			return err
-		}
-		if stmt, err = tx.Prepare(s.queryStatic); err != nil {
-			_ = tx.Rollback()
-			return err
-		}
+		// Some drivers only support executing statements via a prepared
+		// statement within a transaction.
+		tx = txSettings{enabled: true}
 	}
 
-	for i := range batch {
-		var args []interface{}
-		resMsg, err := batch.BloblangQuery(i, s.argsMapping)
-		if err != nil {
-			return err
-		}
-
-		iargs, err := resMsg.AsStructured()
-		if err != nil {
-			return err
-		}
-
-		var ok bool
-		if args, ok = iargs.([]interface{}); !ok {
-			return fmt.Errorf("mapping returned non-array result: %T", iargs)
+	return tx.run(ctx, s.db, func(conn sqlConn) error {
+		var stmts []*sql.Stmt
+		if s.useTxStmt {
+			stmts = make([]*sql.Stmt, len(s.queries))
+			for j, q := range s.queries {
+				stmt, err := conn.PrepareContext(ctx, q.static)
+				if err != nil {
+					return err
+				}
+				defer stmt.Close()
+				stmts[j] = stmt
+			}
 		}
 
-		if tx == nil {
-			if _, err = s.db.ExecContext(ctx, s.queryStatic, args...); err != nil {
-				return err
+		for i := range batch {
+			for j, q := range s.queries {
+				args, err := q.args(batch, i)
+				if err != nil {
+					return err
+				}
+				if stmts != nil {
+					_, err = stmts[j].ExecContext(ctx, args...)
+				} else {
+					_, err = conn.ExecContext(ctx, q.static, args...)
+				}
+				if err != nil {
+					return err
+				}
 			}
-		} else if _, err = stmt.Exec(args...); err != nil {
-			return err
 		}
-	}
-
-	var err error
-	if tx != nil {
-		err = tx.Commit()
-	}
-	return err
+		return nil
+	})
 }
 
 func (s *sqlRawOutput) Close(ctx context.Context) error {
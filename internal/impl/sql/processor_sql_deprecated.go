@@ -98,5 +98,6 @@ func NewSQLDeprecatedProcessorFromConfig(conf *service.ParsedConfig, logger *ser
 	if err != nil {
 		return nil, err
 	}
-	return newSQLRawProcessor(logger, driverStr, dsnStr, queryStatic, queryDyn, onlyExec, useTxStmt, argsMapping, connSettings)
+	queries := []rawQuery{{static: queryStatic, dynamic: queryDyn, argsMapping: argsMapping}}
+	return newSQLRawProcessor(logger, driverStr, dsnStr, queries, onlyExec, useTxStmt, txSettings{}, connSettings)
 }
@@ -3,11 +3,9 @@ package sql
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"sync"
 
-	"github.com/Masterminds/squirrel"
-
 	"github.com/benthosdev/benthos/v4/internal/shutdown"
 	"github.com/benthosdev/benthos/v4/public/bloblang"
 	"github.com/benthosdev/benthos/v4/public/service"
@@ -20,19 +18,21 @@ func InsertProcessorConfig() *service.ConfigSpec {
 		Categories("Integration").
 		Summary("Inserts rows into an SQL database for each message, and leaves the message unchanged.").
 		Description(`
-If the insert fails to execute then the message will still remain unchanged and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).`).
+If the insert fails to execute then the message will still remain unchanged and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).` + insertDescription).
 		Field(driverField).
 		Field(dsnField).
 		Field(service.NewStringField("table").
 			Description("The table to insert to.").
 			Example("foo")).
 		Field(service.NewStringListField("columns").
-			Description("A list of columns to insert.").
-			Example([]string{"foo", "bar", "baz"})).
+			Description("A list of columns to insert, which must be set unless `auto_schema` is enabled.").
+			Example([]string{"foo", "bar", "baz"}).
+			Optional()).
 		Field(service.NewBloblangField("args_mapping").
-			Description("A [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an array of values matching in size to the number of columns specified.").
+			Description("A [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an array of values matching in size to the number of columns specified, or to an object of columns when `auto_schema` is enabled. This must be set unless `auto_schema` is enabled, in which case messages are inserted as they are by default.").
 			Example("root = [ this.cat.meow, this.doc.woofs[0] ]").
-			Example(`root = [ meta("user.id") ]`)).
+			Example(`root = [ meta("user.id") ]`).
+			Optional()).
 		Field(service.NewStringField("prefix").
 			Description("An optional prefix to prepend to the insert query (before INSERT).").
 			Optional().
@@ -43,6 +43,12 @@ If the insert fails to execute then the message will still remain unchanged and
 			Advanced().
 			Example("ON CONFLICT (name) DO NOTHING"))
 
+	for _, f := range insertFields() {
+		spec = spec.Field(f)
+	}
+
+	spec = spec.Field(txField())
+
 	for _, f := range connFields() {
 		spec = spec.Field(f)
 	}
@@ -86,11 +92,11 @@ func init() {
 
 type sqlInsertProcessor struct {
 	db      *sql.DB
-	builder squirrel.InsertBuilder
+	builder *insertBuilder
 	dbMut   sync.RWMutex
 
-	useTxStmt   bool
 	argsMapping *bloblang.Executor
+	tx          txSettings
 
 	logger  *service.Logger
 	shutSig *shutdown.Signaller
@@ -108,24 +114,13 @@ func NewSQLInsertProcessorFromConfig(conf *service.ParsedConfig, logger *service
 	if err != nil {
 		return nil, err
 	}
-	if _, in := map[string]struct{}{
-		"clickhouse": {},
-	}[driverStr]; in {
-		s.useTxStmt = true
-	}
 
 	dsnStr, err := conf.FieldString("dsn")
 	if err != nil {
 		return nil, err
 	}
 
-	tableStr, err := conf.FieldString("table")
-	if err != nil {
-		return nil, err
-	}
-
-	columns, err := conf.FieldStringList("columns")
-	if err != nil {
+	if s.builder, err = insertBuilderFromParsed(conf, driverStr); err != nil {
 		return nil, err
 	}
 
@@ -133,27 +128,12 @@ func NewSQLInsertProcessorFromConfig(conf *service.ParsedConfig, logger *service
 		if s.argsMapping, err = conf.FieldBloblang("args_mapping"); err != nil {
 			return nil, err
 		}
+	} else if !s.builder.autoSchema {
+		return nil, errors.New("an args_mapping must be specified when auto_schema is disabled")
 	}
 
-	s.builder = squirrel.Insert(tableStr).Columns(columns...)
-	if driverStr == "postgres" {
-		s.builder = s.builder.PlaceholderFormat(squirrel.Dollar)
-	}
-
-	if conf.Contains("prefix") {
-		prefixStr, err := conf.FieldString("prefix")
-		if err != nil {
-			return nil, err
-		}
-		s.builder = s.builder.Prefix(prefixStr)
-	}
-
-	if conf.Contains("suffix") {
-		suffixStr, err := conf.FieldString("suffix")
-		if err != nil {
-			return nil, err
-		}
-		s.builder = s.builder.Suffix(suffixStr)
+	if s.tx, err = txSettingsFromParsed(conf, driverStr); err != nil {
+		return nil, err
 	}
 
 	connSettings, err := connSettingsFromParsed(conf)
@@ -183,62 +163,48 @@ func (s *sqlInsertProcessor) ProcessBatch(ctx context.Context, batch service.Mes
 	s.dbMut.RLock()
 	defer s.dbMut.RUnlock()
 
-	insertBuilder := s.builder
-
-	var tx *sql.Tx
-	var stmt *sql.Stmt
-	if s.useTxStmt {
-		var err error
-		if tx, err = s.db.Begin(); err != nil {
-			return nil, err
-		}
-		sqlStr, _, err := insertBuilder.Values().ToSql()
-		if err != nil {
-			return nil, err
-		}
-		if stmt, err = tx.Prepare(sqlStr); err != nil {
-			_ = tx.Rollback()
-			return nil, err
-		}
-	}
-
 	batch = batch.Copy()
-	for i, msg := range batch {
-		var args []interface{}
-		resMsg, err := batch.BloblangQuery(i, s.argsMapping)
-		if err != nil {
-			s.logger.Debugf("Arguments mapping failed: %v", err)
-			msg.SetError(err)
-			continue
-		}
-
-		iargs, err := resMsg.AsStructured()
-		if err != nil {
-			s.logger.Debugf("Mapping returned non-structured result: %v", err)
-			msg.SetError(fmt.Errorf("mapping returned non-structured result: %w", err))
-			continue
-		}
 
-		var ok bool
-		if args, ok = iargs.([]interface{}); !ok {
-			s.logger.Debugf("Mapping returned non-array result: %T", iargs)
-			msg.SetError(fmt.Errorf("mapping returned non-array result: %T", iargs))
-			continue
+	columns := s.builder.columns
+	var rows [][]interface{}
+	if s.builder.autoSchema {
+		objs := make([]map[string]interface{}, 0, len(batch))
+		for i, msg := range batch {
+			obj, err := insertObjectFromMessage(batch, i, s.argsMapping)
+			if err != nil {
+				s.logger.Debugf("Failed to extract columns: %v", err)
+				if s.tx.enabled {
+					return nil, err
+				}
+				msg.SetError(err)
+				continue
+			}
+			objs = append(objs, obj)
 		}
-
-		if tx == nil {
-			insertBuilder = insertBuilder.Values(args...)
-		} else if _, err = stmt.Exec(args...); err != nil {
+		var err error
+		if columns, rows, err = s.builder.rowsFromObjects(ctx, s.db, objs); err != nil {
+			s.logger.Debugf("Failed to update schema: %v", err)
 			return nil, err
 		}
-	}
-
-	var err error
-	if tx == nil {
-		_, err = insertBuilder.RunWith(s.db).ExecContext(ctx)
 	} else {
-		err = tx.Commit()
+		rows = make([][]interface{}, 0, len(batch))
+		for i, msg := range batch {
+			args, err := insertArgsFromMessage(batch, i, s.argsMapping)
+			if err != nil {
+				s.logger.Debugf("Arguments mapping failed: %v", err)
+				if s.tx.enabled {
+					return nil, err
+				}
+				msg.SetError(err)
+				continue
+			}
+			rows = append(rows, args)
+		}
 	}
+
+	err := s.tx.run(ctx, s.db, func(conn sqlConn) error {
+		return s.builder.insertRows(ctx, conn, columns, rows)
+	})
 	if err != nil {
 		s.logger.Debugf("Failed to run query: %v", err)
 		return nil, err
@@ -3,11 +3,9 @@ package sql
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"sync"
 
 	"github.com/benthosdev/benthos/v4/internal/shutdown"
-	"github.com/benthosdev/benthos/v4/public/bloblang"
 	"github.com/benthosdev/benthos/v4/public/service"
 )
 
@@ -20,22 +18,21 @@ func RawProcessorConfig() *service.ConfigSpec {
 		Description(`
 If the query fails to execute then the message will remain unchanged and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).`).
 		Field(driverField).
-		Field(dsnField).
-		Field(service.NewStringField("query").
-			Description("The query to execute.").
-			Example("INSERT INTO footable (foo, bar, baz) VALUES (?, ?, ?);")).
-		Field(service.NewBoolField("unsafe_dynamic_query").
-			Description("Whether to enable [interpolation functions](/docs/configuration/interpolation/#bloblang-queries) in the query. Great care should be made to ensure your queries are defended against injection attacks.").
-			Advanced().
-			Default(false)).
-		Field(service.NewBloblangField("args_mapping").
-			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an array of values matching in size to the number of placeholder arguments in the field `query`.").
-			Example("root = [ this.cat.meow, this.doc.woofs[0] ]").
-			Example(`root = [ meta("user.id") ]`).
-			Optional()).
+		Field(dsnField)
+
+	for _, f := range rawQueryFields() {
+		spec = spec.Field(f)
+	}
+
+	spec = spec.Field(service.NewBoolField("unsafe_dynamic_query").
+		Description("Whether to enable [interpolation functions](/docs/configuration/interpolation/#bloblang-queries) in the query. Great care should be made to ensure your queries are defended against injection attacks.").
+		Advanced().
+		Default(false)).
+		Field(rawQueriesField()).
 		Field(service.NewBoolField("exec_only").
-			Description("Whether the query result should be discarded. When set to `true` the message contents will remain unchanged, which is useful in cases where you are executing inserts, updates, etc.").
-			Default(false))
+			Description("Whether the query result should be discarded. When set to `true` the message contents will remain unchanged, which is useful in cases where you are executing inserts, updates, etc. When multiple `queries` are specified the result of the final query is used.").
+			Default(false)).
+		Field(txField())
 
 	for _, f := range connFields() {
 		spec = spec.Field(f)
@@ -93,12 +90,10 @@ type sqlRawProcessor struct {
 	db    *sql.DB
 	dbMut sync.RWMutex
 
-	queryStatic string
-	queryDyn    *service.InterpolatedString
-	onlyExec    bool
-	useTxStmt   bool
-
-	argsMapping *bloblang.Executor
+	queries   []rawQuery
+	onlyExec  bool
+	useTxStmt bool
+	tx        txSettings
 
 	logger  *service.Logger
 	shutSig *shutdown.Signaller
@@ -117,18 +112,14 @@ func NewSQLRawProcessorFromConfig(conf *service.ParsedConfig, logger *service.Lo
 		return nil, err
 	}
 
-	queryStatic, err := conf.FieldString("query")
+	unsafeDyn, err := conf.FieldBool("unsafe_dynamic_query")
 	if err != nil {
 		return nil, err
 	}
 
-	var queryDyn *service.InterpolatedString
-	if unsafeDyn, err := conf.FieldBool("unsafe_dynamic_query"); err != nil {
+	queries, err := rawQueriesFromParsed(conf, unsafeDyn)
+	if err != nil {
 		return nil, err
-	} else if unsafeDyn {
-		if queryDyn, err = conf.FieldInterpolatedString("query"); err != nil {
-			return nil, err
-		}
 	}
 
 	onlyExec, err := conf.FieldBool("exec_only")
@@ -136,42 +127,38 @@ func NewSQLRawProcessorFromConfig(conf *service.ParsedConfig, logger *service.Lo
 		return nil, err
 	}
 
-	var argsMapping *bloblang.Executor
-	if conf.Contains("args_mapping") {
-		if argsMapping, err = conf.FieldBloblang("args_mapping"); err != nil {
-			return nil, err
-		}
-	}
-
 	_, useTxStmt := map[string]struct{}{
 		"clickhouse": {},
 	}[driverStr]
 
+	tx, err := txSettingsFromParsed(conf, driverStr)
+	if err != nil {
+		return nil, err
+	}
+
 	connSettings, err := connSettingsFromParsed(conf)
 	if err != nil {
 		return nil, err
 	}
-	return newSQLRawProcessor(logger, driverStr, dsnStr, queryStatic, queryDyn, onlyExec, useTxStmt, argsMapping, connSettings)
+	return newSQLRawProcessor(logger, driverStr, dsnStr, queries, onlyExec, useTxStmt, tx, connSettings)
 }
 
 func newSQLRawProcessor(
 	logger *service.Logger,
 	driverStr, dsnStr string,
-	queryStatic string,
-	queryDyn *service.InterpolatedString,
+	queries []rawQuery,
 	onlyExec bool,
 	useTxStmt bool,
-	argsMapping *bloblang.Executor,
+	tx txSettings,
 	connSettings connSettings,
 ) (*sqlRawProcessor, error) {
 	s := &sqlRawProcessor{
-		logger:      logger,
-		shutSig:     shutdown.NewSignaller(),
-		queryStatic: queryStatic,
-		queryDyn:    queryDyn,
-		onlyExec:    onlyExec,
-		useTxStmt:   useTxStmt,
-		argsMapping: argsMapping,
+		logger:    logger,
+		shutSig:   shutdown.NewSignaller(),
+		queries:   queries,
+		onlyExec:  onlyExec,
+		useTxStmt: useTxStmt,
+		tx:        tx,
 	}
 
 	var err error
@@ -192,79 +179,85 @@ func newSQLRawProcessor(
 	return s, nil
 }
 
+// queryMessage executes the queries of a message of a batch, returning the
+// rows of the final query unless exec_only is set.
+func (s *sqlRawProcessor) queryMessage(ctx context.Context, conn sqlConn, batch service.MessageBatch, i int) ([]interface{}, error) {
+	var result []interface{}
+	for j, q := range s.queries {
+		args, err := q.args(batch, i)
+		if err != nil {
+			return nil, err
+		}
+
+		queryStr := q.query(batch, i)
+		if s.onlyExec || j < len(s.queries)-1 {
+			if _, err = conn.ExecContext(ctx, queryStr, args...); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		rows, err := conn.QueryContext(ctx, queryStr, args...)
+		if err != nil {
+			return nil, err
+		}
+		result, err = sqlRowsToArray(rows)
+		_ = rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 func (s *sqlRawProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
 	s.dbMut.RLock()
 	defer s.dbMut.RUnlock()
 
 	batch = batch.Copy()
-	for i, msg := range batch {
-		var args []interface{}
-		if s.argsMapping != nil {
-			resMsg, err := batch.BloblangQuery(i, s.argsMapping)
-			if err != nil {
-				s.logger.Debugf("Arguments mapping failed: %v", err)
-				msg.SetError(err)
-				continue
-			}
 
-			iargs, err := resMsg.AsStructured()
-			if err != nil {
-				s.logger.Debugf("Mapping returned non-structured result: %v", err)
-				msg.SetError(fmt.Errorf("mapping returned non-structured result: %w", err))
-				continue
-			}
-
-			var ok bool
-			if args, ok = iargs.([]interface{}); !ok {
-				s.logger.Debugf("Mapping returned non-array result: %T", iargs)
-				msg.SetError(fmt.Errorf("mapping returned non-array result: %T", iargs))
-				continue
+	if s.tx.enabled {
+		// Results are only written to messages once the transaction has been
+		// committed, and a failure of any message fails the whole batch.
+		results := make([][]interface{}, len(batch))
+		if err := s.tx.run(ctx, s.db, func(conn sqlConn) error {
+			for i := range batch {
+				var err error
+				if results[i], err = s.queryMessage(ctx, conn, batch, i); err != nil {
+					return err
+				}
 			}
+			return nil
+		}); err != nil {
+			s.logger.Debugf("Failed to run transaction: %v", err)
+			return nil, err
 		}
-
-		queryStr := s.queryStatic
-		if s.queryDyn != nil {
-			queryStr = batch.InterpolatedString(i, s.queryDyn)
+		if !s.onlyExec {
+			for i, msg := range batch {
+				msg.SetStructured(results[i])
+			}
 		}
+		return []service.MessageBatch{batch}, nil
+	}
 
-		if s.onlyExec {
-			// TODO: Batch-wide transaction
-			if s.useTxStmt {
-				tx, err := s.db.Begin()
-				if err == nil {
-					_, err = tx.Exec(queryStr, args...)
-				}
-				if err == nil {
-					err = tx.Commit()
-				} else {
-					_ = tx.Rollback()
-				}
-				if err != nil {
-					s.logger.Debugf("Failed to run query: %v", err)
-					msg.SetError(err)
-					continue
-				}
-			} else {
-				if _, err := s.db.ExecContext(ctx, queryStr, args...); err != nil {
-					s.logger.Debugf("Failed to run query: %v", err)
-					msg.SetError(err)
-					continue
-				}
-			}
+	for i, msg := range batch {
+		var result []interface{}
+		var err error
+		if s.onlyExec && s.useTxStmt {
+			err = txSettings{enabled: true}.run(ctx, s.db, func(conn sqlConn) error {
+				_, err := s.queryMessage(ctx, conn, batch, i)
+				return err
+			})
 		} else {
-			rows, err := s.db.QueryContext(ctx, queryStr, args...)
-			if err != nil {
-				s.logger.Debugf("Failed to run query: %v", err)
-				msg.SetError(err)
-				continue
-			}
-
-			if jArray, err := sqlRowsToArray(rows); err != nil {
-				s.logger.Debugf("Failed to convert rows: %v", err)
-				msg.SetError(err)
-			} else {
-				msg.SetStructured(jArray)
-			}
+			result, err = s.queryMessage(ctx, s.db, batch, i)
+		}
+		if err != nil {
+			s.logger.Debugf("Failed to run query: %v", err)
+			msg.SetError(err)
+			continue
+		}
+		if !s.onlyExec {
+			msg.SetStructured(result)
 		}
 	}
 	return []service.MessageBatch{batch}, nil
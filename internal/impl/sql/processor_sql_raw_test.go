@@ -0,0 +1,200 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+
+	_ "modernc.org/sqlite"
+)
+
+func sqliteTestDB(t *testing.T) (string, *sql.DB) {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	_, err = db.Exec(`create table things (id integer primary key, name text not null)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`create table events (thing_id integer not null)`)
+	require.NoError(t, err)
+	return dsn, db
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRow("select count(*) from "+table).Scan(&n))
+	return n
+}
+
+func rawTestBatch(docs ...string) service.MessageBatch {
+	var batch service.MessageBatch
+	for _, d := range docs {
+		batch = append(batch, service.NewMessage([]byte(d)))
+	}
+	return batch
+}
+
+func TestSQLRawQueriesConfigErrs(t *testing.T) {
+	for _, conf := range []string{
+		`
+driver: sqlite
+dsn: foo
+`,
+		`
+driver: sqlite
+dsn: foo
+query: select 1
+queries:
+  - query: select 2
+`,
+		`
+driver: clickhouse
+dsn: foo
+query: select 1
+transaction:
+  enabled: true
+`,
+	} {
+		pConf, err := RawProcessorConfig().ParseYAML(conf, service.NewEnvironment())
+		require.NoError(t, err)
+
+		_, err = NewSQLRawProcessorFromConfig(pConf, nil)
+		require.Error(t, err, conf)
+	}
+}
+
+func TestSQLRawProcessorTransaction(t *testing.T) {
+	dsn, db := sqliteTestDB(t)
+
+	pConf, err := RawProcessorConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+queries:
+  - query: insert into things (id, name) values (?, ?)
+    args_mapping: 'root = [ this.id, this.name ]'
+  - query: insert into events (thing_id) values (?)
+    args_mapping: 'root = [ this.id ]'
+  - query: select count(*) as n from events
+transaction:
+  enabled: true
+  isolation_level: serializable
+`, service.NewEnvironment())
+	require.NoError(t, err)
+
+	proc, err := NewSQLRawProcessorFromConfig(pConf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		proc.Close(context.Background())
+	})
+
+	tCtx := context.Background()
+
+	batches, err := proc.ProcessBatch(tCtx, rawTestBatch(
+		`{"id":1,"name":"foo"}`,
+		`{"id":2,"name":"bar"}`,
+	))
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 2)
+	for i, exp := range []string{`[{"n":1}]`, `[{"n":2}]`} {
+		require.NoError(t, batches[0][i].GetError())
+		mBytes, err := batches[0][i].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(mBytes))
+	}
+
+	// The final message conflicts with the first, and therefore the entire
+	// batch is rolled back.
+	_, err = proc.ProcessBatch(tCtx, rawTestBatch(
+		`{"id":3,"name":"baz"}`,
+		`{"id":1,"name":"buz"}`,
+	))
+	require.Error(t, err)
+
+	assert.Equal(t, 2, countRows(t, db, "things"))
+	assert.Equal(t, 2, countRows(t, db, "events"))
+}
+
+func TestSQLRawProcessorNoTransaction(t *testing.T) {
+	dsn, db := sqliteTestDB(t)
+
+	pConf, err := RawProcessorConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+query: insert into things (id, name) values (?, ?)
+args_mapping: 'root = [ this.id, this.name ]'
+exec_only: true
+`, service.NewEnvironment())
+	require.NoError(t, err)
+
+	proc, err := NewSQLRawProcessorFromConfig(pConf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		proc.Close(context.Background())
+	})
+
+	batches, err := proc.ProcessBatch(context.Background(), rawTestBatch(
+		`{"id":1,"name":"foo"}`,
+		`{"id":1,"name":"bar"}`,
+		`{"id":2,"name":"baz"}`,
+	))
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 3)
+
+	assert.NoError(t, batches[0][0].GetError())
+	assert.Error(t, batches[0][1].GetError())
+	assert.NoError(t, batches[0][2].GetError())
+	assert.Equal(t, 2, countRows(t, db, "things"))
+}
+
+func TestSQLRawOutputTransaction(t *testing.T) {
+	dsn, db := sqliteTestDB(t)
+
+	pConf, err := sqlRawOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+queries:
+  - query: insert into things (id, name) values (?, ?)
+    args_mapping: 'root = [ this.id, this.name ]'
+  - query: insert into events (thing_id) values (?)
+    args_mapping: 'root = [ this.id ]'
+transaction:
+  enabled: true
+`, service.NewEnvironment())
+	require.NoError(t, err)
+
+	out, err := newSQLRawOutputFromConfig(pConf, nil)
+	require.NoError(t, err)
+
+	tCtx := context.Background()
+	require.NoError(t, out.Connect(tCtx))
+	t.Cleanup(func() {
+		out.Close(tCtx)
+	})
+
+	require.NoError(t, out.WriteBatch(tCtx, rawTestBatch(
+		`{"id":1,"name":"foo"}`,
+		`{"id":2,"name":"bar"}`,
+	)))
+	require.Error(t, out.WriteBatch(tCtx, rawTestBatch(
+		`{"id":3,"name":"baz"}`,
+		`{"id":2,"name":"buz"}`,
+	)))
+
+	assert.Equal(t, 2, countRows(t, db, "things"))
+	assert.Equal(t, 2, countRows(t, db, "events"))
+}
@@ -0,0 +1,139 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func rawArgsMappingField() *service.ConfigField {
+	return service.NewBloblangField("args_mapping").
+		Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) which should evaluate to an array of values matching in size to the number of placeholder arguments in the field `query`.").
+		Example("root = [ this.cat.meow, this.doc.woofs[0] ]").
+		Example(`root = [ meta("user.id") ]`).
+		Optional()
+}
+
+func rawQueryFields() []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewStringField("query").
+			Description("The query to execute. Either this field or `queries` must be set.").
+			Example("INSERT INTO footable (foo, bar, baz) VALUES (?, ?, ?);").
+			Optional(),
+		rawArgsMappingField(),
+	}
+}
+
+func rawQueriesField() *service.ConfigField {
+	return service.NewObjectListField("queries",
+		service.NewStringField("query").
+			Description("The query to execute.").
+			Example("INSERT INTO footable (foo, bar, baz) VALUES (?, ?, ?);"),
+		rawArgsMappingField(),
+	).
+		Description("A list of queries to execute in order for each message, as an alternative to the fields `query` and `args_mapping`. This is useful for executing multiple statements per message, and is best combined with a `transaction` so that the statements of a batch succeed or fail together.").
+		Optional().
+		Advanced().
+		Version("4.0.0")
+}
+
+// rawQuery is a query along with an optional mapping for its arguments.
+type rawQuery struct {
+	static      string
+	dynamic     *service.InterpolatedString
+	argsMapping *bloblang.Executor
+}
+
+func rawQueryFromParsed(conf *service.ParsedConfig, unsafeDynamic bool) (q rawQuery, err error) {
+	if q.static, err = conf.FieldString("query"); err != nil {
+		return
+	}
+	if unsafeDynamic {
+		if q.dynamic, err = conf.FieldInterpolatedString("query"); err != nil {
+			return
+		}
+	}
+	if conf.Contains("args_mapping") {
+		if q.argsMapping, err = conf.FieldBloblang("args_mapping"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// rawQueriesFromParsed returns either the single query described by the fields
+// query and args_mapping, or the list of queries from the field queries.
+func rawQueriesFromParsed(conf *service.ParsedConfig, unsafeDynamic bool) ([]rawQuery, error) {
+	var queryStr string
+	if conf.Contains("query") {
+		var err error
+		if queryStr, err = conf.FieldString("query"); err != nil {
+			return nil, err
+		}
+	}
+
+	var qConfs []*service.ParsedConfig
+	if conf.Contains("queries") {
+		var err error
+		if qConfs, err = conf.FieldObjectList("queries"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(qConfs) > 0 {
+		if queryStr != "" {
+			return nil, errors.New("cannot specify both query and queries")
+		}
+
+		queries := make([]rawQuery, len(qConfs))
+		for i, qConf := range qConfs {
+			var err error
+			if queries[i], err = rawQueryFromParsed(qConf, unsafeDynamic); err != nil {
+				return nil, fmt.Errorf("query %v: %w", i, err)
+			}
+		}
+		return queries, nil
+	}
+
+	if queryStr == "" {
+		return nil, errors.New("either query or queries must be specified")
+	}
+	q, err := rawQueryFromParsed(conf, unsafeDynamic)
+	if err != nil {
+		return nil, err
+	}
+	return []rawQuery{q}, nil
+}
+
+// query returns the query string for a message of a batch.
+func (q rawQuery) query(batch service.MessageBatch, i int) string {
+	if q.dynamic != nil {
+		return batch.InterpolatedString(i, q.dynamic)
+	}
+	return q.static
+}
+
+// args returns the arguments of the query for a message of a batch.
+func (q rawQuery) args(batch service.MessageBatch, i int) ([]interface{}, error) {
+	if q.argsMapping == nil {
+		return nil, nil
+	}
+
+	resMsg, err := batch.BloblangQuery(i, q.argsMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	iargs, err := resMsg.AsStructured()
+	if err != nil {
+		return nil, fmt.Errorf("mapping returned non-structured result: %w", err)
+	}
+
+	args, ok := iargs.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("mapping returned non-array result: %T", iargs)
+	}
+	return args, nil
+}
@@ -0,0 +1,85 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+var txIsolationLevels = map[string]sql.IsolationLevel{
+	"default":          sql.LevelDefault,
+	"read_uncommitted": sql.LevelReadUncommitted,
+	"read_committed":   sql.LevelReadCommitted,
+	"repeatable_read":  sql.LevelRepeatableRead,
+	"snapshot":         sql.LevelSnapshot,
+	"serializable":     sql.LevelSerializable,
+}
+
+func txField() *service.ConfigField {
+	return service.NewObjectField("transaction",
+		service.NewBoolField("enabled").
+			Description("Whether to execute all statements of a batch within a single transaction.").
+			Default(false),
+		service.NewStringEnumField("isolation_level", "default", "read_uncommitted", "read_committed", "repeatable_read", "snapshot", "serializable").
+			Description("The isolation level of each transaction, where `default` uses the default level of the database. Not all drivers support all isolation levels, in which case starting a transaction fails.").
+			Default("default"),
+	).
+		Description("Execute the statements of each batch within a single transaction, where the transaction is committed only when all statements succeed. When any statement of a batch fails the transaction is rolled back and all messages of the batch are marked as failed. Transactions are not supported by the `clickhouse` driver.").
+		Advanced().
+		Version("4.0.0")
+}
+
+type txSettings struct {
+	enabled bool
+	opts    *sql.TxOptions
+}
+
+func txSettingsFromParsed(conf *service.ParsedConfig, driver string) (t txSettings, err error) {
+	if t.enabled, err = conf.FieldBool("transaction", "enabled"); err != nil || !t.enabled {
+		return
+	}
+	if driver == "clickhouse" {
+		err = fmt.Errorf("transactions are not supported by the %v driver", driver)
+		return
+	}
+
+	var levelStr string
+	if levelStr, err = conf.FieldString("transaction", "isolation_level"); err != nil {
+		return
+	}
+	level, exists := txIsolationLevels[levelStr]
+	if !exists {
+		err = fmt.Errorf("isolation level %q is not recognised", levelStr)
+		return
+	}
+	t.opts = &sql.TxOptions{Isolation: level}
+	return
+}
+
+// sqlConn is satisfied by both a database and a transaction.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// run calls fn with the database when transactions are disabled, otherwise fn
+// is called with a new transaction that is committed when fn succeeds and is
+// rolled back when it fails.
+func (t txSettings) run(ctx context.Context, db *sql.DB, fn func(conn sqlConn) error) error {
+	if !t.enabled {
+		return fn(db)
+	}
+
+	tx, err := db.BeginTx(ctx, t.opts)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,113 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func graphiteInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Network").
+		Version("4.0.0").
+		Summary("Creates a server that receives metrics over the Graphite plaintext protocol.").
+		Description(`
+Accepts TCP or unix socket connections and consumes each line of the form ` + "`<path> <value> [timestamp]`" + ` as a structured message of the form:
+
+` + "```json" + `
+{
+  "name": "servers.web01.cpu",
+  "value": 0.75,
+  "tags": { "region": "eu" },
+  "timestamp": 1656581400
+}
+` + "```" + `
+
+Tags are parsed from paths in the [Graphite tagged form](https://graphite.readthedocs.io/en/latest/tags.html) ` + "`path;key=value`" + `, and when a line has no timestamp, or a timestamp of ` + "`-1`" + `, the time the metric was received is used instead. Lines that cannot be parsed are dropped.
+
+### Aggregation
+
+When a ` + "`flush_interval`" + ` is specified metrics are aggregated per series (name and tags) and a batch of the aggregated metrics is emitted at each interval, where each series keeps its last value and the latest timestamp of its metrics.`).
+		Field(service.NewStringEnumField("network", "tcp", "unix").
+			Description("The network type to listen on.").
+			Default("tcp")).
+		Field(service.NewStringField("address").
+			Description("The address to listen from.").
+			Example("/tmp/graphite.sock").
+			Default("0.0.0.0:2003")).
+		Field(service.NewStringField("cert_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with the `tcp` and `unix` networks.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringField("key_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with the `tcp` and `unix` networks.").
+			Default("").
+			Advanced()).
+		Field(service.NewDurationField("flush_interval").
+			Description("An optional interval at which metrics are aggregated and flushed, where metrics aggregated when the input is closed are flushed a final time. When left empty each metric is emitted as it is received.").
+			Example("1m").
+			Optional())
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"graphite", graphiteInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			s, err := newMetricServerFromConfig(conf, parseGraphiteLine, mgr.Logger())
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(s), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// parseGraphiteLine parses a line of the form <path>[;<tag>=<value>...]
+// <value> [timestamp].
+func parseGraphiteLine(line string, now time.Time) ([]metricLine, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, errors.New("expected a path, value and optional timestamp")
+	}
+
+	pathParts := strings.Split(fields[0], ";")
+	m := metricLine{
+		name:      pathParts[0],
+		tags:      map[string]string{},
+		timestamp: now,
+	}
+	if m.name == "" {
+		return nil, errors.New("missing metric path")
+	}
+	for _, tag := range pathParts[1:] {
+		i := strings.IndexByte(tag, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid tag: %v", tag)
+		}
+		m.tags[tag[:i]] = tag[i+1:]
+	}
+
+	var err error
+	if m.value, err = strconv.ParseFloat(fields[1], 64); err != nil || math.IsNaN(m.value) || math.IsInf(m.value, 0) {
+		return nil, fmt.Errorf("invalid value: %v", fields[1])
+	}
+
+	if len(fields) == 3 && fields[2] != "-1" {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp: %v", fields[2])
+		}
+		m.timestamp = time.Unix(int64(ts), 0)
+	}
+	return []metricLine{m}, nil
+}
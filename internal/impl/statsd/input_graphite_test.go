@@ -0,0 +1,101 @@
+package statsd
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGraphiteLine(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	for _, test := range []struct {
+		line string
+		exp  metricLine
+		err  string
+	}{
+		{
+			line: "servers.web01.cpu 0.75 1656581400",
+			exp:  metricLine{name: "servers.web01.cpu", value: 0.75, tags: map[string]string{}, timestamp: time.Unix(1656581400, 0)},
+		},
+		{
+			line: "servers.cpu;region=eu;host=web01 12 -1",
+			exp:  metricLine{name: "servers.cpu", value: 12, tags: map[string]string{"region": "eu", "host": "web01"}, timestamp: now},
+		},
+		{
+			line: "servers.cpu 12",
+			exp:  metricLine{name: "servers.cpu", value: 12, tags: map[string]string{}, timestamp: now},
+		},
+		{line: "servers.cpu", err: "expected a path, value and optional timestamp"},
+		{line: "servers.cpu nope 1656581400", err: "invalid value: nope"},
+		{line: "servers.cpu nan 1656581400", err: "invalid value: nan"},
+		{line: "servers.cpu;region 1 1656581400", err: "invalid tag: region"},
+		{line: "servers.cpu 1 yesterday", err: "invalid timestamp: yesterday"},
+	} {
+		metrics, err := parseGraphiteLine(test.line, now)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.line)
+			continue
+		}
+		require.NoError(t, err, test.line)
+		assert.Equal(t, []metricLine{test.exp}, metrics, test.line)
+	}
+}
+
+func TestGraphiteInput(t *testing.T) {
+	m := testMetricServer(t, graphiteInputConfig(), `
+address: 127.0.0.1:0
+`, parseGraphiteLine)
+
+	conn, err := net.Dial("tcp", m.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("servers.cpu;region=eu 0.5 1656581400\nnot a metric\nservers.mem 1024 1656581401\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":      "servers.cpu",
+			"value":     0.5,
+			"tags":      map[string]interface{}{"region": "eu"},
+			"timestamp": int64(1656581400),
+		},
+	}, readMetricBatch(t, m))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":      "servers.mem",
+			"value":     1024.0,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(1656581401),
+		},
+	}, readMetricBatch(t, m))
+}
+
+func TestGraphiteInputUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graphite.sock")
+	m := testMetricServer(t, graphiteInputConfig(), `
+network: unix
+address: `+path+`
+`, parseGraphiteLine)
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("servers.cpu 0.5 1656581400\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":      "servers.cpu",
+			"value":     0.5,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(1656581400),
+		},
+	}, readMetricBatch(t, m))
+}
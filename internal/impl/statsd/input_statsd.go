@@ -0,0 +1,164 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func statsdInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Network").
+		Version("4.0.0").
+		Summary("Creates a server that receives metrics over the StatsD protocol.").
+		Description(`
+Each metric received is converted into a structured message of the form:
+
+` + "```json" + `
+{
+  "name": "api.requests",
+  "type": "counter",
+  "value": 1,
+  "sample_rate": 0.5,
+  "tags": { "region": "eu" },
+  "timestamp": 1656581400
+}
+` + "```" + `
+
+Where ` + "`type`" + ` is one of ` + "`counter`, `gauge`, `timer`, `histogram`, `distribution` or `set`" + `, and the ` + "`value`" + ` of sets is a string. Tags are parsed in the [DogStatsD](https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/) form ` + "`|#key:value,key2`" + ` and the timestamp is taken from a DogStatsD ` + "`|T`" + ` field when present, otherwise it is the time the metric was received. The field ` + "`sample_rate`" + ` is only present when the metric specifies a sample rate, and the field ` + "`delta`" + ` is set to ` + "`true`" + ` for gauges with a signed value (e.g. ` + "`+5` or `-3`" + `), which modify the current value of the gauge rather than replacing it. Lines that cannot be parsed, and DogStatsD events and service checks, are dropped.
+
+When receiving metrics over UDP each packet is consumed as a batch, and when receiving over TCP or a unix socket each line is consumed as a message.
+
+### Aggregation
+
+When a ` + "`flush_interval`" + ` is specified metrics are aggregated per series (name, type and tags) and a batch of the aggregated metrics is emitted at each interval. Counters are summed with their sample rates taken into account, gauges keep their last value with any deltas that follow it added, and sets emit the number of unique values as their ` + "`value`" + `. Timers, histograms and distributions emit the fields ` + "`count`, `sum`, `min`, `max` and `mean`" + ` instead of a ` + "`value`" + `. The ` + "`timestamp`" + ` of an aggregated metric is the latest timestamp of the metrics of its series. Gauges that only receive deltas within an interval emit the sum of the deltas with the field ` + "`delta`" + ` set to ` + "`true`" + `.`).
+		Field(service.NewStringEnumField("network", "udp", "tcp", "unix").
+			Description("The network type to listen on.").
+			Default("udp")).
+		Field(service.NewStringField("address").
+			Description("The address to listen from.").
+			Example("/tmp/statsd.sock").
+			Default("0.0.0.0:8125")).
+		Field(service.NewStringField("cert_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with the `tcp` and `unix` networks.").
+			Default("").
+			Advanced()).
+		Field(service.NewStringField("key_file").
+			Description("Enable TLS by specifying a certificate and key file. Only valid with the `tcp` and `unix` networks.").
+			Default("").
+			Advanced()).
+		Field(service.NewDurationField("flush_interval").
+			Description("An optional interval at which metrics are aggregated and flushed, where metrics aggregated when the input is closed are flushed a final time. When left empty each metric is emitted as it is received.").
+			Example("10s").
+			Optional())
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"statsd", statsdInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			s, err := newMetricServerFromConfig(conf, parseStatsDLine, mgr.Logger())
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(s), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+var statsdTypes = map[string]string{
+	"c":  "counter",
+	"g":  "gauge",
+	"ms": "timer",
+	"h":  "histogram",
+	"d":  "distribution",
+	"s":  "set",
+}
+
+// parseStatsDLine parses a line of the form
+// <name>:<value>[:<value>...]|<type>[|@<rate>][|#<tags>][|T<timestamp>], where
+// multiple values result in multiple metrics.
+func parseStatsDLine(line string, now time.Time) ([]metricLine, error) {
+	if strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		return nil, errors.New("events and service checks are not supported")
+	}
+
+	nameEnd := strings.IndexByte(line, ':')
+	if nameEnd <= 0 {
+		return nil, errors.New("missing metric name")
+	}
+	name := line[:nameEnd]
+
+	sections := strings.Split(line[nameEnd+1:], "|")
+	if len(sections) < 2 {
+		return nil, errors.New("missing metric type")
+	}
+
+	typ, exists := statsdTypes[sections[1]]
+	if !exists {
+		return nil, fmt.Errorf("unrecognised metric type: %v", sections[1])
+	}
+
+	m := metricLine{
+		name:      name,
+		typ:       typ,
+		tags:      map[string]string{},
+		timestamp: now,
+	}
+	for _, section := range sections[2:] {
+		if section == "" {
+			continue
+		}
+		switch section[0] {
+		case '@':
+			rate, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid sample rate: %v", section[1:])
+			}
+			m.sampleRate = rate
+		case '#':
+			for _, tag := range strings.Split(section[1:], ",") {
+				if tag == "" {
+					continue
+				}
+				k, v := tag, ""
+				if i := strings.IndexByte(tag, ':'); i >= 0 {
+					k, v = tag[:i], tag[i+1:]
+				}
+				m.tags[k] = v
+			}
+		case 'T':
+			ts, err := strconv.ParseInt(section[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp: %v", section[1:])
+			}
+			m.timestamp = time.Unix(ts, 0)
+		}
+	}
+
+	var metrics []metricLine
+	for _, v := range strings.Split(sections[0], ":") {
+		if typ == "set" {
+			m.setValue = v
+		} else {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, fmt.Errorf("invalid value: %v", v)
+			}
+			m.value = f
+			m.delta = typ == "gauge" && (v[0] == '+' || v[0] == '-')
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
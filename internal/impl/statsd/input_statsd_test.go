@@ -0,0 +1,295 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestParseStatsDLine(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	for _, test := range []struct {
+		line string
+		exp  []metricLine
+		err  string
+	}{
+		{
+			line: "api.requests:1|c",
+			exp: []metricLine{
+				{name: "api.requests", typ: "counter", value: 1, tags: map[string]string{}, timestamp: now},
+			},
+		},
+		{
+			line: "api.latency:12.5|ms|@0.1|#region:eu,canary|T1656581400",
+			exp: []metricLine{
+				{
+					name: "api.latency", typ: "timer", value: 12.5, sampleRate: 0.1,
+					tags:      map[string]string{"region": "eu", "canary": ""},
+					timestamp: time.Unix(1656581400, 0),
+				},
+			},
+		},
+		{
+			line: "queue.size:3|g",
+			exp: []metricLine{
+				{name: "queue.size", typ: "gauge", value: 3, tags: map[string]string{}, timestamp: now},
+			},
+		},
+		{
+			line: "queue.size:-3|g",
+			exp: []metricLine{
+				{name: "queue.size", typ: "gauge", value: -3, tags: map[string]string{}, timestamp: now, delta: true},
+			},
+		},
+		{
+			line: "queue.size:+2|g",
+			exp: []metricLine{
+				{name: "queue.size", typ: "gauge", value: 2, tags: map[string]string{}, timestamp: now, delta: true},
+			},
+		},
+		{
+			line: "users:alice|s",
+			exp: []metricLine{
+				{name: "users", typ: "set", setValue: "alice", tags: map[string]string{}, timestamp: now},
+			},
+		},
+		{
+			line: "sizes:1:2|d|#a:b",
+			exp: []metricLine{
+				{name: "sizes", typ: "distribution", value: 1, tags: map[string]string{"a": "b"}, timestamp: now},
+				{name: "sizes", typ: "distribution", value: 2, tags: map[string]string{"a": "b"}, timestamp: now},
+			},
+		},
+		{line: "api.requests", err: "missing metric name"},
+		{line: "api.requests:1", err: "missing metric type"},
+		{line: "api.requests:1|x", err: "unrecognised metric type: x"},
+		{line: "api.requests:foo|c", err: "invalid value: foo"},
+		{line: "api.requests:1|c|@2", err: "invalid sample rate: 2"},
+		{line: "_e{5,4}:title|text", err: "events and service checks are not supported"},
+	} {
+		metrics, err := parseStatsDLine(test.line, now)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.line)
+			continue
+		}
+		require.NoError(t, err, test.line)
+		assert.Equal(t, test.exp, metrics, test.line)
+	}
+}
+
+func testMetricServer(t *testing.T, spec *service.ConfigSpec, conf string, parse lineParser) *metricServer {
+	t.Helper()
+
+	pConf, err := spec.ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	m, err := newMetricServerFromConfig(pConf, parse, service.MockResources().Logger())
+	require.NoError(t, err)
+	require.NoError(t, m.Connect(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, m.Close(context.Background()))
+	})
+	return m
+}
+
+func readMetricBatch(t *testing.T, m *metricServer) []interface{} {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	batch, ackFn, err := m.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.NoError(t, ackFn(ctx, nil))
+
+	var objs []interface{}
+	for _, msg := range batch {
+		v, err := msg.AsStructured()
+		require.NoError(t, err)
+		objs = append(objs, v)
+	}
+	return objs
+}
+
+func TestStatsDInputUDP(t *testing.T) {
+	m := testMetricServer(t, statsdInputConfig(), `
+address: 127.0.0.1:0
+`, parseStatsDLine)
+
+	conn, err := net.Dial("udp", m.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("api.requests:1|c|#region:eu|T1656581400\nnot a metric\nusers:bob|s|@0.5|T1656581400\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":      "api.requests",
+			"type":      "counter",
+			"value":     1.0,
+			"tags":      map[string]interface{}{"region": "eu"},
+			"timestamp": int64(1656581400),
+		},
+		map[string]interface{}{
+			"name":        "users",
+			"type":        "set",
+			"value":       "bob",
+			"sample_rate": 0.5,
+			"tags":        map[string]interface{}{},
+			"timestamp":   int64(1656581400),
+		},
+	}, readMetricBatch(t, m))
+}
+
+func TestStatsDAggregation(t *testing.T) {
+	agg := newAggregator()
+	for _, line := range []string{
+		"api.requests:1|c|T100",
+		"api.requests:2|c|@0.5|T102",
+		"api.requests:1|c|#region:eu|T100",
+		"queue.size:+1|g|T100",
+		"queue.size:5|g|T100",
+		"queue.size:3|g|T101",
+		"queue.size:+2|g|T101",
+		"queue.depth:+2|g|T100",
+		"queue.depth:-5|g|T101",
+		"api.latency:10|ms|T100",
+		"api.latency:30|ms|T100",
+		"users:alice|s|T100",
+		"users:bob|s|T100",
+		"users:alice|s|T100",
+	} {
+		metrics, err := parseStatsDLine(line, time.Now())
+		require.NoError(t, err)
+		for _, m := range metrics {
+			agg.add(m)
+		}
+	}
+
+	var objs []interface{}
+	for _, msg := range agg.flush() {
+		v, err := msg.AsStructured()
+		require.NoError(t, err)
+		objs = append(objs, v)
+	}
+	assert.Empty(t, agg.flush())
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":      "api.requests",
+			"type":      "counter",
+			"value":     5.0,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(102),
+		},
+		map[string]interface{}{
+			"name":      "api.requests",
+			"type":      "counter",
+			"value":     1.0,
+			"tags":      map[string]interface{}{"region": "eu"},
+			"timestamp": int64(100),
+		},
+		map[string]interface{}{
+			"name":      "queue.depth",
+			"type":      "gauge",
+			"value":     -3.0,
+			"delta":     true,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(101),
+		},
+		map[string]interface{}{
+			"name":      "queue.size",
+			"type":      "gauge",
+			"value":     5.0,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(101),
+		},
+		map[string]interface{}{
+			"name":      "users",
+			"type":      "set",
+			"value":     2,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(100),
+		},
+		map[string]interface{}{
+			"name":      "api.latency",
+			"type":      "timer",
+			"count":     2.0,
+			"sum":       40.0,
+			"min":       10.0,
+			"max":       30.0,
+			"mean":      20.0,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(100),
+		},
+	}, objs)
+}
+
+func TestStatsDInputFlushInterval(t *testing.T) {
+	m := testMetricServer(t, statsdInputConfig(), `
+network: tcp
+address: 127.0.0.1:0
+flush_interval: 50ms
+`, parseStatsDLine)
+
+	conn, err := net.Dial("tcp", m.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("queue.size:5|g|T100\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":      "queue.size",
+			"type":      "gauge",
+			"value":     5.0,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(100),
+		},
+	}, readMetricBatch(t, m))
+}
+
+func TestStatsDInputFlushOnClose(t *testing.T) {
+	m := testMetricServer(t, statsdInputConfig(), `
+network: tcp
+address: 127.0.0.1:0
+flush_interval: 1h
+`, parseStatsDLine)
+
+	conn, err := net.Dial("tcp", m.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("api.requests:2|c|T100\n"))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		m.agg.mut.Lock()
+		defer m.agg.mut.Unlock()
+		return len(m.agg.series) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	require.NoError(t, m.Close(context.Background()))
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":      "api.requests",
+			"type":      "counter",
+			"value":     2.0,
+			"tags":      map[string]interface{}{},
+			"timestamp": int64(100),
+		},
+	}, readMetricBatch(t, m))
+
+	_, _, err = m.ReadBatch(context.Background())
+	assert.Equal(t, service.ErrEndOfInput, err)
+}
@@ -0,0 +1,485 @@
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/internal/socket"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// metricLine is a single metric parsed from a line of either the StatsD or
+// the Graphite plaintext protocol.
+type metricLine struct {
+	name       string
+	typ        string
+	value      float64
+	setValue   string
+	sampleRate float64
+	tags       map[string]string
+	timestamp  time.Time
+
+	// delta is set for gauges with a signed value, which modify the current
+	// value of the gauge rather than replacing it.
+	delta bool
+}
+
+// lineParser parses a line into metrics, where the time given is used when the
+// line does not specify a timestamp.
+type lineParser func(line string, now time.Time) ([]metricLine, error)
+
+func (m metricLine) structured() map[string]interface{} {
+	obj := map[string]interface{}{
+		"name":      m.name,
+		"tags":      tagsToStructured(m.tags),
+		"timestamp": m.timestamp.Unix(),
+	}
+	if m.typ != "" {
+		obj["type"] = m.typ
+	}
+	if m.typ == "set" {
+		obj["value"] = m.setValue
+	} else {
+		obj["value"] = m.value
+	}
+	if m.sampleRate > 0 {
+		obj["sample_rate"] = m.sampleRate
+	}
+	if m.delta {
+		obj["delta"] = true
+	}
+	return obj
+}
+
+func tagsToStructured(tags map[string]string) map[string]interface{} {
+	obj := make(map[string]interface{}, len(tags))
+	for k, v := range tags {
+		obj[k] = v
+	}
+	return obj
+}
+
+//------------------------------------------------------------------------------
+
+type aggSeries struct {
+	name      string
+	typ       string
+	tags      map[string]string
+	timestamp time.Time
+
+	// Counters, gauges and metrics without a type, where delta is set for
+	// gauges that have only received deltas.
+	value float64
+	delta bool
+
+	// Timers, histograms and distributions.
+	count, sum, min, max float64
+
+	// Sets.
+	set map[string]struct{}
+}
+
+func (s *aggSeries) structured() map[string]interface{} {
+	obj := map[string]interface{}{
+		"name":      s.name,
+		"tags":      tagsToStructured(s.tags),
+		"timestamp": s.timestamp.Unix(),
+	}
+	if s.typ != "" {
+		obj["type"] = s.typ
+	}
+	switch s.typ {
+	case "timer", "histogram", "distribution":
+		obj["count"] = s.count
+		obj["sum"] = s.sum
+		obj["min"] = s.min
+		obj["max"] = s.max
+		obj["mean"] = s.sum / s.count
+	case "set":
+		obj["value"] = len(s.set)
+	default:
+		obj["value"] = s.value
+	}
+	if s.delta {
+		obj["delta"] = true
+	}
+	return obj
+}
+
+// aggregator combines metrics of the same series received within a flush
+// interval into a single metric.
+type aggregator struct {
+	mut    sync.Mutex
+	series map[string]*aggSeries
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{series: map[string]*aggSeries{}}
+}
+
+func seriesKey(m metricLine) string {
+	var b strings.Builder
+	b.WriteString(m.typ)
+	b.WriteByte(0)
+	b.WriteString(m.name)
+
+	keys := make([]string, 0, len(m.tags))
+	for k := range m.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(m.tags[k])
+	}
+	return b.String()
+}
+
+func (a *aggregator) add(m metricLine) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	key := seriesKey(m)
+	s, exists := a.series[key]
+	if !exists {
+		s = &aggSeries{
+			name:  m.name,
+			typ:   m.typ,
+			tags:  m.tags,
+			delta: m.delta,
+		}
+		a.series[key] = s
+	}
+	if m.timestamp.After(s.timestamp) {
+		s.timestamp = m.timestamp
+	}
+
+	rate := m.sampleRate
+	if rate <= 0 {
+		rate = 1
+	}
+
+	switch m.typ {
+	case "counter":
+		s.value += m.value / rate
+	case "timer", "histogram", "distribution":
+		if s.count == 0 || m.value < s.min {
+			s.min = m.value
+		}
+		if s.count == 0 || m.value > s.max {
+			s.max = m.value
+		}
+		s.count += 1 / rate
+		s.sum += m.value / rate
+	case "set":
+		if s.set == nil {
+			s.set = map[string]struct{}{}
+		}
+		s.set[m.setValue] = struct{}{}
+	case "gauge":
+		if m.delta {
+			s.value += m.value
+		} else {
+			s.value = m.value
+			s.delta = false
+		}
+	default:
+		s.value = m.value
+	}
+}
+
+// flush returns a message for each series aggregated since the last flush,
+// sorted by series for determinism.
+func (a *aggregator) flush() service.MessageBatch {
+	a.mut.Lock()
+	series := a.series
+	a.series = map[string]*aggSeries{}
+	a.mut.Unlock()
+
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	batch := make(service.MessageBatch, 0, len(keys))
+	for _, k := range keys {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(series[k].structured())
+		batch = append(batch, msg)
+	}
+	return batch
+}
+
+//------------------------------------------------------------------------------
+
+// metricServer listens for lines of metrics over a socket, using the same
+// listener as the socket_server input, and converts them into batches of
+// structured messages.
+type metricServer struct {
+	network       string
+	address       string
+	certFile      string
+	keyFile       string
+	parse         lineParser
+	flushInterval time.Duration
+	log           *service.Logger
+
+	agg     *aggregator
+	server  *socket.Server
+	batches chan service.MessageBatch
+
+	finalMut sync.Mutex
+	final    service.MessageBatch
+
+	ctx     context.Context
+	closeFn func()
+	wg      sync.WaitGroup
+}
+
+func newMetricServer(network, address string, flushInterval time.Duration, parse lineParser, log *service.Logger) *metricServer {
+	m := &metricServer{
+		network:       network,
+		address:       address,
+		parse:         parse,
+		flushInterval: flushInterval,
+		log:           log,
+		batches:       make(chan service.MessageBatch),
+	}
+	if flushInterval > 0 {
+		m.agg = newAggregator()
+	}
+	m.ctx, m.closeFn = context.WithCancel(context.Background())
+	return m
+}
+
+// newMetricServerFromConfig creates a metric server from the common fields of
+// the statsd and graphite inputs.
+func newMetricServerFromConfig(conf *service.ParsedConfig, parse lineParser, log *service.Logger) (*metricServer, error) {
+	network, err := conf.FieldString("network")
+	if err != nil {
+		return nil, err
+	}
+	address, err := conf.FieldString("address")
+	if err != nil {
+		return nil, err
+	}
+	var flushInterval time.Duration
+	if conf.Contains("flush_interval") {
+		if flushInterval, err = conf.FieldDuration("flush_interval"); err != nil {
+			return nil, err
+		}
+		if flushInterval <= 0 {
+			return nil, errors.New("flush_interval must be greater than zero")
+		}
+	}
+	m := newMetricServer(network, address, flushInterval, parse, log)
+	if m.certFile, err = conf.FieldString("cert_file"); err != nil {
+		return nil, err
+	}
+	if m.keyFile, err = conf.FieldString("key_file"); err != nil {
+		return nil, err
+	}
+	if (m.certFile == "") != (m.keyFile == "") {
+		return nil, errors.New("both a cert_file and key_file must be specified in order to enable TLS")
+	}
+	return m, nil
+}
+
+// Addr returns the address of the underlying listener.
+func (m *metricServer) Addr() net.Addr {
+	return m.server.Addr()
+}
+
+func (m *metricServer) Connect(ctx context.Context) error {
+	if m.server != nil {
+		return nil
+	}
+
+	var tlsConf *tls.Config
+	if m.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+		if err != nil {
+			return err
+		}
+		tlsConf = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	server, err := socket.Listen(m.network, m.address, tlsConf)
+	if err != nil {
+		return err
+	}
+	m.server = server
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.server.Serve(m.ctx, m.log, m.handleConn)
+	}()
+	if m.agg != nil {
+		m.wg.Add(1)
+		go m.flushLoop()
+	}
+	m.log.Infof("Receiving %v metrics from address: %v", m.network, m.Addr())
+	return nil
+}
+
+func (m *metricServer) send(batch service.MessageBatch) bool {
+	if len(batch) == 0 {
+		return true
+	}
+	select {
+	case m.batches <- batch:
+		return true
+	case <-m.ctx.Done():
+		return false
+	}
+}
+
+func (m *metricServer) handleLines(lines []string, now time.Time) bool {
+	var batch service.MessageBatch
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		metrics, err := m.parse(line, now)
+		if err != nil {
+			m.log.Debugf("Failed to parse metric line '%v': %v", line, err)
+			continue
+		}
+		for _, metric := range metrics {
+			if m.agg != nil {
+				m.agg.add(metric)
+				continue
+			}
+			msg := service.NewMessage(nil)
+			msg.SetStructured(metric.structured())
+			batch = append(batch, msg)
+		}
+	}
+	return m.send(batch)
+}
+
+// handleConn consumes the metrics of a connection, where each packet received
+// over udp is consumed as a batch and each line received over a stream is
+// consumed as a message.
+func (m *metricServer) handleConn(ctx context.Context, conn io.ReadCloser) {
+	defer conn.Close()
+
+	if m.server.Network() == "udp" {
+		buf := make([]byte, 65535)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				if ctx.Err() == nil {
+					m.log.Errorf("Connection dropped due to: %v", err)
+				}
+				return
+			}
+			if !m.handleLines(strings.Split(string(buf[:n]), "\n"), time.Now()) {
+				return
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if !m.handleLines([]string{scanner.Text()}, time.Now()) {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		m.log.Errorf("Connection dropped due to: %v", err)
+	}
+}
+
+func (m *metricServer) flushLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if batch := m.agg.flush(); !m.send(batch) {
+				m.addFinal(batch)
+				return
+			}
+		case <-m.ctx.Done():
+			// Flush once more so that metrics aggregated within the last
+			// interval are not lost.
+			m.addFinal(m.agg.flush())
+			return
+		}
+	}
+}
+
+// addFinal stores metrics flushed once the server is closed, which are read
+// before the end of the input is signalled.
+func (m *metricServer) addFinal(batch service.MessageBatch) {
+	m.finalMut.Lock()
+	m.final = append(m.final, batch...)
+	m.finalMut.Unlock()
+}
+
+// takeFinal returns the metrics flushed once the server is closed, along with
+// any that have been aggregated since.
+func (m *metricServer) takeFinal() service.MessageBatch {
+	if m.agg == nil {
+		return nil
+	}
+	m.finalMut.Lock()
+	defer m.finalMut.Unlock()
+
+	batch := append(m.final, m.agg.flush()...)
+	m.final = nil
+	return batch
+}
+
+func (m *metricServer) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	ackFn := func(context.Context, error) error {
+		return nil
+	}
+	select {
+	case batch := <-m.batches:
+		return batch, ackFn, nil
+	case <-m.ctx.Done():
+		if batch := m.takeFinal(); len(batch) > 0 {
+			return batch, ackFn, nil
+		}
+		return nil, nil, service.ErrEndOfInput
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (m *metricServer) Close(ctx context.Context) error {
+	m.closeFn()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -6,20 +6,26 @@ import (
 
 // AWSSQSConfig contains configuration values for the input type.
 type AWSSQSConfig struct {
-	sess.Config         `json:",inline" yaml:",inline"`
-	URL                 string `json:"url" yaml:"url"`
-	DeleteMessage       bool   `json:"delete_message" yaml:"delete_message"`
-	ResetVisibility     bool   `json:"reset_visibility" yaml:"reset_visibility"`
-	MaxNumberOfMessages int    `json:"max_number_of_messages" yaml:"max_number_of_messages"`
+	sess.Config            `json:",inline" yaml:",inline"`
+	URL                    string `json:"url" yaml:"url"`
+	DeleteMessage          bool   `json:"delete_message" yaml:"delete_message"`
+	ResetVisibility        bool   `json:"reset_visibility" yaml:"reset_visibility"`
+	MaxNumberOfMessages    int    `json:"max_number_of_messages" yaml:"max_number_of_messages"`
+	ExtendVisibility       bool   `json:"extend_visibility" yaml:"extend_visibility"`
+	VisibilityTimeout      string `json:"visibility_timeout" yaml:"visibility_timeout"`
+	MaxVisibilityExtension string `json:"max_visibility_extension" yaml:"max_visibility_extension"`
 }
 
 // NewAWSSQSConfig creates a new Config with default values.
 func NewAWSSQSConfig() AWSSQSConfig {
 	return AWSSQSConfig{
-		Config:              sess.NewConfig(),
-		URL:                 "",
-		DeleteMessage:       true,
-		ResetVisibility:     true,
-		MaxNumberOfMessages: 10,
+		Config:                 sess.NewConfig(),
+		URL:                    "",
+		DeleteMessage:          true,
+		ResetVisibility:        true,
+		MaxNumberOfMessages:    10,
+		ExtendVisibility:       false,
+		VisibilityTimeout:      "30s",
+		MaxVisibilityExtension: "12h",
 	}
 }
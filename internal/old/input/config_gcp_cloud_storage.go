@@ -1,18 +1,38 @@
 package input
 
+// GCPCloudStoragePubSubConfig contains configuration for hooking up the Google
+// Cloud Storage input with a Pub/Sub subscription.
+type GCPCloudStoragePubSubConfig struct {
+	Project                string `json:"project" yaml:"project"`
+	Subscription           string `json:"subscription" yaml:"subscription"`
+	MaxOutstandingMessages int    `json:"max_outstanding_messages" yaml:"max_outstanding_messages"`
+}
+
+// NewGCPCloudStoragePubSubConfig creates a new GCPCloudStoragePubSubConfig
+// with default values.
+func NewGCPCloudStoragePubSubConfig() GCPCloudStoragePubSubConfig {
+	return GCPCloudStoragePubSubConfig{
+		Project:                "",
+		Subscription:           "",
+		MaxOutstandingMessages: 10,
+	}
+}
+
 // GCPCloudStorageConfig contains configuration fields for the Google Cloud
 // Storage input type.
 type GCPCloudStorageConfig struct {
-	Bucket        string `json:"bucket" yaml:"bucket"`
-	Prefix        string `json:"prefix" yaml:"prefix"`
-	Codec         string `json:"codec" yaml:"codec"`
-	DeleteObjects bool   `json:"delete_objects" yaml:"delete_objects"`
+	Bucket        string                      `json:"bucket" yaml:"bucket"`
+	Prefix        string                      `json:"prefix" yaml:"prefix"`
+	Codec         string                      `json:"codec" yaml:"codec"`
+	DeleteObjects bool                        `json:"delete_objects" yaml:"delete_objects"`
+	PubSub        GCPCloudStoragePubSubConfig `json:"pubsub" yaml:"pubsub"`
 }
 
 // NewGCPCloudStorageConfig creates a new GCPCloudStorageConfig with default
 // values.
 func NewGCPCloudStorageConfig() GCPCloudStorageConfig {
 	return GCPCloudStorageConfig{
-		Codec: "all-bytes",
+		Codec:  "all-bytes",
+		PubSub: NewGCPCloudStoragePubSubConfig(),
 	}
 }
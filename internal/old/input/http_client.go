@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	nethttp "net/http"
 	"strings"
 	"sync"
 	"time"
@@ -24,7 +25,7 @@ import (
 func httpClientSpec() docs.FieldSpec {
 	codecDocs := codec.ReaderDocs.AtVersion("3.42.0")
 	codecDocs.Description = "The way in which the bytes of a continuous stream are converted into messages. It's possible to consume lines using a custom delimiter with the `delim:x` codec, where x is the character sequence custom delimiter. It's not necessary to add gzip in the codec when the response headers specify it as it will be decompressed automatically."
-	codecDocs.Examples = []interface{}{"lines", "delim:\t", "delim:foobar", "csv"}
+	codecDocs.Examples = []interface{}{"lines", "delim:\t", "delim:foobar", "csv", "sse"}
 
 	streamSpecs := docs.FieldSpecs{
 		docs.FieldBool("enabled", "Enables streaming mode."),
@@ -55,6 +56,8 @@ interpolations described [here](/docs/configuration/interpolation#bloblang-queri
 
 If you enable streaming then Benthos will consume the body of the response as a continuous stream of data, breaking messages out following a chosen codec. This allows you to consume APIs that provide long lived streamed data feeds (such as Twitter).
 
+The ` + "`sse`" + ` codec consumes a stream of [server-sent events](https://html.spec.whatwg.org/multipage/server-sent-events.html), where the data of each event becomes a message with the metadata fields ` + "`sse_event` and `sse_id`" + `. When the connection is re-established the ID of the last event consumed is sent as the header ` + "`Last-Event-ID`" + ` in order to resume the stream.
+
 ### Pagination
 
 This input supports interpolation functions in the ` + "`url` and `headers`" + ` fields where data from the previous successfully consumed message (if there was one) can be referenced. This can be used in order to support basic levels of pagination. However, in cases where pagination depends on logic it is recommended that you use an ` + "[`http` processor](/docs/components/processors/http) instead, often combined with a [`generate` input](/docs/components/inputs/generate)" + ` in order to schedule the processor.`,
@@ -141,9 +144,11 @@ type HTTPClient struct {
 	prevResponse *message.Batch
 
 	codecCtor codec.ReaderConstructor
+	sse       bool
 
-	codecMut sync.Mutex
-	codec    codec.Reader
+	codecMut    sync.Mutex
+	codec       codec.Reader
+	lastEventID string
 }
 
 // NewHTTPClient creates a new HTTPClient input type.
@@ -157,6 +162,7 @@ func NewHTTPClient(conf Config, mgr interop.Manager, log log.Modular, stats metr
 
 func newHTTPClient(conf HTTPClientConfig, mgr interop.Manager, log log.Modular, stats metrics.Type) (*HTTPClient, error) {
 	var codecCtor codec.ReaderConstructor
+	var sse bool
 
 	if conf.Stream.Enabled {
 		// Timeout should be left at zero if we are streaming.
@@ -168,6 +174,11 @@ func newHTTPClient(conf HTTPClientConfig, mgr interop.Manager, log log.Modular,
 		if codecCtor, err = codec.GetReader(conf.Stream.Codec, codecConf); err != nil {
 			return nil, err
 		}
+
+		// Codecs can be chained with decompression codecs (e.g. gzip/sse),
+		// where the last codec determines how messages are read.
+		codecs := strings.Split(conf.Stream.Codec, "/")
+		sse = codecs[len(codecs)-1] == "sse"
 	}
 
 	payload := message.QuickBatch(nil)
@@ -175,24 +186,43 @@ func newHTTPClient(conf HTTPClientConfig, mgr interop.Manager, log log.Modular,
 		payload = message.QuickBatch([][]byte{[]byte(conf.Payload)})
 	}
 
-	client, err := http.NewClient(
-		conf.Config,
+	h := &HTTPClient{
+		conf:         conf,
+		payload:      payload,
+		prevResponse: message.QuickBatch(nil),
+
+		codecCtor: codecCtor,
+		sse:       sse,
+	}
+
+	opts := []func(*http.Client){
 		http.OptSetManager(mgr),
 		http.OptSetLogger(log),
 		http.OptSetStats(stats),
-	)
-	if err != nil {
-		return nil, err
+	}
+	if conf.Stream.Enabled {
+		opts = append(opts, http.OptSetRequestHook(h.setStreamHeaders))
 	}
 
-	return &HTTPClient{
-		conf:         conf,
-		payload:      payload,
-		prevResponse: message.QuickBatch(nil),
-		client:       client,
+	var err error
+	if h.client, err = http.NewClient(conf.Config, opts...); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
 
-		codecCtor: codecCtor,
-	}, nil
+// setStreamHeaders adds the headers of server-sent event streams to requests,
+// which are only sent by ConnectWithContext and therefore codecMut is held.
+func (h *HTTPClient) setStreamHeaders(req *nethttp.Request) {
+	if !h.sse {
+		return
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if h.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", h.lastEventID)
+	}
 }
 
 //------------------------------------------------------------------------------
@@ -271,6 +301,14 @@ func (h *HTTPClient) readStreamed(ctx context.Context) (*message.Batch, reader.A
 		return nil, nil, err
 	}
 
+	if h.sse {
+		for _, p := range parts {
+			if id := p.MetaGet("sse_id"); id != "" {
+				h.lastEventID = id
+			}
+		}
+	}
+
 	msg := message.QuickBatch(nil)
 	msg.Append(parts...)
 
@@ -887,3 +887,80 @@ func BenchmarkHTTPClientGETMultipart(b *testing.B) {
 		b.Error(err)
 	}
 }
+
+func TestHTTPClientStreamGETSSE(t *testing.T) {
+	tCtx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	var reqMut sync.Mutex
+	var lastEventIDs []string
+	tserve := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+
+		reqMut.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		reqMut.Unlock()
+
+		w.Header().Add("Content-Type", "text/event-stream")
+		if r.Header.Get("Last-Event-ID") == "" {
+			_, _ = w.Write([]byte("event: greeting\nid: 1\ndata: hello\n\n: keep alive\n\nid: 2\ndata: foo\ndata: bar\n\n"))
+			return
+		}
+		_, _ = w.Write([]byte("id: 3\ndata: resumed\n\n"))
+	}))
+	defer tserve.Close()
+
+	conf := NewConfig()
+	conf.HTTPClient.URL = tserve.URL + "/events"
+	conf.HTTPClient.Retry = "1ms"
+	conf.HTTPClient.Stream.Enabled = true
+	conf.HTTPClient.Stream.Codec = "sse"
+
+	h, err := NewHTTPClient(conf, mock.NewManager(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	for _, exp := range []struct {
+		data, event, id string
+	}{
+		{data: "hello", event: "greeting", id: "1"},
+		{data: "foo\nbar", event: "message", id: "2"},
+		{data: "resumed", event: "message", id: "3"},
+	} {
+		var ts message.Transaction
+		select {
+		case ts = <-h.TransactionChan():
+		case <-time.After(time.Second):
+			t.Fatal("Action timed out")
+		}
+		require.Equal(t, 1, ts.Payload.Len())
+		assert.Equal(t, exp.data, string(ts.Payload.Get(0).Get()))
+		assert.Equal(t, exp.event, ts.Payload.Get(0).MetaGet("sse_event"))
+		assert.Equal(t, exp.id, ts.Payload.Get(0).MetaGet("sse_id"))
+		require.NoError(t, ts.Ack(tCtx, nil))
+	}
+
+	h.CloseAsync()
+	require.NoError(t, h.WaitForClose(time.Second))
+
+	reqMut.Lock()
+	assert.Equal(t, []string{"", "2"}, lastEventIDs[:2])
+	reqMut.Unlock()
+}
+
+func TestHTTPClientStreamSSECodecDetection(t *testing.T) {
+	for codec, exp := range map[string]bool{
+		"sse":       true,
+		"gzip/sse":  true,
+		"delim:sse": false,
+		"lines":     false,
+	} {
+		conf := NewConfig()
+		conf.HTTPClient.URL = "http://localhost:4195/events"
+		conf.HTTPClient.Stream.Enabled = true
+		conf.HTTPClient.Stream.Codec = codec
+
+		h, err := newHTTPClient(conf.HTTPClient, mock.NewManager(), log.Noop(), metrics.Noop())
+		require.NoError(t, err, codec)
+		assert.Equal(t, exp, h.sse, codec)
+	}
+}
@@ -2,10 +2,8 @@ package input
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net"
-	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +15,7 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/interop"
 	"github.com/benthosdev/benthos/v4/internal/log"
 	"github.com/benthosdev/benthos/v4/internal/message"
+	"github.com/benthosdev/benthos/v4/internal/socket"
 )
 
 //------------------------------------------------------------------------------
@@ -63,15 +62,6 @@ func NewSocketServerConfig() SocketServerConfig {
 
 //------------------------------------------------------------------------------
 
-type wrapPacketConn struct {
-	net.PacketConn
-}
-
-func (w *wrapPacketConn) Read(p []byte) (n int, err error) {
-	n, _, err = w.ReadFrom(p)
-	return
-}
-
 // SocketServer is an input type that binds to an address and consumes streams of
 // messages over Socket.
 type SocketServer struct {
@@ -80,8 +70,7 @@ type SocketServer struct {
 	log   log.Modular
 
 	codecCtor codec.ReaderConstructor
-	listener  net.Listener
-	conn      net.PacketConn
+	server    *socket.Server
 
 	retriesMut   sync.RWMutex
 	transactions chan message.Transaction
@@ -96,10 +85,6 @@ type SocketServer struct {
 
 // NewSocketServer creates a new SocketServer input type.
 func NewSocketServer(conf Config, mgr interop.Manager, log log.Modular, stats metrics.Type) (input.Streamed, error) {
-	var ln net.Listener
-	var cn net.PacketConn
-	var err error
-
 	sconf := conf.SocketServer
 
 	codecConf := codec.NewReaderConfig()
@@ -109,14 +94,7 @@ func NewSocketServer(conf Config, mgr interop.Manager, log log.Modular, stats me
 		return nil, err
 	}
 
-	switch sconf.Network {
-	case "tcp", "unix":
-		ln, err = net.Listen(sconf.Network, sconf.Address)
-	case "udp":
-		cn, err = net.ListenPacket(sconf.Network, sconf.Address)
-	default:
-		return nil, fmt.Errorf("socket network '%v' is not supported by this input", sconf.Network)
-	}
+	server, err := socket.Listen(sconf.Network, sconf.Address, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +105,7 @@ func NewSocketServer(conf Config, mgr interop.Manager, log log.Modular, stats me
 		log:   log,
 
 		codecCtor: ctor,
-		listener:  ln,
-		conn:      cn,
+		server:    server,
 
 		transactions: make(chan message.Transaction),
 		closedChan:   make(chan struct{}),
@@ -138,11 +115,7 @@ func NewSocketServer(conf Config, mgr interop.Manager, log log.Modular, stats me
 	}
 	t.ctx, t.closeFn = context.WithCancel(context.Background())
 
-	if ln == nil {
-		go t.udpLoop()
-	} else {
-		go t.loop()
-	}
+	go t.loop()
 	return &t, nil
 }
 
@@ -150,10 +123,7 @@ func NewSocketServer(conf Config, mgr interop.Manager, log log.Modular, stats me
 
 // Addr returns the underlying Socket listeners address.
 func (t *SocketServer) Addr() net.Addr {
-	if t.listener != nil {
-		return t.listener.Addr()
-	}
-	return t.conn.LocalAddr()
+	return t.server.Addr()
 }
 
 func (t *SocketServer) sendMsg(msg *message.Batch) bool {
@@ -222,132 +192,49 @@ func (t *SocketServer) sendMsg(msg *message.Batch) bool {
 }
 
 func (t *SocketServer) loop() {
-	var wg sync.WaitGroup
-
 	defer func() {
-		wg.Wait()
-
 		t.retriesMut.Lock()
 		// nolint:staticcheck, gocritic // Ignore SA2001 empty critical section, Ignore badLock
 		t.retriesMut.Unlock()
 
-		t.listener.Close()
-
 		close(t.transactions)
 		close(t.closedChan)
 	}()
 
-	t.log.Infof("Receiving %v socket messages from address: %v\n", t.conf.Network, t.listener.Addr())
+	t.log.Infof("Receiving %v socket messages from address: %v\n", t.conf.Network, t.server.Addr())
 
-	go func() {
-		<-t.ctx.Done()
-		t.listener.Close()
-	}()
-
-acceptLoop:
-	for {
-		conn, err := t.listener.Accept()
+	t.server.Serve(t.ctx, t.log, func(ctx context.Context, c io.ReadCloser) {
+		codec, err := t.codecCtor("", c, func(ctx context.Context, err error) error {
+			return nil
+		})
 		if err != nil {
-			if !strings.Contains(err.Error(), "use of closed network connection") {
-				t.log.Errorf("Failed to accept Socket connection: %v\n", err)
-			}
-			select {
-			case <-time.After(time.Second):
-				continue acceptLoop
-			case <-t.ctx.Done():
-				return
-			}
+			c.Close()
+			t.log.Errorf("Failed to create codec for new connection: %v\n", err)
+			return
 		}
-		connCtx, connDone := context.WithCancel(t.ctx)
-		go func() {
-			<-connCtx.Done()
-			conn.Close()
-		}()
-		wg.Add(1)
-		go func(c net.Conn) {
-			defer func() {
-				connDone()
-				wg.Done()
-				c.Close()
-			}()
-			codec, err := t.codecCtor("", c, func(ctx context.Context, err error) error {
-				return nil
-			})
-			if err != nil {
-				t.log.Errorf("Failed to create codec for new connection: %v\n", err)
-				return
-			}
+		defer codec.Close(context.Background())
 
-			for {
-				parts, ackFn, err := codec.Next(t.ctx)
-				if err != nil {
-					if err != io.EOF && err != component.ErrTimeout {
-						t.log.Errorf("Connection dropped due to: %v\n", err)
-					}
-					return
-				}
-				t.mRcvd.Incr(int64(len(parts)))
-
-				// We simply bounce rejected messages in a loop downstream so
-				// there's no benefit to aggregating acks.
-				_ = ackFn(t.ctx, nil)
-
-				msg := message.QuickBatch(nil)
-				msg.Append(parts...)
-				if !t.sendMsg(msg) {
-					return
+		for {
+			parts, ackFn, err := codec.Next(ctx)
+			if err != nil {
+				if err != io.EOF && err != component.ErrTimeout {
+					t.log.Errorf("Connection dropped due to: %v\n", err)
 				}
+				return
 			}
-		}(conn)
-	}
-}
-
-func (t *SocketServer) udpLoop() {
-	defer func() {
-		t.retriesMut.Lock()
-		// nolint:staticcheck, gocritic // Ignore SA2001 empty critical section, Ignore badLock
-		t.retriesMut.Unlock()
-
-		close(t.transactions)
-		close(t.closedChan)
-	}()
-
-	codec, err := t.codecCtor("", &wrapPacketConn{PacketConn: t.conn}, func(ctx context.Context, err error) error {
-		return nil
-	})
-	if err != nil {
-		t.log.Errorf("Connection error due to: %v\n", err)
-		return
-	}
-
-	go func() {
-		<-t.ctx.Done()
-		codec.Close(context.Background())
-		t.conn.Close()
-	}()
+			t.mRcvd.Incr(int64(len(parts)))
 
-	t.log.Infof("Receiving udp socket messages from address: %v\n", t.conn.LocalAddr())
+			// We simply bounce rejected messages in a loop downstream so
+			// there's no benefit to aggregating acks.
+			_ = ackFn(t.ctx, nil)
 
-	for {
-		parts, ackFn, err := codec.Next(t.ctx)
-		if err != nil {
-			if err != io.EOF && err != component.ErrTimeout {
-				t.log.Errorf("Connection dropped due to: %v\n", err)
+			msg := message.QuickBatch(nil)
+			msg.Append(parts...)
+			if !t.sendMsg(msg) {
+				return
 			}
-			return
 		}
-		t.mRcvd.Incr(int64(len(parts)))
-
-		// We simply bounce rejected messages in a loop downstream so
-		// there's no benefit to aggregating acks.
-		_ = ackFn(t.ctx, nil)
-
-		msg := message.QuickBatch(nil)
-		msg.Append(parts...)
-		if !t.sendMsg(msg) {
-			return
-		}
-	}
+	})
 }
 
 // TransactionChan returns a transactions channel for consuming messages from
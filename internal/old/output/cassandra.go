@@ -3,7 +3,6 @@ package output
 import (
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
@@ -16,11 +15,11 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/batch/policy"
 	"github.com/benthosdev/benthos/v4/internal/bloblang/field"
 	"github.com/benthosdev/benthos/v4/internal/bloblang/mapping"
-	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
 	"github.com/benthosdev/benthos/v4/internal/component"
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/component/output"
 	"github.com/benthosdev/benthos/v4/internal/docs"
+	"github.com/benthosdev/benthos/v4/internal/impl/cassandra/shared"
 	"github.com/benthosdev/benthos/v4/internal/interop"
 	"github.com/benthosdev/benthos/v4/internal/log"
 	"github.com/benthosdev/benthos/v4/internal/message"
@@ -89,44 +88,13 @@ output:
 `,
 			},
 		},
-		Config: docs.FieldComponent().WithChildren(
-			docs.FieldString(
-				"addresses",
-				"A list of Cassandra nodes to connect to. Multiple comma separated addresses can be specified on a single line.",
-				[]string{"localhost:9042"},
-				[]string{"foo:9042", "bar:9042"},
-				[]string{"foo:9042,bar:9042"},
-			).Array(),
-			btls.FieldSpec(),
-			docs.FieldObject(
-				"password_authenticator",
-				"An object containing the username and password.",
-			).WithChildren(
-				docs.FieldBool("enabled", "Whether to use password authentication."),
-				docs.FieldString("username", "A username."),
-				docs.FieldString("password", "A password."),
-			).Advanced(),
-			docs.FieldBool(
-				"disable_initial_host_lookup",
-				"If enabled the driver will not attempt to get host info from the system.peers table. This can speed up queries but will mean that data_centre, rack and token information will not be available.",
-			).Advanced(),
+		Config: docs.FieldComponent().WithChildren(shared.ConnectionFieldSpecs()...).WithChildren(
 			docs.FieldString("query", "A query to execute for each message."),
 			docs.FieldBloblang(
 				"args_mapping",
 				"A [Bloblang mapping](/docs/guides/bloblang/about) that can be used to provide arguments to Cassandra queries. The result of the query must be an array containing a matching number of elements to the query arguments.").AtVersion("3.55.0"),
-			docs.FieldString(
-				"consistency",
-				"The consistency level to use.",
-			).HasOptions(
-				"ANY", "ONE", "TWO", "THREE", "QUORUM", "ALL", "LOCAL_QUORUM", "EACH_QUORUM", "LOCAL_ONE",
-			).Advanced(),
-			docs.FieldInt("max_retries", "The maximum number of retries before giving up on a request.").Advanced(),
-			docs.FieldObject("backoff", "Control time intervals between retry attempts.").WithChildren(
-				docs.FieldString("initial_interval", "The initial period to wait between retry attempts."),
-				docs.FieldString("max_interval", "The maximum period to wait between retry attempts."),
-				docs.FieldString("max_elapsed_time", "").Deprecated(),
-			).Advanced(),
-			docs.FieldString("timeout", "The client connection timeout.").AtVersion("3.63.0"),
+		).WithChildren(shared.RequestFieldSpecs()...).WithChildren(
+			shared.TimeoutFieldSpec().AtVersion("3.63.0"),
 		).WithChildren(
 			docs.FieldInt("max_in_flight", "The maximum number of messages to have in flight at a given time. Increase this to improve throughput."),
 			policy.FieldSpec(),
@@ -356,7 +324,7 @@ func (c *cassandraWriter) mapArgs(msg *message.Batch, index int) ([]interface{},
 		}
 
 		for i, v := range j {
-			j[i] = genericValue{v: v}
+			j[i] = shared.GenericValue{V: v}
 		}
 		return j, nil
 	}
@@ -494,44 +462,3 @@ func (s stringValue) MarshalCQL(info gocql.TypeInfo) ([]byte, error) {
 	}
 	return gocql.Marshal(info, string(s))
 }
-
-type genericValue struct {
-	v interface{}
-}
-
-// We get typed values out of mappings. However, gocql performs type checking
-// and unfortunately does not like timestamp and some other values as strings:
-// https://github.com/gocql/gocql/blob/5913df4d474e0b2492a129d17bbb3c04537a15cd/marshal.go#L1160
-// it's also very strict on numerical types, so we need to do some magic here.
-func (g genericValue) MarshalCQL(info gocql.TypeInfo) ([]byte, error) {
-	switch info.Type() {
-	case gocql.TypeTimestamp:
-		t, err := query.IGetTimestamp(g.v)
-		if err != nil {
-			return nil, err
-		}
-		return gocql.Marshal(info, t)
-	case gocql.TypeDouble:
-		f, err := query.IGetNumber(g.v)
-		if err != nil {
-			return nil, err
-		}
-		return gocql.Marshal(info, f)
-	case gocql.TypeFloat:
-		f, err := query.IGetFloat32(g.v)
-		if err != nil {
-			return nil, err
-		}
-		return gocql.Marshal(info, f)
-	case gocql.TypeVarchar:
-		return gocql.Marshal(info, query.IToString(g.v))
-	}
-	if _, isJSONNum := g.v.(json.Number); isJSONNum {
-		i, err := query.IGetInt(g.v)
-		if err != nil {
-			return nil, err
-		}
-		return gocql.Marshal(info, i)
-	}
-	return gocql.Marshal(info, g.v)
-}
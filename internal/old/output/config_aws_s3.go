@@ -2,6 +2,7 @@ package output
 
 import (
 	"github.com/benthosdev/benthos/v4/internal/batch/policy"
+	"github.com/benthosdev/benthos/v4/internal/codec"
 	sess "github.com/benthosdev/benthos/v4/internal/impl/aws/session"
 	"github.com/benthosdev/benthos/v4/internal/metadata"
 )
@@ -24,6 +25,7 @@ type AmazonS3Config struct {
 	Timeout                 string                       `json:"timeout" yaml:"timeout"`
 	KMSKeyID                string                       `json:"kms_key_id" yaml:"kms_key_id"`
 	ServerSideEncryption    string                       `json:"server_side_encryption" yaml:"server_side_encryption"`
+	Streaming               codec.StreamingConfig        `json:"streaming" yaml:"streaming"`
 	MaxInFlight             int                          `json:"max_in_flight" yaml:"max_in_flight"`
 	Batching                policy.Config                `json:"batching" yaml:"batching"`
 }
@@ -47,6 +49,7 @@ func NewAmazonS3Config() AmazonS3Config {
 		Timeout:                 "5s",
 		KMSKeyID:                "",
 		ServerSideEncryption:    "",
+		Streaming:               codec.NewStreamingConfig(),
 		MaxInFlight:             64,
 		Batching:                policy.NewConfig(),
 	}
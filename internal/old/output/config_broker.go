@@ -6,18 +6,22 @@ import (
 
 // BrokerConfig contains configuration fields for the Broker output type.
 type BrokerConfig struct {
-	Copies   int           `json:"copies" yaml:"copies"`
-	Pattern  string        `json:"pattern" yaml:"pattern"`
-	Outputs  []Config      `json:"outputs" yaml:"outputs"`
-	Batching policy.Config `json:"batching" yaml:"batching"`
+	Copies       int           `json:"copies" yaml:"copies"`
+	Pattern      string        `json:"pattern" yaml:"pattern"`
+	Outputs      []Config      `json:"outputs" yaml:"outputs"`
+	Key          string        `json:"key" yaml:"key"`
+	VirtualNodes int           `json:"virtual_nodes" yaml:"virtual_nodes"`
+	Batching     policy.Config `json:"batching" yaml:"batching"`
 }
 
 // NewBrokerConfig creates a new BrokerConfig with default values.
 func NewBrokerConfig() BrokerConfig {
 	return BrokerConfig{
-		Copies:   1,
-		Pattern:  "fan_out",
-		Outputs:  []Config{},
-		Batching: policy.NewConfig(),
+		Copies:       1,
+		Pattern:      "fan_out",
+		Outputs:      []Config{},
+		Key:          "",
+		VirtualNodes: 128,
+		Batching:     policy.NewConfig(),
 	}
 }
@@ -0,0 +1,67 @@
+package output
+
+import (
+	"encoding/json"
+)
+
+// CircuitBreakerConfig contains configuration values for the CircuitBreaker
+// output type.
+type CircuitBreakerConfig struct {
+	MaxConsecutiveFailures int     `json:"max_consecutive_failures" yaml:"max_consecutive_failures"`
+	ErrorRateThreshold     float64 `json:"error_rate_threshold" yaml:"error_rate_threshold"`
+	ErrorRateWindow        int     `json:"error_rate_window" yaml:"error_rate_window"`
+	CoolDown               string  `json:"cool_down" yaml:"cool_down"`
+	Output                 *Config `json:"output" yaml:"output"`
+	OpenOutput             *Config `json:"open_output" yaml:"open_output"`
+}
+
+// NewCircuitBreakerConfig creates a new CircuitBreakerConfig with default
+// values.
+func NewCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		MaxConsecutiveFailures: 5,
+		ErrorRateThreshold:     0,
+		ErrorRateWindow:        20,
+		CoolDown:               "30s",
+		Output:                 nil,
+		OpenOutput:             nil,
+	}
+}
+
+type dummyCircuitBreakerConfig struct {
+	MaxConsecutiveFailures int         `json:"max_consecutive_failures" yaml:"max_consecutive_failures"`
+	ErrorRateThreshold     float64     `json:"error_rate_threshold" yaml:"error_rate_threshold"`
+	ErrorRateWindow        int         `json:"error_rate_window" yaml:"error_rate_window"`
+	CoolDown               string      `json:"cool_down" yaml:"cool_down"`
+	Output                 interface{} `json:"output" yaml:"output"`
+	OpenOutput             interface{} `json:"open_output" yaml:"open_output"`
+}
+
+func (c CircuitBreakerConfig) dummy() dummyCircuitBreakerConfig {
+	dummy := dummyCircuitBreakerConfig{
+		MaxConsecutiveFailures: c.MaxConsecutiveFailures,
+		ErrorRateThreshold:     c.ErrorRateThreshold,
+		ErrorRateWindow:        c.ErrorRateWindow,
+		CoolDown:               c.CoolDown,
+		Output:                 c.Output,
+	}
+	if c.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	// An empty open_output would be parsed as a default output, so it is
+	// printed as null when not set.
+	if c.OpenOutput != nil {
+		dummy.OpenOutput = c.OpenOutput
+	}
+	return dummy
+}
+
+// MarshalJSON prints an empty object instead of a nil output.
+func (c CircuitBreakerConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.dummy())
+}
+
+// MarshalYAML prints an empty object instead of a nil output.
+func (c CircuitBreakerConfig) MarshalYAML() (interface{}, error) {
+	return c.dummy(), nil
+}
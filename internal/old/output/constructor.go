@@ -126,6 +126,7 @@ const (
 	TypeBroker             = "broker"
 	TypeCache              = "cache"
 	TypeCassandra          = "cassandra"
+	TypeCircuitBreaker     = "circuit_breaker"
 	TypeDrop               = "drop"
 	TypeDropOn             = "drop_on"
 	TypeDynamic            = "dynamic"
@@ -185,6 +186,7 @@ type Config struct {
 	Broker             BrokerConfig                   `json:"broker" yaml:"broker"`
 	Cache              writer.CacheConfig             `json:"cache" yaml:"cache"`
 	Cassandra          CassandraConfig                `json:"cassandra" yaml:"cassandra"`
+	CircuitBreaker     CircuitBreakerConfig           `json:"circuit_breaker" yaml:"circuit_breaker"`
 	Drop               writer.DropConfig              `json:"drop" yaml:"drop"`
 	DropOn             DropOnConfig                   `json:"drop_on" yaml:"drop_on"`
 	Dynamic            DynamicConfig                  `json:"dynamic" yaml:"dynamic"`
@@ -243,6 +245,7 @@ func NewConfig() Config {
 		Broker:             NewBrokerConfig(),
 		Cache:              writer.NewCacheConfig(),
 		Cassandra:          NewCassandraConfig(),
+		CircuitBreaker:     NewCircuitBreakerConfig(),
 		Drop:               writer.NewDropConfig(),
 		DropOn:             NewDropOnConfig(),
 		Dynamic:            NewDynamicConfig(),
@@ -6,7 +6,7 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/component/output"
 	"github.com/benthosdev/benthos/v4/internal/docs"
 	"github.com/benthosdev/benthos/v4/internal/http/docs/auth"
-	sess "github.com/benthosdev/benthos/v4/internal/impl/aws/session"
+	esshared "github.com/benthosdev/benthos/v4/internal/impl/elasticsearch/shared"
 	"github.com/benthosdev/benthos/v4/internal/interop"
 	"github.com/benthosdev/benthos/v4/internal/log"
 	"github.com/benthosdev/benthos/v4/internal/old/output/writer"
@@ -42,20 +42,16 @@ false for connections to succeed.`,
 			docs.FieldString("id", "The ID for indexed messages. Interpolation should be used in order to create a unique ID for each message.").IsInterpolated(),
 			docs.FieldString("type", "The document type.").Deprecated(),
 			docs.FieldString("routing", "The routing key to use for the document.").IsInterpolated().Advanced(),
-			docs.FieldBool("sniff", "Prompts Benthos to sniff for brokers to connect to when establishing a connection.").Advanced(),
-			docs.FieldBool("healthcheck", "Whether to enable healthchecks.").Advanced(),
+			esshared.SniffFieldSpec(),
+			esshared.HealthcheckFieldSpec(),
 			docs.FieldString("timeout", "The maximum time to wait before abandoning a request (and trying again).").Advanced(),
 			tls.FieldSpec(),
 			docs.FieldInt("max_in_flight", "The maximum number of messages to have in flight at a given time. Increase this to improve throughput."),
 		).WithChildren(retries.FieldSpecs()...).WithChildren(
 			auth.BasicAuthFieldSpec(),
 			policy.FieldSpec(),
-			docs.FieldObject("aws", "Enables and customises connectivity to Amazon Elastic Service.").WithChildren(
-				docs.FieldSpecs{
-					docs.FieldBool("enabled", "Whether to connect to Amazon Elastic Service."),
-				}.Merge(sess.FieldSpecs())...,
-			).Advanced(),
-			docs.FieldBool("gzip_compression", "Enable gzip compression on the request side.").Advanced(),
+			esshared.AWSFieldSpec(),
+			esshared.GzipFieldSpec(),
 		),
 		Categories: []string{
 			"Services",
@@ -1,10 +1,15 @@
 package output
 
 import (
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,7 +34,13 @@ Writes messages to files on disk based on a chosen codec.`,
 		Description: `
 Messages can be written to different files by using [interpolation functions](/docs/configuration/interpolation#bloblang-queries) in the path field. However, only one file is ever open at a given time, and therefore when the path changes the previously open file is closed.
 
-` + multipartCodecDoc,
+` + multipartCodecDoc + `
+
+## Rotation
+
+When ` + "`rotation.enabled`" + ` is set to ` + "`true`" + ` files are written with the suffix ` + "`rotation.in_progress_suffix`" + ` appended to their path, and once a file is finished it is atomically renamed to its path with a UTC timestamp inserted before the extension, e.g. ` + "`/tmp/data.txt`" + ` would be written as ` + "`/tmp/data.txt.inprogress`" + ` and then finished as ` + "`/tmp/data-2022-01-02T15-04-05.000000000.txt`" + `. This ensures that consumers of the directory never observe partially written files.
+
+A file is finished when the path of a message differs from that of the open file, when the output is closed, or when any of the limits ` + "`rotation.max_bytes`, `rotation.max_messages` or `rotation.max_age`" + ` are reached, in which case subsequent messages are written to a fresh file. Finished files can optionally be compressed, and the number of finished files kept per path can be limited with ` + "`rotation.max_files`" + `, where the oldest files are deleted first.`,
 		Config: docs.FieldComponent().WithChildren(
 			docs.FieldString(
 				"path", "The file to write to, if the file does not yet exist it will be created.",
@@ -38,6 +49,15 @@ Messages can be written to different files by using [interpolation functions](/d
 				`/tmp/${! json("document.id") }.json`,
 			).IsInterpolated().AtVersion("3.33.0"),
 			codec.WriterDocs.AtVersion("3.33.0"),
+			docs.FieldObject("rotation", "Controls whether and when files are rotated.").WithChildren(
+				docs.FieldBool("enabled", "Whether to write files with an in progress suffix and rotate them into timestamped files once finished."),
+				docs.FieldInt("max_bytes", "The maximum size in bytes of a file before it is rotated, set to `0` to disable."),
+				docs.FieldString("max_age", "The maximum period of time a file is written to before it is rotated, set to an empty string to disable.", "1h", "24h"),
+				docs.FieldInt("max_messages", "The maximum number of messages written to a file before it is rotated, set to `0` to disable."),
+				docs.FieldString("in_progress_suffix", "A suffix appended to the path of files that are still being written to.").Advanced(),
+				docs.FieldString("compression", "An optional compression algorithm applied to files once they are rotated.").HasOptions("none", "gzip"),
+				docs.FieldInt("max_files", "The maximum number of rotated files to keep for each path, where the oldest files are deleted first. Set to `0` to keep all files."),
+			).AtVersion("4.0.0"),
 		),
 		Categories: []string{
 			"Local",
@@ -47,17 +67,44 @@ Messages can be written to different files by using [interpolation functions](/d
 
 //------------------------------------------------------------------------------
 
+// FileRotationConfig contains configuration fields for rotating the files
+// written by the file output type.
+type FileRotationConfig struct {
+	Enabled          bool   `json:"enabled" yaml:"enabled"`
+	MaxBytes         int64  `json:"max_bytes" yaml:"max_bytes"`
+	MaxAge           string `json:"max_age" yaml:"max_age"`
+	MaxMessages      int    `json:"max_messages" yaml:"max_messages"`
+	InProgressSuffix string `json:"in_progress_suffix" yaml:"in_progress_suffix"`
+	Compression      string `json:"compression" yaml:"compression"`
+	MaxFiles         int    `json:"max_files" yaml:"max_files"`
+}
+
+// NewFileRotationConfig creates a new FileRotationConfig with default values.
+func NewFileRotationConfig() FileRotationConfig {
+	return FileRotationConfig{
+		Enabled:          false,
+		MaxBytes:         0,
+		MaxAge:           "",
+		MaxMessages:      0,
+		InProgressSuffix: ".inprogress",
+		Compression:      "none",
+		MaxFiles:         0,
+	}
+}
+
 // FileConfig contains configuration fields for the file based output type.
 type FileConfig struct {
-	Path  string `json:"path" yaml:"path"`
-	Codec string `json:"codec" yaml:"codec"`
+	Path     string             `json:"path" yaml:"path"`
+	Codec    string             `json:"codec" yaml:"codec"`
+	Rotation FileRotationConfig `json:"rotation" yaml:"rotation"`
 }
 
 // NewFileConfig creates a new FileConfig with default values.
 func NewFileConfig() FileConfig {
 	return FileConfig{
-		Path:  "",
-		Codec: "lines",
+		Path:     "",
+		Codec:    "lines",
+		Rotation: NewFileRotationConfig(),
 	}
 }
 
@@ -65,7 +112,7 @@ func NewFileConfig() FileConfig {
 
 // NewFile creates a new File output type.
 func NewFile(conf Config, mgr interop.Manager, log log.Modular, stats metrics.Type) (output.Streamed, error) {
-	f, err := newFileWriter(conf.File.Path, conf.File.Codec, mgr, log, stats)
+	f, err := newFileWriter(conf.File, mgr, log, stats)
 	if err != nil {
 		return nil, err
 	}
@@ -89,86 +136,321 @@ type fileWriter struct {
 	codec     codec.WriterConstructor
 	codecConf codec.WriterConfig
 
-	handleMut  sync.Mutex
-	handlePath string
-	handle     codec.Writer
+	rotation FileRotationConfig
+	maxAge   time.Duration
+
+	handleMut      sync.Mutex
+	handlePath     string
+	handle         codec.Writer
+	handleFile     *fileCountingWriter
+	handleMessages int
+	handleGen      int
+	handleTimer    *time.Timer
 
 	shutSig *shutdown.Signaller
 }
 
-func newFileWriter(pathStr, codecStr string, mgr interop.Manager, log log.Modular, stats metrics.Type) (*fileWriter, error) {
-	codec, codecConf, err := codec.GetWriter(codecStr)
+func newFileWriter(conf FileConfig, mgr interop.Manager, log log.Modular, stats metrics.Type) (*fileWriter, error) {
+	codec, codecConf, err := codec.GetWriter(conf.Codec)
 	if err != nil {
 		return nil, err
 	}
-	path, err := mgr.BloblEnvironment().NewField(pathStr)
+	path, err := mgr.BloblEnvironment().NewField(conf.Path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse path expression: %w", err)
 	}
-	return &fileWriter{
+	w := &fileWriter{
 		codec:     codec,
 		codecConf: codecConf,
 		path:      path,
+		rotation:  conf.Rotation,
 		log:       log,
 		stats:     stats,
 		shutSig:   shutdown.NewSignaller(),
-	}, nil
+	}
+	if conf.Rotation.Enabled {
+		if conf.Rotation.MaxAge != "" {
+			if w.maxAge, err = time.ParseDuration(conf.Rotation.MaxAge); err != nil {
+				return nil, fmt.Errorf("failed to parse rotation max age: %w", err)
+			}
+		}
+		if conf.Rotation.InProgressSuffix == "" {
+			return nil, errors.New("rotation in progress suffix must not be empty")
+		}
+		switch conf.Rotation.Compression {
+		case "", "none", "gzip":
+		default:
+			return nil, fmt.Errorf("unrecognised rotation compression: %v", conf.Rotation.Compression)
+		}
+	}
+	return w, nil
 }
 
 //------------------------------------------------------------------------------
 
-func (w *fileWriter) ConnectWithContext(ctx context.Context) error {
+// fileCountingWriter wraps a file and tracks the number of bytes within it.
+type fileCountingWriter struct {
+	f *os.File
+	n int64
+}
+
+func (c *fileCountingWriter) Write(p []byte) (int, error) {
+	n, err := c.f.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *fileCountingWriter) Close() error {
+	return c.f.Close()
+}
+
+const fileRotationTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// rotatedFilePath returns the path of a finished file, which is the original
+// path with a timestamp inserted before the extension.
+func rotatedFilePath(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + t.UTC().Format(fileRotationTimeFormat) + ext
+}
+
+func (w *fileWriter) compressionExt() string {
+	if w.rotation.Compression == "gzip" {
+		return ".gz"
+	}
+	return ""
+}
+
+// openHandle opens the file for a given path, the mutex must be held by the
+// caller.
+func (w *fileWriter) openHandle(path string) error {
+	flag := os.O_CREATE | os.O_RDWR
+	if w.codecConf.Append {
+		flag |= os.O_APPEND
+	}
+	if w.codecConf.Truncate {
+		flag |= os.O_TRUNC
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0o777)); err != nil {
+		return err
+	}
+
+	filePath := path
+	if w.rotation.Enabled {
+		filePath += w.rotation.InProgressSuffix
+	}
+
+	file, err := os.OpenFile(filePath, flag, os.FileMode(0o666))
+	if err != nil {
+		return err
+	}
+
+	counter := &fileCountingWriter{f: file}
+	if info, err := file.Stat(); err == nil && !w.codecConf.Truncate {
+		counter.n = info.Size()
+	}
+
+	handle, err := w.codec(counter)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.handleGen++
+	w.handlePath = path
+	w.handle = handle
+	w.handleFile = counter
+	w.handleMessages = 0
+
+	if w.rotation.Enabled && w.maxAge > 0 {
+		gen := w.handleGen
+		w.handleTimer = time.AfterFunc(w.maxAge, func() {
+			w.handleMut.Lock()
+			defer w.handleMut.Unlock()
+			if w.handle == nil || w.handleGen != gen {
+				return
+			}
+			if err := w.closeHandle(context.Background()); err != nil {
+				w.log.Errorf("Failed to rotate file '%v': %v\n", path, err)
+			}
+		})
+	}
 	return nil
 }
 
-func (w *fileWriter) WriteWithContext(ctx context.Context, msg *message.Batch) error {
-	err := writer.IterateBatchedSend(msg, func(i int, p *message.Part) error {
-		path := filepath.Clean(w.path.String(i, msg))
+// releaseHandle closes the currently open file without finishing it, the
+// mutex must be held by the caller.
+func (w *fileWriter) releaseHandle(ctx context.Context) error {
+	if w.handleTimer != nil {
+		w.handleTimer.Stop()
+		w.handleTimer = nil
+	}
 
-		w.handleMut.Lock()
-		defer w.handleMut.Unlock()
+	err := w.handle.Close(ctx)
 
-		if w.handle != nil && path == w.handlePath {
-			return w.handle.Write(ctx, p)
+	w.handle = nil
+	w.handleFile = nil
+	w.handlePath = ""
+	w.handleMessages = 0
+	return err
+}
+
+// closeHandle closes the currently open file, and if rotation is enabled
+// finishes it, the mutex must be held by the caller.
+func (w *fileWriter) closeHandle(ctx context.Context) error {
+	if w.handle == nil {
+		return nil
+	}
+
+	path := w.handlePath
+	if err := w.releaseHandle(ctx); err != nil {
+		return err
+	}
+	if !w.rotation.Enabled {
+		return nil
+	}
+	return w.finishFile(path)
+}
+
+func (w *fileWriter) rotationLimitReached() bool {
+	if !w.rotation.Enabled || w.handle == nil {
+		return false
+	}
+	if w.rotation.MaxBytes > 0 && w.handleFile.n >= w.rotation.MaxBytes {
+		return true
+	}
+	if w.rotation.MaxMessages > 0 && w.handleMessages >= w.rotation.MaxMessages {
+		return true
+	}
+	return false
+}
+
+// finishFile moves an in progress file to its rotated path, compressing it if
+// configured to do so, and then removes any rotated files beyond the
+// retention limit.
+func (w *fileWriter) finishFile(path string) error {
+	inProgressPath := path + w.rotation.InProgressSuffix
+	finishedPath := rotatedFilePath(path, time.Now()) + w.compressionExt()
+
+	if w.rotation.Compression == "gzip" {
+		if err := gzipFile(inProgressPath, finishedPath, w.rotation.InProgressSuffix); err != nil {
+			return fmt.Errorf("failed to compress file: %w", err)
 		}
-		if w.handle != nil {
-			if err := w.handle.Close(ctx); err != nil {
-				return err
-			}
+		if err := os.Remove(inProgressPath); err != nil {
+			return err
 		}
+	} else if err := os.Rename(inProgressPath, finishedPath); err != nil {
+		return err
+	}
+	return w.removeExpiredFiles(path)
+}
+
+// gzipFile writes a compressed copy of src to dst, the copy is written with a
+// temporary suffix and renamed once complete.
+func gzipFile(src, dst, tmpSuffix string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst+tmpSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0o666))
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(out)
+	if _, err = io.Copy(gw, in); err == nil {
+		err = gw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst + tmpSuffix)
+		return err
+	}
+	return os.Rename(dst+tmpSuffix, dst)
+}
+
+// removeExpiredFiles deletes the oldest rotated files of a path until at most
+// max_files remain.
+func (w *fileWriter) removeExpiredFiles(path string) error {
+	if w.rotation.MaxFiles <= 0 {
+		return nil
+	}
+
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	suffix := ext + w.compressionExt()
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
 
-		flag := os.O_CREATE | os.O_RDWR
-		if w.codecConf.Append {
-			flag |= os.O_APPEND
+	// Timestamps are of a fixed width and therefore sorting the names sorts
+	// them chronologically.
+	var rotated []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || len(name) <= len(prefix)+len(suffix) ||
+			!strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
 		}
-		if w.codecConf.Truncate {
-			flag |= os.O_TRUNC
+		if _, err := time.Parse(fileRotationTimeFormat, name[len(prefix):len(name)-len(suffix)]); err != nil {
+			continue
 		}
+		rotated = append(rotated, name)
+	}
+	sort.Strings(rotated)
 
-		if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0o777)); err != nil {
+	for len(rotated) > w.rotation.MaxFiles {
+		if err := os.Remove(filepath.Join(dir, rotated[0])); err != nil && !os.IsNotExist(err) {
 			return err
 		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
 
-		file, err := os.OpenFile(path, flag, os.FileMode(0o666))
-		if err != nil {
-			return err
+//------------------------------------------------------------------------------
+
+func (w *fileWriter) ConnectWithContext(ctx context.Context) error {
+	return nil
+}
+
+func (w *fileWriter) WriteWithContext(ctx context.Context, msg *message.Batch) error {
+	err := writer.IterateBatchedSend(msg, func(i int, p *message.Part) error {
+		path := filepath.Clean(w.path.String(i, msg))
+
+		w.handleMut.Lock()
+		defer w.handleMut.Unlock()
+
+		if w.handle != nil && path != w.handlePath {
+			if err := w.closeHandle(ctx); err != nil {
+				return err
+			}
 		}
 
-		w.handlePath = path
-		handle, err := w.codec(file)
-		if err != nil {
-			return err
+		fresh := w.handle == nil
+		if fresh {
+			if err := w.openHandle(path); err != nil {
+				return err
+			}
 		}
 
-		if err = handle.Write(ctx, p); err != nil {
-			handle.Close(ctx)
+		if err := w.handle.Write(ctx, p); err != nil {
+			if fresh {
+				_ = w.releaseHandle(ctx)
+			}
 			return err
 		}
+		w.handleMessages++
 
-		if !w.codecConf.CloseAfter {
-			w.handle = handle
-		} else {
-			handle.Close(ctx)
+		if w.codecConf.CloseAfter || w.rotationLimitReached() {
+			return w.closeHandle(ctx)
 		}
 		return nil
 	})
@@ -190,9 +472,8 @@ func (w *fileWriter) WriteWithContext(ctx context.Context, msg *message.Batch) e
 func (w *fileWriter) CloseAsync() {
 	go func() {
 		w.handleMut.Lock()
-		if w.handle != nil {
-			w.handle.Close(context.Background())
-			w.handle = nil
+		if err := w.closeHandle(context.Background()); err != nil {
+			w.log.Errorf("Failed to close file: %v\n", err)
 		}
 		w.handleMut.Unlock()
 		w.shutSig.ShutdownComplete()
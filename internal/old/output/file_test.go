@@ -0,0 +1,196 @@
+package output_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/component/metrics"
+	"github.com/benthosdev/benthos/v4/internal/log"
+	"github.com/benthosdev/benthos/v4/internal/manager/mock"
+	"github.com/benthosdev/benthos/v4/internal/message"
+	"github.com/benthosdev/benthos/v4/internal/old/output"
+)
+
+func sendFileMsg(t *testing.T, msg string, tChan chan message.Transaction) {
+	t.Helper()
+
+	resChan := make(chan error)
+	select {
+	case tChan <- message.NewTransaction(message.QuickBatch([][]byte{[]byte(msg)}), resChan):
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case res := <-resChan:
+		require.NoError(t, res)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+}
+
+func writeFileMsgs(t *testing.T, conf output.Config, msgs ...string) {
+	t.Helper()
+
+	f, err := output.NewFile(conf, mock.NewManager(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	tChan := make(chan message.Transaction)
+	require.NoError(t, f.Consume(tChan))
+
+	for _, m := range msgs {
+		sendFileMsg(t, m, tChan)
+	}
+
+	f.CloseAsync()
+	require.NoError(t, f.WaitForClose(time.Second*5))
+}
+
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFileNoRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := output.NewConfig()
+	conf.File.Path = filepath.Join(dir, "data.txt")
+
+	writeFileMsgs(t, conf, "foo", "bar")
+
+	assert.Equal(t, []string{"data.txt"}, dirFiles(t, dir))
+
+	b, err := os.ReadFile(filepath.Join(dir, "data.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", string(b))
+}
+
+func TestFileRotationMaxMessages(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := output.NewConfig()
+	conf.File.Path = filepath.Join(dir, "data.txt")
+	conf.File.Rotation.Enabled = true
+	conf.File.Rotation.MaxMessages = 2
+
+	writeFileMsgs(t, conf, "foo", "bar", "baz", "buz", "bev")
+
+	names := dirFiles(t, dir)
+	require.Len(t, names, 3)
+
+	var contents []string
+	for _, n := range names {
+		assert.Regexp(t, `^data-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{9}\.txt$`, n)
+
+		b, err := os.ReadFile(filepath.Join(dir, n))
+		require.NoError(t, err)
+		contents = append(contents, string(b))
+	}
+	assert.Equal(t, []string{"foo\nbar\n", "baz\nbuz\n", "bev\n"}, contents)
+}
+
+func TestFileRotationMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := output.NewConfig()
+	conf.File.Path = filepath.Join(dir, "data.txt")
+	conf.File.Rotation.Enabled = true
+	conf.File.Rotation.MaxBytes = 5
+
+	writeFileMsgs(t, conf, "foo", "bar", "baz")
+
+	names := dirFiles(t, dir)
+	require.Len(t, names, 2)
+
+	b, err := os.ReadFile(filepath.Join(dir, names[0]))
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", string(b))
+}
+
+func TestFileRotationMaxAge(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := output.NewConfig()
+	conf.File.Path = filepath.Join(dir, "data.txt")
+	conf.File.Rotation.Enabled = true
+	conf.File.Rotation.MaxAge = "50ms"
+
+	f, err := output.NewFile(conf, mock.NewManager(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	tChan := make(chan message.Transaction)
+	require.NoError(t, f.Consume(tChan))
+
+	sendFileMsg(t, "foo", tChan)
+	assert.Equal(t, []string{"data.txt.inprogress"}, dirFiles(t, dir))
+
+	assert.Eventually(t, func() bool {
+		names := dirFiles(t, dir)
+		return len(names) == 1 && names[0] != "data.txt.inprogress"
+	}, time.Second*5, time.Millisecond*10)
+
+	f.CloseAsync()
+	require.NoError(t, f.WaitForClose(time.Second*5))
+}
+
+func TestFileRotationCompressionRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := output.NewConfig()
+	conf.File.Path = filepath.Join(dir, "data.txt")
+	conf.File.Rotation.Enabled = true
+	conf.File.Rotation.MaxMessages = 1
+	conf.File.Rotation.Compression = "gzip"
+	conf.File.Rotation.MaxFiles = 2
+
+	writeFileMsgs(t, conf, "foo", "bar", "baz", "buz")
+
+	names := dirFiles(t, dir)
+	require.Len(t, names, 2)
+
+	var contents []string
+	for _, n := range names {
+		assert.Regexp(t, `^data-.+\.txt\.gz$`, n)
+
+		file, err := os.Open(filepath.Join(dir, n))
+		require.NoError(t, err)
+
+		gr, err := gzip.NewReader(file)
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(gr)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		contents = append(contents, string(b))
+	}
+	assert.Equal(t, []string{"baz\n", "buz\n"}, contents)
+}
+
+func TestFileRotationBadConfig(t *testing.T) {
+	conf := output.NewConfig()
+	conf.File.Path = "/tmp/data.txt"
+	conf.File.Rotation.Enabled = true
+	conf.File.Rotation.Compression = "nope"
+
+	_, err := output.NewFile(conf, mock.NewManager(), log.Noop(), metrics.Noop())
+	require.Error(t, err)
+}
@@ -2,6 +2,7 @@ package output
 
 import (
 	"github.com/benthosdev/benthos/v4/internal/batch/policy"
+	"github.com/benthosdev/benthos/v4/internal/codec"
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/component/output"
 	"github.com/benthosdev/benthos/v4/internal/docs"
@@ -20,7 +21,13 @@ Sends message parts as files to a HDFS directory.`,
 		Description: `
 Each file is written with the path specified with the 'path' field, in order to
 have a different path for each object you should use function interpolations
-described [here](/docs/configuration/interpolation#bloblang-queries).`,
+described [here](/docs/configuration/interpolation#bloblang-queries).
+
+Messages of a batch that resolve to the same path are written to the same file
+using the chosen codec, which makes it possible to write batches of messages as
+a single file with, for example, the codec ` + "`gzip/lines`" + `. The default
+codec ` + "`all-bytes`" + ` writes each message to its own file, and therefore
+messages of a batch that share a path result in an error.`,
 		Async: true,
 		Config: docs.FieldComponent().WithChildren(
 			docs.FieldString("hosts", "A list of hosts to connect to.", "localhost:9000").Array(),
@@ -30,6 +37,7 @@ described [here](/docs/configuration/interpolation#bloblang-queries).`,
 				"path", "The path to upload messages as, interpolation functions should be used in order to generate unique file paths.",
 				`${!count("files")}-${!timestamp_unix_nano()}.txt`,
 			).IsInterpolated(),
+			codec.WriterDocs.AtVersion("4.0.0"),
 			docs.FieldInt("max_in_flight", "The maximum number of messages to have in flight at a given time. Increase this to improve throughput."),
 			policy.FieldSpec(),
 		),
@@ -53,7 +61,7 @@ func NewHDFS(conf Config, mgr interop.Manager, log log.Modular, stats metrics.Ty
 	if err != nil {
 		return nil, err
 	}
-	return NewBatcherFromConfig(conf.HDFS.Batching, OnlySinglePayloads(w), mgr, log, stats)
+	return NewBatcherFromConfig(conf.HDFS.Batching, w, mgr, log, stats)
 }
 
 //------------------------------------------------------------------------------
@@ -9,6 +9,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"strings"
 	"sync"
 	"time"
 
@@ -37,15 +38,20 @@ Sets up an HTTP server that will send messages over HTTP(S) GET requests. HTTP 2
 		Description: `
 Sets up an HTTP server that will send messages over HTTP(S) GET requests. If the ` + "`address`" + ` config field is left blank the [service-wide HTTP server](/docs/components/http/about) will be used.
 
-Three endpoints will be registered at the paths specified by the fields ` + "`path`, `stream_path` and `ws_path`" + `. Which allow you to consume a single message batch, a continuous stream of line delimited messages, or a websocket of messages for each request respectively.
+Three endpoints will be registered at the paths specified by the fields ` + "`path`, `stream_path` and `ws_path`" + `. Which allow you to consume a single message batch, a continuous stream of line delimited messages, or a websocket of messages for each request respectively. An endpoint for consuming a stream of [server-sent events](https://html.spec.whatwg.org/multipage/server-sent-events.html) is also registered when the field ` + "`sse_path`" + ` is set.
 
-When messages are batched the ` + "`path`" + ` endpoint encodes the batch according to [RFC1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html). This behaviour can be overridden by [archiving your batches](/docs/configuration/batching#post-batch-processing).`,
+When messages are batched the ` + "`path`" + ` endpoint encodes the batch according to [RFC1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html). This behaviour can be overridden by [archiving your batches](/docs/configuration/batching#post-batch-processing).
+
+### Server-Sent Events
+
+The ` + "`sse_path`" + ` endpoint sends each message as an event where each line of the message becomes a ` + "`data`" + ` field, which allows browsers to consume messages with an ` + "`EventSource`" + `. The metadata fields ` + "`sse_event` and `sse_id`" + `, when present, are sent as the type and ID of the event.`,
 		Config: docs.FieldComponent().WithChildren(
 			docs.FieldString("address", "An optional address to listen from. If left empty the service wide HTTP server is used."),
 			docs.FieldString("path", "The path from which discrete messages can be consumed."),
 			docs.FieldString("stream_path", "The path from which a continuous stream of messages can be consumed."),
 			docs.FieldString("ws_path", "The path from which websocket connections can be established."),
-			docs.FieldString("allowed_verbs", "An array of verbs that are allowed for the `path` and `stream_path` HTTP endpoint.").Array(),
+			docs.FieldString("sse_path", "An optional path from which a stream of server-sent events can be consumed. The endpoint is only registered when a path is specified.", "/get/sse").AtVersion("4.0.0"),
+			docs.FieldString("allowed_verbs", "An array of verbs that are allowed for the `path`, `stream_path` and `sse_path` HTTP endpoints.").Array(),
 			docs.FieldString("timeout", "The maximum time to wait before a blocking, inactive connection is dropped (only applies to the `path` endpoint).").Advanced(),
 			docs.FieldString("cert_file", "An optional certificate file to use for TLS connections. Only applicable when an `address` is specified.").Advanced(),
 			docs.FieldString("key_file", "An optional certificate key file to use for TLS connections. Only applicable when an `address` is specified.").Advanced(),
@@ -66,6 +72,7 @@ type HTTPServerConfig struct {
 	Path         string              `json:"path" yaml:"path"`
 	StreamPath   string              `json:"stream_path" yaml:"stream_path"`
 	WSPath       string              `json:"ws_path" yaml:"ws_path"`
+	SSEPath      string              `json:"sse_path" yaml:"sse_path"`
 	AllowedVerbs []string            `json:"allowed_verbs" yaml:"allowed_verbs"`
 	Timeout      string              `json:"timeout" yaml:"timeout"`
 	CertFile     string              `json:"cert_file" yaml:"cert_file"`
@@ -80,6 +87,7 @@ func NewHTTPServerConfig() HTTPServerConfig {
 		Path:       "/get",
 		StreamPath: "/get/stream",
 		WSPath:     "/get/ws",
+		SSEPath:    "",
 		AllowedVerbs: []string{
 			"GET",
 		},
@@ -118,6 +126,10 @@ type HTTPServer struct {
 	mStreamBatchSent metrics.StatCounter
 	mStreamError     metrics.StatCounter
 
+	mSSESent      metrics.StatCounter
+	mSSEBatchSent metrics.StatCounter
+	mSSEError     metrics.StatCounter
+
 	closeServerOnce sync.Once
 	shutSig         *shutdown.Signaller
 }
@@ -170,6 +182,10 @@ func NewHTTPServer(conf Config, mgr interop.Manager, log log.Modular, stats metr
 		mStreamSent:      mSent.With("stream"),
 		mStreamBatchSent: mBatchSent.With("stream"),
 		mStreamError:     mError.With("stream"),
+
+		mSSESent:      mSent.With("sse"),
+		mSSEBatchSent: mBatchSent.With("sse"),
+		mSSEError:     mError.With("sse"),
 	}
 
 	if tout := conf.HTTPServer.Timeout; len(tout) > 0 {
@@ -188,6 +204,9 @@ func NewHTTPServer(conf Config, mgr interop.Manager, log log.Modular, stats metr
 		if len(h.conf.HTTPServer.WSPath) > 0 {
 			h.mux.HandleFunc(h.conf.HTTPServer.WSPath, h.wsHandler)
 		}
+		if len(h.conf.HTTPServer.SSEPath) > 0 {
+			h.mux.HandleFunc(h.conf.HTTPServer.SSEPath, h.sseHandler)
+		}
 	} else {
 		if len(h.conf.HTTPServer.Path) > 0 {
 			mgr.RegisterEndpoint(
@@ -209,6 +228,13 @@ func NewHTTPServer(conf Config, mgr interop.Manager, log log.Modular, stats metr
 				h.wsHandler,
 			)
 		}
+		if len(h.conf.HTTPServer.SSEPath) > 0 {
+			mgr.RegisterEndpoint(
+				h.conf.HTTPServer.SSEPath,
+				"Read a stream of server-sent events from Benthos.",
+				h.sseHandler,
+			)
+		}
 	}
 
 	return &h, nil
@@ -378,6 +404,81 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeSSEEvent writes a message part as a server-sent event, where each line
+// of the content becomes a data field. Lines may be terminated by any of the
+// line endings recognised by clients, which are CRLF, LF and a lone CR.
+func writeSSEEvent(buf *bytes.Buffer, p *message.Part) {
+	if v := p.MetaGet("sse_event"); v != "" && !strings.ContainsAny(v, "\r\n") {
+		buf.WriteString("event: " + v + "\n")
+	}
+	if v := p.MetaGet("sse_id"); v != "" && !strings.ContainsAny(v, "\r\n") {
+		buf.WriteString("id: " + v + "\n")
+	}
+	data := strings.ReplaceAll(string(p.Get()), "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteByte('\n')
+}
+
+func (h *HTTPServer) sseHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		h.log.Errorln("Failed to cast response writer to flusher")
+		return
+	}
+
+	if _, exists := h.allowedVerbs[r.Method]; !exists {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, done := h.shutSig.CloseAtLeisureCtx(r.Context())
+	defer done()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for !h.shutSig.ShouldCloseAtLeisure() {
+		var ts message.Transaction
+		var open bool
+
+		select {
+		case ts, open = <-h.transactions:
+			if !open {
+				go h.CloseAsync()
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-h.shutSig.CloseAtLeisureChan():
+			return
+		}
+
+		var buf bytes.Buffer
+		_ = ts.Payload.Iter(func(i int, p *message.Part) error {
+			writeSSEEvent(&buf, p)
+			return nil
+		})
+
+		_, err := w.Write(buf.Bytes())
+		_ = ts.Ack(ctx, err)
+		if err != nil {
+			h.mSSEError.Incr(1)
+			return
+		}
+
+		flusher.Flush()
+		h.mSSESent.Incr(int64(batch.MessageCollapsedCount(ts.Payload)))
+		h.mSSEBatchSent.Incr(1)
+	}
+}
+
 //------------------------------------------------------------------------------
 
 // Consume assigns a messages channel for the output to read.
@@ -1,11 +1,17 @@
 package output
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/log"
 	"github.com/benthosdev/benthos/v4/internal/manager/mock"
@@ -145,3 +151,68 @@ func TestHTTPTimeout(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHTTPServerSSE(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTPServer.SSEPath = "/testsse"
+
+	out, err := NewHTTPServer(conf, mock.NewManager(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgChan := make(chan message.Transaction)
+	resChan := make(chan error)
+	require.NoError(t, out.Consume(msgChan))
+
+	ts := httptest.NewServer(http.HandlerFunc(out.(*HTTPServer).sseHandler))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	testMsg := message.QuickBatch([][]byte{[]byte("foo"), []byte("bar\nbaz")})
+	testMsg.Get(1).MetaSet("sse_event", "update")
+	testMsg.Get(1).MetaSet("sse_id", "5")
+
+	select {
+	case msgChan <- message.NewTransaction(testMsg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+	select {
+	case err := <-resChan:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+
+	exp := "data: foo\n\nevent: update\nid: 5\ndata: bar\ndata: baz\n\n"
+	buf := make([]byte, len(exp))
+	_, err = io.ReadFull(res.Body, buf)
+	require.NoError(t, err)
+	assert.Equal(t, exp, string(buf))
+
+	out.CloseAsync()
+	require.NoError(t, out.WaitForClose(time.Second*5))
+}
+
+func TestHTTPServerSSELineEndings(t *testing.T) {
+	tests := []struct {
+		content string
+		exp     string
+	}{
+		{content: "foo", exp: "data: foo\n\n"},
+		{content: "foo\nbar", exp: "data: foo\ndata: bar\n\n"},
+		{content: "foo\r\nbar", exp: "data: foo\ndata: bar\n\n"},
+		{content: "foo\rid: 6", exp: "data: foo\ndata: id: 6\n\n"},
+		{content: "foo\r\rbar\n", exp: "data: foo\ndata: \ndata: bar\ndata: \n\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writeSSEEvent(&buf, message.NewPart([]byte(test.content)))
+		assert.Equal(t, test.exp, buf.String(), test.content)
+	}
+}
@@ -4,13 +4,10 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"net/http"
-	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/olivere/elastic/v7"
-	aws "github.com/olivere/elastic/v7/aws/v4"
 
 	"github.com/benthosdev/benthos/v4/internal/batch/policy"
 	"github.com/benthosdev/benthos/v4/internal/bloblang/field"
@@ -18,6 +15,7 @@ import (
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/http/docs/auth"
 	sess "github.com/benthosdev/benthos/v4/internal/impl/aws/session"
+	esshared "github.com/benthosdev/benthos/v4/internal/impl/elasticsearch/shared"
 	"github.com/benthosdev/benthos/v4/internal/interop"
 	"github.com/benthosdev/benthos/v4/internal/log"
 	"github.com/benthosdev/benthos/v4/internal/message"
@@ -141,13 +139,7 @@ func NewElasticsearchV2(conf ElasticsearchConfig, mgr interop.Manager, log log.M
 		return nil, fmt.Errorf("failed to parse routing key expression: %v", err)
 	}
 
-	for _, u := range conf.URLs {
-		for _, splitURL := range strings.Split(u, ",") {
-			if len(splitURL) > 0 {
-				e.urls = append(e.urls, splitURL)
-			}
-		}
-	}
+	e.urls = esshared.SplitURLs(conf.URLs)
 
 	if tout := conf.Timeout; len(tout) > 0 {
 		var err error
@@ -183,46 +175,21 @@ func (e *Elasticsearch) Connect() error {
 		return nil
 	}
 
-	opts := []elastic.ClientOptionFunc{
-		elastic.SetURL(e.urls...),
-		elastic.SetSniff(e.sniff),
-		elastic.SetHealthcheck(e.healthcheck),
-	}
-
-	if e.conf.Auth.Enabled {
-		opts = append(opts, elastic.SetBasicAuth(
-			e.conf.Auth.Username, e.conf.Auth.Password,
-		))
-	}
-
-	if e.conf.TLS.Enabled {
-		opts = append(opts, elastic.SetHttpClient(&http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: e.tlsConf,
-			},
-			Timeout: e.timeout,
-		}))
-
-	} else {
-		opts = append(opts, elastic.SetHttpClient(&http.Client{
-			Timeout: e.timeout,
-		}))
-	}
-
-	if e.conf.AWS.Enabled {
-		tsess, err := e.conf.AWS.GetSession()
-		if err != nil {
-			return err
-		}
-		signingClient := aws.NewV4SigningClient(tsess.Config.Credentials, e.conf.AWS.Region)
-		opts = append(opts, elastic.SetHttpClient(signingClient))
-	}
-
-	if e.conf.GzipCompression {
-		opts = append(opts, elastic.SetGzip(true))
+	clientConf := esshared.ClientConfig{
+		URLs:        e.urls,
+		Sniff:       e.sniff,
+		Healthcheck: e.healthcheck,
+		Timeout:     e.timeout,
+		TLS:         e.tlsConf,
+		Gzip:        e.conf.GzipCompression,
+		AuthEnabled: e.conf.Auth.Enabled,
+		Username:    e.conf.Auth.Username,
+		Password:    e.conf.Auth.Password,
+		AWSEnabled:  e.conf.AWS.Enabled,
+		AWS:         e.conf.AWS.Config,
 	}
 
-	client, err := elastic.NewClient(opts...)
+	client, err := clientConf.NewClient()
 	if err != nil {
 		return err
 	}
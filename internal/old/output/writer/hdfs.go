@@ -11,6 +11,7 @@ import (
 
 	"github.com/benthosdev/benthos/v4/internal/batch/policy"
 	"github.com/benthosdev/benthos/v4/internal/bloblang/field"
+	"github.com/benthosdev/benthos/v4/internal/codec"
 	"github.com/benthosdev/benthos/v4/internal/component"
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/interop"
@@ -26,6 +27,7 @@ type HDFSConfig struct {
 	User        string        `json:"user" yaml:"user"`
 	Directory   string        `json:"directory" yaml:"directory"`
 	Path        string        `json:"path" yaml:"path"`
+	Codec       string        `json:"codec" yaml:"codec"`
 	MaxInFlight int           `json:"max_in_flight" yaml:"max_in_flight"`
 	Batching    policy.Config `json:"batching" yaml:"batching"`
 }
@@ -37,6 +39,7 @@ func NewHDFSConfig() HDFSConfig {
 		User:        "",
 		Directory:   "",
 		Path:        `${!count("files")}-${!timestamp_unix_nano()}.txt`,
+		Codec:       "all-bytes",
 		MaxInFlight: 64,
 		Batching:    policy.NewConfig(),
 	}
@@ -49,7 +52,9 @@ func NewHDFSConfig() HDFSConfig {
 type HDFS struct {
 	conf HDFSConfig
 
-	path *field.Expression
+	path      *field.Expression
+	codec     codec.WriterConstructor
+	codecConf codec.WriterConfig
 
 	client *hdfs.Client
 
@@ -68,11 +73,17 @@ func NewHDFSV2(
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse path expression: %v", err)
 	}
+	codec, codecConf, err := codec.GetWriter(conf.Codec)
+	if err != nil {
+		return nil, err
+	}
 	return &HDFS{
-		conf:  conf,
-		path:  path,
-		log:   log,
-		stats: stats,
+		conf:      conf,
+		path:      path,
+		codec:     codec,
+		codecConf: codecConf,
+		log:       log,
+		stats:     stats,
 	}, nil
 }
 
@@ -114,26 +125,49 @@ func (h *HDFS) Write(msg *message.Batch) error {
 		return component.ErrNotConnected
 	}
 
-	return IterateBatchedSend(msg, func(i int, p *message.Part) error {
+	ctx := context.Background()
+
+	// Messages of a batch that share a path are written to the same file,
+	// unless the codec writes each message to a file in full (all-bytes), in
+	// which case each message gets its own file.
+	handles := map[string]codec.Writer{}
+	err := IterateBatchedSend(msg, func(i int, p *message.Part) error {
 		path := h.path.String(i, msg)
 		filePath := filepath.Join(h.conf.Directory, path)
 
-		err := h.client.MkdirAll(h.conf.Directory, os.ModeDir|0o644)
-		if err != nil {
-			return err
-		}
-
-		fw, err := h.client.Create(filePath)
-		if err != nil {
-			return err
+		handle, exists := handles[filePath]
+		if !exists {
+			err := h.client.MkdirAll(h.conf.Directory, os.ModeDir|0o644)
+			if err != nil {
+				return err
+			}
+
+			fw, err := h.client.Create(filePath)
+			if err != nil {
+				return err
+			}
+
+			if handle, err = h.codec(fw); err != nil {
+				fw.Close()
+				return err
+			}
+			if h.codecConf.CloseAfter {
+				if err := handle.Write(ctx, p); err != nil {
+					handle.Close(ctx)
+					return err
+				}
+				return handle.Close(ctx)
+			}
+			handles[filePath] = handle
 		}
-
-		if _, err := fw.Write(p.Get()); err != nil {
-			return err
-		}
-		fw.Close()
-		return nil
+		return handle.Write(ctx, p)
 	})
+	for _, handle := range handles {
+		if cerr := handle.Close(ctx); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
 
 // CloseAsync begins cleaning up resources used by this reader asynchronously.
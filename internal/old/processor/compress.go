@@ -8,8 +8,11 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/andybalholm/brotli"
 	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
 
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/component/processor"
@@ -35,11 +38,11 @@ func init() {
 		},
 		Summary: `
 Compresses messages according to the selected algorithm. Supported compression
-algorithms are: gzip, zlib, flate, snappy, lz4.`,
+algorithms are: gzip, zlib, flate, snappy, lz4, zstd, brotli, xz.`,
 		Description: `
 The 'level' field might not apply to all algorithms.`,
 		Config: docs.FieldComponent().WithChildren(
-			docs.FieldString("algorithm", "The compression algorithm to use.").HasOptions("gzip", "zlib", "flate", "snappy", "lz4"),
+			docs.FieldString("algorithm", "The compression algorithm to use.").HasOptions("gzip", "zlib", "flate", "snappy", "lz4", "zstd", "brotli", "xz"),
 			docs.FieldInt("level", "The level of compression to use. May not be applicable to all algorithms."),
 		),
 	}
@@ -137,6 +140,56 @@ func lz4Compress(level int, b []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func zstdCompress(level int, b []byte) ([]byte, error) {
+	opts := []zstd.EOption{}
+	if level > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	w, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	return w.EncodeAll(b, nil), nil
+}
+
+func brotliCompress(level int, b []byte) ([]byte, error) {
+	if level < 0 {
+		level = brotli.DefaultCompression
+	}
+
+	buf := &bytes.Buffer{}
+	w := brotli.NewWriterLevel(buf, level)
+
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return nil, err
+	}
+	// Must flush writer before calling buf.Bytes()
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xzCompress(level int, b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := xz.NewWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(b); err != nil {
+		w.Close()
+		return nil, err
+	}
+	// Must flush writer before calling buf.Bytes()
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 func strToCompressor(str string) (compressFunc, error) {
 	switch str {
 	case "gzip":
@@ -149,6 +202,12 @@ func strToCompressor(str string) (compressFunc, error) {
 		return snappyCompress, nil
 	case "lz4":
 		return lz4Compress, nil
+	case "zstd":
+		return zstdCompress, nil
+	case "brotli":
+		return brotliCompress, nil
+	case "xz":
+		return xzCompress, nil
 	}
 	return nil, fmt.Errorf("compression type not recognised: %v", str)
 }
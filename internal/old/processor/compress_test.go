@@ -265,3 +265,56 @@ func TestCompressLZ4(t *testing.T) {
 		t.Errorf("Unexpected output: %s != %s", act, exp)
 	}
 }
+
+func TestCompressDecompressRoundTrip(t *testing.T) {
+	for _, algo := range []string{"zstd", "brotli", "xz"} {
+		algo := algo
+		t.Run(algo, func(t *testing.T) {
+			input := [][]byte{
+				[]byte("hello world first part"),
+				[]byte("hello world second part"),
+				[]byte("third part"),
+				[]byte("fourth"),
+				[]byte("5"),
+			}
+
+			compConf := NewConfig()
+			compConf.Type = "compress"
+			compConf.Compress.Algorithm = algo
+
+			comp, err := New(compConf, mock.NewManager(), log.Noop(), metrics.Noop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decompConf := NewConfig()
+			decompConf.Type = "decompress"
+			decompConf.Decompress.Algorithm = algo
+
+			decomp, err := New(decompConf, mock.NewManager(), log.Noop(), metrics.Noop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msgs, res := comp.ProcessMessage(message.QuickBatch(input))
+			if len(msgs) != 1 {
+				t.Fatal("Compress failed")
+			} else if res != nil {
+				t.Fatalf("Expected nil response: %v", res)
+			}
+			if act := message.GetAllBytes(msgs[0]); reflect.DeepEqual(input, act) {
+				t.Fatal("Input and compressed output are the same")
+			}
+
+			msgs, res = decomp.ProcessMessage(msgs[0])
+			if len(msgs) != 1 {
+				t.Fatal("Decompress failed")
+			} else if res != nil {
+				t.Fatalf("Expected nil response: %v", res)
+			}
+			if act := message.GetAllBytes(msgs[0]); !reflect.DeepEqual(input, act) {
+				t.Errorf("Unexpected output: %s != %s", act, input)
+			}
+		})
+	}
+}
@@ -10,8 +10,11 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/andybalholm/brotli"
 	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
 
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/component/processor"
@@ -37,9 +40,9 @@ func init() {
 		},
 		Summary: `
 Decompresses messages according to the selected algorithm. Supported
-decompression types are: gzip, zlib, bzip2, flate, snappy, lz4.`,
+decompression types are: gzip, zlib, bzip2, flate, snappy, lz4, zstd, brotli, xz.`,
 		Config: docs.FieldComponent().WithChildren(
-			docs.FieldString("algorithm", "The decompression algorithm to use.").HasOptions("gzip", "zlib", "bzip2", "flate", "snappy", "lz4"),
+			docs.FieldString("algorithm", "The decompression algorithm to use.").HasOptions("gzip", "zlib", "bzip2", "flate", "snappy", "lz4", "zstd", "brotli", "xz"),
 		),
 	}
 }
@@ -130,6 +133,38 @@ func lz4Decompress(b []byte) ([]byte, error) {
 	return outBuf.Bytes(), nil
 }
 
+func zstdDecompress(b []byte) ([]byte, error) {
+	r, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return r.DecodeAll(b, nil)
+}
+
+func brotliDecompress(b []byte) ([]byte, error) {
+	r := brotli.NewReader(bytes.NewBuffer(b))
+
+	outBuf := bytes.Buffer{}
+	if _, err := io.Copy(&outBuf, r); err != nil {
+		return nil, err
+	}
+	return outBuf.Bytes(), nil
+}
+
+func xzDecompress(b []byte) ([]byte, error) {
+	r, err := xz.NewReader(bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+
+	outBuf := bytes.Buffer{}
+	if _, err := io.Copy(&outBuf, r); err != nil {
+		return nil, err
+	}
+	return outBuf.Bytes(), nil
+}
+
 func strToDecompressor(str string) (decompressFunc, error) {
 	switch str {
 	case "gzip":
@@ -144,6 +179,12 @@ func strToDecompressor(str string) (decompressFunc, error) {
 		return snappyDecompress, nil
 	case "lz4":
 		return lz4Decompress, nil
+	case "zstd":
+		return zstdDecompress, nil
+	case "brotli":
+		return brotliDecompress, nil
+	case "xz":
+		return xzDecompress, nil
 	}
 	return nil, fmt.Errorf("decompression type not recognised: %v", str)
 }
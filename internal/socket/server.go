@@ -0,0 +1,135 @@
+// Package socket contains listener logic shared by components that act as a
+// server for streams of data over tcp, udp or unix sockets.
+package socket
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Logger is the subset of a logger required by a server in order to report
+// connection errors.
+type Logger interface {
+	Errorf(format string, v ...interface{})
+}
+
+// Handler consumes the data of a connection until either the connection is
+// exhausted or the context is cancelled, at which point the connection is
+// closed. When the network of a server is udp the handler is called once, and
+// each read from the connection returns a single packet.
+type Handler func(ctx context.Context, conn io.ReadCloser)
+
+type wrapPacketConn struct {
+	net.PacketConn
+}
+
+func (w *wrapPacketConn) Read(p []byte) (n int, err error) {
+	n, _, err = w.ReadFrom(p)
+	return
+}
+
+// Server listens on an address of a unix, tcp or udp network.
+type Server struct {
+	network  string
+	listener net.Listener
+	conn     net.PacketConn
+}
+
+// Listen creates a server that listens on an address of a unix, tcp or udp
+// network. When a TLS config is provided connections of unix and tcp networks
+// are served over TLS.
+func Listen(network, address string, tlsConf *tls.Config) (*Server, error) {
+	s := &Server{network: network}
+
+	var err error
+	switch network {
+	case "tcp", "unix":
+		if s.listener, err = net.Listen(network, address); err == nil && tlsConf != nil {
+			s.listener = tls.NewListener(s.listener, tlsConf)
+		}
+	case "udp":
+		if tlsConf != nil {
+			return nil, errors.New("tls is not supported with the udp network")
+		}
+		s.conn, err = net.ListenPacket(network, address)
+	default:
+		return nil, fmt.Errorf("socket network '%v' is not supported", network)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Network returns the network type of the server.
+func (s *Server) Network() string {
+	return s.network
+}
+
+// Addr returns the address of the underlying listener.
+func (s *Server) Addr() net.Addr {
+	if s.listener != nil {
+		return s.listener.Addr()
+	}
+	return s.conn.LocalAddr()
+}
+
+// Serve calls a handler for each connection accepted by the server, each within
+// its own goroutine, and blocks until the context is cancelled and all handlers
+// have returned. The server is closed once Serve returns.
+func (s *Server) Serve(ctx context.Context, log Logger, handler Handler) {
+	if s.conn != nil {
+		defer s.conn.Close()
+		go func() {
+			<-ctx.Done()
+			s.conn.Close()
+		}()
+		handler(ctx, &wrapPacketConn{PacketConn: s.conn})
+		return
+	}
+
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		s.listener.Close()
+	}()
+
+	go func() {
+		<-ctx.Done()
+		s.listener.Close()
+	}()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("Failed to accept Socket connection: %v\n", err)
+			}
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		connCtx, connDone := context.WithCancel(ctx)
+		go func() {
+			<-connCtx.Done()
+			conn.Close()
+		}()
+		wg.Add(1)
+		go func(c net.Conn) {
+			defer func() {
+				connDone()
+				wg.Done()
+			}()
+			handler(connCtx, c)
+		}(conn)
+	}
+}
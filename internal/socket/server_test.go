@@ -0,0 +1,92 @@
+package socket
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Errorf(format string, v ...interface{}) {
+	l.t.Logf(format, v...)
+}
+
+// testServe serves a server until the test completes, sending the data read
+// from each connection to the returned channel.
+func testServe(t *testing.T, s *Server) <-chan string {
+	t.Helper()
+
+	ctx, done := context.WithCancel(context.Background())
+	reads := make(chan string)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Serve(ctx, testLogger{t: t}, func(ctx context.Context, conn io.ReadCloser) {
+			buf := make([]byte, 1024)
+			for {
+				n, err := conn.Read(buf)
+				if err != nil {
+					return
+				}
+				select {
+				case reads <- string(buf[:n]):
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}()
+	t.Cleanup(func() {
+		done()
+		wg.Wait()
+	})
+	return reads
+}
+
+func TestServerNetworks(t *testing.T) {
+	for _, test := range []struct {
+		network string
+		address string
+	}{
+		{network: "tcp", address: "127.0.0.1:0"},
+		{network: "udp", address: "127.0.0.1:0"},
+		{network: "unix", address: filepath.Join(t.TempDir(), "test.sock")},
+	} {
+		test := test
+		t.Run(test.network, func(t *testing.T) {
+			s, err := Listen(test.network, test.address, nil)
+			require.NoError(t, err)
+			assert.Equal(t, test.network, s.Network())
+
+			reads := testServe(t, s)
+
+			conn, err := net.Dial(test.network, s.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("hello world"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", <-reads)
+		})
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	_, err := Listen("nope", "127.0.0.1:0", nil)
+	require.Error(t, err)
+
+	_, err = Listen("udp", "127.0.0.1:0", &tls.Config{})
+	require.Error(t, err)
+}
@@ -11,22 +11,28 @@ import (
 	_ "github.com/benthosdev/benthos/v4/internal/impl/amqp09"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/amqp1"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/aws"
+	_ "github.com/benthosdev/benthos/v4/internal/impl/cassandra"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/confluent"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/dgraph"
+	_ "github.com/benthosdev/benthos/v4/internal/impl/elasticsearch"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/gcp"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/generic"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/influxdb"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/jaeger"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/kafka"
+	_ "github.com/benthosdev/benthos/v4/internal/impl/loki"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/maxmind"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/memcached"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/mongodb"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/msgpack"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/nats"
+	_ "github.com/benthosdev/benthos/v4/internal/impl/otlp"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/parquet"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/prometheus"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/redis"
+	_ "github.com/benthosdev/benthos/v4/internal/impl/smtp"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/snowflake"
+	_ "github.com/benthosdev/benthos/v4/internal/impl/splunk"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/sql"
 	_ "github.com/benthosdev/benthos/v4/internal/impl/statsd"
 	"github.com/benthosdev/benthos/v4/internal/template"
@@ -36,6 +42,7 @@ import (
 	_ "github.com/denisenkom/go-mssqldb"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 func init() {
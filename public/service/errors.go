@@ -0,0 +1,85 @@
+package service
+
+import (
+	"github.com/benthosdev/benthos/v4/internal/batch"
+	"github.com/benthosdev/benthos/v4/internal/message"
+)
+
+// BatchError is an error type that can be returned by batched outputs in order
+// to indicate which messages of a batch failed, allowing only those messages
+// to be nacked and retried rather than the whole batch.
+type BatchError struct {
+	b           MessageBatch
+	err         error
+	indexErrors map[int]error
+}
+
+// NewBatchError creates a BatchError for a batch, where a headline error must
+// be supplied which is exposed when upstream components do not support
+// granular batch errors.
+//
+// If Failed is never called then all messages of the batch are considered
+// failed.
+func NewBatchError(b MessageBatch, headline error) *BatchError {
+	return &BatchError{b: b, err: headline}
+}
+
+// Failed stores an error state for a particular message of a batch. Returns a
+// pointer to the underlying error, allowing the method to be chained.
+//
+// If Failed is called at least once then all message indexes that aren't
+// explicitly failed are assumed to have been processed successfully.
+func (e *BatchError) Failed(i int, err error) *BatchError {
+	if e.indexErrors == nil {
+		e.indexErrors = map[int]error{}
+	}
+	e.indexErrors[i] = err
+	return e
+}
+
+// IndexedErrors returns the number of indexed errors that have been registered
+// for the batch.
+func (e *BatchError) IndexedErrors() int {
+	return len(e.indexErrors)
+}
+
+// WalkMessages applies a closure to each message of the batch that the error
+// was created for. The closure is provided the message index, the message, and
+// its individual error, which may be nil if the message itself was processed
+// successfully. The closure returns a bool which indicates whether the
+// iteration should be continued.
+func (e *BatchError) WalkMessages(fn func(int, *Message, error) bool) {
+	for i, m := range e.b {
+		err := e.err
+		if e.indexErrors != nil {
+			err = e.indexErrors[i]
+		}
+		if !fn(i, m, err) {
+			return
+		}
+	}
+}
+
+// Error returns the underlying headline error.
+func (e *BatchError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying headline error.
+func (e *BatchError) Unwrap() error {
+	return e.err
+}
+
+// toInternal converts the error into a batch error of the internal batch from
+// which the messages were created. When the error was created for a different
+// batch the indexes cannot be trusted and so all messages are failed.
+func (e *BatchError) toInternal(msg *message.Batch) error {
+	if len(e.b) != msg.Len() {
+		return e.err
+	}
+	bErr := batch.NewError(msg, e.err)
+	for i, err := range e.indexErrors {
+		bErr.Failed(i, err)
+	}
+	return bErr
+}
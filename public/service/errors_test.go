@@ -0,0 +1,48 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchErrorWalkMessages(t *testing.T) {
+	batch := MessageBatch{
+		NewMessage([]byte("foo")),
+		NewMessage([]byte("bar")),
+		NewMessage([]byte("baz")),
+	}
+
+	walk := func(bErr *BatchError) (res []string) {
+		bErr.WalkMessages(func(i int, m *Message, err error) bool {
+			b, _ := m.AsBytes()
+			res = append(res, fmt.Sprintf("%v: %s: %v", i, b, err))
+			return true
+		})
+		return
+	}
+
+	bErr := NewBatchError(batch, errors.New("headline"))
+	assert.Equal(t, []string{
+		"0: foo: headline",
+		"1: bar: headline",
+		"2: baz: headline",
+	}, walk(bErr))
+
+	bErr.Failed(1, errors.New("bad message"))
+	assert.Equal(t, 1, bErr.IndexedErrors())
+	assert.Equal(t, []string{
+		"0: foo: <nil>",
+		"1: bar: bad message",
+		"2: baz: <nil>",
+	}, walk(bErr))
+
+	var visited int
+	bErr.WalkMessages(func(int, *Message, error) bool {
+		visited++
+		return false
+	})
+	assert.Equal(t, 1, visited)
+}
@@ -55,6 +55,19 @@ func newMessageFromPart(part *message.Part) *Message {
 	return &Message{part, false}
 }
 
+type messageUnwrapper struct {
+	child *message.Part
+}
+
+func (m messageUnwrapper) Unwrap() *message.Part {
+	return m.child
+}
+
+// XUnwrapper is for internal use only, do not use this.
+func (m *Message) XUnwrapper() interface{} {
+	return messageUnwrapper{child: m.part}
+}
+
 // Copy creates a shallow copy of a message that is safe to mutate with Set
 // methods without mutating the original. Both messages will share a context,
 // and therefore a tracing ID, if one has been associated with them.
@@ -66,7 +66,9 @@ type BatchOutput interface {
 	// not possible.
 	//
 	// If this method returns ErrNotConnected then write will not be called
-	// again until Connect has returned a nil error.
+	// again until Connect has returned a nil error. If only a subset of the
+	// messages failed then a *BatchError can be returned in order to indicate
+	// which.
 	WriteBatch(context.Context, MessageBatch) error
 
 	Closer
@@ -141,6 +143,10 @@ func (a *airGapBatchWriter) WriteWithContext(ctx context.Context, msg *message.B
 	if err != nil && errors.Is(err, ErrNotConnected) {
 		err = component.ErrNotConnected
 	}
+	var bErr *BatchError
+	if err != nil && errors.As(err, &bErr) {
+		return bErr.toInternal(msg)
+	}
 	return err
 }
 
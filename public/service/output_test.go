@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/benthosdev/benthos/v4/internal/batch"
 	"github.com/benthosdev/benthos/v4/internal/component"
 	"github.com/benthosdev/benthos/v4/internal/message"
 )
@@ -177,3 +180,55 @@ func TestBatchOutputAirGapHappy(t *testing.T) {
 
 	assert.Equal(t, "hello world", wroteMsg)
 }
+
+func TestBatchOutputAirGapBatchError(t *testing.T) {
+	o := &fnBatchOutput{
+		connect: func() error {
+			return nil
+		},
+		writeBatch: func(m MessageBatch) error {
+			return NewBatchError(m, errors.New("headline")).
+				Failed(1, errors.New("bad message"))
+		},
+	}
+	agi := newAirGapBatchWriter(o)
+
+	inMsg := message.QuickBatch([][]byte{[]byte("foo"), []byte("bar"), []byte("baz")})
+
+	err := agi.WriteWithContext(context.Background(), inMsg)
+	assert.EqualError(t, err, "headline")
+
+	bErr, ok := err.(*batch.Error)
+	require.True(t, ok, "%T", err)
+	assert.Equal(t, 1, bErr.IndexedErrors())
+
+	var errs []string
+	bErr.WalkParts(func(i int, p *message.Part, err error) bool {
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %s: %v", i, p.Get(), err))
+		}
+		return true
+	})
+	assert.Equal(t, []string{"1: bar: bad message"}, errs)
+}
+
+func TestBatchOutputAirGapBatchErrorMismatchedBatch(t *testing.T) {
+	o := &fnBatchOutput{
+		connect: func() error {
+			return nil
+		},
+		writeBatch: func(m MessageBatch) error {
+			return NewBatchError(m[:1], errors.New("headline")).
+				Failed(0, errors.New("bad message"))
+		},
+	}
+	agi := newAirGapBatchWriter(o)
+
+	inMsg := message.QuickBatch([][]byte{[]byte("foo"), []byte("bar")})
+
+	err := agi.WriteWithContext(context.Background(), inMsg)
+	assert.EqualError(t, err, "headline")
+
+	_, ok := err.(*batch.Error)
+	assert.False(t, ok, "indexes of a different batch should not be trusted")
+}
@@ -76,3 +76,16 @@ func (r *Resources) AccessRateLimit(ctx context.Context, name string, fn func(r
 func (r *Resources) HasRateLimit(name string) bool {
 	return r.mgr.ProbeRateLimit(name)
 }
+
+type resourcesUnwrapper struct {
+	child bundle.NewManagement
+}
+
+func (r resourcesUnwrapper) Unwrap() bundle.NewManagement {
+	return r.child
+}
+
+// XUnwrapper is for internal use only, do not use this.
+func (r *Resources) XUnwrapper() interface{} {
+	return resourcesUnwrapper{child: r.mgr}
+}